@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/es"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/migrate"
+	"go_web_scaffolding/pkg/seed"
+	"go_web_scaffolding/settings"
+	"os"
+)
+
+// 子命令，执行完直接退出，不启动服务：
+//
+//	app migrate up      执行全部未执行的迁移
+//	app migrate down    回滚最近的一个迁移
+//	app migrate status  查看迁移状态
+//	app seed [env]      导入 seeds/<env> 下的初始数据，env 默认为当前的 mode
+//	app reindex users   用数据库里的数据重建搜索索引
+
+const usage = `usage:
+  app                  启动服务
+  app migrate up       执行全部未执行的迁移
+  app migrate down     回滚最近的一个迁移
+  app migrate status   查看迁移状态
+  app seed [env]       导入 seeds/<env> 下的初始数据，env 默认为当前的 mode，dev/test 以外的模式需要加 -force
+  app reindex users    用数据库里的用户重建搜索索引，可以重复执行`
+
+// runCommand 执行子命令，没有子命令时 handled 为 false，继续启动服务
+func runCommand(args []string) (handled bool, err error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch args[0] {
+	case "migrate":
+		return true, runMigrate(args[1:])
+	case "seed":
+		return true, runSeed(args[1:])
+	case "reindex":
+		return true, runReindex(args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return true, nil
+	default:
+		return true, fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+func runMigrate(args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New(usage)
+	}
+	if err = mysql.Init(settings.Conf.MySQLConfig); err != nil {
+		return
+	}
+	defer mysql.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		return migrate.Up(ctx)
+	case "down":
+		return migrate.Down(ctx)
+	case "status":
+		return migrate.PrintStatus(ctx, os.Stdout)
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], usage)
+	}
+}
+
+func runSeed(args []string) (err error) {
+	env, force := settings.Conf.Mode, false
+	if env == "local" {
+		// local 模式没有单独的种子数据，用 dev 的
+		env = "dev"
+	}
+	for _, arg := range args {
+		if arg == "-force" || arg == "--force" {
+			force = true
+		} else {
+			env = arg
+		}
+	}
+	// 种子数据会覆盖同主键的行，生产环境误执行的后果很严重
+	if mode := settings.Conf.Mode; mode != "dev" && mode != "test" && mode != "local" && !force {
+		return fmt.Errorf("refuse to seed in %s mode, add -force if you really mean it", mode)
+	}
+	if err = mysql.Init(settings.Conf.MySQLConfig); err != nil {
+		return
+	}
+	defer mysql.Close()
+	if err = redis.Init(settings.Conf.RedisConfig); err != nil {
+		return
+	}
+	defer redis.Close()
+	return seed.Run(context.Background(), env)
+}
+
+func runReindex(args []string) (err error) {
+	if len(args) != 1 || args[0] != "users" {
+		return errors.New(usage)
+	}
+	if err = mysql.Init(settings.Conf.MySQLConfig); err != nil {
+		return
+	}
+	defer mysql.Close()
+	if err = es.Init(settings.Conf.ESConfig); err != nil {
+		return
+	}
+	ctx := context.Background()
+	defer es.Close(ctx)
+	total, failed, err := logic.ReindexUsers(ctx)
+	fmt.Printf("reindexed %d users, %d failed\n", total, failed)
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d documents were rejected by es", failed)
+	}
+	return
+}
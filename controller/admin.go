@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/dao/profile"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/version"
+	"go_web_scaffolding/settings"
+	"go_web_scaffolding/web"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ListProfilesHandler 列出所有 profile 以及当前生效的 profile
+func ListProfilesHandler(c *gin.Context) {
+	response.ResponseSuccess(c, gin.H{
+		"active":   profile.Active(),
+		"profiles": profile.Names(),
+	})
+}
+
+// SwitchProfileHandler 切换外部依赖到指定 profile
+func SwitchProfileHandler(c *gin.Context) {
+	name := c.Param("name")
+	// 建连 + 校验给足时间，但不能无限等待
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := profile.Switch(ctx, name); err != nil {
+		logger.Module(c.Request.Context(), "controller").Error("profile.Switch failed", zap.String("profile", name), zap.Error(err))
+		if errors.Is(err, profile.ErrProfileNotFound) {
+			response.ResponseErrorWithMsg(c, response.CodeNotFound, err.Error())
+			return
+		}
+		response.ResponseErrorWithMsg(c, response.CodeServiceUnavailable, err.Error())
+		return
+	}
+	response.ResponseSuccess(c, gin.H{"active": profile.Active()})
+}
+
+// ParamLogLevel 修改日志级别，Duration 秒后恢复成配置的级别，0 表示一直生效
+type ParamLogLevel struct {
+	Level    string `json:"level" binding:"required,oneof=debug info warn error"`
+	Duration int    `json:"duration" binding:"gte=0,lte=86400"`
+}
+
+// GetLogLevelHandler 当前日志级别
+func GetLogLevelHandler(c *gin.Context) {
+	response.ResponseSuccess(c, logger.Level())
+}
+
+// SetLogLevelHandler 运行时修改日志级别，只对当前实例生效
+func SetLogLevelHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamLogLevel](c)
+	if !ok {
+		return
+	}
+	if err := logger.SetLevel(p.Level, time.Duration(p.Duration)*time.Second); err != nil {
+		response.ResponseErrorWithMsg(c, response.CodeInvalidParam, err.Error())
+		return
+	}
+	response.ResponseSuccess(c, logger.Level())
+}
+
+// PanicStatsHandler 按指纹聚合的 panic 统计
+func PanicStatsHandler(c *gin.Context) {
+	response.ResponseSuccess(c, gin.H{"panics": logger.PanicStats()})
+}
+
+// DashboardHandler 运行状态总览：配置快照、依赖健康、连接池、缓存命中率、最近错误以及各子系统注册的状态
+func DashboardHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	data := dashboard.Snapshot(ctx)
+	data["app"] = gin.H{
+		"name":    settings.Conf.Name,
+		"mode":    settings.Conf.Mode,
+		"build":   version.Get(),
+		"profile": profile.Active(),
+	}
+	data["config"] = settings.Snapshot()
+	data["panics"] = logger.PanicStats()
+	response.ResponseSuccess(c, data)
+}
+
+// AdminUIHandler 管理页面，页面本身不含数据，不需要鉴权
+func AdminUIHandler(c *gin.Context) {
+	if cfg := settings.Conf.AdminConfig; cfg == nil || !cfg.UI {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", web.AdminUI())
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/apperr"
+	"go_web_scaffolding/pkg/audit"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/pagination"
+	"go_web_scaffolding/pkg/query"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParamCreateAPIKey 创建 api key 的请求参数，ttl_days 为 0 表示永不过期，不能为负数
+type ParamCreateAPIKey struct {
+	Name    string   `json:"name" binding:"required"`
+	Scopes  []string `json:"scopes" binding:"required"`
+	TTLDays int      `json:"ttl_days" binding:"min=0"`
+}
+
+// ParamListAPIKeys 分页查询 api key 的请求参数
+type ParamListAPIKeys struct {
+	pagination.Params
+}
+
+// apiKeyID 解析路径里的 api key ID
+func apiKeyID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ResponseErrorWithMsg(c, response.CodeInvalidParam, "invalid id")
+		return 0, false
+	}
+	return id, true
+}
+
+// apiKeyError 统一处理 api key 管理函数的错误
+// logic 返回的 apperr（如 api key 不存在）由 response.Error 直接转换，这里只处理分页和筛选参数的错误
+func apiKeyError(c *gin.Context, err error) {
+	if errors.Is(err, pagination.ErrInvalidCursor) || errors.Is(err, query.ErrInvalidSort) || errors.Is(err, query.ErrInvalidFilter) {
+		err = apperr.BadRequest(err.Error())
+	}
+	response.Error(c, err)
+}
+
+// ListAPIKeysHandler 分页列出 api key，支持 ?sort=-create_time&name[like]=xx&revoked=0
+func ListAPIKeysHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamListAPIKeys](c)
+	if !ok {
+		return
+	}
+	res, err := logic.ListAPIKeys(c.Request.Context(), p.Params, c.Request.URL.Query())
+	if err != nil {
+		apiKeyError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, res)
+}
+
+// CreateAPIKeyHandler 创建 api key，明文密钥只在响应里出现这一次
+func CreateAPIKeyHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamCreateAPIKey](c)
+	if !ok {
+		return
+	}
+	key, k, err := logic.CreateAPIKey(c.Request.Context(), p.Name, p.Scopes, time.Duration(p.TTLDays)*24*time.Hour)
+	if err != nil {
+		apiKeyError(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "apikey.create", strconv.FormatInt(k.ID, 10), k.Name)
+	response.ResponseSuccess(c, gin.H{"key": key, "api_key": k})
+}
+
+// RotateAPIKeyHandler 轮换密钥，旧密钥立即失效
+func RotateAPIKeyHandler(c *gin.Context) {
+	id, ok := apiKeyID(c)
+	if !ok {
+		return
+	}
+	key, err := logic.RotateAPIKey(c.Request.Context(), id)
+	if err != nil {
+		apiKeyError(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "apikey.rotate", c.Param("id"), "")
+	response.ResponseSuccess(c, gin.H{"key": key})
+}
+
+// RevokeAPIKeyHandler 吊销 api key
+func RevokeAPIKeyHandler(c *gin.Context) {
+	id, ok := apiKeyID(c)
+	if !ok {
+		return
+	}
+	if err := logic.RevokeAPIKey(c.Request.Context(), id); err != nil {
+		apiKeyError(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "apikey.revoke", c.Param("id"), "")
+	response.ResponseSuccess(c, nil)
+}
+
+// OpenPingHandler 需要 api key 才能访问的测试接口
+func OpenPingHandler(c *gin.Context) {
+	client, _ := ctxutil.CurrentAPIClient(c.Request.Context())
+	response.ResponseSuccess(c, gin.H{"client": client.Name, "scopes": client.Scopes})
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/ctxutil"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ParamRefreshToken 刷新 token 的请求参数
+type ParamRefreshToken struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// ParamLogout 注销的请求参数，refresh_token 可选
+type ParamLogout struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshTokenHandler 用 refresh token 换取新的 access token 和 refresh token
+func RefreshTokenHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamRefreshToken](c)
+	if !ok {
+		return
+	}
+	aToken, rToken, err := logic.RefreshToken(c.Request.Context(), p.RefreshToken)
+	if err != nil {
+		logger.Module(c.Request.Context(), "controller").Debug("logic.RefreshToken failed", zap.Error(err))
+		if errors.Is(err, logic.ErrSessionReplaced) {
+			response.ResponseError(c, response.CodeSessionReplaced)
+			return
+		}
+		response.ResponseError(c, response.CodeInvalidToken)
+		return
+	}
+	response.ResponseSuccess(c, gin.H{
+		"access_token":  aToken,
+		"refresh_token": rToken,
+	})
+}
+
+// LogoutHandler 注销，当前 token 立即失效
+func LogoutHandler(c *gin.Context) {
+	p := new(ParamLogout)
+	// 请求体可以为空
+	_ = c.ShouldBindJSON(p)
+	if err := logic.Logout(c.Request.Context(), p.RefreshToken); err != nil {
+		logger.Module(c.Request.Context(), "controller").Error("logic.Logout failed", zap.Error(err))
+		_ = c.Error(err)
+		response.ResponseError(c, response.CodeServerBusy)
+		return
+	}
+	response.ResponseSuccess(c, nil)
+}
+
+// PingHandler 需要登录才能访问的测试接口
+func PingHandler(c *gin.Context) {
+	u, _ := ctxutil.CurrentUser(c.Request.Context())
+	response.ResponseSuccess(c, gin.H{"user_id": strconv.FormatInt(u.ID, 10), "username": u.Username})
+}
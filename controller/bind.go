@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/pkg/i18n"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError 单个字段的错误，返回在 data.errors 里，前端可以直接标到对应的输入框上
+type FieldError struct {
+	Field string `json:"field"`
+	Tag   string `json:"tag,omitempty"`
+	Msg   string `json:"msg"`
+}
+
+// BindAndValidate 绑定并校验请求参数，失败时已经写好了响应，调用方直接 return
+// GET 请求从 query 绑定，其它请求按 json 绑定
+//
+//	p, ok := BindAndValidate[ParamSignUp](c)
+//	if !ok {
+//		return
+//	}
+func BindAndValidate[T any](c *gin.Context) (p *T, ok bool) {
+	p = new(T)
+	var err error
+	if c.Request.Method == http.MethodGet {
+		err = c.ShouldBindQuery(p)
+	} else {
+		err = c.ShouldBindJSON(p)
+	}
+	if err != nil {
+		bindError(c, err)
+		return nil, false
+	}
+	return p, true
+}
+
+// bindError 把绑定错误分成三类返回：
+//
+//	请求体为空或 json 语法错误：只有 msg，没有字段信息
+//	字段类型不对（比如字符串传给了数字）：data.errors 里给出字段和期望的类型
+//	校验不通过：data.errors 里按结构体字段顺序给出每个字段翻译后的提示
+func bindError(c *gin.Context, err error) {
+	ctx := c.Request.Context()
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var errs validator.ValidationErrors
+	switch {
+	case errors.Is(err, io.EOF):
+		response.ResponseErrorWithMsg(c, response.CodeInvalidParam, i18n.T(ctx, "bind.empty_body"))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		response.ResponseErrorWithMsg(c, response.CodeInvalidParam, i18n.T(ctx, "bind.invalid_json"))
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		response.ResponseErrorWithData(c, response.CodeInvalidParam, gin.H{"errors": []FieldError{{
+			Field: field,
+			Msg:   i18n.T(ctx, "bind.type_mismatch", field, typeErr.Type.String()),
+		}}})
+	case errors.As(err, &errs) && uni != nil:
+		trans := translator(c)
+		list := make([]FieldError, 0, len(errs))
+		for _, fe := range errs {
+			list = append(list, FieldError{
+				Field: trimTopStruct(fe.Namespace()),
+				Tag:   fe.Tag(),
+				Msg:   fe.Translate(trans),
+			})
+		}
+		response.ResponseErrorWithData(c, response.CodeInvalidParam, gin.H{"errors": list})
+	default:
+		response.ResponseErrorWithMsg(c, response.CodeInvalidParam, err.Error())
+	}
+}
+
+// trimTopStruct ParamSignUp.email 去掉结构体名称变成 email
+func trimTopStruct(namespace string) string {
+	_, field, found := strings.Cut(namespace, ".")
+	if !found {
+		return namespace
+	}
+	return field
+}
@@ -0,0 +1,22 @@
+package controller
+
+import (
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/captcha"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CaptchaHandler 获取图形验证码
+func CaptchaHandler(c *gin.Context) {
+	id, image, err := captcha.Generate(c.Request.Context())
+	if err != nil {
+		logger.Module(c.Request.Context(), "controller").Error("captcha.Generate failed", zap.Error(err))
+		_ = c.Error(err)
+		response.ResponseError(c, response.CodeServerBusy)
+		return
+	}
+	response.ResponseSuccess(c, gin.H{"captcha_id": id, "image": image})
+}
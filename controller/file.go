@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/ctxutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead multipart 的边界和表单头占用的字节，限制请求体时在文件大小上加上它
+const multipartOverhead = 1 << 20
+
+// fileID 解析路径里的文件 ID
+func fileID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ResponseErrorWithMsg(c, response.CodeInvalidParam, "invalid id")
+		return 0, false
+	}
+	return id, true
+}
+
+// UploadFileHandler 上传文件，multipart 表单的 file 字段
+func UploadFileHandler(c *gin.Context) {
+	// 超过上限的请求体读到一半就断开，不用等整个文件传完
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, logic.MaxUploadSize()+multipartOverhead)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.Error(c, logic.ErrFileTooLarge)
+			return
+		}
+		response.ResponseErrorWithMsg(c, response.CodeInvalidParam, "file is required")
+		return
+	}
+	ctx := c.Request.Context()
+	f, err := logic.UploadFile(ctx, ctxutil.UserID(ctx), fh)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ResponseSuccess(c, f)
+}
+
+// GetFileHandler 查询自己上传的文件
+func GetFileHandler(c *gin.Context) {
+	id, ok := fileID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	f, err := logic.GetFile(ctx, ctxutil.UserID(ctx), id)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ResponseSuccess(c, f)
+}
+
+// DeleteFileHandler 删除自己上传的文件
+func DeleteFileHandler(c *gin.Context) {
+	id, ok := fileID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if err := logic.DeleteFile(ctx, ctxutil.UserID(ctx), id); err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ResponseSuccess(c, nil)
+}
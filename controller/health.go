@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/pkg/health"
+	"go_web_scaffolding/pkg/k8s"
+	"go_web_scaffolding/pkg/lifecycle"
+	"go_web_scaffolding/pkg/version"
+	"go_web_scaffolding/settings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 三个探针的分工：
+//
+//	/livez   存活检查，只要进程能处理请求就返回成功，不检查外部依赖，
+//	         否则数据库一抖动 k8s 就会把所有 Pod 都重启掉
+//	/readyz  就绪检查，检查 MySQL、Redis 等依赖，失败时 k8s 停止向本 Pod 转发流量，恢复后自动加回来
+//	/healthz 和 /readyz 一样检查依赖，但不受跛脚鸭状态影响，给监控和人工排查用
+
+// LivezHandler 存活检查
+func LivezHandler(c *gin.Context) {
+	response.ResponseSuccess(c, gin.H{"status": "ok", "pod": k8s.Pod()})
+}
+
+// HealthzHandler 依赖检查，返回每个依赖的状态和耗时
+func HealthzHandler(c *gin.Context) {
+	ok, checks := health.Check(c.Request.Context())
+	if !ok {
+		response.ResponseErrorWithData(c, response.CodeServiceUnavailable, gin.H{"status": "unavailable", "pod": k8s.Pod(), "checks": checks})
+		return
+	}
+	response.ResponseSuccess(c, gin.H{"status": "ok", "pod": k8s.Pod(), "checks": checks})
+}
+
+// ReadyzHandler 就绪检查，进入跛脚鸭状态或者依赖不可用时返回 503
+func ReadyzHandler(c *gin.Context) {
+	if lifecycle.IsLameDuck() {
+		response.ResponseErrorWithData(c, response.CodeServiceUnavailable, gin.H{"status": "draining", "pod": k8s.Pod()})
+		return
+	}
+	HealthzHandler(c)
+}
+
+// VersionHandler 当前运行的版本、commit 和构建时间，确认发布的是不是预期的版本
+func VersionHandler(c *gin.Context) {
+	response.ResponseSuccess(c, version.Get())
+}
+
+// PreStopHandler 给 k8s preStop 钩子调用，进入跛脚鸭状态并阻塞到排空结束，
+// kubelet 等钩子返回后才发送 SIGTERM，此时 main 里的 Drain 会立即返回
+//
+//	lifecycle:
+//	  preStop:
+//	    httpGet:
+//	      path: /admin/prestop
+//	      port: 8081
+//	      httpHeaders: [{name: X-Admin-Token, value: "..."}]
+func PreStopHandler(c *gin.Context) {
+	lifecycle.Drain(time.Duration(settings.Conf.ShutdownConfig.LameDuck) * time.Second)
+	response.ResponseSuccess(c, gin.H{"status": "drained"})
+}
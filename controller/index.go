@@ -0,0 +1,16 @@
+package controller
+
+import (
+	"go_web_scaffolding/settings"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IndexHandler 服务端渲染页面示例
+func IndexHandler(c *gin.Context) {
+	c.HTML(http.StatusOK, "index.html", gin.H{
+		"Name":    settings.Conf.Name,
+		"Version": settings.Conf.Version,
+	})
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/audit"
+	"go_web_scaffolding/pkg/jobs"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ParamListDeadJobs 分页查询死信任务的请求参数
+type ParamListDeadJobs struct {
+	Queue string `form:"queue" binding:"omitempty,max=64"`
+	Page  int    `form:"page" binding:"omitempty,min=1"`
+	Size  int    `form:"size" binding:"omitempty,min=1,max=100"`
+}
+
+// ListDeadJobsHandler 列出重试次数用完的后台任务，queue 默认 default
+func ListDeadJobsHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamListDeadJobs](c)
+	if !ok {
+		return
+	}
+	if p.Queue == "" {
+		p.Queue = "default"
+	}
+	if p.Page == 0 {
+		p.Page = 1
+	}
+	if p.Size == 0 {
+		p.Size = 20
+	}
+	list, err := jobs.DeadLetters(p.Queue, p.Page, p.Size)
+	// 队列里还没有过任务时 asynq 认为队列不存在，按空列表返回
+	if errors.Is(err, jobs.ErrNotFound) {
+		list, err = []*jobs.DeadLetter{}, nil
+	}
+	if err != nil {
+		jobsError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, gin.H{"queue": p.Queue, "list": list})
+}
+
+// RetryDeadJobHandler 重新执行死信任务
+func RetryDeadJobHandler(c *gin.Context) {
+	if err := jobs.RetryDeadLetter(c.Param("queue"), c.Param("id")); err != nil {
+		jobsError(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "jobs.retry", c.Param("id"), c.Param("queue"))
+	response.ResponseSuccess(c, nil)
+}
+
+// DeleteDeadJobHandler 删除死信任务
+func DeleteDeadJobHandler(c *gin.Context) {
+	if err := jobs.DeleteDeadLetter(c.Param("queue"), c.Param("id")); err != nil {
+		jobsError(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "jobs.delete", c.Param("id"), c.Param("queue"))
+	response.ResponseSuccess(c, nil)
+}
+
+func jobsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		response.ResponseError(c, response.CodeNotFound)
+	case errors.Is(err, jobs.ErrDisabled):
+		response.ResponseErrorWithMsg(c, response.CodeServiceUnavailable, "后台任务队列未开启")
+	default:
+		logger.Module(c.Request.Context(), "controller").Error("jobs admin failed", zap.Error(err))
+		response.ResponseError(c, response.CodeServerBusy)
+	}
+}
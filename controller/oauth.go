@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/oauth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// oauthNonceCookie 发起授权的浏览器持有的随机数，回调时和 state 里保存的比对，
+// 只在第三方登录的路径下发送，授权页跳回来是顶层 GET 导航，SameSite=Lax 能带上
+const oauthNonceCookie = "oauth_nonce"
+
+func setOAuthNonce(c *gin.Context, nonce string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthNonceCookie, nonce, int(oauth.StateTTL().Seconds()), "/api/v1/oauth", "", c.Request.TLS != nil, true)
+}
+
+// OAuthProvidersHandler 已开启的第三方登录平台
+func OAuthProvidersHandler(c *gin.Context) {
+	response.ResponseSuccess(c, gin.H{"providers": oauth.Names()})
+}
+
+// OAuthLoginHandler 跳转到第三方授权页
+func OAuthLoginHandler(c *gin.Context) {
+	url, nonce, err := logic.OAuthAuthURL(c.Request.Context(), c.Param("provider"), 0)
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+	setOAuthNonce(c, nonce)
+	c.Redirect(http.StatusFound, url)
+}
+
+// OAuthBindHandler 已登录用户绑定第三方账号，返回授权页地址由前端跳转
+func OAuthBindHandler(c *gin.Context) {
+	url, nonce, err := logic.OAuthAuthURL(c.Request.Context(), c.Param("provider"), ctxutil.UserID(c.Request.Context()))
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+	setOAuthNonce(c, nonce)
+	response.ResponseSuccess(c, gin.H{"url": url})
+}
+
+// OAuthCallbackHandler 第三方授权后的回调，登录成功返回 token，开启了两步验证时返回 mfa_token
+func OAuthCallbackHandler(c *gin.Context) {
+	nonce, _ := c.Cookie(oauthNonceCookie)
+	c.SetCookie(oauthNonceCookie, "", -1, "/api/v1/oauth", "", c.Request.TLS != nil, true)
+	res, err := logic.OAuthCallback(c.Request.Context(), c.Param("provider"), c.Query("code"), c.Query("state"), nonce)
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, res)
+}
+
+// oauthError 统一处理第三方登录的错误
+func oauthError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, oauth.ErrProviderNotFound):
+		response.ResponseErrorWithMsg(c, response.CodeNotFound, err.Error())
+	case errors.Is(err, logic.ErrInvalidOAuthState):
+		response.ResponseError(c, response.CodeInvalidOAuthState)
+	case errors.Is(err, logic.ErrOAuthBound):
+		response.ResponseError(c, response.CodeOAuthBound)
+	case errors.Is(err, logic.ErrOAuthEmailExist):
+		response.ResponseError(c, response.CodeOAuthEmailExist)
+	default:
+		logger.Module(c.Request.Context(), "controller").Error("oauth failed", zap.String("provider", c.Param("provider")), zap.Error(err))
+		response.ResponseError(c, response.CodeOAuthFailed)
+	}
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/password"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ParamForgotPassword 忘记密码的请求参数
+type ParamForgotPassword struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ParamResetPassword 重置密码的请求参数
+type ParamResetPassword struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// ForgotPasswordHandler 发送重置密码邮件，不论邮箱是否注册过都返回成功
+func ForgotPasswordHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamForgotPassword](c)
+	if !ok {
+		return
+	}
+	if err := logic.ForgotPassword(c.Request.Context(), p.Email); err != nil {
+		logger.Module(c.Request.Context(), "controller").Error("logic.ForgotPassword failed", zap.Error(err))
+		_ = c.Error(err)
+		response.ResponseError(c, response.CodeServerBusy)
+		return
+	}
+	response.ResponseSuccess(c, nil)
+}
+
+// ResetPasswordHandler 用邮件里的 token 设置新密码
+func ResetPasswordHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamResetPassword](c)
+	if !ok {
+		return
+	}
+	err := logic.ResetPassword(c.Request.Context(), p.Token, p.Password)
+	var weak *password.PolicyError
+	switch {
+	case err == nil:
+		response.ResponseSuccess(c, nil)
+	case errors.Is(err, logic.ErrInvalidResetToken):
+		response.ResponseError(c, response.CodeInvalidResetToken)
+	case errors.As(err, &weak):
+		response.ResponseErrorWithData(c, response.CodeWeakPassword, gin.H{"violations": weak.Violations})
+	default:
+		logger.Module(c.Request.Context(), "controller").Error("logic.ResetPassword failed", zap.Error(err))
+		_ = c.Error(err)
+		response.ResponseError(c, response.CodeServerBusy)
+	}
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/ctxutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParamPushDevice 登记推送设备的请求参数，token 是 APNs 的 device token（十六进制）或 FCM 的 registration token
+type ParamPushDevice struct {
+	Platform string `json:"platform" binding:"required,oneof=ios android"`
+	Token    string `json:"token" binding:"required,max=255,push_token"`
+}
+
+// RegisterPushDeviceHandler 登记当前用户的推送设备
+func RegisterPushDeviceHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamPushDevice](c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if err := logic.RegisterPushDevice(ctx, ctxutil.UserID(ctx), p.Platform, p.Token); err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ResponseSuccess(c, nil)
+}
+
+// ListPushDevicesHandler 列出当前用户的推送设备
+func ListPushDevicesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	list, err := logic.ListPushDevices(ctx, ctxutil.UserID(ctx))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ResponseSuccess(c, list)
+}
+
+// DeletePushDeviceHandler 注销推送设备
+func DeletePushDeviceHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := logic.DeletePushDevice(ctx, ctxutil.UserID(ctx), c.Param("token")); err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ResponseSuccess(c, nil)
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/audit"
+	"go_web_scaffolding/pkg/rbac"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ParamPolicy 权限策略：sub 可以是用户 ID 或角色名
+type ParamPolicy struct {
+	Sub string `json:"sub" binding:"required"`
+	Obj string `json:"obj" binding:"required"`
+	Act string `json:"act" binding:"required"`
+}
+
+// ParamRole 给用户分配的角色
+type ParamRole struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// rbacError 统一处理 rbac 管理函数的错误
+func rbacError(c *gin.Context, err error) {
+	if errors.Is(err, rbac.ErrDisabled) {
+		response.ResponseErrorWithMsg(c, response.CodeNotFound, "rbac disabled")
+		return
+	}
+	logger.Module(c.Request.Context(), "controller").Error("rbac operation failed", zap.Error(err))
+	_ = c.Error(err)
+	response.ResponseError(c, response.CodeServerBusy)
+}
+
+// ListPoliciesHandler 列出全部权限策略和角色分配
+func ListPoliciesHandler(c *gin.Context) {
+	policies, groupings, err := rbac.Policies()
+	if err != nil {
+		rbacError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, gin.H{"policies": policies, "groupings": groupings})
+}
+
+// AddPolicyHandler 新增权限策略
+func AddPolicyHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamPolicy](c)
+	if !ok {
+		return
+	}
+	added, err := rbac.AddPolicy(c.Request.Context(), p.Sub, p.Obj, p.Act)
+	if err != nil {
+		rbacError(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "rbac.policy.add", p.Sub, fmt.Sprintf("%s %s", p.Act, p.Obj))
+	response.ResponseSuccess(c, gin.H{"added": added})
+}
+
+// RemovePolicyHandler 删除权限策略
+func RemovePolicyHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamPolicy](c)
+	if !ok {
+		return
+	}
+	removed, err := rbac.RemovePolicy(c.Request.Context(), p.Sub, p.Obj, p.Act)
+	if err != nil {
+		rbacError(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "rbac.policy.remove", p.Sub, fmt.Sprintf("%s %s", p.Act, p.Obj))
+	response.ResponseSuccess(c, gin.H{"removed": removed})
+}
+
+// ListUserRolesHandler 查询用户拥有的角色
+func ListUserRolesHandler(c *gin.Context) {
+	roles, err := rbac.RolesForUser(c.Param("id"))
+	if err != nil {
+		rbacError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, gin.H{"roles": roles})
+}
+
+// AddUserRoleHandler 给用户分配角色
+func AddUserRoleHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamRole](c)
+	if !ok {
+		return
+	}
+	user := c.Param("id")
+	added, err := rbac.AddRoleForUser(c.Request.Context(), user, p.Role)
+	if err != nil {
+		rbacError(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "rbac.role.add", user, p.Role)
+	response.ResponseSuccess(c, gin.H{"added": added})
+}
+
+// DeleteUserRoleHandler 收回用户的角色
+func DeleteUserRoleHandler(c *gin.Context) {
+	user, role := c.Param("id"), c.Param("role")
+	removed, err := rbac.DeleteRoleForUser(c.Request.Context(), user, role)
+	if err != nil {
+		rbacError(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "rbac.role.remove", user, role)
+	response.ResponseSuccess(c, gin.H{"removed": removed})
+}
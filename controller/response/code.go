@@ -0,0 +1,151 @@
+package response
+
+import (
+	"context"
+	"go_web_scaffolding/pkg/i18n"
+	"net/http"
+	"strconv"
+)
+
+// ResCode 业务状态码，客户端根据它判断结果，HTTP 状态码只区分大类
+type ResCode int64
+
+const (
+	CodeSuccess ResCode = 1000 + iota
+	CodeInvalidParam
+	CodeServerBusy
+	CodeNotFound
+	CodeTooManyRequests
+	CodeServiceUnavailable
+
+	// 登录鉴权
+	CodeNeedLogin
+	CodeInvalidToken
+	CodeSessionReplaced
+	CodeForbidden
+	CodeInvalidAPIKey
+	CodeInsufficientScope
+	CodeAdminDisabled
+	CodeInvalidAdminToken
+
+	// 用户
+	CodeUserExist
+	CodeEmailExist
+	CodeUserNotExist
+	CodeInvalidPassword
+	CodeWeakPassword
+	CodeLoginLocked
+
+	// 验证码、两步验证、第三方登录
+	CodeInvalidCaptcha
+	CodeInvalidCode
+	CodeCodeTooFrequent
+	CodeInvalidTOTP
+	CodeInvalidMFAToken
+	CodeTOTPState
+	CodeInvalidResetToken
+	CodeInvalidOAuthState
+	CodeOAuthBound
+	CodeOAuthFailed
+	CodeOAuthEmailExist
+)
+
+// codeMsgMap 状态码的默认提示，其它语言的翻译在 pkg/i18n/locales 里
+var codeMsgMap = map[ResCode]string{
+	CodeSuccess:            "success",
+	CodeInvalidParam:       "请求参数错误",
+	CodeServerBusy:         "服务繁忙",
+	CodeNotFound:           "资源不存在",
+	CodeTooManyRequests:    "请求太频繁",
+	CodeServiceUnavailable: "服务暂不可用",
+
+	CodeNeedLogin:         "需要登录",
+	CodeInvalidToken:      "无效的token",
+	CodeSessionReplaced:   "账号已在其它设备登录",
+	CodeForbidden:         "没有权限",
+	CodeInvalidAPIKey:     "无效的api key",
+	CodeInsufficientScope: "api key 权限不足",
+	CodeAdminDisabled:     "管理接口未开启",
+	CodeInvalidAdminToken: "无效的管理令牌",
+
+	CodeUserExist:       "用户名已存在",
+	CodeEmailExist:      "邮箱已注册",
+	CodeUserNotExist:    "用户不存在",
+	CodeInvalidPassword: "用户名或密码错误",
+	CodeWeakPassword:    "密码强度不够",
+	CodeLoginLocked:     "失败次数过多，请稍后再试",
+
+	CodeInvalidCaptcha:    "图形验证码错误",
+	CodeInvalidCode:       "验证码错误或已过期",
+	CodeCodeTooFrequent:   "验证码发送太频繁",
+	CodeInvalidTOTP:       "两步验证码错误",
+	CodeInvalidMFAToken:   "登录已过期，请重新登录",
+	CodeTOTPState:         "两步验证状态不正确",
+	CodeInvalidResetToken: "重置链接无效或已过期",
+	CodeInvalidOAuthState: "第三方登录已过期，请重新登录",
+	CodeOAuthBound:        "该第三方账号已绑定其它用户",
+	CodeOAuthFailed:       "第三方登录失败",
+	CodeOAuthEmailExist:   "该邮箱已注册，请登录后再绑定第三方账号",
+}
+
+// codeStatusMap 业务状态码对应的 HTTP 状态码，没有列出的都是 200
+var codeStatusMap = map[ResCode]int{
+	CodeInvalidParam:       http.StatusBadRequest,
+	CodeServerBusy:         http.StatusInternalServerError,
+	CodeNotFound:           http.StatusNotFound,
+	CodeTooManyRequests:    http.StatusTooManyRequests,
+	CodeServiceUnavailable: http.StatusServiceUnavailable,
+
+	CodeNeedLogin:         http.StatusUnauthorized,
+	CodeInvalidToken:      http.StatusUnauthorized,
+	CodeSessionReplaced:   http.StatusUnauthorized,
+	CodeForbidden:         http.StatusForbidden,
+	CodeInvalidAPIKey:     http.StatusUnauthorized,
+	CodeInsufficientScope: http.StatusForbidden,
+	CodeAdminDisabled:     http.StatusForbidden,
+	CodeInvalidAdminToken: http.StatusUnauthorized,
+
+	CodeUserExist:       http.StatusBadRequest,
+	CodeEmailExist:      http.StatusBadRequest,
+	CodeUserNotExist:    http.StatusNotFound,
+	CodeInvalidPassword: http.StatusUnauthorized,
+	CodeWeakPassword:    http.StatusBadRequest,
+	CodeLoginLocked:     http.StatusTooManyRequests,
+
+	CodeInvalidCaptcha:    http.StatusBadRequest,
+	CodeInvalidCode:       http.StatusBadRequest,
+	CodeCodeTooFrequent:   http.StatusTooManyRequests,
+	CodeInvalidTOTP:       http.StatusUnauthorized,
+	CodeInvalidMFAToken:   http.StatusUnauthorized,
+	CodeTOTPState:         http.StatusBadRequest,
+	CodeInvalidResetToken: http.StatusBadRequest,
+	CodeInvalidOAuthState: http.StatusBadRequest,
+	CodeOAuthBound:        http.StatusBadRequest,
+	CodeOAuthFailed:       http.StatusBadGateway,
+	CodeOAuthEmailExist:   http.StatusConflict,
+}
+
+// Msg 状态码的默认提示（中文）
+func (c ResCode) Msg() string {
+	msg, ok := codeMsgMap[c]
+	if !ok {
+		msg = codeMsgMap[CodeServerBusy]
+	}
+	return msg
+}
+
+// Localize 按当前请求的语言返回状态码的提示，翻译文件里的 key 是 code.<状态码>，没有翻译时用默认提示
+func (c ResCode) Localize(ctx context.Context) string {
+	if _, ok := codeMsgMap[c]; !ok {
+		c = CodeServerBusy
+	}
+	return i18n.Text(ctx, "code."+strconv.FormatInt(int64(c), 10), c.Msg())
+}
+
+// HTTPStatus 状态码对应的 HTTP 状态码
+func (c ResCode) HTTPStatus() int {
+	if s, ok := codeStatusMap[c]; ok {
+		return s
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,84 @@
+package response
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/apperr"
+	"go_web_scaffolding/pkg/breaker"
+	"go_web_scaffolding/pkg/i18n"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// statusCodeMap apperr 没有指定业务状态码时，按 HTTP 状态码取默认的业务状态码
+var statusCodeMap = map[int]ResCode{
+	http.StatusBadRequest:         CodeInvalidParam,
+	http.StatusUnauthorized:       CodeNeedLogin,
+	http.StatusForbidden:          CodeForbidden,
+	http.StatusNotFound:           CodeNotFound,
+	http.StatusConflict:           CodeInvalidParam,
+	http.StatusTooManyRequests:    CodeTooManyRequests,
+	http.StatusServiceUnavailable: CodeServiceUnavailable,
+}
+
+// Err 业务状态码转成 apperr，controller 里需要返回指定状态码时使用，提示在返回时按请求的语言取
+func (c ResCode) Err() *apperr.Error {
+	return apperr.New(int64(c), c.HTTPStatus(), "")
+}
+
+// errUnavailable 依赖被熔断时返回 503，客户端可以稍后重试
+var errUnavailable = apperr.Unavailable("")
+
+// Error 统一处理错误：apperr 按它带的状态码和提示（按请求的语言翻译）返回，依赖被熔断返回 503，其它错误一律当作内部错误返回"服务繁忙"
+// 5xx 的底层错误记录到日志并通过 c.Error 交给 Sentry，客户端看不到
+func Error(c *gin.Context, err error) {
+	ae, code, status, msg := resolve(c.Request.Context(), err)
+	if status >= http.StatusInternalServerError {
+		_ = c.Error(err)
+	}
+	c.JSON(status, &ResponseData{
+		Code: code,
+		Msg:  msg,
+		Data: ae.Data,
+	})
+}
+
+// resolve 把错误转换成业务状态码、HTTP 状态码和提示，并按级别记录日志，HTTP 和 gRPC 共用
+func resolve(ctx context.Context, err error) (ae *apperr.Error, code ResCode, status int, msg string) {
+	ae, ok := apperr.As(err)
+	if !ok {
+		if errors.Is(err, breaker.ErrOpen) {
+			ae = errUnavailable.Wrap(err)
+		} else {
+			ae = apperr.Internal(err)
+		}
+	}
+
+	code = ResCode(ae.Code)
+	if code == 0 {
+		code = CodeServerBusy
+		if cc, ok := statusCodeMap[ae.Status]; ok {
+			code = cc
+		}
+	}
+	status = ae.Status
+	if status == 0 {
+		status = code.HTTPStatus()
+	}
+	// apperr 的提示以原文作为 key 翻译
+	msg = i18n.T(ctx, ae.Msg)
+	if msg == "" {
+		msg = code.Localize(ctx)
+	}
+
+	lg := logger.Module(ctx, "controller")
+	if status >= http.StatusInternalServerError {
+		lg.Error("request failed", zap.Int64("code", int64(code)), zap.Error(err))
+	} else if ae.Cause() != nil {
+		lg.Debug("request rejected", zap.Int64("code", int64(code)), zap.Error(err))
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package response
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC 接口的错误：gRPC 状态码按 HTTP 状态码的大类转换，业务状态码放在 ErrorInfo 详情的 metadata["code"] 里，
+// grpc-gateway 转成 REST 响应时、Go 客户端拿到错误时都可以用 CodeFromGRPC 取回业务状态码
+
+const (
+	grpcErrorReason = "BUSINESS_ERROR"
+	grpcErrorDomain = "go_web_scaffolding"
+)
+
+// httpGRPCCodes HTTP 状态码对应的 gRPC 状态码，没有列出的是 Unknown
+var httpGRPCCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusInternalServerError: codes.Internal,
+	http.StatusBadGateway:          codes.Unavailable,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+}
+
+// GRPCError 业务状态码转成 gRPC 错误，msg 为空时使用默认提示
+func (c ResCode) GRPCError(msg string) error {
+	if msg == "" {
+		msg = c.Msg()
+	}
+	return grpcError(c, c.HTTPStatus(), msg)
+}
+
+func grpcError(c ResCode, httpStatus int, msg string) error {
+	gc, ok := httpGRPCCodes[httpStatus]
+	if !ok {
+		gc = codes.Unknown
+	}
+	st := status.New(gc, msg)
+	if withInfo, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   grpcErrorReason,
+		Domain:   grpcErrorDomain,
+		Metadata: map[string]string{"code": strconv.FormatInt(int64(c), 10)},
+	}); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+// GRPC gRPC 版的 Error：apperr 按它带的状态码和提示转换，其它错误返回 Internal 并记录日志
+func GRPC(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	_, code, httpStatus, msg := resolve(ctx, err)
+	return grpcError(code, httpStatus, msg)
+}
+
+// CodeFromGRPC 取出 gRPC 错误里的业务状态码，不是本服务返回的错误时 ok 为 false
+func CodeFromGRPC(st *status.Status) (code ResCode, ok bool) {
+	for _, d := range st.Details() {
+		info, isInfo := d.(*errdetails.ErrorInfo)
+		if !isInfo || info.Domain != grpcErrorDomain {
+			continue
+		}
+		if n, err := strconv.ParseInt(info.Metadata["code"], 10, 64); err == nil {
+			return ResCode(n), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,62 @@
+package response
+
+import (
+	"go_web_scaffolding/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+{
+	"code": 1000, // 程序中的错误码
+	"msg": "success", // 提示信息
+	"data": {} // 数据
+}
+*/
+
+// ResponseData 统一的响应结构
+type ResponseData struct {
+	Code ResCode     `json:"code"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// ResponseError 使用状态码的默认提示，按请求的语言翻译
+func ResponseError(c *gin.Context, code ResCode) {
+	c.JSON(code.HTTPStatus(), &ResponseData{
+		Code: code,
+		Msg:  code.Localize(c.Request.Context()),
+	})
+}
+
+// ResponseErrorWithMsg 自定义提示，比如参数校验的具体原因；msg 在翻译文件里有对应的 key 时返回译文
+func ResponseErrorWithMsg(c *gin.Context, code ResCode, msg string) {
+	c.JSON(code.HTTPStatus(), &ResponseData{
+		Code: code,
+		Msg:  i18n.T(c.Request.Context(), msg),
+	})
+}
+
+// ResponseErrorWithData 错误时也需要返回数据，比如密码不满足的具体规则
+func ResponseErrorWithData(c *gin.Context, code ResCode, data interface{}) {
+	c.JSON(code.HTTPStatus(), &ResponseData{
+		Code: code,
+		Msg:  code.Localize(c.Request.Context()),
+		Data: data,
+	})
+}
+
+// ResponseSuccess 成功
+func ResponseSuccess(c *gin.Context, data interface{}) {
+	c.JSON(CodeSuccess.HTTPStatus(), &ResponseData{
+		Code: CodeSuccess,
+		Msg:  CodeSuccess.Msg(),
+		Data: data,
+	})
+}
+
+// Abort 中间件里使用，返回错误并终止后续的处理函数
+func Abort(c *gin.Context, code ResCode) {
+	ResponseError(c, code)
+	c.Abort()
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParamSearch 搜索参数，type 为搜索的对象，目前只有 user
+type ParamSearch struct {
+	Q    string `form:"q" binding:"required,max=100"`
+	Type string `form:"type" binding:"omitempty,oneof=user"`
+	pagination.Params
+}
+
+// SearchHandler 全文搜索，返回分页结果和高亮片段
+func SearchHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamSearch](c)
+	if !ok {
+		return
+	}
+	res, err := logic.SearchUsers(c.Request.Context(), p.Q, p.Params)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ResponseSuccess(c, res)
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/sms"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SMSReportHandler 接收短信服务商推送的送达回执，响应格式由服务商决定，不走统一的 response
+func SMSReportHandler(c *gin.Context) {
+	ack, err := sms.HandleReport(c.Request.Context(), c.Param("provider"), c.Request)
+	switch {
+	case errors.Is(err, sms.ErrProviderNotFound):
+		c.Status(http.StatusNotFound)
+		return
+	case errors.Is(err, sms.ErrInvalidReport):
+		logger.Module(c.Request.Context(), "controller").Warn("invalid sms report", zap.Error(err))
+		c.Status(http.StatusForbidden)
+		return
+	case err != nil:
+		logger.Module(c.Request.Context(), "controller").Error("handle sms report failed", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if ack == nil {
+		c.Status(http.StatusOK)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", ack)
+}
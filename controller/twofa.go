@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/loginguard"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ParamTOTPCode 验证码，也可以填恢复码
+type ParamTOTPCode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ParamVerifyMFA 登录第二步的请求参数
+type ParamVerifyMFA struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// twoFAError 统一处理两步验证的错误
+func twoFAError(c *gin.Context, err error) {
+	var locked *loginguard.LockedError
+	switch {
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+		response.ResponseError(c, response.CodeLoginLocked)
+	case errors.Is(err, logic.ErrInvalidTOTPCode):
+		response.ResponseError(c, response.CodeInvalidTOTP)
+	case errors.Is(err, logic.ErrInvalidMFAToken):
+		response.ResponseError(c, response.CodeInvalidMFAToken)
+	case errors.Is(err, logic.ErrTOTPEnabled), errors.Is(err, logic.ErrTOTPNotEnabled), errors.Is(err, logic.ErrTOTPSetup):
+		response.ResponseErrorWithMsg(c, response.CodeTOTPState, err.Error())
+	default:
+		logger.Module(c.Request.Context(), "controller").Error("two-factor operation failed", zap.Error(err))
+		_ = c.Error(err)
+		response.ResponseError(c, response.CodeServerBusy)
+	}
+}
+
+// SetupTOTPHandler 生成两步验证密钥，uri 由前端生成二维码
+func SetupTOTPHandler(c *gin.Context) {
+	secret, uri, err := logic.SetupTOTP(c.Request.Context(), ctxutil.UserID(c.Request.Context()))
+	if err != nil {
+		twoFAError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, gin.H{"secret": secret, "uri": uri})
+}
+
+// EnableTOTPHandler 输入验证码确认开启两步验证，返回的恢复码只展示这一次
+func EnableTOTPHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamTOTPCode](c)
+	if !ok {
+		return
+	}
+	codes, err := logic.EnableTOTP(c.Request.Context(), ctxutil.UserID(c.Request.Context()), p.Code)
+	if err != nil {
+		twoFAError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, gin.H{"recovery_codes": codes})
+}
+
+// DisableTOTPHandler 关闭两步验证
+func DisableTOTPHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamTOTPCode](c)
+	if !ok {
+		return
+	}
+	if err := logic.DisableTOTP(c.Request.Context(), ctxutil.UserID(c.Request.Context()), p.Code); err != nil {
+		twoFAError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, nil)
+}
+
+// RecoveryCodesHandler 重新生成恢复码
+func RecoveryCodesHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamTOTPCode](c)
+	if !ok {
+		return
+	}
+	codes, err := logic.RegenerateRecoveryCodes(c.Request.Context(), ctxutil.UserID(c.Request.Context()), p.Code)
+	if err != nil {
+		twoFAError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, gin.H{"recovery_codes": codes})
+}
+
+// VerifyMFAHandler 登录第二步，校验通过后返回 token
+func VerifyMFAHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamVerifyMFA](c)
+	if !ok {
+		return
+	}
+	res, err := logic.VerifyMFA(c.Request.Context(), p.MFAToken, p.Code)
+	if err != nil {
+		twoFAError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, res)
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/apperr"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/loginguard"
+	"go_web_scaffolding/pkg/password"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ParamSignUp 注册的请求参数
+type ParamSignUp struct {
+	Username   string `json:"username" binding:"required,username"`
+	Password   string `json:"password" binding:"required"`
+	RePassword string `json:"re_password" binding:"required,eqfield=Password"`
+	Email      string `json:"email" binding:"required,email"`
+	Code       string `json:"code" binding:"required"`
+}
+
+// ParamLogin 登录的请求参数
+type ParamLogin struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// ParamUpdateProfile 修改资料的请求参数，空字段不修改
+type ParamUpdateProfile struct {
+	Nickname string `json:"nickname" binding:"omitempty,max=64"`
+	Avatar   string `json:"avatar" binding:"omitempty,url,max=512"`
+	Locale   string `json:"locale" binding:"omitempty,max=16"`
+	Timezone string `json:"timezone" binding:"omitempty,max=64"`
+}
+
+// ParamChangePassword 修改密码的请求参数
+type ParamChangePassword struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// userError 统一处理用户模块的错误
+func userError(c *gin.Context, err error) {
+	var weak *password.PolicyError
+	var locked *loginguard.LockedError
+	var ae *apperr.Error
+	switch {
+	case errors.As(err, &weak):
+		response.ResponseErrorWithData(c, response.CodeWeakPassword, gin.H{"violations": weak.Violations})
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+		response.ResponseError(c, response.CodeLoginLocked)
+	case errors.Is(err, logic.ErrUserExist):
+		response.ResponseError(c, response.CodeUserExist)
+	case errors.Is(err, logic.ErrEmailExist):
+		response.ResponseError(c, response.CodeEmailExist)
+	case errors.Is(err, logic.ErrInvalidCode):
+		response.ResponseError(c, response.CodeInvalidCode)
+	case errors.Is(err, logic.ErrInvalidPassword):
+		response.ResponseError(c, response.CodeInvalidPassword)
+	case errors.Is(err, logic.ErrUserNotExist):
+		response.ResponseError(c, response.CodeUserNotExist)
+	case errors.As(err, &ae):
+		response.Error(c, err)
+	default:
+		logger.Module(c.Request.Context(), "controller").Error("user operation failed", zap.Error(err))
+		_ = c.Error(err)
+		response.ResponseError(c, response.CodeServerBusy)
+	}
+}
+
+// SignUpHandler 注册
+func SignUpHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamSignUp](c)
+	if !ok {
+		return
+	}
+	u, err := logic.SignUp(c.Request.Context(), &logic.SignUpParams{
+		Username: p.Username,
+		Password: p.Password,
+		Email:    p.Email,
+		Code:     p.Code,
+	})
+	if err != nil {
+		userError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, gin.H{"user_id": strconv.FormatInt(u.UserID, 10)})
+}
+
+// LoginHandler 用户名密码登录，开启了两步验证时返回 mfa_token
+func LoginHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamLogin](c)
+	if !ok {
+		return
+	}
+	res, err := logic.Login(c.Request.Context(), p.Username, p.Password)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, res)
+}
+
+// GetProfileHandler 当前用户的资料
+func GetProfileHandler(c *gin.Context) {
+	u, err := logic.GetProfile(c.Request.Context(), ctxutil.UserID(c.Request.Context()))
+	if err != nil {
+		userError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, u)
+}
+
+// UpdateProfileHandler 修改当前用户的资料
+func UpdateProfileHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamUpdateProfile](c)
+	if !ok {
+		return
+	}
+	u, err := logic.UpdateProfile(c.Request.Context(), ctxutil.UserID(c.Request.Context()), &logic.ProfileParams{
+		Nickname: p.Nickname,
+		Avatar:   p.Avatar,
+		Locale:   p.Locale,
+		Timezone: p.Timezone,
+	})
+	if err != nil {
+		userError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, u)
+}
+
+// ChangePasswordHandler 修改当前用户的密码，之前的 token 全部失效，返回当前设备的新 token
+func ChangePasswordHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamChangePassword](c)
+	if !ok {
+		return
+	}
+	res, err := logic.ChangePassword(c.Request.Context(), ctxutil.UserID(c.Request.Context()), p.OldPassword, p.NewPassword)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, res)
+}
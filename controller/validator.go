@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"go_web_scaffolding/pkg/locale"
+	"go_web_scaffolding/pkg/validate"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+)
+
+// uni 保存所有语言的翻译器，按请求的语言取用
+var uni *ut.UniversalTranslator
+
+// InitTrans 给 gin 的校验器注册中文和英文翻译
+// 参数校验失败时按请求的语言返回 "email必须是一个有效的邮箱" 这样的提示，而不是校验器原始的英文信息
+func InitTrans() (err error) {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("binding validator is not go-playground/validator")
+	}
+
+	// 错误信息里的字段名用 json tag，和客户端提交的字段保持一致
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name, _, _ := strings.Cut(fld.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	zhT := zh.New()
+	enT := en.New()
+	// 第一个参数是备用（fallback）的语言环境
+	uni = ut.New(zhT, zhT, enT)
+
+	trans, _ := uni.GetTranslator("zh")
+	if err = zhTranslations.RegisterDefaultTranslations(v, trans); err != nil {
+		return fmt.Errorf("register zh translations: %w", err)
+	}
+	trans, _ = uni.GetTranslator("en")
+	if err = enTranslations.RegisterDefaultTranslations(v, trans); err != nil {
+		return fmt.Errorf("register en translations: %w", err)
+	}
+	// 项目自定义的校验规则，在默认翻译之后注册，同名的提示以自定义的为准
+	return validate.Apply(v, uni)
+}
+
+// translator 按当前请求的语言取翻译器，不支持的语言退回中文
+func translator(c *gin.Context) ut.Translator {
+	trans, _ := uni.GetTranslator(locale.Lang(c.Request.Context()))
+	return trans
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/ctxutil"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParamSendEmailCode 发送邮箱验证码的请求参数，未登录时只能发送注册验证码
+type ParamSendEmailCode struct {
+	Email string `json:"email" binding:"required,email"`
+	Scene string `json:"scene" binding:"required,oneof=register"`
+}
+
+// ParamVerifyEmailCode 校验邮箱验证码的请求参数
+type ParamVerifyEmailCode struct {
+	Email string `json:"email" binding:"required,email"`
+	Scene string `json:"scene" binding:"required,oneof=register"`
+	Code  string `json:"code" binding:"required"`
+}
+
+// codeError 统一处理验证码的错误
+func codeError(c *gin.Context, err error) {
+	var limited *logic.CodeRateLimitError
+	switch {
+	case errors.As(err, &limited):
+		if limited.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(limited.RetryAfter.Seconds())+1))
+		}
+		response.ResponseErrorWithMsg(c, response.CodeCodeTooFrequent, err.Error())
+	case errors.Is(err, logic.ErrInvalidCode):
+		response.ResponseError(c, response.CodeInvalidCode)
+	case errors.Is(err, logic.ErrEmailExist):
+		response.ResponseError(c, response.CodeEmailExist)
+	case errors.Is(err, logic.ErrUnknownScene):
+		response.ResponseErrorWithMsg(c, response.CodeInvalidParam, err.Error())
+	default:
+		// 邮件限额（mailer.ErrRateLimited）这类 apperr 按它的状态码返回，其它错误记日志后返回"服务繁忙"
+		response.Error(c, err)
+	}
+}
+
+// SendEmailCodeHandler 发送邮箱验证码
+func SendEmailCodeHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamSendEmailCode](c)
+	if !ok {
+		return
+	}
+	if err := logic.SendEmailCode(c.Request.Context(), p.Scene, p.Email); err != nil {
+		codeError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, nil)
+}
+
+// VerifyEmailCodeHandler 单独校验邮箱验证码，给前端提前提示用，验证码在注册成功时才作废
+func VerifyEmailCodeHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamVerifyEmailCode](c)
+	if !ok {
+		return
+	}
+	if err := logic.CheckEmailCode(c.Request.Context(), p.Scene, p.Email, p.Code); err != nil {
+		codeError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, nil)
+}
+
+// SendConfirmCodeHandler 给当前登录用户的邮箱发送敏感操作确认码
+func SendConfirmCodeHandler(c *gin.Context) {
+	if err := logic.SendConfirmCode(c.Request.Context(), ctxutil.UserID(c.Request.Context())); err != nil {
+		codeError(c, err)
+		return
+	}
+	response.ResponseSuccess(c, nil)
+}
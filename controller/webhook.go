@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/apperr"
+	"go_web_scaffolding/pkg/audit"
+	"go_web_scaffolding/pkg/pagination"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParamWebhook 创建和修改 webhook 的请求参数，events 为空表示订阅所有事件
+// 创建时 enabled 默认为 true，修改时需要带上
+type ParamWebhook struct {
+	URL         string   `json:"url" binding:"required,url,max=512"`
+	Events      []string `json:"events" binding:"omitempty,dive,max=64"`
+	Description string   `json:"description" binding:"max=255"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+func (p *ParamWebhook) params() *logic.WebhookParams {
+	enabled := p.Enabled == nil || *p.Enabled
+	return &logic.WebhookParams{URL: p.URL, Events: p.Events, Description: p.Description, Enabled: enabled}
+}
+
+// ParamListWebhookDeliveries 分页查询投递日志的请求参数
+type ParamListWebhookDeliveries struct {
+	pagination.Params
+}
+
+// webhookID 解析路径里的 webhook 或投递记录 ID
+func webhookID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ResponseErrorWithMsg(c, response.CodeInvalidParam, "invalid id")
+		return 0, false
+	}
+	return id, true
+}
+
+// ListWebhooksHandler 列出所有 webhook
+func ListWebhooksHandler(c *gin.Context) {
+	list, err := logic.ListWebhooks(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	response.ResponseSuccess(c, list)
+}
+
+// CreateWebhookHandler 创建 webhook，签名密钥只在响应里出现这一次
+func CreateWebhookHandler(c *gin.Context) {
+	p, ok := BindAndValidate[ParamWebhook](c)
+	if !ok {
+		return
+	}
+	secret, w, err := logic.CreateWebhook(c.Request.Context(), p.params())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "webhook.create", strconv.FormatInt(w.ID, 10), w.URL)
+	response.ResponseSuccess(c, gin.H{"secret": secret, "webhook": w})
+}
+
+// UpdateWebhookHandler 修改 webhook 的 URL、订阅的事件和启用状态
+func UpdateWebhookHandler(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	p, ok := BindAndValidate[ParamWebhook](c)
+	if !ok {
+		return
+	}
+	w, err := logic.UpdateWebhook(c.Request.Context(), id, p.params())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "webhook.update", c.Param("id"), w.URL)
+	response.ResponseSuccess(c, w)
+}
+
+// DeleteWebhookHandler 删除 webhook
+func DeleteWebhookHandler(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	if err := logic.DeleteWebhook(c.Request.Context(), id); err != nil {
+		response.Error(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "webhook.delete", c.Param("id"), "")
+	response.ResponseSuccess(c, nil)
+}
+
+// ListWebhookDeliveriesHandler 分页查看一个 webhook 的投递日志
+func ListWebhookDeliveriesHandler(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	p, ok := BindAndValidate[ParamListWebhookDeliveries](c)
+	if !ok {
+		return
+	}
+	res, err := logic.ListWebhookDeliveries(c.Request.Context(), id, p.Params)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			err = apperr.BadRequest(err.Error())
+		}
+		response.Error(c, err)
+		return
+	}
+	response.ResponseSuccess(c, res)
+}
+
+// RedeliverWebhookHandler 重新投递一条已经结束的记录，成功的也可以重新投递
+func RedeliverWebhookHandler(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	if err := logic.RedeliverWebhook(c.Request.Context(), id); err != nil {
+		response.Error(c, err)
+		return
+	}
+	audit.Log(c.Request.Context(), "webhook.redeliver", c.Param("id"), "")
+	response.ResponseSuccess(c, nil)
+}
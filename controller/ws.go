@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/jwt"
+	"go_web_scaffolding/pkg/ws"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// WSHandler 建立 WebSocket 连接，一直阻塞到连接断开
+// 连接期间定时用握手时的 token 重新校验登录状态，redis 暂时不可用时不断开
+func WSHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	var check func(ctx context.Context) error
+	if mc, ok := jwt.ClaimsFromContext(ctx); ok {
+		check = func(ctx context.Context) error {
+			err := logic.ValidateSession(ctx, mc)
+			if err != nil && !errors.Is(err, logic.ErrSessionRevoked) && !errors.Is(err, logic.ErrSessionReplaced) {
+				logger.Module(ctx, "ws").Warn("logic.ValidateSession failed", zap.Error(err))
+				return nil
+			}
+			return err
+		}
+	}
+	err := ws.Serve(c.Writer, c.Request, ctxutil.UserID(ctx), check)
+	switch {
+	case err == nil:
+	case errors.Is(err, ws.ErrShuttingDown):
+		// 客户端换一个实例重连
+		response.Abort(c, response.CodeServiceUnavailable)
+	default:
+		// 升级失败时（Origin 不对、不是 WebSocket 请求）gorilla 已经写了响应
+		logger.Module(c.Request.Context(), "ws").Info("upgrade failed", zap.Error(err))
+	}
+}
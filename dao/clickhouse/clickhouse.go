@@ -0,0 +1,217 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/pkg/breaker"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/health"
+	"go_web_scaffolding/settings"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 访问事件、业务指标这类量大、只追加、按时间聚合查询的数据写 ClickHouse，不占用 MySQL
+// 走 HTTP 接口（默认 8123 端口），写入用 JSONEachRow 格式，一行一个 JSON 对象，字段名对应列名
+//
+//	clickhouse.Async(ctx, "access_event", &models.AccessEvent{...})   // 攒批异步写入，请求路径上用这个
+//	rows, err := clickhouse.Select[Row](ctx, "SELECT route, count() AS n FROM access_event WHERE date = {d:Date} GROUP BY route",
+//		map[string]interface{}{"d": "2024-01-01"})
+//
+// ClickHouse 每次 INSERT 都会生成一个数据分片，逐行写入很快就会触发 too many parts，所以一定要攒批
+// 没有开启时 Async 直接丢弃，不影响业务
+
+const (
+	defaultTimeout = 10 * time.Second
+	maxErrBody     = 1024
+)
+
+// ErrDisabled 没有开启 clickhouse 时返回的错误
+var ErrDisabled = errors.New("clickhouse: not enabled")
+
+type client struct {
+	addr     string
+	database string
+	username string
+	password string
+	async    bool
+	http     *http.Client
+}
+
+var cli atomic.Pointer[client]
+
+func init() {
+	dashboard.Register("clickhouse", func(ctx context.Context) interface{} {
+		c := cli.Load()
+		if c == nil {
+			return map[string]interface{}{"enable": false}
+		}
+		status := map[string]interface{}{"enable": true, "addr": c.addr, "database": c.database, "writers": writerStats(), "healthy": true}
+		if err := Ping(ctx); err != nil {
+			status["healthy"] = false
+			status["error"] = err.Error()
+		}
+		return status
+	})
+}
+
+func Init(cfg *settings.ClickHouseConfig) (err error) {
+	if cfg == nil || !cfg.Enable {
+		return
+	}
+	timeout := defaultTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Millisecond
+	}
+	c := &client{
+		addr:     strings.TrimRight(cfg.Addr, "/"),
+		database: cfg.Database,
+		username: cfg.Username,
+		password: cfg.Password,
+		async:    cfg.AsyncInsert,
+		http:     &http.Client{Timeout: timeout, Transport: breaker.Transport(nil)},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err = c.exec(ctx, "SELECT 1", nil, nil, nil); err != nil {
+		return
+	}
+	initWriters(cfg)
+	cli.Store(c)
+	health.Register("clickhouse", Ping)
+	return
+}
+
+// Close 把所有表的缓冲区写完，需要在退出前调用
+func Close(ctx context.Context) {
+	if cli.Load() == nil {
+		return
+	}
+	closeWriters(ctx)
+}
+
+// Enabled 是否已经连接 clickhouse
+func Enabled() bool {
+	return cli.Load() != nil
+}
+
+// Ping 检查 clickhouse 是否可用
+func Ping(ctx context.Context) error {
+	return Exec(ctx, "SELECT 1", nil)
+}
+
+// Exec 执行不返回数据的语句（DDL 等），params 对应 SQL 里的 {name:Type} 参数
+func Exec(ctx context.Context, query string, params map[string]interface{}) error {
+	c := cli.Load()
+	if c == nil {
+		return ErrDisabled
+	}
+	return c.exec(ctx, query, params, nil, nil)
+}
+
+// Select 执行查询，每行按 JSON 反序列化为 T，列名对应 T 的 json tag
+func Select[T any](ctx context.Context, query string, params map[string]interface{}) (rows []T, err error) {
+	c := cli.Load()
+	if c == nil {
+		return nil, ErrDisabled
+	}
+	err = c.exec(ctx, query+" FORMAT JSONEachRow", params, nil, func(r io.Reader) error {
+		dec := json.NewDecoder(r)
+		for {
+			var row T
+			if err := dec.Decode(&row); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			rows = append(rows, row)
+		}
+	})
+	return
+}
+
+// Insert 同步写入一批数据，rows 的每个元素序列化为一行 JSON
+func Insert[T any](ctx context.Context, table string, rows []T) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return insertRaw(ctx, table, buf.Bytes())
+}
+
+func insertRaw(ctx context.Context, table string, body []byte) error {
+	c := cli.Load()
+	if c == nil {
+		return ErrDisabled
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	return c.exec(ctx, "INSERT INTO "+quoteIdent(table)+" FORMAT JSONEachRow", nil, body, nil)
+}
+
+// exec 发送一次请求：query 放在 URL 参数里，body 为 INSERT 的数据，read 不为 nil 时读取响应
+func (c *client) exec(ctx context.Context, query string, params map[string]interface{}, body []byte, read func(io.Reader) error) error {
+	q := url.Values{}
+	q.Set("query", query)
+	if c.database != "" {
+		q.Set("database", c.database)
+	}
+	// Int64/UInt64 默认输出成字符串，关掉后可以直接反序列化到 int64
+	q.Set("output_format_json_quote_64bit_integers", "0")
+	// 时间字段可以直接写 RFC3339 格式（time.Time 的 JSON 格式）
+	q.Set("date_time_input_format", "best_effort")
+	if body != nil && c.async {
+		// 服务端攒批，多个实例各自攒的小批次在服务端合并成一个分片
+		q.Set("async_insert", "1")
+		q.Set("wait_for_async_insert", "1")
+	}
+	for k, v := range params {
+		q.Set("param_"+k, fmt.Sprint(v))
+	}
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.addr+"/?"+q.Encode(), r)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrBody))
+		return fmt.Errorf("clickhouse: %d %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if read == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return read(resp.Body)
+}
+
+// quoteIdent 用反引号包住表名，db.table 分别处理
+func quoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = "`" + strings.ReplaceAll(p, "`", "\\`") + "`"
+	}
+	return strings.Join(parts, ".")
+}
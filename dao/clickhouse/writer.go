@@ -0,0 +1,99 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go_web_scaffolding/pkg/batcher"
+	"go_web_scaffolding/settings"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 每张表一个批量写入器，第一次写入时创建；行在入队时就序列化好，写入时直接拼接
+
+var (
+	writerMu   sync.RWMutex
+	writers    = make(map[string]*batcher.Batcher[json.RawMessage])
+	writerOpts batcher.Options
+	closed     bool
+)
+
+func initWriters(cfg *settings.ClickHouseConfig) {
+	writerOpts = batcher.Options{
+		BufferSize:    cfg.BufferSize,
+		BatchSize:     cfg.BatchSize,
+		FlushInterval: time.Duration(cfg.FlushInterval) * time.Millisecond,
+		Policy:        batcher.PolicyDrop,
+	}
+}
+
+// Async 把一行数据放进 table 的缓冲区，攒批后写入；缓冲区满或没有开启时丢弃
+func Async(ctx context.Context, table string, row interface{}) error {
+	if cli.Load() == nil {
+		return ErrDisabled
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	// 持有读锁入队，Close 拿到写锁之后就不会再有 Add 进来
+	writerMu.RLock()
+	w, ok := writers[table]
+	if ok {
+		defer writerMu.RUnlock()
+		return w.Add(ctx, data)
+	}
+	writerMu.RUnlock()
+
+	writerMu.Lock()
+	defer writerMu.Unlock()
+	if closed {
+		return ErrDisabled
+	}
+	if w, ok = writers[table]; !ok {
+		w = newWriter(table)
+		writers[table] = w
+	}
+	return w.Add(ctx, data)
+}
+
+func newWriter(table string) *batcher.Batcher[json.RawMessage] {
+	opts := writerOpts
+	opts.Name = "clickhouse." + table
+	return batcher.New(opts, func(ctx context.Context, rows []json.RawMessage) error {
+		var buf bytes.Buffer
+		for _, row := range rows {
+			buf.Write(row)
+			buf.WriteByte('\n')
+		}
+		return insertRaw(ctx, table, buf.Bytes())
+	})
+}
+
+func closeWriters(ctx context.Context) {
+	writerMu.Lock()
+	closed = true
+	ws := writers
+	writers = make(map[string]*batcher.Batcher[json.RawMessage])
+	writerMu.Unlock()
+	for table, w := range ws {
+		if err := w.Close(ctx); err != nil {
+			zap.L().Named("dao").Warn("flush clickhouse writer failed", zap.String("table", table), zap.Error(err))
+		}
+	}
+}
+
+func writerStats() []batcher.Stats {
+	writerMu.RLock()
+	defer writerMu.RUnlock()
+	stats := make([]batcher.Stats, 0, len(writers))
+	for _, w := range writers {
+		stats = append(stats, w.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
@@ -0,0 +1,138 @@
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"go_web_scaffolding/pkg/batcher"
+	"go_web_scaffolding/settings"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 写入搜索索引不需要和业务同步，先放进缓冲区，由后台协程攒批调用 _bulk 接口
+// es 暂时不可用时这一批会丢掉（记录错误日志），之后用 app reindex users 从 mysql 重建索引
+
+// BulkItem 一条批量写入操作，Delete 为 true 时删除文档，否则整篇写入（覆盖）
+type BulkItem struct {
+	Index  string // 不带前缀的索引名
+	ID     string
+	Doc    interface{}
+	Delete bool
+}
+
+var bulker atomic.Pointer[batcher.Batcher[*BulkItem]]
+
+func startBulk(cfg *settings.ESConfig) {
+	b := batcher.New(batcher.Options{
+		Name:          "es_bulk",
+		BufferSize:    cfg.BufferSize,
+		BatchSize:     cfg.BulkSize,
+		FlushInterval: time.Duration(cfg.FlushInterval) * time.Millisecond,
+		Policy:        batcher.PolicyDrop,
+	}, flushBulk)
+	bulker.Store(b)
+}
+
+func stopBulk(ctx context.Context) error {
+	b := bulker.Swap(nil)
+	if b == nil {
+		return nil
+	}
+	return b.Close(ctx)
+}
+
+func bulkStats() interface{} {
+	if b := bulker.Load(); b != nil {
+		return b.Stats()
+	}
+	return nil
+}
+
+// Index 异步写入（覆盖）一篇文档
+func Index(ctx context.Context, index, id string, doc interface{}) error {
+	return addBulk(ctx, &BulkItem{Index: index, ID: id, Doc: doc})
+}
+
+// Delete 异步删除一篇文档
+func Delete(ctx context.Context, index, id string) error {
+	return addBulk(ctx, &BulkItem{Index: index, ID: id, Delete: true})
+}
+
+func addBulk(ctx context.Context, item *BulkItem) error {
+	b := bulker.Load()
+	if b == nil {
+		return ErrDisabled
+	}
+	return b.Add(ctx, item)
+}
+
+// Bulk 同步执行一批写入操作，重建索引这类需要知道结果的场景使用
+// 请求成功但部分文档失败时返回失败的条数
+func Bulk(ctx context.Context, items []*BulkItem) (failed int, err error) {
+	if len(items) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, it := range items {
+		meta := map[string]string{"_index": IndexName(it.Index), "_id": it.ID}
+		if it.Delete {
+			if err = enc.Encode(map[string]interface{}{"delete": meta}); err != nil {
+				return
+			}
+			continue
+		}
+		if err = enc.Encode(map[string]interface{}{"index": meta}); err != nil {
+			return
+		}
+		if err = enc.Encode(it.Doc); err != nil {
+			return
+		}
+	}
+
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  *Error `json:"error"`
+		} `json:"items"`
+	}
+	if err = Do(ctx, http.MethodPost, "/_bulk", buf.Bytes(), &resp); err != nil || !resp.Errors {
+		return
+	}
+	for _, item := range resp.Items {
+		for action, r := range item {
+			// 删除不存在的文档返回 404，不算失败
+			if r.Error == nil || (action == "delete" && r.Status == http.StatusNotFound) {
+				continue
+			}
+			failed++
+			zap.L().Named("dao").Warn("es bulk item failed",
+				zap.String("action", action),
+				zap.String("id", r.ID),
+				zap.Int("status", r.Status),
+				zap.String("type", r.Error.Type),
+				zap.String("reason", r.Error.Reason))
+		}
+	}
+	return
+}
+
+var errBulkPartial = errors.New("es: some bulk items failed")
+
+func flushBulk(ctx context.Context, items []*BulkItem) error {
+	failed, err := Bulk(ctx, items)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return errBulkPartial
+	}
+	return nil
+}
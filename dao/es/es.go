@@ -0,0 +1,234 @@
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/pkg/breaker"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/health"
+	"go_web_scaffolding/pkg/retry"
+	"go_web_scaffolding/settings"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 全文搜索用 Elasticsearch，没有开启时 Init 什么都不做，用到的模块自己判断 Enabled()
+// 直接调 REST API，只封装了本项目用到的几个接口：索引管理、批量写入和搜索
+//
+// 配置了多个节点时轮询使用，请求失败（网络错误或 5xx）换下一个节点重试一次
+
+const (
+	defaultTimeout = 5 * time.Second
+	maxErrBody     = 1024
+)
+
+// ErrDisabled 没有开启 es 时返回的错误
+var ErrDisabled = errors.New("es: not enabled")
+
+// Error es 返回的错误响应
+type Error struct {
+	Status int    `json:"status"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("es: %d %s: %s", e.Status, e.Type, e.Reason)
+}
+
+// IsNotFound 索引或文档不存在
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+type client struct {
+	addrs    []string
+	next     atomic.Uint64
+	username string
+	password string
+	prefix   string
+	http     *http.Client
+}
+
+var cli atomic.Pointer[client]
+
+func init() {
+	dashboard.Register("es", func(ctx context.Context) interface{} {
+		c := cli.Load()
+		if c == nil {
+			return map[string]interface{}{"enable": false}
+		}
+		status := map[string]interface{}{"enable": true, "addresses": c.addrs, "bulk": bulkStats(), "healthy": true}
+		if err := Ping(ctx); err != nil {
+			status["healthy"] = false
+			status["error"] = err.Error()
+		}
+		return status
+	})
+}
+
+func Init(cfg *settings.ESConfig) (err error) {
+	if cfg == nil || !cfg.Enable {
+		return
+	}
+	if len(cfg.Addresses) == 0 {
+		return errors.New("es: addresses is required")
+	}
+	timeout := defaultTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Millisecond
+	}
+	c := &client{
+		addrs:    make([]string, 0, len(cfg.Addresses)),
+		username: cfg.Username,
+		password: cfg.Password,
+		prefix:   cfg.IndexPrefix,
+		http:     &http.Client{Timeout: timeout, Transport: breaker.Transport(nil)},
+	}
+	for _, addr := range cfg.Addresses {
+		c.addrs = append(c.addrs, strings.TrimRight(addr, "/"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err = c.do(ctx, http.MethodGet, "/", nil, nil); err != nil {
+		return
+	}
+	cli.Store(c)
+	if err = ensureIndexes(ctx); err != nil {
+		cli.Store(nil)
+		return
+	}
+	startBulk(cfg)
+	health.Register("es", Ping)
+	return
+}
+
+// Close 把批量写入缓冲区里的数据写完
+func Close(ctx context.Context) {
+	if cli.Load() == nil {
+		return
+	}
+	if err := stopBulk(ctx); err != nil {
+		zap.L().Named("dao").Warn("flush es bulk indexer failed", zap.Error(err))
+	}
+}
+
+// Enabled 是否已经连接 es
+func Enabled() bool {
+	return cli.Load() != nil
+}
+
+// Ping 检查 es 是否可用
+func Ping(ctx context.Context) error {
+	return Do(ctx, http.MethodGet, "/_cluster/health?local=true", nil, nil)
+}
+
+// IndexName 加上配置的前缀，多个环境共用一个集群时用前缀区分
+func IndexName(name string) string {
+	if c := cli.Load(); c != nil && c.prefix != "" {
+		return c.prefix + name
+	}
+	return name
+}
+
+// Do 调用 es 的 REST API，body 为 []byte 时原样发送，其它非 nil 值序列化为 JSON，out 不为 nil 时反序列化响应
+func Do(ctx context.Context, method, path string, body, out interface{}) error {
+	c := cli.Load()
+	if c == nil {
+		return ErrDisabled
+	}
+	return c.do(ctx, method, path, body, out)
+}
+
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) (err error) {
+	var data []byte
+	contentType := "application/json"
+	switch v := body.(type) {
+	case nil:
+	case []byte:
+		data = v
+		// _bulk 接口要求 ndjson
+		if strings.HasSuffix(strings.SplitN(path, "?", 2)[0], "/_bulk") {
+			contentType = "application/x-ndjson"
+		}
+	default:
+		if data, err = json.Marshal(v); err != nil {
+			return
+		}
+	}
+
+	start, i := c.next.Add(1), uint64(0)
+	policy := retry.Policy{MaxAttempts: min(2, len(c.addrs)), Initial: 50 * time.Millisecond}
+	return retry.Do(ctx, policy, func(ctx context.Context) error {
+		addr := c.addrs[(start+i)%uint64(len(c.addrs))]
+		i++
+		again, err := c.send(ctx, method, addr+path, contentType, data, out)
+		if !again {
+			return retry.Permanent(err)
+		}
+		return err
+	})
+}
+
+// send 发送一次请求，网络错误和 5xx 返回 again 为 true
+func (c *client) send(ctx context.Context, method, url, contentType string, data []byte, out interface{}) (again bool, err error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return false, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, decodeError(resp)
+	}
+	if out == nil || method == http.MethodHead {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	return false, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// decodeError 解析 {"error": {"type": ..., "reason": ...}, "status": 404} 格式的错误
+func decodeError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrBody))
+	e := &Error{Status: resp.StatusCode}
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) == nil && len(body.Error) > 0 {
+		var detail struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		}
+		if json.Unmarshal(body.Error, &detail) == nil && detail.Type != "" {
+			e.Type, e.Reason = detail.Type, detail.Reason
+			return e
+		}
+	}
+	e.Type, e.Reason = http.StatusText(resp.StatusCode), string(raw)
+	return e
+}
@@ -0,0 +1,81 @@
+package es
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// 用到的索引在 init 里用 RegisterIndex 声明 mapping，Init 时不存在的索引会自动创建
+// 已经存在的索引不会修改 mapping，改字段类型需要新建索引后 reindex
+
+var (
+	indexMu sync.Mutex
+	indexes = make(map[string]map[string]interface{})
+)
+
+// RegisterIndex 声明索引及其 settings/mappings，name 不带前缀
+func RegisterIndex(name string, body map[string]interface{}) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	indexes[name] = body
+}
+
+func ensureIndexes(ctx context.Context) error {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	for name, body := range indexes {
+		created, err := EnsureIndex(ctx, name, body)
+		if err != nil {
+			return err
+		}
+		if created {
+			zap.L().Named("dao").Info("es index created", zap.String("index", IndexName(name)))
+		}
+	}
+	return nil
+}
+
+// IndexExists 索引是否存在
+func IndexExists(ctx context.Context, name string) (bool, error) {
+	err := Do(ctx, http.MethodHead, "/"+url.PathEscape(IndexName(name)), nil, nil)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CreateIndex 创建索引，body 为 settings/mappings
+func CreateIndex(ctx context.Context, name string, body map[string]interface{}) error {
+	return Do(ctx, http.MethodPut, "/"+url.PathEscape(IndexName(name)), body, nil)
+}
+
+// EnsureIndex 索引不存在时创建，多个实例同时启动时别人先创建了也不算错误
+func EnsureIndex(ctx context.Context, name string, body map[string]interface{}) (created bool, err error) {
+	exist, err := IndexExists(ctx, name)
+	if err != nil || exist {
+		return false, err
+	}
+	err = CreateIndex(ctx, name, body)
+	if e, ok := err.(*Error); ok && e.Type == "resource_already_exists_exception" {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DeleteIndex 删除索引，不存在时不报错
+func DeleteIndex(ctx context.Context, name string) error {
+	err := Do(ctx, http.MethodDelete, "/"+url.PathEscape(IndexName(name)), nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Refresh 让刚写入的文档立即可以被搜索到，一般只在测试和重建索引后使用
+func Refresh(ctx context.Context, name string) error {
+	return Do(ctx, http.MethodPost, "/"+url.PathEscape(IndexName(name))+"/_refresh", nil, nil)
+}
@@ -0,0 +1,54 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// SearchResult 搜索结果
+type SearchResult struct {
+	Total int64
+	Hits  []Hit
+}
+
+// Hit 一条命中的文档，Highlight 为高亮片段，key 是字段名
+type Hit struct {
+	ID        string
+	Score     float64
+	Source    json.RawMessage
+	Highlight map[string][]string
+}
+
+// Bind 把文档内容反序列化到 v
+func (h *Hit) Bind(v interface{}) error {
+	return json.Unmarshal(h.Source, v)
+}
+
+// Search 在索引上执行查询，body 为完整的 search 请求体（query、from、size、highlight 等）
+func Search(ctx context.Context, index string, body map[string]interface{}) (res *SearchResult, err error) {
+	var resp struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				ID        string              `json:"_id"`
+				Score     float64             `json:"_score"`
+				Source    json.RawMessage     `json:"_source"`
+				Highlight map[string][]string `json:"highlight"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	// track_total_hits 默认只精确统计到 10000，分页展示总数需要精确值
+	path := "/" + url.PathEscape(IndexName(index)) + "/_search?track_total_hits=true"
+	if err = Do(ctx, http.MethodPost, path, body, &resp); err != nil {
+		return
+	}
+	res = &SearchResult{Total: resp.Hits.Total.Value, Hits: make([]Hit, 0, len(resp.Hits.Hits))}
+	for _, h := range resp.Hits.Hits {
+		res.Hits = append(res.Hits, Hit{ID: h.ID, Score: h.Score, Source: h.Source, Highlight: h.Highlight})
+	}
+	return
+}
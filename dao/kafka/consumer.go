@@ -0,0 +1,360 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/retry"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// Message 消费到的一条消息
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       string
+	Value     []byte
+	Headers   map[string]string
+	Timestamp time.Time
+	// Retried 已经重试过的次数，第一次处理时为 0
+	Retried int
+}
+
+// Bind 把 JSON 格式的 Value 反序列化到 v
+func (m *Message) Bind(v interface{}) error {
+	return json.Unmarshal(m.Value, v)
+}
+
+// Handler 返回 nil 时标记 offset；返回错误时按退避重试，重试用完后写入死信 topic
+type Handler func(ctx context.Context, msg *Message) error
+
+// DeadLetterTopic 死信 topic 的名字
+func DeadLetterTopic(topic string) string {
+	return topic + ".dlq"
+}
+
+// group 一个消费组，同一个组的所有 topic 共用一个 sarama.ConsumerGroup
+type group struct {
+	name     string
+	handlers map[string]Handler
+	cg       sarama.ConsumerGroup
+	policy   retry.Policy
+
+	mu sync.Mutex
+	// claims 当前分配到本实例的分区，rebalance 时更新
+	claims map[string][]int32
+}
+
+var (
+	groupsMu sync.Mutex
+	groups   = make(map[string]*group)
+	cancel   context.CancelFunc
+	runWg    sync.WaitGroup
+)
+
+// Handle 注册 topic 在消费组 groupName 下的处理函数，需要在 Start 之前调用
+func Handle(topic, groupName string, h Handler) {
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	g := groups[groupName]
+	if g == nil {
+		g = &group{name: groupName, handlers: make(map[string]Handler)}
+		groups[groupName] = g
+	}
+	g.handlers[topic] = h
+}
+
+// Start 为每个消费组创建消费者并开始消费，没有开启 kafka 时不做任何事
+func Start() {
+	if cfg == nil {
+		return
+	}
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	if len(groups) == 0 {
+		return
+	}
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	for _, g := range groups {
+		conf, err := consumerConfig()
+		if err == nil {
+			g.cg, err = sarama.NewConsumerGroup(cfg.Brokers, g.name, conf)
+		}
+		if err != nil {
+			zap.L().Error("kafka create consumer group failed", zap.String("group", g.name), zap.Error(err))
+			continue
+		}
+		g.policy = retryPolicy()
+		runWg.Add(2)
+		go func(g *group) {
+			defer runWg.Done()
+			g.run(ctx)
+		}(g)
+		go func(g *group) {
+			defer runWg.Done()
+			for err := range g.cg.Errors() {
+				zap.L().Error("kafka consumer error", zap.String("group", g.name), zap.Error(err))
+			}
+		}(g)
+	}
+}
+
+// Stop 停止消费：正在处理的消息处理完，提交 offset 后离开消费组，需要在 Close 之前调用
+func Stop() {
+	if cancel == nil {
+		return
+	}
+	cancel()
+	groupsMu.Lock()
+	for _, g := range groups {
+		if g.cg == nil {
+			continue
+		}
+		if err := g.cg.Close(); err != nil {
+			zap.L().Warn("kafka close consumer group failed", zap.String("group", g.name), zap.Error(err))
+		}
+	}
+	groupsMu.Unlock()
+	runWg.Wait()
+}
+
+func consumerConfig() (*sarama.Config, error) {
+	conf, err := baseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	conf.Consumer.Return.Errors = true
+	conf.Consumer.Offsets.AutoCommit.Enable = true
+	conf.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+
+	c := cfg.Consumer
+	if c == nil {
+		return conf, nil
+	}
+	switch c.InitialOffset {
+	case "", "newest":
+		conf.Consumer.Offsets.Initial = sarama.OffsetNewest
+	case "oldest":
+		conf.Consumer.Offsets.Initial = sarama.OffsetOldest
+	default:
+		return nil, errors.New("kafka: initial_offset must be newest or oldest")
+	}
+	switch c.Rebalance {
+	case "", "sticky":
+	case "range":
+		conf.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRange()}
+	case "roundrobin":
+		conf.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	default:
+		return nil, errors.New("kafka: rebalance must be sticky, range or roundrobin")
+	}
+	if c.SessionTimeout > 0 {
+		conf.Consumer.Group.Session.Timeout = time.Duration(c.SessionTimeout) * time.Second
+		// 心跳间隔要明显小于会话超时，一般取三分之一
+		conf.Consumer.Group.Heartbeat.Interval = conf.Consumer.Group.Session.Timeout / 3
+	}
+	if c.CommitInterval > 0 {
+		conf.Consumer.Offsets.AutoCommit.Interval = time.Duration(c.CommitInterval) * time.Millisecond
+	}
+	return conf, nil
+}
+
+func retryPolicy() retry.Policy {
+	n := defaultMaxRetries
+	if cfg.Consumer != nil && cfg.Consumer.MaxRetries > 0 {
+		n = cfg.Consumer.MaxRetries
+	}
+	return retry.Policy{MaxAttempts: n + 1, Initial: 200 * time.Millisecond, Max: 10 * time.Second, Jitter: 0.2}
+}
+
+func (g *group) run(ctx context.Context) {
+	topics := make([]string, 0, len(g.handlers))
+	for t := range g.handlers {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	for {
+		// Consume 一直阻塞到这一轮会话结束（rebalance 或者 ctx 取消），然后重新加入消费组
+		if err := g.cg.Consume(ctx, topics, g); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			zap.L().Error("kafka consume failed", zap.String("group", g.name), zap.Error(err))
+			sleep(ctx, time.Second)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// Setup 新一轮会话开始，分到了哪些分区
+func (g *group) Setup(sess sarama.ConsumerGroupSession) error {
+	rebalances.WithLabelValues(g.name).Inc()
+	g.mu.Lock()
+	g.claims = sess.Claims()
+	g.mu.Unlock()
+	zap.L().Info("kafka partitions assigned", zap.String("group", g.name), zap.Any("claims", sess.Claims()))
+	return nil
+}
+
+// Cleanup 所有分区的 ConsumeClaim 都返回之后调用，同步提交已经标记的 offset 再交出分区，
+// 接手的实例从这里继续，不会重复处理已经处理完的消息
+func (g *group) Cleanup(sess sarama.ConsumerGroupSession) error {
+	sess.Commit()
+	g.mu.Lock()
+	g.claims = nil
+	g.mu.Unlock()
+	zap.L().Info("kafka partitions revoked", zap.String("group", g.name))
+	return nil
+}
+
+// ConsumeClaim 按顺序处理一个分区的消息，会话结束时处理完当前这条就返回
+func (g *group) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case m, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !g.process(sess, m) {
+				return nil
+			}
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+// process 处理一条消息，返回 false 表示会话已经结束，消息没有标记，由接手的实例重新处理
+func (g *group) process(sess sarama.ConsumerGroupSession, m *sarama.ConsumerMessage) bool {
+	msg := &Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       string(m.Key),
+		Value:     m.Value,
+		Headers:   make(map[string]string, len(m.Headers)),
+		Timestamp: m.Timestamp,
+	}
+	for _, h := range m.Headers {
+		msg.Headers[string(h.Key)] = string(h.Value)
+	}
+	// 处理函数的 ctx 不跟着会话取消，rebalance 时让它把手上这条做完
+	ctx := context.WithoutCancel(sess.Context())
+	if id := msg.Headers[requestIDHeader]; id != "" {
+		ctx = ctxutil.WithRequestID(ctx, id)
+	}
+	log := logger.Module(ctx, "kafka").With(
+		zap.String("group", g.name),
+		zap.String("topic", m.Topic),
+		zap.Int32("partition", m.Partition),
+		zap.Int64("offset", m.Offset))
+
+	h := g.handlers[m.Topic]
+	start := time.Now()
+	attempt := 0
+	// 退避等待用会话的 ctx，rebalance 时不再重试
+	err := retry.Do(sess.Context(), g.policy, func(context.Context) (err error) {
+		msg.Retried = attempt
+		attempt++
+		defer func() {
+			if r := recover(); r != nil {
+				err = retry.Permanent(fmt.Errorf("panic: %v", r))
+			}
+		}()
+		return h(ctx, msg)
+	})
+	consumeDuration.WithLabelValues(m.Topic, g.name).Observe(time.Since(start).Seconds())
+	if err == nil {
+		consumed.WithLabelValues(m.Topic, g.name, "success").Inc()
+		sess.MarkMessage(m, "")
+		return true
+	}
+	if sess.Context().Err() != nil {
+		log.Warn("kafka handler failed during rebalance, leave it to the next owner", zap.Error(err))
+		return false
+	}
+	if !g.deadLetter(sess.Context(), m, err) {
+		return false
+	}
+	consumed.WithLabelValues(m.Topic, g.name, "dead").Inc()
+	log.Error("kafka message moved to dead letter", zap.Int("retried", msg.Retried), zap.Error(err))
+	sess.MarkMessage(m, "")
+	return true
+}
+
+// deadLetter 把消息原样写入死信 topic，带上来源和失败原因；写不进去就一直重试，
+// offset 只能往前提交，跳过这条消息它就丢了
+func (g *group) deadLetter(ctx context.Context, m *sarama.ConsumerMessage, cause error) bool {
+	headers := append([]sarama.RecordHeader{
+		{Key: []byte("dlq_group"), Value: []byte(g.name)},
+		{Key: []byte("dlq_partition"), Value: []byte(strconv.Itoa(int(m.Partition)))},
+		{Key: []byte("dlq_offset"), Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		{Key: []byte("dlq_error"), Value: []byte(cause.Error())},
+	}, derefHeaders(m.Headers)...)
+	for attempt := 1; ; attempt++ {
+		msg := &sarama.ProducerMessage{Topic: DeadLetterTopic(m.Topic), Value: sarama.ByteEncoder(m.Value), Headers: headers}
+		if m.Key != nil {
+			msg.Key = sarama.ByteEncoder(m.Key)
+		}
+		err := send(ctx, msg, true)
+		if err == nil {
+			return true
+		}
+		zap.L().Error("kafka write dead letter failed", zap.String("topic", m.Topic), zap.Int64("offset", m.Offset), zap.Error(err))
+		sleep(ctx, retry.Policy{Max: 30 * time.Second}.Backoff(attempt))
+		if ctx.Err() != nil {
+			return false
+		}
+	}
+}
+
+func derefHeaders(hs []*sarama.RecordHeader) []sarama.RecordHeader {
+	res := make([]sarama.RecordHeader, 0, len(hs))
+	for _, h := range hs {
+		if h != nil {
+			res = append(res, *h)
+		}
+	}
+	return res
+}
+
+func consumerStats() interface{} {
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	res := make([]map[string]interface{}, 0, len(groups))
+	for _, g := range groups {
+		topics := make([]string, 0, len(g.handlers))
+		for t := range g.handlers {
+			topics = append(topics, t)
+		}
+		sort.Strings(topics)
+		g.mu.Lock()
+		res = append(res, map[string]interface{}{
+			"group":  g.name,
+			"topics": topics,
+			"claims": g.claims,
+		})
+		g.mu.Unlock()
+	}
+	return res
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,295 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/health"
+	"go_web_scaffolding/settings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// Kafka 消息队列，没有开启时 Init 什么都不做，Send 返回 ErrDisabled
+//
+//	kafka.Send(ctx, "user_events", userID, event)        // 等 broker 确认后返回，和其它并发的 Send 一起攒批发送
+//	kafka.SendAsync(ctx, "access_log", "", line)         // 放进发送队列就返回，失败只记日志和指标
+//	kafka.Handle("user_events", "mailer", handler)       // Start 之前注册，同一个消费组的实例分摊分区
+//
+// 生产者开启幂等（acks=all），broker 端按 producer id + 序号去重，网络抖动重试不会写出重复消息
+// key 相同的消息进同一个分区，保证顺序；key 为空时随机分区
+//
+// 消费者处理成功后才标记 offset，后台定时提交，所以是至少一次，处理函数要能重复执行
+// 处理失败按退避重试，次数用完或者返回 retry.Permanent 包装的错误时写入死信 topic（<topic>.dlq）再继续往后消费
+// 分区被重新分配（扩缩容、实例重启）时，正在处理的消息处理完、offset 提交之后才交出分区
+//
+// 日志 sink（logger/sink_kafka.go）也用 sarama，但不开幂等，单独创建生产者
+
+// requestIDHeader 消息头里的 request_id，消费时放回 ctx，日志能串起来
+const requestIDHeader = "request_id"
+
+const (
+	defaultSendTimeout = 5 * time.Second
+	defaultMaxRetries  = 3
+)
+
+// ErrDisabled 没有开启 kafka 时返回的错误
+var ErrDisabled = errors.New("kafka: not enabled")
+
+// ErrClosed 已经关闭，不再发送消息
+var ErrClosed = errors.New("kafka: producer closed")
+
+var (
+	cfg      *settings.KafkaConfig
+	client   sarama.Client
+	producer sarama.AsyncProducer
+	timeout  = defaultSendTimeout
+
+	// mu 保护 closed，关闭之后再往 Input 写会 panic
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+)
+
+func init() {
+	dashboard.Register("kafka", func(ctx context.Context) interface{} {
+		if client == nil {
+			return map[string]interface{}{"enable": false}
+		}
+		brokers := make([]string, 0, len(client.Brokers()))
+		for _, b := range client.Brokers() {
+			brokers = append(brokers, b.Addr())
+		}
+		status := map[string]interface{}{"enable": true, "brokers": brokers, "consumers": consumerStats(), "healthy": true}
+		if err := Ping(ctx); err != nil {
+			status["healthy"] = false
+			status["error"] = err.Error()
+		}
+		return status
+	})
+}
+
+// Init 连接 broker 并创建生产者，消费者在 Start 时创建
+func Init(c *settings.KafkaConfig) (err error) {
+	if c == nil || !c.Enable {
+		return
+	}
+	if len(c.Brokers) == 0 {
+		return errors.New("kafka: brokers is required")
+	}
+	conf, err := producerConfig(c)
+	if err != nil {
+		return
+	}
+	if client, err = sarama.NewClient(c.Brokers, conf); err != nil {
+		return fmt.Errorf("kafka: connect: %w", err)
+	}
+	if producer, err = sarama.NewAsyncProducerFromClient(client); err != nil {
+		_ = client.Close()
+		client = nil
+		return fmt.Errorf("kafka: create producer: %w", err)
+	}
+	if c.Producer != nil && c.Producer.Timeout > 0 {
+		timeout = time.Duration(c.Producer.Timeout) * time.Millisecond
+	}
+	cfg = c
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for m := range producer.Successes() {
+			done(m, nil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for e := range producer.Errors() {
+			done(e.Msg, e.Err)
+		}
+	}()
+	health.Register("kafka", Ping)
+	return
+}
+
+// baseConfig 生产者和消费者共用的配置
+func baseConfig(c *settings.KafkaConfig) (*sarama.Config, error) {
+	conf := sarama.NewConfig()
+	if c.Version != "" {
+		v, err := sarama.ParseKafkaVersion(c.Version)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: invalid version %q: %w", c.Version, err)
+		}
+		conf.Version = v
+	}
+	if settings.Conf != nil && settings.Conf.Name != "" {
+		conf.ClientID = settings.Conf.Name
+	}
+	return conf, nil
+}
+
+func producerConfig(c *settings.KafkaConfig) (*sarama.Config, error) {
+	conf, err := baseConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	// 幂等生产的要求：acks=all，同一个连接上只能有一个未确认的请求，否则重试时可能乱序
+	conf.Producer.Idempotent = true
+	conf.Producer.RequiredAcks = sarama.WaitForAll
+	conf.Net.MaxOpenRequests = 1
+	conf.Producer.Retry.Max = defaultMaxRetries
+	conf.Producer.Return.Successes = true
+	conf.Producer.Return.Errors = true
+
+	p := c.Producer
+	if p == nil {
+		return conf, nil
+	}
+	if p.Compression != "" {
+		if err = conf.Producer.Compression.UnmarshalText([]byte(p.Compression)); err != nil {
+			return nil, fmt.Errorf("kafka: invalid compression %q: %w", p.Compression, err)
+		}
+	}
+	if p.MaxRetries > 0 {
+		conf.Producer.Retry.Max = p.MaxRetries
+	}
+	conf.Producer.Flush.Messages = p.BatchSize
+	conf.Producer.Flush.Bytes = p.BatchBytes
+	conf.Producer.Flush.Frequency = time.Duration(p.Linger) * time.Millisecond
+	return conf, nil
+}
+
+// Close 把发送队列里的消息发完后关闭连接，需要在 Stop 之后调用
+func Close() {
+	if client == nil {
+		return
+	}
+	mu.Lock()
+	closed = true
+	mu.Unlock()
+	// AsyncClose 之后 Successes、Errors 发完剩下的结果再关闭，等分发协程退出
+	producer.AsyncClose()
+	wg.Wait()
+	if err := client.Close(); err != nil {
+		zap.L().Warn("kafka close client failed", zap.Error(err))
+	}
+}
+
+// Enabled 是否已经连接 kafka
+func Enabled() bool {
+	return client != nil
+}
+
+// Ping 刷新一次集群元数据，broker 都连不上时返回错误
+func Ping(ctx context.Context) error {
+	if client == nil {
+		return ErrDisabled
+	}
+	return client.RefreshMetadata()
+}
+
+// Send 发送一条消息并等待 broker 确认，payload 为 string/[]byte 时原样发送，其它类型序列化为 JSON
+// 等待时间不超过 producer.timeout；超时返回错误时消息可能已经发出去了
+func Send(ctx context.Context, topic, key string, payload interface{}) error {
+	msg, err := newMessage(ctx, topic, key, payload)
+	if err != nil {
+		return err
+	}
+	return send(ctx, msg, true)
+}
+
+// SendAsync 放进发送队列就返回，发送失败只记日志和指标，适合丢了也没关系的消息
+func SendAsync(ctx context.Context, topic, key string, payload interface{}) error {
+	msg, err := newMessage(ctx, topic, key, payload)
+	if err != nil {
+		return err
+	}
+	return send(ctx, msg, false)
+}
+
+func newMessage(ctx context.Context, topic, key string, payload interface{}) (*sarama.ProducerMessage, error) {
+	var value []byte
+	switch v := payload.(type) {
+	case string:
+		value = []byte(v)
+	case []byte:
+		value = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		value = b
+	}
+	msg := &sarama.ProducerMessage{Topic: topic, Value: sarama.ByteEncoder(value)}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	if id := ctxutil.RequestID(ctx); id != "" {
+		msg.Headers = []sarama.RecordHeader{{Key: []byte(requestIDHeader), Value: []byte(id)}}
+	}
+	return msg, nil
+}
+
+// send 放进生产者的发送队列，wait 为 true 时等待 broker 确认
+func send(ctx context.Context, msg *sarama.ProducerMessage, wait bool) error {
+	if client == nil {
+		return ErrDisabled
+	}
+	var res chan error
+	if wait {
+		res = make(chan error, 1)
+		msg.Metadata = res
+	}
+	if err := push(ctx, msg); err != nil {
+		return err
+	}
+	if !wait {
+		return nil
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case err := <-res:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return fmt.Errorf("kafka: send to %s: %w", msg.Topic, context.DeadlineExceeded)
+	}
+}
+
+func push(ctx context.Context, msg *sarama.ProducerMessage) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+	select {
+	case producer.Input() <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// done 处理 broker 的确认结果，Send 发的消息把结果交回给调用方
+func done(m *sarama.ProducerMessage, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	produced.WithLabelValues(m.Topic, result).Inc()
+	if res, ok := m.Metadata.(chan error); ok && res != nil {
+		res <- err
+		return
+	}
+	if err != nil {
+		zap.L().Error("kafka async send failed", zap.String("topic", m.Topic), zap.Error(err))
+	}
+}
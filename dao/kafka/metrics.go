@@ -0,0 +1,36 @@
+package kafka
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	produced = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "kafka",
+		Name:      "produced_total",
+		Help:      "发送的消息数，result: success/failure",
+	}, []string{"topic", "result"})
+	consumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "kafka",
+		Name:      "consumed_total",
+		Help:      "处理完的消息数，result: success/dead（写入死信 topic）",
+	}, []string{"topic", "group", "result"})
+	consumeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "app",
+		Subsystem: "kafka",
+		Name:      "consume_duration_seconds",
+		Help:      "处理一条消息的耗时，包括重试",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic", "group"})
+	rebalances = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "kafka",
+		Name:      "rebalances_total",
+		Help:      "本实例在消费组里重新分配分区的次数",
+	}, []string{"group"})
+)
+
+// Collectors kafka 指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{produced, consumed, consumeDuration, rebalances}
+}
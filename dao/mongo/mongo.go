@@ -0,0 +1,155 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/health"
+	"go_web_scaffolding/settings"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.uber.org/zap"
+)
+
+// 文档型数据（用户行为事件、审计的原始载荷这类结构不固定的数据）存 MongoDB，
+// 没有开启时 Init 什么都不做，用到的模块自己判断 Enabled()
+//
+//	ctx, cancel := mongo.WithTimeout(ctx)
+//	defer cancel()
+//	_, err := mongo.Collection("user_event").InsertOne(ctx, bson.M{"user_id": uid, "action": "login"})
+//
+// 驱动自带连接池，Client 是并发安全的，整个进程共用一个
+
+const (
+	defaultTimeout = 3 * time.Second
+	closeTimeout   = 5 * time.Second
+)
+
+var (
+	client   atomic.Pointer[mongo.Client]
+	database string
+	timeout  = defaultTimeout
+)
+
+// ErrDisabled 没有开启 mongo 时调用 Ping 等函数返回的错误
+var ErrDisabled = errors.New("mongo: not enabled")
+
+func init() {
+	dashboard.Register("mongo", func(ctx context.Context) interface{} {
+		if !Enabled() {
+			return map[string]interface{}{"enable": false}
+		}
+		status := map[string]interface{}{"enable": true, "database": database, "pool": poolStats(), "healthy": true}
+		if err := Ping(ctx); err != nil {
+			status["healthy"] = false
+			status["error"] = err.Error()
+		}
+		return status
+	})
+}
+
+func Init(cfg *settings.MongoConfig) (err error) {
+	if cfg == nil || !cfg.Enable {
+		return
+	}
+	opts := options.Client().
+		ApplyURI(cfg.URI).
+		SetMonitor(newCommandMonitor()).
+		SetPoolMonitor(poolMonitor)
+	if cfg.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(cfg.MaxPoolSize)
+	}
+	if cfg.MinPoolSize > 0 {
+		opts.SetMinPoolSize(cfg.MinPoolSize)
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		opts.SetMaxConnIdleTime(time.Duration(cfg.MaxConnIdleTime) * time.Second)
+	}
+	if cfg.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(time.Duration(cfg.ConnectTimeout) * time.Millisecond)
+	}
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Millisecond
+	}
+	database = cfg.Database
+
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	c, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return
+	}
+	// Connect 不会真正建立连接，ping 一下主节点，配置错了启动时就能发现
+	if err = c.Ping(ctx, readpref.Primary()); err != nil {
+		_ = c.Disconnect(ctx)
+		return
+	}
+	client.Store(c)
+	health.Register("mongo", Ping)
+	return
+}
+
+// Close 断开连接，等待正在执行的操作完成
+func Close() {
+	c := client.Swap(nil)
+	if c == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	if err := c.Disconnect(ctx); err != nil {
+		zap.L().Named("dao").Warn("close mongo client failed", zap.Error(err))
+	}
+}
+
+// Enabled 是否已经连接 mongo
+func Enabled() bool {
+	return client.Load() != nil
+}
+
+// Ping 检查 mongo 是否可用，给健康检查和管理接口使用
+func Ping(ctx context.Context) error {
+	c := client.Load()
+	if c == nil {
+		return ErrDisabled
+	}
+	return c.Ping(ctx, readpref.Primary())
+}
+
+// Client 返回底层的客户端，没有开启时返回 nil
+func Client() *mongo.Client {
+	return client.Load()
+}
+
+// Database 配置的默认库，传入 name 时返回对应的库
+func Database(name ...string) *mongo.Database {
+	c := client.Load()
+	if c == nil {
+		return nil
+	}
+	if len(name) > 0 && name[0] != "" {
+		return c.Database(name[0])
+	}
+	return c.Database(database)
+}
+
+// Collection 默认库下的集合
+func Collection(name string) *mongo.Collection {
+	db := Database()
+	if db == nil {
+		return nil
+	}
+	return db.Collection(name)
+}
+
+// WithTimeout ctx 没有截止时间时加上配置的操作超时，避免 mongo 卡住时请求一直挂着
+func WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
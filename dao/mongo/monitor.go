@@ -0,0 +1,90 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("go_web_scaffolding/dao/mongo")
+
+// newCommandMonitor ctx 里有链路追踪的 span 时，每条命令记录一个子 span
+// 驱动的开始和结束是两个回调，用 RequestID 把它们对应起来
+func newCommandMonitor() *event.CommandMonitor {
+	var spans sync.Map
+	end := func(id int64, failure string) {
+		v, ok := spans.LoadAndDelete(id)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		if failure != "" {
+			span.RecordError(errors.New(failure))
+			span.SetStatus(codes.Error, failure)
+		}
+		span.End()
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				return
+			}
+			collection, _ := e.Command.Lookup(e.CommandName).StringValueOK()
+			_, span := tracer.Start(ctx, "mongo "+e.CommandName, trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system", "mongodb"),
+					attribute.String("db.name", e.DatabaseName),
+					attribute.String("db.operation", e.CommandName),
+					attribute.String("db.mongodb.collection", collection),
+				))
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			end(e.RequestID, "")
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			end(e.RequestID, e.Failure)
+		},
+	}
+}
+
+// 连接池统计，驱动没有直接暴露，通过 PoolMonitor 的事件累计
+var (
+	connOpen, connInUse     atomic.Int64
+	checkoutFailed, cleared atomic.Int64
+)
+
+var poolMonitor = &event.PoolMonitor{
+	Event: func(e *event.PoolEvent) {
+		switch e.Type {
+		case event.ConnectionCreated:
+			connOpen.Add(1)
+		case event.ConnectionClosed:
+			connOpen.Add(-1)
+		case event.GetSucceeded:
+			connInUse.Add(1)
+		case event.ConnectionReturned:
+			connInUse.Add(-1)
+		case event.GetFailed:
+			checkoutFailed.Add(1)
+		case event.PoolCleared:
+			cleared.Add(1)
+		}
+	},
+}
+
+func poolStats() map[string]int64 {
+	return map[string]int64{
+		"open":            connOpen.Load(),
+		"in_use":          connInUse.Load(),
+		"checkout_failed": checkoutFailed.Load(),
+		"cleared":         cleared.Load(),
+	}
+}
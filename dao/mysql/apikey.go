@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"context"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/pagination"
+	"go_web_scaffolding/pkg/query"
+)
+
+// InsertAPIKey 插入 api key，成功后回填 ID
+func InsertAPIKey(ctx context.Context, k *models.APIKey) (err error) {
+	sqlStr := `insert into api_key(name, prefix, key_hash, scopes, expire_time)
+	values (:name, :prefix, :key_hash, :scopes, :expire_time)`
+	k.ID, err = NamedInsertID(ctx, sqlStr, k)
+	return
+}
+
+// GetAPIKeyByPrefix 按前缀查询 api key，不存在时返回 sql.ErrNoRows
+// 查询结果会被缓存，走主库，避免吊销后从库的旧数据又被写回缓存
+func GetAPIKeyByPrefix(ctx context.Context, prefix string) (k *models.APIKey, err error) {
+	k = new(models.APIKey)
+	sqlStr := `select id, name, prefix, key_hash, scopes, expire_time, revoked, create_time, update_time
+	from api_key where prefix = ?`
+	err = conn(ctx).GetContext(ctx, k, sqlStr, prefix)
+	return
+}
+
+// GetAPIKeyByID 按 ID 查询 api key，不存在时返回 sql.ErrNoRows
+func GetAPIKeyByID(ctx context.Context, id int64) (k *models.APIKey, err error) {
+	k = new(models.APIKey)
+	sqlStr := `select id, name, prefix, key_hash, scopes, expire_time, revoked, create_time, update_time
+	from api_key where id = ?`
+	err = readConn(ctx).GetContext(ctx, k, sqlStr, id)
+	return
+}
+
+// APIKeyListSpec api key 列表允许的排序和筛选字段
+var APIKeyListSpec = &query.Spec{
+	Sorts: map[string]string{
+		"id":          "id",
+		"name":        "name",
+		"create_time": "create_time",
+		"expire_time": "expire_time",
+	},
+	Filters: map[string]query.Field{
+		"name":        {Column: "name", Ops: []query.Op{query.Eq, query.Like}},
+		"revoked":     {Column: "revoked"},
+		"create_time": {Column: "create_time", Ops: []query.Op{query.Gte, query.Lt}},
+	},
+}
+
+// apiKeyColumns api key 列表查询的列
+var apiKeyColumns = []string{"id", "name", "prefix", "key_hash", "scopes", "expire_time", "revoked", "create_time", "update_time"}
+
+// ListAPIKeys 按条件分页查询 api key，默认按 id 倒序
+// 游标模式只能按 id 倒序翻页，忽略自定义排序，多查一条且不统计总数，total 为 0
+func ListAPIKeys(ctx context.Context, q *query.Query, p pagination.Params) (keys []*models.APIKey, total int64, err error) {
+	b := Builder.Select(apiKeyColumns...).From("api_key")
+	if p.IsCursor() {
+		cond, args, e := p.Keyset("id", true)
+		if e != nil {
+			return nil, 0, e
+		}
+		q.And(cond, args...)
+		b = b.Where(q.Filter()).OrderBy("id DESC").Limit(uint64(p.Limit() + 1))
+		err = SelectSQL(ctx, &keys, b)
+		return
+	}
+
+	if err = GetSQL(ctx, &total, Builder.Select("count(*)").From("api_key").Where(q.Filter())); err != nil || total == 0 {
+		return
+	}
+	b = q.Apply(b, "`id` DESC").Limit(uint64(p.Limit())).Offset(uint64(p.Offset()))
+	err = SelectSQL(ctx, &keys, b)
+	return
+}
+
+// UpdateAPIKeySecret 轮换密钥：替换前缀和摘要，旧密钥立即失效
+func UpdateAPIKeySecret(ctx context.Context, id int64, prefix, keyHash string) (err error) {
+	sqlStr := `update api_key set prefix = ?, key_hash = ? where id = ? and revoked = 0`
+	_, err = conn(ctx).ExecContext(ctx, sqlStr, prefix, keyHash, id)
+	return
+}
+
+// RevokeAPIKey 吊销 api key
+func RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	sqlStr := `update api_key set revoked = 1 where id = ?`
+	_, err = conn(ctx).ExecContext(ctx, sqlStr, id)
+	return
+}
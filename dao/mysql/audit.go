@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"context"
+	"go_web_scaffolding/models"
+)
+
+// BatchInsertAuditLogs 多行插入审计日志，行数多时自动拆成多批
+func BatchInsertAuditLogs(ctx context.Context, logs []*models.AuditLog) (err error) {
+	sqlStr := `insert into audit_log(user_id, action, resource, detail, ip, request_id, create_time)
+	values (:user_id, :action, :resource, :detail, :ip, :request_id, :create_time)`
+	_, err = BulkInsert(ctx, sqlStr, logs, 0)
+	return
+}
+
+// BatchInsertDomainEvents 多行插入领域事件
+func BatchInsertDomainEvents(ctx context.Context, events []*models.DomainEvent) (err error) {
+	sqlStr := `insert into domain_event(name, payload, request_id, create_time)
+	values (:name, :payload, :request_id, :create_time)`
+	_, err = BulkInsert(ctx, sqlStr, events, 0)
+	return
+}
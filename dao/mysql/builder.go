@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// 条件、排序是动态的查询用 squirrel 构造，不再手工拼 SQL 字符串：
+//
+//	b := mysql.Builder.Select("id", "name").From("tag").
+//		Where(sq.Eq{"owner_id": uid}).
+//		OrderBy("id DESC").Limit(20)
+//	err = mysql.SelectSQL(ctx, &tags, b)
+//
+// 列表接口的筛选和排序由 pkg/query 解析，用 q.Apply(b, fallback) 加到构造器上
+// 只读的 GetSQL/SelectSQL 走从库（事务里走事务），ExecSQL 走主库
+
+// Builder MySQL 使用 ? 占位符的语句构造器
+var Builder = sq.StatementBuilder.PlaceholderFormat(sq.Question)
+
+// GetSQL 执行构造好的查询，取一行
+func GetSQL(ctx context.Context, dest interface{}, b sq.Sqlizer) error {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return err
+	}
+	return readConn(ctx).GetContext(ctx, dest, query, args...)
+}
+
+// SelectSQL 执行构造好的查询，取多行
+func SelectSQL(ctx context.Context, dest interface{}, b sq.Sqlizer) error {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return err
+	}
+	return readConn(ctx).SelectContext(ctx, dest, query, args...)
+}
+
+// ExecSQL 执行构造好的 insert/update/delete
+func ExecSQL(ctx context.Context, b sq.Sqlizer) (sql.Result, error) {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return conn(ctx).ExecContext(ctx, query, args...)
+}
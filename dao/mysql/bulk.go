@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 批量写入和 IN 查询的辅助函数：
+//
+//	// 多行插入，按 chunk 行一批拆成多条 insert，全部批次在一个事务里
+//	n, err := mysql.BulkInsert(ctx, `insert into tag(name, color) values (:name, :color)`, tags, 0)
+//
+//	// 同一条语句按每个元素执行一次（批量更新），也在一个事务里
+//	n, err = mysql.NamedExecEach(ctx, `update tag set color = :color where id = :id`, tags)
+//
+//	// IN 查询，切片参数展开成 (?, ?, ?)
+//	err = mysql.SelectIn(ctx, &users, `select id, username from user where id in (?)`, ids)
+
+const (
+	// defaultBulkChunk BulkInsert 每批的默认行数
+	defaultBulkChunk = 500
+	// maxPlaceholders MySQL 一条语句最多 65535 个占位符
+	maxPlaceholders = 65535
+)
+
+// BulkInsert 多行插入，query 是单行的 named insert 语句，rows 是结构体或 map 的切片
+// 每批不超过 chunk 行（0 表示 500），同时保证占位符不超过 MySQL 的上限；返回总的影响行数
+func BulkInsert(ctx context.Context, query string, rows interface{}, chunk int) (affected int64, err error) {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("mysql: BulkInsert rows must be a slice, got %T", rows)
+	}
+	n := v.Len()
+	if n == 0 {
+		return 0, nil
+	}
+	if chunk <= 0 {
+		chunk = defaultBulkChunk
+	}
+	// 用第一行算出每行的参数个数
+	_, args, err := sqlx.Named(query, v.Index(0).Interface())
+	if err != nil {
+		return 0, err
+	}
+	if len(args) > 0 && chunk*len(args) > maxPlaceholders {
+		chunk = maxPlaceholders / len(args)
+	}
+
+	err = WithTx(ctx, func(ctx context.Context) error {
+		for i := 0; i < n; i += chunk {
+			end := min(i+chunk, n)
+			res, err := conn(ctx).NamedExecContext(ctx, query, v.Slice(i, end).Interface())
+			if err != nil {
+				return fmt.Errorf("mysql: bulk insert rows [%d, %d): %w", i, end, err)
+			}
+			if c, err := res.RowsAffected(); err == nil {
+				affected += c
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+// NamedExecEach 对 rows 的每个元素执行一次 named 语句，语句只 prepare 一次，全部在一个事务里
+func NamedExecEach(ctx context.Context, query string, rows interface{}) (affected int64, err error) {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("mysql: NamedExecEach rows must be a slice, got %T", rows)
+	}
+	if v.Len() == 0 {
+		return 0, nil
+	}
+	err = WithTx(ctx, func(ctx context.Context) error {
+		tx, _ := TxFromContext(ctx)
+		stmt, err := tx.PrepareNamedContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for i := 0; i < v.Len(); i++ {
+			res, err := stmt.ExecContext(ctx, v.Index(i).Interface())
+			if err != nil {
+				return fmt.Errorf("mysql: exec row %d: %w", i, err)
+			}
+			if c, err := res.RowsAffected(); err == nil {
+				affected += c
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+// In 展开 query 里切片参数对应的 ?，空切片会返回错误（in () 是语法错误），调用方要先判断
+func In(query string, args ...interface{}) (string, []interface{}, error) {
+	return sqlx.In(query, args...)
+}
+
+// SelectIn 带 IN 展开的 SelectContext，走从库
+func SelectIn(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	q, a, err := sqlx.In(query, args...)
+	if err != nil {
+		return err
+	}
+	return readConn(ctx).SelectContext(ctx, dest, q, a...)
+}
+
+// ExecIn 带 IN 展开的 ExecContext，如 delete from t where id in (?)
+func ExecIn(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	q, a, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return conn(ctx).ExecContext(ctx, q, a...)
+}
@@ -0,0 +1,63 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/models"
+	"strings"
+)
+
+// ListCasbinRules 查询全部策略规则
+func ListCasbinRules(ctx context.Context) (rules []*models.CasbinRule, err error) {
+	sqlStr := `select id, ptype, v0, v1, v2, v3, v4, v5 from casbin_rule`
+	err = conn(ctx).SelectContext(ctx, &rules, sqlStr)
+	return
+}
+
+// InsertCasbinRule 插入一条策略规则
+func InsertCasbinRule(ctx context.Context, rule *models.CasbinRule) (err error) {
+	sqlStr := `insert into casbin_rule(ptype, v0, v1, v2, v3, v4, v5)
+	values (:ptype, :v0, :v1, :v2, :v3, :v4, :v5)`
+	_, err = conn(ctx).NamedExecContext(ctx, sqlStr, rule)
+	return
+}
+
+// DeleteCasbinRule 精确删除一条策略规则
+func DeleteCasbinRule(ctx context.Context, rule *models.CasbinRule) (err error) {
+	sqlStr := `delete from casbin_rule
+	where ptype = :ptype and v0 = :v0 and v1 = :v1 and v2 = :v2 and v3 = :v3 and v4 = :v4 and v5 = :v5`
+	_, err = conn(ctx).NamedExecContext(ctx, sqlStr, rule)
+	return
+}
+
+// DeleteCasbinRulesFiltered 按字段过滤删除策略规则
+// 从 v{fieldIndex} 开始依次匹配 values，空字符串表示该字段不参与过滤
+func DeleteCasbinRulesFiltered(ctx context.Context, ptype string, fieldIndex int, values ...string) (err error) {
+	conds := []string{"ptype = ?"}
+	args := []interface{}{ptype}
+	for i, v := range values {
+		if v == "" {
+			continue
+		}
+		conds = append(conds, fmt.Sprintf("v%d = ?", fieldIndex+i))
+		args = append(args, v)
+	}
+	sqlStr := "delete from casbin_rule where " + strings.Join(conds, " and ")
+	_, err = conn(ctx).ExecContext(ctx, sqlStr, args...)
+	return
+}
+
+// ReplaceCasbinRules 在一个事务里清空并重新写入全部策略规则
+func ReplaceCasbinRules(ctx context.Context, rules []*models.CasbinRule) error {
+	return WithTx(ctx, func(ctx context.Context) (err error) {
+		if _, err = conn(ctx).ExecContext(ctx, "delete from casbin_rule"); err != nil {
+			return
+		}
+		if len(rules) > 0 {
+			sqlStr := `insert into casbin_rule(ptype, v0, v1, v2, v3, v4, v5)
+		values (:ptype, :v0, :v1, :v2, :v3, :v4, :v5)`
+			_, err = conn(ctx).NamedExecContext(ctx, sqlStr, rules)
+		}
+		return
+	})
+}
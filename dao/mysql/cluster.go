@@ -0,0 +1,213 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 读写分离：配置了 mysql.replicas 时，只读的 dao 函数通过 readConn(ctx) 轮询选择一个可用的从库，
+// 写操作、事务和没有标记为只读的查询仍然走主库
+//
+// 后台按 ping_interval 定时检查主库和每个从库：
+//   - 从库 ping 失败时暂时摘掉，恢复后自动加回来；从库全部不可用时读请求退回主库
+//   - 主库 ping 失败，或者 @@read_only 为 1（主从切换后旧主库被降级，连接池里还是连着它的旧连接）时，
+//     标记为不可用并清空空闲连接，之后的请求重新建立连接，通过域名连到新的主库，不用等旧连接一个个报错
+//   - 主库的检查结果会反映到 /healthz 和 /readyz
+//
+// 从库有复制延迟，刚写完马上要读到的场景（比如注册后立即查询）用 UsePrimary 强制走主库：
+//
+//	ctx = mysql.UsePrimary(ctx)
+
+const (
+	defaultPingInterval = 5 * time.Second
+	pingTimeout         = 2 * time.Second
+)
+
+var errPrimaryReadOnly = errors.New("mysql primary is read-only")
+
+// cluster 一组主从连接池，profile 切换时整体替换
+type cluster struct {
+	dialect  *dialect
+	primary  *node
+	replicas []*node
+	next     atomic.Uint64
+	// gorm 复用主库连接池，没有开启 GORM 时为 nil
+	gorm *gorm.DB
+
+	interval time.Duration
+	maxIdle  int
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// node 一个数据库实例的连接池和最近一次检查的结果
+type node struct {
+	addr      string
+	db        *sqlx.DB
+	healthy   atomic.Bool
+	failures  atomic.Int64 // 连续失败次数
+	lastErr   atomic.Pointer[error]
+	lastCheck atomic.Int64 // unix 毫秒
+}
+
+func (n *node) err() error {
+	if p := n.lastErr.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+type primaryKey struct{}
+
+// UsePrimary 返回的 ctx 上的只读查询也走主库
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// readConn 只读查询使用的连接：事务里用事务，否则优先用从库
+func readConn(ctx context.Context) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	c := dbp.Load()
+	if c == nil {
+		return getDB()
+	}
+	if force, _ := ctx.Value(primaryKey{}).(bool); force {
+		return c.primary.db
+	}
+	return c.reader()
+}
+
+// reader 从当前开始轮询，返回第一个可用的从库，没有可用的从库时返回主库
+func (c *cluster) reader() *sqlx.DB {
+	n := uint64(len(c.replicas))
+	if n == 0 {
+		return c.primary.db
+	}
+	start := c.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := c.replicas[(start+i)%n]; r.healthy.Load() {
+			return r.db
+		}
+	}
+	return c.primary.db
+}
+
+// start 启动后台健康检查
+func (c *cluster) start() {
+	if c.interval <= 0 {
+		c.interval = defaultPingInterval
+	}
+	c.stop = make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.check()
+			}
+		}
+	}()
+}
+
+func (c *cluster) check() {
+	lg := zap.L().Named("dao")
+	err := c.checkNode(c.primary, true)
+	switch {
+	case err != nil && c.primary.failures.Load() == 1:
+		lg.Error("mysql primary is unhealthy, idle connections dropped", zap.String("addr", c.primary.addr), zap.Error(err))
+	case err == nil && c.primary.failures.Swap(0) > 0:
+		lg.Info("mysql primary recovered", zap.String("addr", c.primary.addr))
+	}
+	for _, r := range c.replicas {
+		err := c.checkNode(r, false)
+		switch {
+		case err != nil && r.failures.Load() == 1:
+			lg.Warn("mysql replica is down, reads fall back to others", zap.String("addr", r.addr), zap.Error(err))
+		case err == nil && r.failures.Swap(0) > 0:
+			lg.Info("mysql replica recovered", zap.String("addr", r.addr))
+		}
+	}
+}
+
+// checkNode ping 一次，主库额外检查 read_only；失败时清空空闲连接，下次取连接时重新建立
+func (c *cluster) checkNode(n *node, primary bool) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	if err = n.db.PingContext(ctx); err == nil && primary {
+		var readOnly bool
+		if err = n.db.QueryRowContext(ctx, c.dialect.readOnlyQuery).Scan(&readOnly); err == nil && readOnly {
+			err = errPrimaryReadOnly
+		}
+	}
+	n.lastCheck.Store(time.Now().UnixMilli())
+	if err != nil {
+		n.lastErr.Store(&err)
+		n.healthy.Store(false)
+		n.failures.Add(1)
+		c.resetIdle(n.db)
+		return
+	}
+	n.lastErr.Store(nil)
+	n.healthy.Store(true)
+	return
+}
+
+// resetIdle 关闭连接池里的全部空闲连接，正在使用的连接归还时按 ConnMaxLifetime 淘汰
+func (c *cluster) resetIdle(db *sqlx.DB) {
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(c.maxIdle)
+}
+
+// close 停止健康检查并关闭全部连接池，Close 会等待已开始的查询执行完
+func (c *cluster) close() error {
+	if c.stop != nil {
+		close(c.stop)
+		c.wg.Wait()
+	}
+	var errs []error
+	if c.primary != nil {
+		errs = append(errs, c.primary.db.Close())
+	}
+	for _, r := range c.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (n *node) status() map[string]interface{} {
+	s := map[string]interface{}{
+		"addr":    n.addr,
+		"healthy": n.healthy.Load(),
+		"stats":   n.db.Stats(),
+	}
+	if ms := n.lastCheck.Load(); ms > 0 {
+		s["last_check"] = time.UnixMilli(ms)
+	}
+	if err := n.err(); err != nil {
+		s["error"] = err.Error()
+		s["failures"] = n.failures.Load()
+	}
+	return s
+}
+
+func (c *cluster) replicaStatus() []map[string]interface{} {
+	status := make([]map[string]interface{}, 0, len(c.replicas))
+	for _, r := range c.replicas {
+		status = append(status, r.status())
+	}
+	return status
+}
@@ -0,0 +1,186 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/pkg/health"
+	"go_web_scaffolding/settings"
+	"sort"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// 除了默认库之外，还可以在 mysql.databases 下配置多个逻辑库，每个库有自己的连接池：
+//
+//	mysql:
+//	  databases:
+//	    analytics:
+//	      db_name: "analytics"
+//	      max_open_conns: 5
+//
+//	db := mysql.DB("analytics")
+//
+// 没有配置的字段（地址、账号、连接池大小）沿用默认库的配置，从库不继承
+// 这些库不参与 profile 切换，本包的 dao 函数和 WithTx 只操作默认库
+
+var (
+	namedMu sync.RWMutex
+	named   = make(map[string]*cluster)
+)
+
+// initDatabases 连接全部命名库，任何一个连不上都返回错误并关闭已经建立的连接
+func initDatabases(ctx context.Context, base *settings.MySQLConfig) (err error) {
+	dbs := make(map[string]*cluster, len(base.Databases))
+	defer func() {
+		if err != nil {
+			for _, c := range dbs {
+				_ = c.close()
+			}
+		}
+	}()
+	for name, cfg := range base.Databases {
+		if cfg == nil {
+			cfg = new(settings.MySQLConfig)
+		}
+		c, err := connect(ctx, inherit(cfg, base))
+		if err != nil {
+			return fmt.Errorf("mysql database %q: %w", name, err)
+		}
+		dbs[name] = c
+	}
+
+	namedMu.Lock()
+	old := named
+	named = dbs
+	namedMu.Unlock()
+	for _, c := range old {
+		_ = c.close()
+	}
+	for name := range dbs {
+		health.Register("mysql."+name, func(ctx context.Context) error {
+			db := namedDB(name)
+			if db == nil {
+				return fmt.Errorf("mysql database %q is closed", name)
+			}
+			return db.PingContext(ctx)
+		})
+	}
+	return
+}
+
+// inherit 没有配置的字段使用默认库的配置，从库只用命名库自己配置的，命名库和分表规则不继承
+func inherit(cfg, base *settings.MySQLConfig) *settings.MySQLConfig {
+	c := *cfg
+	if c.Driver == "" {
+		c.Driver = base.Driver
+	}
+	if c.Host == "" {
+		c.Host, c.Port = base.Host, base.Port
+	}
+	if c.User == "" {
+		c.User, c.Password = base.User, base.Password
+	}
+	if c.DbName == "" {
+		c.DbName = base.DbName
+	}
+	if c.MaxOpenConns == 0 {
+		c.MaxOpenConns = base.MaxOpenConns
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = base.MaxIdleConns
+	}
+	if c.ConnMaxLifetime == 0 {
+		c.ConnMaxLifetime = base.ConnMaxLifetime
+	}
+	if c.ConnMaxIdleTime == 0 {
+		c.ConnMaxIdleTime = base.ConnMaxIdleTime
+	}
+	if c.InitRetries == 0 {
+		c.InitRetries = base.InitRetries
+	}
+	if c.PingInterval == 0 {
+		c.PingInterval = base.PingInterval
+	}
+	if c.SlowThreshold == 0 {
+		c.SlowThreshold = base.SlowThreshold
+	}
+	// DSN 参数，漏掉的话命名库会悄悄用上明文连接、错误的字符集或时区
+	if c.ConnectTimeout == 0 {
+		c.ConnectTimeout = base.ConnectTimeout
+	}
+	if c.ReadTimeout == 0 {
+		c.ReadTimeout = base.ReadTimeout
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = base.WriteTimeout
+	}
+	if c.Charset == "" {
+		c.Charset = base.Charset
+	}
+	if c.Collation == "" {
+		c.Collation = base.Collation
+	}
+	if c.Loc == "" {
+		c.Loc = base.Loc
+	}
+	if c.TimeZone == "" {
+		c.TimeZone = base.TimeZone
+	}
+	if c.TLS == "" {
+		c.TLS, c.TLSCA = base.TLS, base.TLSCA
+	}
+	// bool 区分不了没配置和配置成 false，默认库开启时命名库也开启
+	c.InterpolateParams = c.InterpolateParams || base.InterpolateParams
+	c.Databases = nil
+	c.Shards = nil
+	return &c
+}
+
+func namedDB(name string) *sqlx.DB {
+	namedMu.RLock()
+	defer namedMu.RUnlock()
+	if c, ok := named[name]; ok {
+		return c.primary.db
+	}
+	return nil
+}
+
+// Databases 已配置的命名库
+func Databases() []string {
+	namedMu.RLock()
+	defer namedMu.RUnlock()
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func databasesStatus() map[string]interface{} {
+	namedMu.RLock()
+	defer namedMu.RUnlock()
+	status := make(map[string]interface{}, len(named))
+	for name, c := range named {
+		s := c.primary.status()
+		if len(c.replicas) > 0 {
+			s["replicas"] = c.replicaStatus()
+		}
+		status[name] = s
+	}
+	return status
+}
+
+func closeDatabases() {
+	namedMu.Lock()
+	dbs := named
+	named = make(map[string]*cluster)
+	namedMu.Unlock()
+	for name, c := range dbs {
+		if err := c.close(); err != nil {
+			zap.L().Named("dao").Warn("close mysql database failed", zap.String("name", name), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/settings"
+	"strings"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+// 关系库除了 MySQL 还支持 PostgreSQL，配置 mysql.driver: postgres 即可（包名和配置段沿用 mysql）
+// 另外还有只给 local 模式用的 SQLite，本地开发不需要装数据库，见 dsn_sqlite.go
+//
+// dao 里的 SQL 统一按 MySQL 的写法：? 占位符、反引号包标识符。用 PostgreSQL 时在驱动包装层改写成 $1、$2 和双引号，
+// 所以 dao 函数、squirrel、dao/repo 都不用区分数据库。少数语法差异较大的地方用 Driver() 判断：
+//
+//   - 自增 ID：PostgreSQL 不支持 LastInsertId，插入后要拿 ID 的用 InsertID / NamedInsertID（自动加 RETURNING id）
+//   - ON DUPLICATE KEY UPDATE、DELETE ... LIMIT 这类 MySQL 独有的语法需要分别写
+//   - 表名、列名是 PostgreSQL 关键字时（如 user）在 SQL 里用反引号包起来
+//
+// PostgreSQL 的 ? 还是 jsonb 的操作符，这样的 SQL 请写成 jsonb_exists 等函数
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// dialect 不同数据库的差异
+type dialect struct {
+	name string
+	// readOnlyQuery 主库健康检查时判断是否已经变成只读（被降级为从库）
+	readOnlyQuery string
+	// rebind 把 MySQL 风格的 SQL 改写成本数据库的写法，nil 表示不需要改写
+	rebind func(query string) string
+	// connector 按配置创建驱动的 Connector，同时返回用于日志和指标的地址
+	connector func(cfg *settings.MySQLConfig, host string, port int, user, password string) (driver.Connector, string, error)
+	// utc 为 true 时 time.Time 参数先转成 UTC 再交给驱动，数据库里的时间没有时区信息时用
+	utc bool
+}
+
+var dialects = map[string]*dialect{
+	DriverMySQL: {
+		name:          DriverMySQL,
+		readOnlyQuery: "SELECT @@global.read_only",
+		connector:     mysqlConnector,
+	},
+	DriverPostgres: {
+		name:          DriverPostgres,
+		readOnlyQuery: "SELECT pg_is_in_recovery()",
+		rebind:        rebindDollar,
+		connector:     postgresConnector,
+	},
+	// SQLite 认 ? 占位符和反引号，不需要改写；单文件没有主从，健康检查只 ping
+	DriverSQLite: {
+		name:          DriverSQLite,
+		readOnlyQuery: "SELECT 0",
+		connector:     sqliteConnector,
+		utc:           true,
+	},
+}
+
+func dialectOf(cfg *settings.MySQLConfig) (*dialect, error) {
+	name := cfg.Driver
+	if name == "" {
+		name = DriverMySQL
+	}
+	d, ok := dialects[name]
+	if !ok {
+		return nil, fmt.Errorf("mysql: unsupported driver %q", cfg.Driver)
+	}
+	return d, nil
+}
+
+func mysqlConnector(cfg *settings.MySQLConfig, host string, port int, user, password string) (driver.Connector, string, error) {
+	dc, err := driverConfig(cfg, host, port, user, password)
+	if err != nil {
+		return nil, "", err
+	}
+	c, err := gomysql.NewConnector(dc)
+	return c, dc.Addr, err
+}
+
+// Driver 默认库使用的数据库，mysql、postgres 或 sqlite
+func Driver() string {
+	if c := dbp.Load(); c != nil {
+		return c.dialect.name
+	}
+	return DriverMySQL
+}
+
+// IsDuplicate 错误是否是违反了唯一索引，先查后插的地方用它兜住并发插入
+func IsDuplicate(err error) bool {
+	var me *gomysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == 1062
+	}
+	var pe *pgconn.PgError
+	if errors.As(err, &pe) {
+		return pe.Code == "23505"
+	}
+	// SQLite 驱动没有导出错误码，按错误信息判断
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// InsertID 执行 insert 并返回自增主键：MySQL、SQLite 取 LastInsertId，PostgreSQL 在语句后面加 RETURNING id
+func InsertID(ctx context.Context, query string, args ...interface{}) (id int64, err error) {
+	if Driver() == DriverPostgres {
+		err = conn(ctx).QueryRowxContext(ctx, query+" RETURNING id", args...).Scan(&id)
+		return
+	}
+	res, err := conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	return res.LastInsertId()
+}
+
+// NamedInsertID 同 InsertID，参数用 :name 的形式从 arg 里取
+func NamedInsertID(ctx context.Context, query string, arg interface{}) (int64, error) {
+	q, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return 0, err
+	}
+	return InsertID(ctx, q, args...)
+}
+
+// rebindDollar ? 改成 $1、$2...，反引号改成双引号，字符串常量和注释里的内容不动
+func rebindDollar(query string) string {
+	if !strings.ContainsAny(query, "?`") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			// 原样复制到配对的引号，'' 转义的引号会被当成两段字符串，结果一样
+			j := strings.IndexByte(query[i+1:], ch)
+			if j < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+j+2])
+			i += j + 1
+		case ch == '-' && i+1 < len(query) && query[i+1] == '-':
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+j+1])
+			i += j
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(fmt.Sprint(n))
+		case ch == '`':
+			b.WriteByte('"')
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
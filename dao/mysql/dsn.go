@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"go_web_scaffolding/settings"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+const defaultCharset = "utf8mb4"
+
+// driverConfig 按配置生成驱动的连接参数，user/password/host/port 单独传入，从库和命名库可以覆盖
+// 各选项的含义见 https://github.com/go-sql-driver/mysql#parameters
+func driverConfig(cfg *settings.MySQLConfig, host string, port int, user, password string) (*gomysql.Config, error) {
+	c := gomysql.NewConfig()
+	c.User = user
+	c.Passwd = password
+	c.Net = "tcp"
+	c.Addr = net.JoinHostPort(host, strconv.Itoa(port))
+	c.DBName = cfg.DbName
+	c.ParseTime = true
+	c.InterpolateParams = cfg.InterpolateParams
+
+	c.Timeout = time.Duration(cfg.ConnectTimeout) * time.Millisecond
+	c.ReadTimeout = time.Duration(cfg.ReadTimeout) * time.Millisecond
+	c.WriteTimeout = time.Duration(cfg.WriteTimeout) * time.Millisecond
+
+	charset := cfg.Charset
+	if charset == "" {
+		charset = defaultCharset
+	}
+	c.Params = map[string]string{"charset": charset}
+	c.Collation = cfg.Collation
+	if cfg.TimeZone != "" {
+		// 会话变量，值需要带引号
+		c.Params["time_zone"] = "'" + cfg.TimeZone + "'"
+	}
+	if cfg.Loc != "" {
+		loc, err := time.LoadLocation(cfg.Loc)
+		if err != nil {
+			return nil, fmt.Errorf("mysql loc: %w", err)
+		}
+		c.Loc = loc
+	}
+
+	if err := setTLS(c, cfg, host); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// setTLS tls 可选 true、false、skip-verify、preferred，配置了 tls_ca 时用指定的 CA 校验服务端证书
+func setTLS(c *gomysql.Config, cfg *settings.MySQLConfig, host string) error {
+	if cfg.TLSCA == "" {
+		c.TLSConfig = cfg.TLS
+		return nil
+	}
+	pem, err := os.ReadFile(cfg.TLSCA)
+	if err != nil {
+		return fmt.Errorf("mysql tls_ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("mysql tls_ca: no certificate found in %s", cfg.TLSCA)
+	}
+	c.TLS = &tls.Config{
+		RootCAs:            pool,
+		ServerName:         host,
+		InsecureSkipVerify: cfg.TLS == "skip-verify",
+		MinVersion:         tls.VersionTLS12,
+	}
+	return nil
+}
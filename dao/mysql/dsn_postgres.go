@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"go_web_scaffolding/settings"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresConnector 按配置生成 pgx 的连接参数，和 MySQL 共用同一套配置项：
+//
+//	connect_timeout  => connect_timeout（向上取整到秒）
+//	time_zone        => 会话参数 timezone
+//	tls              => sslmode：true 为 verify-full，false 为 disable，skip-verify 为 require，preferred 为 prefer
+//	tls_ca           => sslrootcert
+//
+// charset、collation、loc、read_timeout、write_timeout、interpolate_params 只对 MySQL 生效
+func postgresConnector(cfg *settings.MySQLConfig, host string, port int, user, password string) (driver.Connector, string, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	u := &url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(user, password),
+		Host:   addr,
+		Path:   "/" + cfg.DbName,
+	}
+	q := url.Values{}
+	if cfg.ConnectTimeout > 0 {
+		q.Set("connect_timeout", strconv.Itoa((cfg.ConnectTimeout+999)/1000))
+	}
+	if cfg.TimeZone != "" {
+		q.Set("timezone", cfg.TimeZone)
+	}
+	switch cfg.TLS {
+	case "":
+	case "true":
+		q.Set("sslmode", "verify-full")
+	case "false":
+		q.Set("sslmode", "disable")
+	case "skip-verify":
+		q.Set("sslmode", "require")
+	case "preferred":
+		q.Set("sslmode", "prefer")
+	default:
+		return nil, "", fmt.Errorf("postgres: unsupported tls %q", cfg.TLS)
+	}
+	if cfg.TLSCA != "" {
+		q.Set("sslrootcert", cfg.TLSCA)
+	}
+	u.RawQuery = q.Encode()
+
+	cc, err := pgx.ParseConfig(u.String())
+	if err != nil {
+		return nil, "", fmt.Errorf("postgres config: %w", err)
+	}
+	return stdlib.GetConnector(*cc), addr, nil
+}
@@ -0,0 +1,50 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"go_web_scaffolding/settings"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+)
+
+// sqliteConnector 本地开发用的 SQLite，db_name 是数据库文件路径，文件和目录不存在时自动创建
+// host、port、账号密码和 DSN 参数都不需要，连接参数固定为：
+//
+//	busy_timeout(5000)   写锁被占用时最多等 5 秒，而不是直接返回 database is locked
+//	journal_mode(WAL)    读写互不阻塞
+//	_txlock=immediate    事务开始时就拿写锁，避免两个事务都从读锁升级写锁时死锁
+//
+// 驱动是纯 Go 实现（modernc.org/sqlite），不需要 cgo 和 gcc，CGO_ENABLED=0 也能编译
+// 驱动把不带时区的 DATETIME 按 UTC 解析，所以建表默认值用 datetime('now')，
+// 写入的 time.Time 也在 hookConn 里统一转成 UTC（见 dialect.utc），按字符串比较时间时才不会错位
+func sqliteConnector(cfg *settings.MySQLConfig, host string, port int, user, password string) (driver.Connector, string, error) {
+	path := cfg.DbName
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, "", err
+		}
+	}
+	q := url.Values{}
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Set("_txlock", "immediate")
+	return dsnConnector{dsn: "file:" + path + "?" + q.Encode(), driver: &gosqlite.Driver{}}, path, nil
+}
+
+// dsnConnector 把只实现了 Open(dsn) 的驱动包装成 driver.Connector
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
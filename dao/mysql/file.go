@@ -0,0 +1,34 @@
+package mysql
+
+import (
+	"context"
+	"go_web_scaffolding/models"
+)
+
+// fileColumns file 查询的列
+const fileColumns = `id, user_id, name, path, size, content_type, sha256, create_time`
+
+// InsertFile 记录上传的文件，成功后回填 ID
+func InsertFile(ctx context.Context, f *models.File) (err error) {
+	sqlStr := `insert into file(user_id, name, path, size, content_type, sha256)
+	values (:user_id, :name, :path, :size, :content_type, :sha256)`
+	f.ID, err = NamedInsertID(ctx, sqlStr, f)
+	return
+}
+
+// GetFileByID 按 ID 查询文件，不存在时返回 sql.ErrNoRows
+func GetFileByID(ctx context.Context, id int64) (f *models.File, err error) {
+	f = new(models.File)
+	// 上传完马上就会来查，走主库避免复制延迟查不到
+	err = conn(ctx).GetContext(ctx, f, `select `+fileColumns+` from file where id = ?`, id)
+	return
+}
+
+// DeleteFile 删除文件记录，只能删自己上传的
+func DeleteFile(ctx context.Context, userID, id int64) (n int64, err error) {
+	res, err := conn(ctx).ExecContext(ctx, `delete from file where id = ? and user_id = ?`, id, userID)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/settings"
+	"time"
+
+	gormsqlite "github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	gormmysql "gorm.io/driver/mysql"
+	gormpostgres "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GORM 模式：CRUD 为主的模块可以用 GORM 写 dao，和 sqlx 写的 dao 并存，按模块自己选
+// GORM 直接复用本包的连接池（同一个 *sql.DB），所以连接配置、慢查询日志、SQL 指标、链路追踪都和 sqlx 一样
+//
+//	func GetTag(ctx context.Context, id int64) (t *models.Tag, err error) {
+//		db, err := mysql.Gorm(ctx)
+//		if err != nil {
+//			return
+//		}
+//		t = new(models.Tag)
+//		err = db.First(t, id).Error
+//		return
+//	}
+//
+// 在 WithTx 里调用时，Gorm(ctx) 返回的 *gorm.DB 使用同一个事务，两种写法的 dao 可以放在一个事务里
+// GORM 的查询都走主库；需要在配置里打开 mysql.gorm.enable
+
+var ErrGormDisabled = errors.New("mysql: gorm is not enabled")
+
+// openGorm 在已有的连接池上创建 *gorm.DB
+func openGorm(d *dialect, db *node, cfg *settings.GormConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch d.name {
+	case DriverPostgres:
+		dialector = gormpostgres.New(gormpostgres.Config{Conn: db.db.DB})
+	case DriverSQLite:
+		dialector = &gormsqlite.Dialector{Conn: db.db.DB}
+	default:
+		dialector = gormmysql.New(gormmysql.Config{Conn: db.db.DB})
+	}
+	gdb, err := gorm.Open(dialector, &gorm.Config{
+		// 事务由 WithTx 管理，单条写操作不需要 GORM 再包一层事务
+		SkipDefaultTransaction: true,
+		PrepareStmt:            cfg.PrepareStmt,
+		Logger:                 gormLogger{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mysql: open gorm: %w", err)
+	}
+	return gdb, nil
+}
+
+// Gorm 返回绑定了 ctx 的 *gorm.DB，ctx 里有 WithTx 开启的事务时使用该事务
+func Gorm(ctx context.Context) (*gorm.DB, error) {
+	c := dbp.Load()
+	if c == nil || c.gorm == nil {
+		return nil, ErrGormDisabled
+	}
+	db := c.gorm.WithContext(ctx)
+	if tx, ok := TxFromContext(ctx); ok {
+		db.Statement.ConnPool = tx.Tx
+	}
+	return db, nil
+}
+
+// gormLogger 把 GORM 的日志转到 zap，SQL 耗时和慢查询已经由驱动包装层记录，这里只记录错误
+type gormLogger struct{}
+
+func (l gormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	logger.Module(ctx, "dao").Info("gorm: " + fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	logger.Module(ctx, "dao").Warn("gorm: " + fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	logger.Module(ctx, "dao").Error("gorm: " + fmt.Sprintf(msg, args...))
+}
+
+// Trace 记录执行失败的 SQL，记录不存在是正常的查询结果，不记录
+func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	sql, rows := fc()
+	logger.Module(ctx, "dao").Warn("gorm query failed",
+		zap.String("sql", compactSQL(sql)),
+		zap.Int64("rows", rows),
+		zap.Duration("duration", time.Since(begin)),
+		zap.Error(err),
+	)
+}
@@ -0,0 +1,215 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"go_web_scaffolding/pkg/breaker"
+	"io"
+	"net"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+// 在数据库驱动外面包一层，每条 SQL 执行完后调用 hookConnector.after，用来记录慢查询和耗时指标
+// 包装顺序为 otelsql -> hook -> mysql/pgx 驱动，database/sql 看到的接口和直接用驱动时一样
+// PostgreSQL 的占位符改写也在这一层做，dao 里的 SQL 不用区分数据库
+// 查询的耗时只算到 QueryContext 返回为止，不包括逐行读取结果的时间
+// 熔断也在这一层：建连接和每条 SQL 执行前先问熔断器，熔断打开时直接返回 *breaker.OpenError
+
+type hookConnector struct {
+	driver.Connector
+	addr string
+	slow time.Duration
+	// rebind 不为 nil 时执行前改写 SQL，见 dialect.go
+	rebind func(string) string
+	// utc 为 true 时 time.Time 参数转成 UTC，见 dialect.go
+	utc bool
+	// breaker 没有开启熔断时为 nil，直接放行
+	breaker *breaker.Breaker
+}
+
+func (c *hookConnector) sql(query string) string {
+	if c.rebind == nil {
+		return query
+	}
+	return c.rebind(query)
+}
+
+// checkNamedValue 先按 utc 转换时间参数，再交给驱动自己的 NamedValueChecker，驱动没有实现时返回 driver.ErrSkip 走默认转换
+func (c *hookConnector) checkNamedValue(v interface{}, nv *driver.NamedValue) error {
+	if t, ok := nv.Value.(time.Time); ok && c.utc {
+		nv.Value = t.UTC()
+	}
+	if checker, ok := v.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *hookConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	done, err := c.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.Connector.Connect(ctx)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return &hookConn{Conn: conn, c: c}, nil
+}
+
+// after 一条 SQL 执行完成；driver.ErrSkip 表示驱动要求 database/sql 换成 prepare 再执行，不算一次查询
+func (c *hookConnector) after(ctx context.Context, query string, args []driver.NamedValue, start time.Time, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+	d := time.Since(start)
+	observeQuery(ctx, c.addr, query, statementName(), d, err)
+	if c.slow > 0 && d >= c.slow {
+		logSlowQuery(ctx, c.addr, query, args, d, err)
+	}
+}
+
+type hookConn struct {
+	driver.Conn
+	c *hookConnector
+}
+
+func (hc *hookConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := hc.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	query = hc.c.sql(query)
+	done, err := hc.c.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	done(err)
+	hc.c.after(ctx, query, args, start, err)
+	return rows, err
+}
+
+func (hc *hookConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := hc.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	query = hc.c.sql(query)
+	done, err := hc.c.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	done(err)
+	hc.c.after(ctx, query, args, start, err)
+	return res, err
+}
+
+func (hc *hookConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	query = hc.c.sql(query)
+	if p, ok := hc.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = hc.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hookStmt{Stmt: stmt, c: hc.c, query: query}, nil
+}
+
+func (hc *hookConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := hc.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return hc.Conn.Begin() //nolint:staticcheck
+}
+
+func (hc *hookConn) Ping(ctx context.Context) error {
+	if p, ok := hc.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (hc *hookConn) ResetSession(ctx context.Context) error {
+	if r, ok := hc.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (hc *hookConn) IsValid() bool {
+	if v, ok := hc.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (hc *hookConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return hc.c.checkNamedValue(hc.Conn, nv)
+}
+
+type hookStmt struct {
+	driver.Stmt
+	c     *hookConnector
+	query string
+}
+
+func (hs *hookStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := hs.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	done, err := hs.c.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, args)
+	done(err)
+	hs.c.after(ctx, hs.query, args, start, err)
+	return rows, err
+}
+
+func (hs *hookStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := hs.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	done, err := hs.c.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, args)
+	done(err)
+	hs.c.after(ctx, hs.query, args, start, err)
+	return res, err
+}
+
+func (hs *hookStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return hs.c.checkNamedValue(hs.Stmt, nv)
+}
+
+// isConnFailure 只有连不上、连接断开、超时这类错误算数据库失败，用来判断是否熔断
+// 主键冲突、语法错误、查不到数据说明数据库是好的；driver.ErrSkip 只是让 database/sql 换一种方式执行
+func isConnFailure(err error) bool {
+	if err == nil || err == driver.ErrSkip {
+		return false
+	}
+	var ne net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, gomysql.ErrInvalidConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &ne)
+}
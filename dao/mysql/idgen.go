@@ -0,0 +1,27 @@
+package mysql
+
+import (
+	"context"
+	"go_web_scaffolding/models"
+)
+
+// AllocIDSegment 为 bizTag 分配下一个号段，返回的号段是 (MaxID-Step, MaxID]
+// 行锁保证多个实例同时取号时拿到的号段不重叠；step 大于 0 时顺便更新步长
+func AllocIDSegment(ctx context.Context, bizTag string, step int64) (seg *models.IDSegment, err error) {
+	err = WithTx(ctx, func(ctx context.Context) (err error) {
+		if step > 0 {
+			_, err = conn(ctx).ExecContext(ctx, `update id_segment set max_id = max_id + ?, step = ? where biz_tag = ?`, step, step, bizTag)
+		} else {
+			_, err = conn(ctx).ExecContext(ctx, `update id_segment set max_id = max_id + step where biz_tag = ?`, bizTag)
+		}
+		if err != nil {
+			return
+		}
+		seg = new(models.IDSegment)
+		return conn(ctx).GetContext(ctx, seg, `select biz_tag, max_id, step, update_time from id_segment where biz_tag = ?`, bizTag)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"go_web_scaffolding/pkg/ctxutil"
+	"strings"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SQL 指标和链路：每条 SQL 按"语句"打标签，语句名是发起查询的函数（一般是 dao 函数，如 dao/mysql.GetUserByID），
+// 同一个函数里的 SQL 是固定的几条，标签基数有上限，不会像用 SQL 文本做标签那样爆炸
+// 再加上请求的路由，就能看出某个接口慢在哪条查询上
+// 指标定义在这里，由 pkg/metrics 注册（metrics 依赖本包采样连接池，反过来会循环引用）
+
+var (
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "app",
+		Subsystem: "mysql",
+		Name:      "query_duration_seconds",
+		Help:      "SQL 执行耗时（秒），statement 为发起查询的函数，route 为请求路由，后台任务为空",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"addr", "op", "statement", "route"})
+	queryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "mysql",
+		Name:      "query_errors_total",
+		Help:      "SQL 执行失败次数",
+	}, []string{"addr", "op", "statement"})
+)
+
+// Collectors SQL 指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{queryDuration, queryErrors}
+}
+
+func observeQuery(ctx context.Context, addr, query, statement string, d time.Duration, err error) {
+	op := sqlOp(query)
+	queryDuration.WithLabelValues(addr, op, statement, ctxutil.Route(ctx)).Observe(d.Seconds())
+	if err != nil && err != driver.ErrBadConn {
+		queryErrors.WithLabelValues(addr, op, statement).Inc()
+	}
+}
+
+// sqlOp SQL 的第一个关键字，如 select、insert
+func sqlOp(query string) string {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	i := strings.IndexAny(query, " \t\r\n")
+	if i < 0 {
+		i = len(query)
+	}
+	switch op := strings.ToLower(query[:i]); op {
+	case "select", "insert", "update", "delete", "replace", "with", "set", "show":
+		return op
+	default:
+		return "other"
+	}
+}
+
+// statementName 发起查询的函数名，找不到时为 unknown
+func statementName() string {
+	if fn, _ := sqlCaller(); fn != "" {
+		return fn
+	}
+	return "unknown"
+}
+
+// spanName 链路里 SQL span 的名字，如 "select dao/mysql.GetUserByID"，比默认的 sql.conn.query 好认
+func spanName(ctx context.Context, method otelsql.Method, query string) string {
+	if query == "" {
+		return string(method)
+	}
+	return sqlOp(query) + " " + statementName()
+}
+
+// spanAttributes 给 SQL span 加上语句名和路由
+func spanAttributes(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("db.statement.name", statementName())}
+	if route := ctxutil.Route(ctx); route != "" {
+		attrs = append(attrs, attribute.String("http.route", route))
+	}
+	return attrs
+}
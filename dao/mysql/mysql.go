@@ -1,39 +1,265 @@
 package mysql
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"go_web_scaffolding/pkg/breaker"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/health"
+	"go_web_scaffolding/pkg/retry"
+	"go_web_scaffolding/pkg/workerpool"
 	"go_web_scaffolding/settings"
+	"sync/atomic"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/XSAM/otelsql"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// 本包所有导出的 dao 函数第一个参数都是 context.Context，内部统一使用 sqlx 的 *Context 方法
+// （GetContext、SelectContext、ExecContext、NamedExecContext 等），
+// 客户端断开或者请求超时后 ctx 被取消，正在执行的 SQL 也会随之取消，不会继续占着连接
+// 新增 dao 函数时不要使用不带 Context 的版本，后台任务没有请求 ctx 时自己用 context.WithTimeout 设置超时
+
+// DefaultDatabase 默认库的名字，DB(DefaultDatabase) 和 DB() 相同
+const DefaultDatabase = "default"
+
 // 小写，不对外暴露
-var db *sqlx.DB
+// 用原子指针保存当前生效的连接池（主库和从库），profile 切换时整体替换，正在执行的查询不受影响
+var dbp atomic.Pointer[cluster]
+
+func init() {
+	dashboard.Register("mysql", func(ctx context.Context) interface{} {
+		status := map[string]interface{}{"stats": Stats(), "healthy": true}
+		if err := Ping(ctx); err != nil {
+			status["healthy"] = false
+			status["error"] = err.Error()
+		}
+		if c := dbp.Load(); c != nil {
+			status["primary"] = c.primary.status()
+			status["slow_queries"] = slowQueries.Load()
+			if len(c.replicas) > 0 {
+				status["replicas"] = c.replicaStatus()
+			}
+		}
+		if dbs := databasesStatus(); len(dbs) > 0 {
+			status["databases"] = dbs
+		}
+		return status
+	})
+	health.Register("mysql", Ping)
+}
 
 func Init(cfg *settings.MySQLConfig) (err error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=true",
-		cfg.User,
-		cfg.Password,
-		cfg.Host,
-		cfg.Port,
-		cfg.DbName,
-	)
-	db, err = sqlx.Connect("mysql", dsn)
+	// 启动时数据库可能还没就绪（比如和应用一起部署），按 init_retries 重试
+	c, err := retry.DoValue(context.Background(), retry.Policy{
+		MaxAttempts: cfg.InitRetries + 1,
+		Initial:     time.Second,
+		Jitter:      0.2,
+		OnRetry: func(attempt int, err error, wait time.Duration) {
+			zap.L().Named("dao").Warn("connect to DB failed, retrying",
+				zap.Int("attempt", attempt), zap.Duration("wait", wait), zap.Error(err))
+		},
+	}, func(ctx context.Context) (*cluster, error) {
+		return connect(ctx, cfg)
+	})
 	if err != nil {
-		zap.L().Error("connect to DB failed", zap.Error(err))
+		zap.L().Named("dao").Error("connect to DB failed", zap.Error(err))
+		return
+	}
+	if err = initDatabases(context.Background(), cfg); err != nil {
+		_ = c.close()
+		zap.L().Named("dao").Error("connect to DB failed", zap.Error(err))
 		return
 	}
+	if err = initShards(cfg.Shards); err != nil {
+		closeDatabases()
+		_ = c.close()
+		zap.L().Named("dao").Error("init mysql shards failed", zap.Error(err))
+		return
+	}
+	dbp.Store(c)
+	return
+}
+
+// getDB 返回当前生效的主库连接池，写操作和事务都走主库
+func getDB() *sqlx.DB {
+	if c := dbp.Load(); c != nil {
+		return c.primary.db
+	}
+	return nil
+}
+
+// DB 返回当前生效的连接池，给 dao/repo 这类通用数据访问工具使用
+// 业务 dao 写在本包里，用 conn(ctx)，这样在 WithTx 里调用时会自动使用事务
+// 传入 name 时返回 mysql.databases 下对应的命名库，没有配置时返回 nil
+func DB(name ...string) *sqlx.DB {
+	if len(name) > 0 && name[0] != "" && name[0] != DefaultDatabase {
+		return namedDB(name[0])
+	}
+	return getDB()
+}
 
+// connect 连接主库和全部从库，主库连不上返回错误，从库连不上只标记为不可用，读请求暂时走主库
+func connect(ctx context.Context, cfg *settings.MySQLConfig) (c *cluster, err error) {
+	d, err := dialectOf(cfg)
+	if err != nil {
+		return
+	}
+	primary, err := open(d, cfg, cfg.Host, cfg.Port, cfg.User, cfg.Password)
+	if err != nil {
+		return
+	}
+	if err = primary.PingContext(ctx); err != nil {
+		_ = primary.Close()
+		return nil, err
+	}
+	c = &cluster{
+		dialect:  d,
+		primary:  &node{addr: fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), db: primary},
+		interval: time.Duration(cfg.PingInterval) * time.Second,
+		maxIdle:  cfg.MaxIdleConns,
+	}
+	c.primary.healthy.Store(true)
+	if cfg.Gorm != nil && cfg.Gorm.Enable {
+		if c.gorm, err = openGorm(d, c.primary, cfg.Gorm); err != nil {
+			_ = primary.Close()
+			return nil, err
+		}
+	}
+	for _, rc := range cfg.Replicas {
+		user, password := rc.User, rc.Password
+		if user == "" {
+			user, password = cfg.User, cfg.Password
+		}
+		r := &node{addr: fmt.Sprintf("%s:%d", rc.Host, rc.Port)}
+		if r.db, err = open(d, cfg, rc.Host, rc.Port, user, password); err != nil {
+			_ = c.close()
+			return nil, err
+		}
+		c.replicas = append(c.replicas, r)
+		if err := r.db.PingContext(ctx); err != nil {
+			zap.L().Named("dao").Warn("connect to mysql replica failed", zap.String("addr", r.addr), zap.Error(err))
+			continue
+		}
+		r.healthy.Store(true)
+	}
+	c.start()
+	return c, nil
+}
+
+// open 建立一个连接池，这时还没有真正连接数据库
+func open(d *dialect, cfg *settings.MySQLConfig, host string, port int, user, password string) (db *sqlx.DB, err error) {
+	connector, addr, err := d.connector(cfg, host, port, user, password)
+	if err != nil {
+		return
+	}
+	hooked := &hookConnector{
+		Connector: connector,
+		addr:      addr,
+		slow:      time.Duration(cfg.SlowThreshold) * time.Millisecond,
+		rebind:    d.rebind,
+		utc:       d.utc,
+		// 熔断配置和 mysql 段一样不区分数据库类型，名字统一是 mysql:<地址>
+		breaker: breaker.Get("mysql:"+addr, isConnFailure),
+	}
+	// otelsql 包装驱动，请求里有 span 时每条 SQL 记录一个子 span，后台任务的 SQL 不单独起链路
+	sqlDB := otelsql.OpenDB(hooked,
+		otelsql.WithAttributes(attribute.String("db.system", d.name), attribute.String("server.address", host)),
+		otelsql.WithSpanNameFormatter(spanName),
+		otelsql.WithAttributesGetter(spanAttributes),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
+	// sqlx 按 MySQL 生成 ? 占位符（Named、In），PostgreSQL 由 hookConnector 统一改写
+	db = sqlx.NewDb(sqlDB, DriverMySQL)
 	db.SetMaxOpenConns(cfg.MaxOpenConns)
 	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	// 连接定期重建，主从切换、负载均衡摘除节点后旧连接最多存活这么久
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
+	db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime) * time.Second)
 	return
 }
 
+// drainDelay profile 切换后旧连接池保留的时间
+// 切换前已经拿到旧连接池、还没开始执行的查询（比如事务中间的下一条 SQL）在这段时间内照常执行，之后再 Close
+const drainDelay = 5 * time.Second
+
+// Prepare 按新配置建立连接池并 ping 校验，成功后返回切换函数和放弃函数
+// cutover 先原子替换当前连接池，旧连接池等 drainDelay 之后在后台 Close（Close 会等待已开始的查询执行完）
+// abort 在其它依赖准备失败时调用，释放刚建立的连接池
+func Prepare(ctx context.Context, cfg *settings.MySQLConfig) (cutover func(), abort func(), err error) {
+	db, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	cutover = func() {
+		old := dbp.Swap(db)
+		if old != nil {
+			// 关机时协程池会等旧连接池排空，ctx 结束（正在关机）时不再等 drainDelay；提交失败就直接在这里关
+			drain := func(ctx context.Context) error {
+				select {
+				case <-time.After(drainDelay):
+				case <-ctx.Done():
+				}
+				if err := old.close(); err != nil {
+					return fmt.Errorf("close old mysql pool: %w", err)
+				}
+				return nil
+			}
+			if err := workerpool.Get("mysql").TrySubmit(ctx, drain); err != nil {
+				time.AfterFunc(drainDelay, func() { _ = old.close() })
+			}
+		}
+	}
+	abort = func() {
+		_ = db.close()
+	}
+	return
+}
+
+// Ping 检查数据库是否可用，给健康检查和管理接口使用
+// 除了实时 ping，后台检查发现主库变成只读（发生了主从切换）时也返回错误
+func Ping(ctx context.Context) error {
+	c := dbp.Load()
+	if c == nil {
+		return sql.ErrConnDone
+	}
+	if err := c.primary.db.PingContext(ctx); err != nil {
+		return err
+	}
+	if err := c.primary.err(); errors.Is(err, errPrimaryReadOnly) {
+		return err
+	}
+	return nil
+}
+
+// Stats 主库连接池统计：打开/使用中/空闲连接数、等待次数和等待时长等
+func Stats() sql.DBStats {
+	db := getDB()
+	if db == nil {
+		return sql.DBStats{}
+	}
+	return db.Stats()
+}
+
 // 小技巧
 // 因为db小写，不对外暴露
 // 可以封装一个Close
 func Close() {
-	_ = db.Close()
+	if c := dbp.Load(); c != nil {
+		_ = c.close()
+	}
+	closeDatabases()
 }
@@ -0,0 +1,49 @@
+package mysql
+
+import (
+	"context"
+	"go_web_scaffolding/models"
+)
+
+// GetUserOAuth 查询第三方账号的绑定关系，不存在时返回 sql.ErrNoRows
+func GetUserOAuth(ctx context.Context, provider, openID string) (o *models.UserOAuth, err error) {
+	o = new(models.UserOAuth)
+	sqlStr := `select id, user_id, provider, open_id, create_time from user_oauth where provider = ? and open_id = ?`
+	err = readConn(ctx).GetContext(ctx, o, sqlStr, provider, openID)
+	return
+}
+
+// ListUserOAuth 查询用户绑定的全部第三方账号
+func ListUserOAuth(ctx context.Context, userID int64) (list []*models.UserOAuth, err error) {
+	sqlStr := `select id, user_id, provider, open_id, create_time from user_oauth where user_id = ?`
+	err = readConn(ctx).SelectContext(ctx, &list, sqlStr, userID)
+	return
+}
+
+// ListUserOAuthByUserIDs 批量查询多个用户绑定的第三方账号
+func ListUserOAuthByUserIDs(ctx context.Context, userIDs []int64) (list []*models.UserOAuth, err error) {
+	if len(userIDs) == 0 {
+		return
+	}
+	sqlStr := `select id, user_id, provider, open_id, create_time from user_oauth where user_id in (?)`
+	err = SelectIn(ctx, &list, sqlStr, userIDs)
+	return
+}
+
+// InsertUserOAuth 绑定第三方账号
+func InsertUserOAuth(ctx context.Context, o *models.UserOAuth) (err error) {
+	sqlStr := `insert into user_oauth(user_id, provider, open_id) values (:user_id, :provider, :open_id)`
+	_, err = conn(ctx).NamedExecContext(ctx, sqlStr, o)
+	return
+}
+
+// CreateUserWithOAuth 在一个事务里创建用户并绑定第三方账号，UserID 由调用方生成
+func CreateUserWithOAuth(ctx context.Context, u *models.User, o *models.UserOAuth) error {
+	return WithTx(ctx, func(ctx context.Context) error {
+		if err := InsertUser(ctx, u); err != nil {
+			return err
+		}
+		o.UserID = u.UserID
+		return InsertUserOAuth(ctx, o)
+	})
+}
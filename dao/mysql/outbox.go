@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"context"
+	"go_web_scaffolding/models"
+	"time"
+)
+
+// InsertOutbox 写入一条待投递消息，传入业务事务的 ctx 时和业务数据一起提交或回滚
+func InsertOutbox(ctx context.Context, m *models.OutboxMessage) (err error) {
+	sqlStr := `insert into outbox(topic, payload, request_id) values(?, ?, ?)`
+	m.ID, err = InsertID(ctx, sqlStr, m.Topic, m.Payload, m.RequestID)
+	return
+}
+
+// LeaseOutbox 取出最多 limit 条到期的待投递消息，并把它们的下次重试时间推迟 lease
+// 只在短事务里锁定并推迟，发布在事务外进行，不会在发布慢的时候长时间持有行锁和连接；
+// 租期内其它实例不会取到这些行，进程在发布途中退出的话，租期过后由其它实例重新投递
+// SKIP LOCKED 需要 MySQL 8.0 或 PostgreSQL 9.5；SQLite 没有行锁，事务开始时已经拿到整个库的写锁
+func LeaseOutbox(ctx context.Context, limit int, lease time.Duration) (msgs []*models.OutboxMessage, err error) {
+	err = WithTx(ctx, func(ctx context.Context) error {
+		sqlStr := `select id, topic, payload, request_id, status, attempts, last_error, next_retry_time, create_time, update_time
+		from outbox
+		where status = ? and next_retry_time <= ?
+		order by id
+		limit ?`
+		if Driver() != DriverSQLite {
+			sqlStr += " for update skip locked"
+		}
+		if err := conn(ctx).SelectContext(ctx, &msgs, sqlStr, models.OutboxPending, time.Now(), limit); err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+		ids := make([]int64, 0, len(msgs))
+		for _, m := range msgs {
+			ids = append(ids, m.ID)
+		}
+		_, err := ExecIn(ctx, `update outbox set next_retry_time = ? where id in (?)`, time.Now().Add(lease), ids)
+		return err
+	})
+	return
+}
+
+// MarkOutboxSent 标记为已投递
+func MarkOutboxSent(ctx context.Context, ids []int64) (err error) {
+	if len(ids) == 0 {
+		return
+	}
+	_, err = ExecIn(ctx, `update outbox set status = ?, attempts = attempts + 1, last_error = '' where id in (?)`,
+		models.OutboxSent, ids)
+	return
+}
+
+// MarkOutboxFailed 记录一次投递失败，status 为 OutboxPending 时在 next 之后重试，OutboxDead 时不再重试
+func MarkOutboxFailed(ctx context.Context, id int64, status int8, lastErr string, next time.Time) (err error) {
+	sqlStr := `update outbox set status = ?, attempts = attempts + 1, last_error = ?, next_retry_time = ? where id = ?`
+	_, err = conn(ctx).ExecContext(ctx, sqlStr, status, lastErr, next, id)
+	return
+}
+
+// DeleteSentOutbox 删除 before 之前投递成功的消息，每次最多删 limit 行，避免长时间锁表
+func DeleteSentOutbox(ctx context.Context, before time.Time, limit int) (n int64, err error) {
+	sqlStr := `delete from outbox where status = ? and update_time < ? limit ?`
+	if Driver() != DriverMySQL {
+		// PostgreSQL 和 SQLite 的 DELETE 不支持 LIMIT
+		sqlStr = `delete from outbox where id in (select id from outbox where status = ? and update_time < ? limit ?)`
+	}
+	res, err := conn(ctx).ExecContext(ctx, sqlStr, models.OutboxSent, before, limit)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+// CountOutbox 按状态统计消息数，管理接口查看积压情况
+func CountOutbox(ctx context.Context) (counts map[int8]int64, err error) {
+	var rows []struct {
+		Status int8  `db:"status"`
+		N      int64 `db:"n"`
+	}
+	if err = readConn(ctx).SelectContext(ctx, &rows, `select status, count(*) n from outbox group by status`); err != nil {
+		return
+	}
+	counts = make(map[int8]int64, len(rows))
+	for _, r := range rows {
+		counts[r.Status] = r.N
+	}
+	return
+}
@@ -0,0 +1,52 @@
+package mysql
+
+import (
+	"context"
+	"go_web_scaffolding/models"
+)
+
+// UpsertPushDevice 登记设备，token 已经存在时改到当前用户名下（同一台设备换了账号登录）
+func UpsertPushDevice(ctx context.Context, d *models.PushDevice) (err error) {
+	sqlStr := `insert into push_device(user_id, platform, token) values(?, ?, ?)
+	on duplicate key update user_id = values(user_id), platform = values(platform), update_time = CURRENT_TIMESTAMP`
+	if Driver() != DriverMySQL {
+		sqlStr = `insert into push_device(user_id, platform, token) values(?, ?, ?)
+		on conflict (token) do update set user_id = excluded.user_id, platform = excluded.platform`
+	}
+	_, err = conn(ctx).ExecContext(ctx, sqlStr, d.UserID, d.Platform, d.Token)
+	return
+}
+
+// ListPushDevicesByUser 列出用户的设备
+func ListPushDevicesByUser(ctx context.Context, userID int64) (list []*models.PushDevice, err error) {
+	sqlStr := `select id, user_id, platform, token, create_time, update_time from push_device where user_id = ? order by id`
+	err = readConn(ctx).SelectContext(ctx, &list, sqlStr, userID)
+	return
+}
+
+// ListPushDevicesByUsers 批量列出多个用户的设备，推送时使用
+func ListPushDevicesByUsers(ctx context.Context, userIDs []int64) (list []*models.PushDevice, err error) {
+	if len(userIDs) == 0 {
+		return
+	}
+	err = SelectIn(ctx, &list, `select id, user_id, platform, token, create_time, update_time from push_device where user_id in (?)`, userIDs)
+	return
+}
+
+// DeletePushDevice 用户注销设备（退出登录），只能删自己名下的
+func DeletePushDevice(ctx context.Context, userID int64, token string) (n int64, err error) {
+	res, err := conn(ctx).ExecContext(ctx, `delete from push_device where user_id = ? and token = ?`, userID, token)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+// DeletePushTokens 删除推送服务返回已失效的 token（应用卸载、token 过期）
+func DeletePushTokens(ctx context.Context, tokens []string) (err error) {
+	if len(tokens) == 0 {
+		return
+	}
+	_, err = ExecIn(ctx, `delete from push_device where token in (?)`, tokens)
+	return
+}
@@ -0,0 +1,246 @@
+package mysql
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"go_web_scaffolding/settings"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// 分表（可以同时分库）：一张逻辑表拆成 N 张物理表 order_00..order_63，按分片键的哈希路由
+//
+//	mysql:
+//	  shards:
+//	    order:
+//	      tables: 64
+//	      databases: ["order0", "order1"]   # 可选，分表均匀落在这些命名库上，不配置时都在默认库
+//
+//	s, err := mysql.Shard("order")
+//	q, table, err := s.Route(ctx, userID)
+//	err = q.GetContext(ctx, &o, "select * from `"+table+"` where user_id = ? and id = ?", userID, id)
+//
+// 不知道分片键的查询（比如按订单号查订单）只能扫所有分表，用 FanOut 并发执行后合并结果
+//
+//	orders, err := mysql.FanOut[models.Order](ctx, s, "select * from {table} where order_no = ?", no)
+//
+// 分片算法是 FNV-1a，分表数和算法上线后不能再改，否则已有数据会路由到错误的表
+// 同一个分片键的数据总在同一个库，单库时 Route 返回的连接会加入 ctx 里的事务，跨库没有分布式事务
+
+// tablePlaceholder FanOut 的 SQL 里分表名的占位符
+const tablePlaceholder = "{table}"
+
+// fanOutConcurrency FanOut 同时查询的分表数，避免一次占满连接池
+const fanOutConcurrency = 8
+
+// Sharding 一张逻辑表的分片规则
+type Sharding struct {
+	name      string
+	tables    int
+	databases []string
+	format    string
+}
+
+var (
+	shardMu sync.RWMutex
+	shards  = make(map[string]*Sharding)
+)
+
+// initShards 按配置生成分片规则，命名库要先连接好
+func initShards(cfg map[string]*settings.ShardConfig) error {
+	m := make(map[string]*Sharding, len(cfg))
+	for name, c := range cfg {
+		if c == nil || c.Tables <= 0 {
+			return fmt.Errorf("mysql shard %q: tables must be positive", name)
+		}
+		for _, db := range c.Databases {
+			if DB(db) == nil {
+				return fmt.Errorf("mysql shard %q: database %q is not configured", name, db)
+			}
+		}
+		width := len(strconv.Itoa(c.Tables - 1))
+		if width < 2 {
+			width = 2
+		}
+		m[name] = &Sharding{
+			name:      name,
+			tables:    c.Tables,
+			databases: c.Databases,
+			format:    fmt.Sprintf("%%s_%%0%dd", width),
+		}
+	}
+	shardMu.Lock()
+	shards = m
+	shardMu.Unlock()
+	return nil
+}
+
+// Shard 返回逻辑表的分片规则，没有配置时返回错误
+func Shard(name string) (*Sharding, error) {
+	shardMu.RLock()
+	defer shardMu.RUnlock()
+	s, ok := shards[name]
+	if !ok {
+		return nil, fmt.Errorf("mysql shard %q is not configured", name)
+	}
+	return s, nil
+}
+
+// Shards 已配置分片的逻辑表
+func Shards() []string {
+	shardMu.RLock()
+	defer shardMu.RUnlock()
+	names := make([]string, 0, len(shards))
+	for name := range shards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Index 分片键落在哪张分表，key 支持整数和字符串
+// 各种整数类型都先转成 int64 再哈希，同一个用户 ID 不管是 int32 还是 int64 都落在同一张表
+func (s *Sharding) Index(key interface{}) int {
+	h := fnv.New64a()
+	switch k := key.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		_ = binary.Write(h, binary.BigEndian, toInt64(k))
+	case string:
+		_, _ = h.Write([]byte(k))
+	case []byte:
+		_, _ = h.Write(k)
+	default:
+		_, _ = fmt.Fprint(h, k)
+	}
+	return int(h.Sum64() % uint64(s.tables))
+}
+
+// toInt64 整数分片键统一成 int64，uint64 按位转换，和之前直接写入 uint64 的结果一致
+func toInt64(key interface{}) int64 {
+	switch k := key.(type) {
+	case int:
+		return int64(k)
+	case int8:
+		return int64(k)
+	case int16:
+		return int64(k)
+	case int32:
+		return int64(k)
+	case int64:
+		return k
+	case uint:
+		return int64(k)
+	case uint8:
+		return int64(k)
+	case uint16:
+		return int64(k)
+	case uint32:
+		return int64(k)
+	case uint64:
+		return int64(k)
+	}
+	return 0
+}
+
+// Table 分片键对应的物理表名
+func (s *Sharding) Table(key interface{}) string {
+	return s.tableAt(s.Index(key))
+}
+
+// Tables 全部物理表名
+func (s *Sharding) Tables() []string {
+	tables := make([]string, s.tables)
+	for i := range tables {
+		tables[i] = s.tableAt(i)
+	}
+	return tables
+}
+
+func (s *Sharding) tableAt(i int) string {
+	return fmt.Sprintf(s.format, s.name, i)
+}
+
+// database 第 i 张分表所在的库，分表按顺序均匀分到各个库
+func (s *Sharding) database(i int) string {
+	if len(s.databases) == 0 {
+		return DefaultDatabase
+	}
+	return s.databases[i*len(s.databases)/s.tables]
+}
+
+// querier 第 i 张分表使用的连接，在默认库上时和 dao 函数一样加入 ctx 里的事务
+func (s *Sharding) querier(ctx context.Context, i int) (Querier, error) {
+	name := s.database(i)
+	if name == DefaultDatabase {
+		return conn(ctx), nil
+	}
+	db := DB(name)
+	if db == nil {
+		return nil, fmt.Errorf("mysql database %q is closed", name)
+	}
+	return db, nil
+}
+
+// Route 分片键对应的连接和物理表名
+func (s *Sharding) Route(ctx context.Context, key interface{}) (q Querier, table string, err error) {
+	i := s.Index(key)
+	q, err = s.querier(ctx, i)
+	return q, s.tableAt(i), err
+}
+
+// Each 对每张分表并发执行 fn，任何一个返回错误时取消其它的并返回第一个错误
+// ctx 里有事务时逐张顺序执行，同一个事务的连接不能并发使用
+func (s *Sharding) Each(ctx context.Context, fn func(ctx context.Context, q Querier, table string) error) error {
+	return s.each(ctx, func(ctx context.Context, _ int, q Querier, table string) error {
+		return fn(ctx, q, table)
+	})
+}
+
+func (s *Sharding) each(ctx context.Context, fn func(ctx context.Context, i int, q Querier, table string) error) error {
+	g, gctx := errgroup.WithContext(ctx)
+	if _, ok := TxFromContext(ctx); ok {
+		g.SetLimit(1)
+	} else {
+		g.SetLimit(fanOutConcurrency)
+	}
+	for i := 0; i < s.tables; i++ {
+		q, err := s.querier(gctx, i)
+		if err != nil {
+			_ = g.Wait()
+			return err
+		}
+		g.Go(func() error {
+			return fn(gctx, i, q, s.tableAt(i))
+		})
+	}
+	return g.Wait()
+}
+
+// FanOut 在每张分表上执行同一条查询并合并结果，query 里用 {table} 表示表名
+// 结果按分表顺序拼接，需要排序、分页时调用方自己处理
+func FanOut[T any](ctx context.Context, s *Sharding, query string, args ...interface{}) ([]T, error) {
+	if !strings.Contains(query, tablePlaceholder) {
+		return nil, fmt.Errorf("mysql shard %q: query has no %s placeholder", s.name, tablePlaceholder)
+	}
+	parts := make([][]T, s.tables)
+	err := s.each(ctx, func(ctx context.Context, i int, q Querier, table string) error {
+		if err := q.SelectContext(ctx, &parts[i], strings.ReplaceAll(query, tablePlaceholder, "`"+table+"`"), args...); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var res []T
+	for _, rows := range parts {
+		res = append(res, rows...)
+	}
+	return res, nil
+}
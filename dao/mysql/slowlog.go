@@ -0,0 +1,113 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/ctxutil"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// slowQueryMaxLen 慢查询日志里语句的最大长度，批量插入的语句可能非常长
+	slowQueryMaxLen = 1024
+	// slowQueryMaxArgs 参数摘要最多列出的参数个数
+	slowQueryMaxArgs = 10
+)
+
+// slowQueries 启动以来的慢查询次数，在管理接口的 mysql 状态里展示
+var slowQueries atomic.Int64
+
+// logSlowQuery 记录一条慢查询：语句、参数摘要、耗时、发起请求的路由和调用的代码位置
+// 参数只记录类型，字符串和二进制只记录长度，避免把密码、手机号之类的值写进日志
+func logSlowQuery(ctx context.Context, addr, query string, args []driver.NamedValue, d time.Duration, err error) {
+	slowQueries.Add(1)
+	fn, line := sqlCaller()
+	fields := []zap.Field{
+		zap.String("addr", addr),
+		zap.String("sql", compactSQL(query)),
+		zap.String("args", summarizeArgs(args)),
+		zap.Duration("duration", d),
+		zap.String("caller", fmt.Sprintf("%s:%d", fn, line)),
+	}
+	if route := ctxutil.Route(ctx); route != "" {
+		fields = append(fields, zap.String("route", route))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	logger.Module(ctx, "dao").Warn("slow query", fields...)
+}
+
+// compactSQL 把换行、缩进压成一个空格，超长时截断
+func compactSQL(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > slowQueryMaxLen {
+		query = query[:slowQueryMaxLen] + "..."
+	}
+	return query
+}
+
+func summarizeArgs(args []driver.NamedValue) string {
+	if len(args) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, a := range args {
+		if i == slowQueryMaxArgs {
+			fmt.Fprintf(&b, ", ...(+%d)", len(args)-i)
+			break
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		switch v := a.Value.(type) {
+		case nil:
+			b.WriteString("NULL")
+		case string:
+			fmt.Fprintf(&b, "string(%d)", len(v))
+		case []byte:
+			fmt.Fprintf(&b, "bytes(%d)", len(v))
+		case time.Time:
+			b.WriteString("time")
+		default:
+			fmt.Fprintf(&b, "%T", v)
+		}
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// sqlCaller 调用栈里第一个不属于 database/sql、sqlx、驱动包装层的本项目函数，一般就是 dao 函数
+// 返回去掉模块前缀的函数名，如 dao/mysql.GetUserByID
+func sqlCaller() (fn string, line int) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if strings.HasPrefix(f.Function, "go_web_scaffolding/") && !internalFrame(f.File) {
+			return strings.TrimPrefix(f.Function, "go_web_scaffolding/"), f.Line
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
+
+// internalFrame 驱动包装层自己的文件
+func internalFrame(file string) bool {
+	for _, name := range []string{"hooks.go", "slowlog.go", "metrics.go"} {
+		if strings.HasSuffix(file, "dao/mysql/"+name) {
+			return true
+		}
+	}
+	return false
+}
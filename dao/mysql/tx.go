@@ -0,0 +1,102 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 需要多条写操作同时成功或同时失败时，用 WithTx 包起来：
+//
+//	err = mysql.WithTx(ctx, func(ctx context.Context) error {
+//		if err := mysql.InsertUser(ctx, u); err != nil {
+//			return err
+//		}
+//		return mysql.InsertUserOAuth(ctx, o)
+//	})
+//
+// 事务保存在 ctx 里，本包的 dao 函数都通过 conn(ctx) 拿连接，传入事务的 ctx 就自动在事务里执行，
+// dao 函数本身不需要区分是不是在事务里。fn 返回错误或 panic 时回滚，否则提交
+// 嵌套调用 WithTx 直接加入外层事务（外层的隔离级别生效），由最外层统一提交或回滚
+// fn 里不要用外面的 ctx，否则 SQL 会在事务之外执行
+
+// Querier *sqlx.DB 和 *sqlx.Tx 共有的查询方法
+type Querier interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
+type txKey struct{}
+
+// TxOption 事务选项
+type TxOption func(*sql.TxOptions)
+
+// Isolation 设置隔离级别，默认使用数据库的隔离级别（MySQL 为可重复读）
+func Isolation(level sql.IsolationLevel) TxOption {
+	return func(o *sql.TxOptions) {
+		o.Isolation = level
+	}
+}
+
+// ReadOnly 只读事务，适合需要一致性快照的多条查询
+func ReadOnly() TxOption {
+	return func(o *sql.TxOptions) {
+		o.ReadOnly = true
+	}
+}
+
+// WithTx 在事务里执行 fn，fn 的 ctx 参数带有事务
+func WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) (err error) {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+	db := getDB()
+	if db == nil {
+		return sql.ErrConnDone
+	}
+	txOpts := new(sql.TxOptions)
+	for _, opt := range opts {
+		opt(txOpts)
+	}
+	tx, err := db.BeginTxx(ctx, txOpts)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && rbErr != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+			}
+			return
+		}
+		err = tx.Commit()
+	}()
+	return fn(context.WithValue(ctx, txKey{}, tx))
+}
+
+// TxFromContext 取出 ctx 里的事务，需要直接操作事务（比如 select ... for update 后自己拼 SQL）时使用
+func TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sqlx.Tx)
+	return tx, ok
+}
+
+// conn ctx 里有事务时返回事务，否则返回连接池，本包的 dao 函数都通过它执行 SQL
+func conn(ctx context.Context) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return getDB()
+}
+
+// Conn 同 conn，给 dao/repo 这类本包之外的数据访问工具使用
+func Conn(ctx context.Context) Querier {
+	return conn(ctx)
+}
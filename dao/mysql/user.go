@@ -0,0 +1,101 @@
+package mysql
+
+import (
+	"context"
+	"go_web_scaffolding/models"
+)
+
+// userColumns user 查询的列
+const userColumns = "id, username, password, nickname, email, avatar, totp_secret, locale, timezone, create_time, update_time"
+
+// 按主键、用户名、邮箱查单个用户的函数都走主库：登录、两步验证要读到刚改过的密码哈希和密钥，
+// 注册查重不能被复制延迟骗过；只有列表、搜索这类查询用 readConn 走从库
+
+// GetUserByID 按 ID 查询用户，不存在时返回 sql.ErrNoRows
+func GetUserByID(ctx context.Context, id int64) (u *models.User, err error) {
+	u = new(models.User)
+	sqlStr := "select " + userColumns + " from `user` where id = ?"
+	err = conn(ctx).GetContext(ctx, u, sqlStr, id)
+	return
+}
+
+// GetUsersByIDs 按 ID 批量查询用户，不存在的 ID 没有对应的结果，结果的顺序和 ids 无关
+func GetUsersByIDs(ctx context.Context, ids []int64) (list []*models.User, err error) {
+	if len(ids) == 0 {
+		return
+	}
+	sqlStr := "select " + userColumns + " from `user` where id in (?)"
+	err = SelectIn(ctx, &list, sqlStr, ids)
+	return
+}
+
+// ListUsersAfter 按 ID 升序取 afterID 之后的 limit 个用户，重建搜索索引这类全表遍历使用，走从库
+func ListUsersAfter(ctx context.Context, afterID int64, limit int) (list []*models.User, err error) {
+	sqlStr := "select " + userColumns + " from `user` where id > ? order by id limit ?"
+	err = readConn(ctx).SelectContext(ctx, &list, sqlStr, afterID, limit)
+	return
+}
+
+// GetUserByUsername 按用户名查询用户，不存在时返回 sql.ErrNoRows
+func GetUserByUsername(ctx context.Context, username string) (u *models.User, err error) {
+	u = new(models.User)
+	sqlStr := "select " + userColumns + " from `user` where username = ?"
+	err = conn(ctx).GetContext(ctx, u, sqlStr, username)
+	return
+}
+
+// GetUserByEmail 按邮箱查询用户，不存在时返回 sql.ErrNoRows
+func GetUserByEmail(ctx context.Context, email string) (u *models.User, err error) {
+	u = new(models.User)
+	sqlStr := "select " + userColumns + " from `user` where email = ? limit 1"
+	err = conn(ctx).GetContext(ctx, u, sqlStr, email)
+	return
+}
+
+// CheckUserExist 用户名是否已被占用
+func CheckUserExist(ctx context.Context, username string) (exist bool, err error) {
+	var count int
+	sqlStr := "select count(id) from `user` where username = ?"
+	if err = conn(ctx).GetContext(ctx, &count, sqlStr, username); err != nil {
+		return
+	}
+	return count > 0, nil
+}
+
+// CheckEmailExist 邮箱是否已被占用
+func CheckEmailExist(ctx context.Context, email string) (exist bool, err error) {
+	var count int
+	sqlStr := "select count(id) from `user` where email = ?"
+	if err = conn(ctx).GetContext(ctx, &count, sqlStr, email); err != nil {
+		return
+	}
+	return count > 0, nil
+}
+
+// InsertUser 插入用户，UserID 由调用方用 idgen 生成
+func InsertUser(ctx context.Context, u *models.User) (err error) {
+	sqlStr := "insert into `user`(id, username, password, nickname, email, avatar) values (:id, :username, :password, :nickname, :email, :avatar)"
+	_, err = conn(ctx).NamedExecContext(ctx, sqlStr, u)
+	return
+}
+
+// UpdateUserTOTPSecret 开启或关闭（secret 为空）两步验证
+func UpdateUserTOTPSecret(ctx context.Context, userID int64, secret string) (err error) {
+	sqlStr := "update `user` set totp_secret = ? where id = ?"
+	_, err = conn(ctx).ExecContext(ctx, sqlStr, secret, userID)
+	return
+}
+
+// UpdateUserPassword 更新密码哈希
+func UpdateUserPassword(ctx context.Context, userID int64, hashed string) (err error) {
+	sqlStr := "update `user` set password = ? where id = ?"
+	_, err = conn(ctx).ExecContext(ctx, sqlStr, hashed, userID)
+	return
+}
+
+// UpdateUserProfile 更新昵称、头像和语言、时区偏好
+func UpdateUserProfile(ctx context.Context, u *models.User) (err error) {
+	sqlStr := "update `user` set nickname = :nickname, avatar = :avatar, locale = :locale, timezone = :timezone where id = :id"
+	_, err = conn(ctx).NamedExecContext(ctx, sqlStr, u)
+	return
+}
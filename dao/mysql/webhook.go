@@ -0,0 +1,181 @@
+package mysql
+
+import (
+	"context"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/pagination"
+	"time"
+)
+
+// webhookColumns webhook 查询的列
+const webhookColumns = `id, url, secret, events, description, enabled, create_time, update_time`
+
+// deliveryColumns 投递日志查询的列
+const deliveryColumns = `id, webhook_id, event, payload, request_id, status, attempts, response_code, last_error, next_retry_time, create_time, update_time`
+
+// InsertWebhook 插入 webhook，成功后回填 ID
+func InsertWebhook(ctx context.Context, w *models.Webhook) (err error) {
+	sqlStr := `insert into webhook(url, secret, events, description, enabled)
+	values (:url, :secret, :events, :description, :enabled)`
+	w.ID, err = NamedInsertID(ctx, sqlStr, w)
+	return
+}
+
+// GetWebhookByID 按 ID 查询 webhook，不存在时返回 sql.ErrNoRows
+func GetWebhookByID(ctx context.Context, id int64) (w *models.Webhook, err error) {
+	w = new(models.Webhook)
+	err = readConn(ctx).GetContext(ctx, w, `select `+webhookColumns+` from webhook where id = ?`, id)
+	return
+}
+
+// GetWebhooksByIDs 批量查询 webhook，投递时取 URL 和密钥
+func GetWebhooksByIDs(ctx context.Context, ids []int64) (list []*models.Webhook, err error) {
+	if len(ids) == 0 {
+		return
+	}
+	err = SelectIn(ctx, &list, `select `+webhookColumns+` from webhook where id in (?)`, ids)
+	return
+}
+
+// ListWebhooks 列出所有 webhook，数量不多，不分页
+func ListWebhooks(ctx context.Context) (list []*models.Webhook, err error) {
+	err = readConn(ctx).SelectContext(ctx, &list, `select `+webhookColumns+` from webhook order by id`)
+	return
+}
+
+// ListEnabledWebhooks 列出启用的 webhook，发布事件时按订阅的事件过滤
+func ListEnabledWebhooks(ctx context.Context) (list []*models.Webhook, err error) {
+	err = readConn(ctx).SelectContext(ctx, &list, `select `+webhookColumns+` from webhook where enabled = 1 order by id`)
+	return
+}
+
+// UpdateWebhook 修改 URL、订阅的事件、描述和启用状态，密钥不变
+func UpdateWebhook(ctx context.Context, w *models.Webhook) (err error) {
+	sqlStr := `update webhook set url = :url, events = :events, description = :description, enabled = :enabled where id = :id`
+	_, err = conn(ctx).NamedExecContext(ctx, sqlStr, w)
+	return
+}
+
+// DeleteWebhook 删除 webhook，还没投递的消息在投递时发现 webhook 不存在后丢弃
+func DeleteWebhook(ctx context.Context, id int64) (n int64, err error) {
+	res, err := conn(ctx).ExecContext(ctx, `delete from webhook where id = ?`, id)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+// InsertWebhookDelivery 写入一条待投递记录
+func InsertWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) (err error) {
+	sqlStr := `insert into webhook_delivery(webhook_id, event, payload, request_id) values(?, ?, ?, ?)`
+	d.ID, err = InsertID(ctx, sqlStr, d.WebhookID, d.Event, d.Payload, d.RequestID)
+	return
+}
+
+// LeaseWebhookDeliveries 取出最多 limit 条到期的待投递记录，并把它们的下次重试时间推迟 lease
+// HTTP 请求可能很慢，不能在事务里持有行锁等对方响应：这里只在短事务里锁定并推迟，
+// 投递在事务外进行，租期内其它实例不会取到这些行；进程在投递途中退出的话，租期过后由其它实例重新投递
+func LeaseWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) (list []*models.WebhookDelivery, err error) {
+	err = WithTx(ctx, func(ctx context.Context) error {
+		sqlStr := `select ` + deliveryColumns + `
+		from webhook_delivery
+		where status = ? and next_retry_time <= ?
+		order by id
+		limit ?`
+		if Driver() != DriverSQLite {
+			sqlStr += " for update skip locked"
+		}
+		if err := conn(ctx).SelectContext(ctx, &list, sqlStr, models.WebhookPending, time.Now(), limit); err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		ids := make([]int64, 0, len(list))
+		for _, d := range list {
+			ids = append(ids, d.ID)
+		}
+		_, err := ExecIn(ctx, `update webhook_delivery set next_retry_time = ? where id in (?)`, time.Now().Add(lease), ids)
+		return err
+	})
+	return
+}
+
+// MarkWebhookDelivery 记录一次投递的结果，status 为 WebhookPending 时在 next 之后重试
+func MarkWebhookDelivery(ctx context.Context, id int64, status int8, code int, lastErr string, next time.Time) (err error) {
+	sqlStr := `update webhook_delivery set status = ?, attempts = attempts + 1, response_code = ?, last_error = ?, next_retry_time = ?
+	where id = ?`
+	_, err = conn(ctx).ExecContext(ctx, sqlStr, status, code, lastErr, next, id)
+	return
+}
+
+// GetWebhookDeliveryByID 按 ID 查询投递记录，不存在时返回 sql.ErrNoRows
+func GetWebhookDeliveryByID(ctx context.Context, id int64) (d *models.WebhookDelivery, err error) {
+	d = new(models.WebhookDelivery)
+	err = readConn(ctx).GetContext(ctx, d, `select `+deliveryColumns+` from webhook_delivery where id = ?`, id)
+	return
+}
+
+// ListWebhookDeliveries 按 id 倒序分页查询一个 webhook 的投递日志
+// 游标模式多查一条且不统计总数，total 为 0
+func ListWebhookDeliveries(ctx context.Context, webhookID int64, p pagination.Params) (list []*models.WebhookDelivery, total int64, err error) {
+	b := Builder.Select(deliveryColumns).From("webhook_delivery").Where("webhook_id = ?", webhookID)
+	if p.IsCursor() {
+		cond, args, e := p.Keyset("id", true)
+		if e != nil {
+			return nil, 0, e
+		}
+		b = b.Where(cond, args...).OrderBy("id DESC").Limit(uint64(p.Limit() + 1))
+		err = SelectSQL(ctx, &list, b)
+		return
+	}
+
+	if err = GetSQL(ctx, &total, Builder.Select("count(*)").From("webhook_delivery").Where("webhook_id = ?", webhookID)); err != nil || total == 0 {
+		return
+	}
+	b = b.OrderBy("id DESC").Limit(uint64(p.Limit())).Offset(uint64(p.Offset()))
+	err = SelectSQL(ctx, &list, b)
+	return
+}
+
+// ResetWebhookDelivery 重新投递：状态改回待投递并立即到期，尝试次数清零
+// 只改已经结束（成功或死信）的记录：待投递的可能正被 relay 租着在发送，改掉租期会被其它实例重复投递；
+// 返回 0 表示记录不存在或者还在待投递
+func ResetWebhookDelivery(ctx context.Context, id int64) (n int64, err error) {
+	sqlStr := `update webhook_delivery set status = ?, attempts = 0, next_retry_time = ? where id = ? and status <> ?`
+	res, err := conn(ctx).ExecContext(ctx, sqlStr, models.WebhookPending, time.Now(), id, models.WebhookPending)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+// DeleteWebhookDeliveries 删除 before 之前已经结束（成功或死信）的投递记录，每次最多删 limit 行
+func DeleteWebhookDeliveries(ctx context.Context, before time.Time, limit int) (n int64, err error) {
+	sqlStr := `delete from webhook_delivery where status <> ? and update_time < ? limit ?`
+	if Driver() != DriverMySQL {
+		// PostgreSQL 和 SQLite 的 DELETE 不支持 LIMIT
+		sqlStr = `delete from webhook_delivery where id in (select id from webhook_delivery where status <> ? and update_time < ? limit ?)`
+	}
+	res, err := conn(ctx).ExecContext(ctx, sqlStr, models.WebhookPending, before, limit)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+// CountWebhookDeliveries 按状态统计投递记录数
+func CountWebhookDeliveries(ctx context.Context) (counts map[int8]int64, err error) {
+	var rows []struct {
+		Status int8  `db:"status"`
+		N      int64 `db:"n"`
+	}
+	if err = readConn(ctx).SelectContext(ctx, &rows, `select status, count(*) n from webhook_delivery group by status`); err != nil {
+		return
+	}
+	counts = make(map[int8]int64, len(rows))
+	for _, r := range rows {
+		counts[r.Status] = r.N
+	}
+	return
+}
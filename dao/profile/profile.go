@@ -0,0 +1,130 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/settings"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// DefaultName 顶层 mysql/redis 配置对应的 profile 名
+const DefaultName = "default"
+
+var ErrProfileNotFound = errors.New("profile not found")
+
+// PrepareFunc 按 profile 为某个依赖建立新连接并校验
+// 成功后返回 cutover（原子切换 + 排空旧连接）和 abort（释放新连接）
+// profile 里没有该依赖的配置时三个返回值都为 nil，表示保持不变
+type PrepareFunc func(ctx context.Context, p *settings.ProfileConfig) (cutover func(), abort func(), err error)
+
+type dependency struct {
+	name    string
+	prepare PrepareFunc
+}
+
+var (
+	mu     sync.Mutex
+	active = DefaultName
+	deps   []dependency
+)
+
+func init() {
+	Register("mysql", func(ctx context.Context, p *settings.ProfileConfig) (func(), func(), error) {
+		if p.MySQLConfig == nil {
+			return nil, nil, nil
+		}
+		return mysql.Prepare(ctx, p.MySQLConfig)
+	})
+	Register("redis", func(ctx context.Context, p *settings.ProfileConfig) (func(), func(), error) {
+		if p.RedisConfig == nil {
+			return nil, nil, nil
+		}
+		return redis.Prepare(ctx, p.RedisConfig)
+	})
+}
+
+// Register 注册一个可切换的外部依赖，MQ 等后续接入的依赖在自己的 init 里调用
+func Register(name string, prepare PrepareFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	deps = append(deps, dependency{name: name, prepare: prepare})
+}
+
+// Active 当前生效的 profile 名
+func Active() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return active
+}
+
+// Names 所有可切换的 profile 名
+func Names() []string {
+	names := []string{DefaultName}
+	for name := range settings.Conf.Profiles {
+		if name != DefaultName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// Switch 切换到指定 profile
+// 流程：所有依赖先按新 profile 建连并校验（reconnect + verify），
+// 全部成功后再依次原子切换（cut over），旧连接在后台排空后关闭（drain）；
+// 任意一个依赖准备失败则释放已建立的新连接，当前连接保持不变
+func Switch(ctx context.Context, name string) (err error) {
+	p, err := lookup(name)
+	if err != nil {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var cutovers, aborts []func()
+	for _, d := range deps {
+		cutover, abort, err := d.prepare(ctx, p)
+		if err != nil {
+			for _, abort := range aborts {
+				abort()
+			}
+			zap.L().Named("dao").Error("prepare profile failed",
+				zap.String("profile", name),
+				zap.String("dependency", d.name),
+				zap.Error(err))
+			return fmt.Errorf("prepare %s: %w", d.name, err)
+		}
+		if cutover != nil {
+			cutovers = append(cutovers, cutover)
+			aborts = append(aborts, abort)
+		}
+	}
+
+	for _, cutover := range cutovers {
+		cutover()
+	}
+	zap.L().Named("dao").Info("profile switched", zap.String("from", active), zap.String("to", name))
+	active = name
+	return
+}
+
+func lookup(name string) (*settings.ProfileConfig, error) {
+	if name == DefaultName {
+		return &settings.ProfileConfig{
+			MySQLConfig: settings.Conf.MySQLConfig,
+			RedisConfig: settings.Conf.RedisConfig,
+		}, nil
+	}
+	p, ok := settings.Conf.Profiles[name]
+	if !ok || p == nil {
+		return nil, ErrProfileNotFound
+	}
+	return p, nil
+}
@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/pkg/breaker"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/go-redis/redis"
+)
+
+// wrapBreaker 给客户端加上熔断，每条命令（pipeline 算一条）执行前先问熔断器 redis:<地址>
+// 包在最里层，Ctx 复制出来的客户端和链路追踪的包装都会经过它
+func wrapBreaker(rdb *redis.Client, addr string) {
+	b := breaker.Get("redis:"+addr, isConnFailure)
+	if b == nil {
+		return
+	}
+	rdb.WrapProcess(func(old func(redis.Cmder) error) func(redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			done, err := b.Allow()
+			if err != nil {
+				return reject(cmd)
+			}
+			err = old(cmd)
+			done(err)
+			return err
+		}
+	})
+	rdb.WrapProcessPipeline(func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			done, err := b.Allow()
+			if err != nil {
+				return reject(cmds...)
+			}
+			err = old(cmds)
+			done(err)
+			return err
+		}
+	})
+}
+
+// rejecter 给被熔断拒绝的命令设置错误：go-redis v6 的命令只能由客户端内部设置错误，
+// 这里用一个拨号总是失败的客户端执行它们，调用方 cmd.Err() 拿到的是 breaker.ErrOpen
+// 所有熔断器共用一个，连接池只有一个位置，第一次拨号失败后 Get 直接返回这个错误，不会每条命令都拨号
+var rejecter = sync.OnceValue(func() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Dialer: func() (net.Conn, error) {
+			return nil, breaker.ErrOpen
+		},
+		PoolSize: 1,
+		// 不启动空闲连接检查的 goroutine
+		IdleTimeout: -1,
+	})
+})
+
+// reject 熔断打开时不发命令，直接返回 breaker.ErrOpen
+func reject(cmds ...redis.Cmder) error {
+	c := rejecter()
+	for _, cmd := range cmds {
+		_ = c.Process(cmd)
+	}
+	return breaker.ErrOpen
+}
+
+// errPoolTimeout go-redis v6 的连接池超时错误定义在 internal 包里，只能按文本判断
+const errPoolTimeout = "redis: connection pool timeout"
+
+// isConnFailure 连不上、连接断开、超时、连接池等待超时算 Redis 失败
+// redis.Nil 和 WRONGTYPE、NOSCRIPT 这类服务端返回的错误说明 Redis 是好的
+func isConnFailure(err error) bool {
+	if err == nil || err == redis.Nil {
+		return false
+	}
+	var ne net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &ne) ||
+		err.Error() == errPoolTimeout
+}
@@ -0,0 +1,33 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// keyCaptchaPrefix 图形验证码的答案
+const keyCaptchaPrefix = "captcha:"
+
+// SaveCaptcha 保存验证码答案
+func SaveCaptcha(ctx context.Context, id, answer string, ttl time.Duration) error {
+	return Ctx(ctx).Set(keyCaptchaPrefix+id, answer, ttl).Err()
+}
+
+// TakeCaptcha 取出并删除验证码答案，不论对错一个验证码只能校验一次，不存在时返回空字符串
+func TakeCaptcha(ctx context.Context, id string) (string, error) {
+	var get *redis.StringCmd
+	_, err := TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(keyCaptchaPrefix + id)
+		pipe.Del(keyCaptchaPrefix + id)
+		return nil
+	})
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return get.Val(), nil
+}
@@ -0,0 +1,25 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+// incrWindowScript 计数加一，第一次计数时设置过期时间
+// 窗口从第一次计数开始算，之后的计数不会续期，是一个固定窗口计数器
+const incrWindowScript = "counter:incr_window"
+
+func init() {
+	RegisterScript(incrWindowScript, `
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+}
+
+// IncrWindow 固定窗口计数，返回窗口内的计数，用于失败次数、发送次数这类限额
+func IncrWindow(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return EvalScript(ctx, incrWindowScript, []string{key}, window.Milliseconds()).Int64()
+}
@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// redis.embedded 为 true 时（mode: local 自动打开）在进程内启动一个 miniredis 代替真正的 Redis，
+// 数据只在内存里，进程退出就没了；Lua 脚本、stream 这些用到的命令 miniredis 都支持
+// miniredis 的 TTL 不会随时间减少，这里每秒 FastForward 一次，验证码、token 才会按时过期
+
+var (
+	embeddedMu   sync.Mutex
+	embedded     *miniredis.Miniredis
+	embeddedStop chan struct{}
+)
+
+// embeddedAddr 返回内嵌 miniredis 的地址，第一次调用时启动，profile 切换时复用同一个实例
+func embeddedAddr() (string, error) {
+	embeddedMu.Lock()
+	defer embeddedMu.Unlock()
+	if embedded != nil {
+		return embedded.Addr(), nil
+	}
+	m, err := miniredis.Run()
+	if err != nil {
+		return "", err
+	}
+	embedded, embeddedStop = m, make(chan struct{})
+	go tick(m, embeddedStop)
+	return m.Addr(), nil
+}
+
+func tick(m *miniredis.Miniredis, stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			m.SetTime(now)
+			m.FastForward(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+func closeEmbedded() {
+	embeddedMu.Lock()
+	defer embeddedMu.Unlock()
+	if embedded == nil {
+		return
+	}
+	close(embeddedStop)
+	embedded.Close()
+	embedded = nil
+}
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// keyLoginFailPrefix 窗口期内的登录失败次数，login:fail:<scope>:<id>，scope 为 account 或 ip
+	keyLoginFailPrefix = "login:fail:"
+	// keyLoginLockPrefix 临时锁定标记，过期自动解锁
+	keyLoginLockPrefix = "login:lock:"
+	// keyLoginAccountsPrefix 同一个 IP 在窗口期内尝试登录过的不同账号数（HyperLogLog）
+	keyLoginAccountsPrefix = "login:accounts:"
+	// keyLoginStuffingPrefix 撞库告警标记，窗口期内只告警一次
+	keyLoginStuffingPrefix = "login:stuffing:"
+)
+
+// countWindowScript 把成员加入 HyperLogLog 并返回基数，同样只在 key 新建时设置过期时间
+const countWindowScript = "login:count_window"
+
+func init() {
+	RegisterScript(countWindowScript, `
+redis.call('PFADD', KEYS[1], ARGV[2])
+if redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return redis.call('PFCOUNT', KEYS[1])
+`)
+}
+
+// IncrLoginFailure 失败次数加一，第一次失败时开始计算窗口期
+func IncrLoginFailure(ctx context.Context, scope, id string, window time.Duration) (int64, error) {
+	return IncrWindow(ctx, keyLoginFailPrefix+scope+":"+id, window)
+}
+
+// GetLoginFailures 窗口期内的失败次数
+func GetLoginFailures(ctx context.Context, scope, id string) (int64, error) {
+	n, err := Ctx(ctx).Get(keyLoginFailPrefix + scope + ":" + id).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// ClearLoginFailures 登录成功后清零
+func ClearLoginFailures(ctx context.Context, scope, id string) error {
+	return Ctx(ctx).Del(keyLoginFailPrefix + scope + ":" + id).Err()
+}
+
+// LockLogin 锁定 d 时间
+func LockLogin(ctx context.Context, scope, id string, d time.Duration) error {
+	return Ctx(ctx).Set(keyLoginLockPrefix+scope+":"+id, 1, d).Err()
+}
+
+// LoginLockTTL 剩余锁定时间，没有锁定时返回 0
+func LoginLockTTL(ctx context.Context, scope, id string) (time.Duration, error) {
+	ttl, err := Ctx(ctx).PTTL(keyLoginLockPrefix + scope + ":" + id).Result()
+	if err != nil || ttl < 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// AddLoginAccount 记录 IP 尝试过的账号，返回窗口期内的不同账号数
+func AddLoginAccount(ctx context.Context, ip, account string, window time.Duration) (int64, error) {
+	return EvalScript(ctx, countWindowScript, []string{keyLoginAccountsPrefix + ip}, window.Milliseconds(), account).Int64()
+}
+
+// MarkStuffingAlert 撞库告警去重，窗口期内第一次调用返回 true
+func MarkStuffingAlert(ctx context.Context, ip string, window time.Duration) (bool, error) {
+	return Ctx(ctx).SetNX(keyLoginStuffingPrefix+ip, 1, window).Result()
+}
@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// keyMailCountPrefix 每个收件地址在窗口期内的发信次数
+const keyMailCountPrefix = "mail:count:"
+
+// keyMailBodyPrefix 等待发送的敏感邮件，和里面的验证码、链接同时过期
+const keyMailBodyPrefix = "mail:body:"
+
+// IncrMailRecipient 收件地址在窗口期内的发信次数，地址不区分大小写
+func IncrMailRecipient(ctx context.Context, address string, window time.Duration) (int64, error) {
+	return IncrWindow(ctx, keyMailCountPrefix+strings.ToLower(address), window)
+}
+
+// GetMailRecipient 收件地址在当前窗口内已经发了多少封，不计数
+func GetMailRecipient(ctx context.Context, address string) (int64, error) {
+	n, err := Ctx(ctx).Get(keyMailCountPrefix + strings.ToLower(address)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// SaveMailBody 保存等待发送的邮件
+func SaveMailBody(ctx context.Context, id, data string, ttl time.Duration) error {
+	return Ctx(ctx).Set(keyMailBodyPrefix+id, data, ttl).Err()
+}
+
+// GetMailBody 取出等待发送的邮件，已经过期或者发送过时返回空字符串
+func GetMailBody(ctx context.Context, id string) (string, error) {
+	data, err := Ctx(ctx).Get(keyMailBodyPrefix + id).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return data, err
+}
+
+// DeleteMailBody 发送成功后删除
+func DeleteMailBody(ctx context.Context, id string) error {
+	return Ctx(ctx).Del(keyMailBodyPrefix + id).Err()
+}
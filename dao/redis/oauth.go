@@ -0,0 +1,33 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// keyOAuthStatePrefix 第三方登录的 state，回调时校验并删除，防止 CSRF 和重放
+const keyOAuthStatePrefix = "oauth:state:"
+
+// SaveOAuthState 保存 state 对应的数据
+func SaveOAuthState(ctx context.Context, state, data string, ttl time.Duration) error {
+	return Ctx(ctx).Set(keyOAuthStatePrefix+state, data, ttl).Err()
+}
+
+// TakeOAuthState 取出并删除 state 对应的数据，state 只能使用一次，不存在时返回空字符串
+func TakeOAuthState(ctx context.Context, state string) (string, error) {
+	var get *redis.StringCmd
+	_, err := TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(keyOAuthStatePrefix + state)
+		pipe.Del(keyOAuthStatePrefix + state)
+		return nil
+	})
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return get.Val(), nil
+}
@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// keyPwdResetPrefix 重置密码 token 的摘要 -> 用户 ID
+	keyPwdResetPrefix = "pwdreset:token:"
+	// keyPwdResetUserPrefix 用户当前有效的 token 摘要，重新申请时旧 token 作废
+	keyPwdResetUserPrefix = "pwdreset:user:"
+	// keyPwdResetCooldownPrefix 同一用户两次申请的最小间隔
+	keyPwdResetCooldownPrefix = "pwdreset:cooldown:"
+)
+
+// SavePasswordResetToken 保存重置 token，同一用户之前申请的 token 立即作废
+func SavePasswordResetToken(ctx context.Context, userID int64, tokenHash string, ttl time.Duration) error {
+	userKey := keyPwdResetUserPrefix + strconv.FormatInt(userID, 10)
+	old, err := Ctx(ctx).Get(userKey).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	_, err = TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if old != "" {
+			pipe.Del(keyPwdResetPrefix + old)
+		}
+		pipe.Set(keyPwdResetPrefix+tokenHash, userID, ttl)
+		pipe.Set(userKey, tokenHash, ttl)
+		return nil
+	})
+	return err
+}
+
+// GetPasswordResetToken 查询重置 token 对应的用户，不删除，不存在时返回 0
+func GetPasswordResetToken(ctx context.Context, tokenHash string) (int64, error) {
+	userID, err := Ctx(ctx).Get(keyPwdResetPrefix + tokenHash).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return userID, err
+}
+
+// TakePasswordResetToken 取出并删除重置 token，token 只能使用一次，不存在时返回 0
+func TakePasswordResetToken(ctx context.Context, tokenHash string) (int64, error) {
+	var get *redis.StringCmd
+	_, err := TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(keyPwdResetPrefix + tokenHash)
+		pipe.Del(keyPwdResetPrefix + tokenHash)
+		return nil
+	})
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	userID, err := get.Int64()
+	if err != nil {
+		return 0, err
+	}
+	return userID, Ctx(ctx).Del(keyPwdResetUserPrefix + strconv.FormatInt(userID, 10)).Err()
+}
+
+// PasswordResetCooldown 申请重置的冷却时间，冷却中返回 false
+func PasswordResetCooldown(ctx context.Context, userID int64, d time.Duration) (bool, error) {
+	return Ctx(ctx).SetNX(keyPwdResetCooldownPrefix+strconv.FormatInt(userID, 10), 1, d).Result()
+}
@@ -0,0 +1,35 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/go-redis/redis"
+)
+
+// Pipelined 把 fn 里的多条命令一次性发给 redis，减少网络往返
+// 命令之间没有原子性保证，需要原子性用 TxPipelined
+func Pipelined(ctx context.Context, fn func(pipe redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return Ctx(ctx).Pipelined(fn)
+}
+
+// TxPipelined 用 MULTI/EXEC 包裹 fn 里的命令，要么全部执行要么全部不执行
+func TxPipelined(ctx context.Context, fn func(pipe redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return Ctx(ctx).TxPipelined(fn)
+}
+
+// Transaction 基于 WATCH 的乐观锁事务，适合"读-改-写"场景
+// fn 里先读取 keys 的当前值，再用 tx.TxPipelined 写入；如果执行期间 keys 被别人修改，
+// EXEC 会失败，这里最多重试 maxRetries 次，仍然冲突时返回 redis.TxFailedErr
+func Transaction(ctx context.Context, keys []string, maxRetries int, fn func(tx *redis.Tx) error) (err error) {
+	rdb := Ctx(ctx)
+	for i := 0; i <= maxRetries; i++ {
+		err = rdb.Watch(fn, keys...)
+		if err != redis.TxFailedErr {
+			return
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return
+}
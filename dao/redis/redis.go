@@ -1,29 +1,136 @@
 package redis
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/health"
+	"go_web_scaffolding/pkg/retry"
+	"go_web_scaffolding/settings"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-redis/redis"
-	"github.com/spf13/viper"
+	"go.uber.org/zap"
 )
 
-var rdb *redis.Client
+// drainTimeout profile 切换后旧客户端保留的时间，让正在执行的命令跑完再关闭
+const drainTimeout = 5 * time.Second
 
-func Init() (err error) {
+// 当前生效的客户端，profile 切换时整体替换
+var rdbp atomic.Pointer[redis.Client]
+
+var errNotInitialized = errors.New("redis: client not initialized")
+
+func init() {
+	dashboard.Register("redis", func(ctx context.Context) interface{} {
+		status := map[string]interface{}{"stats": PoolStats(), "healthy": true}
+		if err := Ping(ctx); err != nil {
+			status["healthy"] = false
+			status["error"] = err.Error()
+		}
+		return status
+	})
+	health.Register("redis", Ping)
+}
+
+func Init(cfg *settings.RedisConfig) (err error) {
+	// 同 mysql.Init，启动时 Redis 可能还没就绪，按 init_retries 重试
+	rdb, err := retry.DoValue(context.Background(), retry.Policy{
+		MaxAttempts: cfg.InitRetries + 1,
+		Initial:     time.Second,
+		Jitter:      0.2,
+		OnRetry: func(attempt int, err error, wait time.Duration) {
+			zap.L().Named("dao").Warn("connect to redis failed, retrying",
+				zap.Int("attempt", attempt), zap.Duration("wait", wait), zap.Error(err))
+		},
+	}, func(ctx context.Context) (*redis.Client, error) {
+		return connect(ctx, cfg)
+	})
+	if err != nil {
+		return
+	}
+	rdbp.Store(rdb)
+	return
+}
+
+func connect(ctx context.Context, cfg *settings.RedisConfig) (rdb *redis.Client, err error) {
+	addr := fmt.Sprintf("%s:%d",
+		cfg.Host,
+		cfg.Port,
+	)
+	if cfg.Embedded {
+		if addr, err = embeddedAddr(); err != nil {
+			return
+		}
+	}
 	rdb = redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d",
-			viper.GetString("redis.host"),
-			viper.GetString("redis.port"),
-		),
-		Password: viper.GetString("redis.password"),
-		DB:       viper.GetInt("redis.db"),
-		PoolSize: viper.GetInt("redis.pool_size"),
+		Addr:     addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+		PoolSize: cfg.PoolSize,
 	})
+	wrapBreaker(rdb, addr)
 
-	_, err = rdb.Ping().Result()
+	if _, err = rdb.WithContext(ctx).Ping().Result(); err != nil {
+		_ = rdb.Close()
+		return nil, err
+	}
+	loadScripts(ctx, rdb)
 	return
 }
 
+// Prepare 按新配置建立客户端并 ping 校验，语义同 mysql.Prepare
+// 旧客户端在 cutover 后等待 drainTimeout 再关闭
+func Prepare(ctx context.Context, cfg *settings.RedisConfig) (cutover func(), abort func(), err error) {
+	rdb, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	cutover = func() {
+		old := rdbp.Swap(rdb)
+		if old != nil {
+			time.AfterFunc(drainTimeout, func() {
+				if err := old.Close(); err != nil {
+					zap.L().Named("dao").Warn("close old redis client failed", zap.Error(err))
+				}
+			})
+		}
+	}
+	abort = func() {
+		_ = rdb.Close()
+	}
+	return
+}
+
+// Ping 检查 redis 是否可用，给健康检查和管理接口使用
+func Ping(ctx context.Context) error {
+	rdb := Client()
+	if rdb == nil {
+		return errNotInitialized
+	}
+	return rdb.WithContext(ctx).Ping().Err()
+}
+
+// PoolStats 连接池统计：命中/未命中/超时次数，总连接数和空闲连接数
+func PoolStats() *redis.PoolStats {
+	rdb := Client()
+	if rdb == nil {
+		return &redis.PoolStats{}
+	}
+	return rdb.PoolStats()
+}
+
 func Close() {
-	_ = rdb.Close()
+	if rdb := rdbp.Load(); rdb != nil {
+		_ = rdb.Close()
+	}
+	closeEmbedded()
+}
+
+// Client 返回底层的 redis 客户端
+// 给 pkg/cache 这类需要直接操作 redis 的工具包使用，业务代码优先走 dao 层封装好的方法
+func Client() *redis.Client {
+	return rdbp.Load()
 }
@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// lua 脚本注册表，业务包在 init 里注册，按名字调用
+var (
+	scriptsMu sync.RWMutex
+	scripts   = make(map[string]*redis.Script)
+)
+
+// RegisterScript 注册一个 lua 脚本，重复注册同名脚本会 panic，一般在包的 init 里调用
+func RegisterScript(name, src string) {
+	scriptsMu.Lock()
+	defer scriptsMu.Unlock()
+	if _, ok := scripts[name]; ok {
+		panic(fmt.Sprintf("redis: script %q already registered", name))
+	}
+	scripts[name] = redis.NewScript(src)
+}
+
+// EvalScript 执行已注册的脚本
+// 优先使用 EVALSHA 只传脚本摘要，服务端没有缓存该脚本（NOSCRIPT，比如 redis 重启或切换了实例）时自动退回 EVAL
+func EvalScript(ctx context.Context, name string, keys []string, args ...interface{}) *redis.Cmd {
+	scriptsMu.RLock()
+	s, ok := scripts[name]
+	scriptsMu.RUnlock()
+	if !ok {
+		return redis.NewCmdResult(nil, fmt.Errorf("redis: script %q not registered", name))
+	}
+	return s.Run(Ctx(ctx), keys, args...)
+}
+
+// loadScripts 把已注册的脚本预先 SCRIPT LOAD 到服务端，之后的 EVALSHA 可以直接命中
+// 加载失败不影响启动，EvalScript 会退回 EVAL
+func loadScripts(ctx context.Context, rdb *redis.Client) {
+	scriptsMu.RLock()
+	defer scriptsMu.RUnlock()
+	for name, s := range scripts {
+		if err := s.Load(rdb.WithContext(ctx)).Err(); err != nil {
+			zap.L().Named("dao").Warn("load redis script failed", zap.String("script", name), zap.Error(err))
+		}
+	}
+}
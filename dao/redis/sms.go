@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// keySMSCooldownPrefix 同一号码两次发送的最小间隔
+	keySMSCooldownPrefix = "sms:cooldown:"
+	// keySMSCountPrefix 号码在窗口期内的发送次数
+	keySMSCountPrefix = "sms:count:"
+)
+
+// SMSCooldown 号码是否在发送冷却中，冷却中返回 false 和剩余时间
+func SMSCooldown(ctx context.Context, phone string) (bool, time.Duration, error) {
+	ttl, err := Ctx(ctx).PTTL(keySMSCooldownPrefix + phone).Result()
+	if err != nil || ttl <= 0 {
+		return true, 0, err
+	}
+	return false, ttl, nil
+}
+
+// SetSMSCooldown 发送成功后开始冷却
+func SetSMSCooldown(ctx context.Context, phone string, d time.Duration) error {
+	return Ctx(ctx).Set(keySMSCooldownPrefix+phone, 1, d).Err()
+}
+
+// IncrSMSPhone 号码在窗口期内的发送次数
+func IncrSMSPhone(ctx context.Context, phone string, window time.Duration) (int64, error) {
+	return IncrWindow(ctx, keySMSCountPrefix+phone, window)
+}
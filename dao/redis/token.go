@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// keyActiveSessionPrefix 单设备登录时用户当前有效的会话 ID
+const keyActiveSessionPrefix = "jwt:active:"
+
+// keyTokenBlacklistPrefix 已吊销 token 的 jti，过期时间与 token 本身一致，token 过期后自动清理
+const keyTokenBlacklistPrefix = "jwt:blacklist:"
+
+// keyTokenGenerationPrefix 用户 token 的代数，改密码、重置密码时加一，代数小于它的 token 全部失效
+// 不设过期时间：refresh 换出的新 token 沿用原来的代数，一条 refresh 链可以一直续下去，
+// key 过期后代数从 0 重新数，旧代数的 token 会重新变成有效的
+const keyTokenGenerationPrefix = "jwt:gen:"
+
+// IncrTokenGeneration 代数加一，返回新的代数
+func IncrTokenGeneration(ctx context.Context, userID int64) (int64, error) {
+	return Ctx(ctx).Incr(keyTokenGenerationPrefix + strconv.FormatInt(userID, 10)).Result()
+}
+
+// GetTokenGeneration 用户 token 当前的代数，没有设置过时为 0
+func GetTokenGeneration(ctx context.Context, userID int64) (int64, error) {
+	n, err := Ctx(ctx).Get(keyTokenGenerationPrefix + strconv.FormatInt(userID, 10)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// RevokeToken 吊销 token，exp 为 token 的过期时间
+func RevokeToken(ctx context.Context, jti string, exp time.Time) error {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		// 已经过期的 token 本来就无法通过校验
+		return nil
+	}
+	return Ctx(ctx).Set(keyTokenBlacklistPrefix+jti, 1, ttl).Err()
+}
+
+// ClaimToken 原子地吊销 token，返回 false 表示它之前已经被吊销了（被别的请求抢先用掉）
+// 一次性的 token（refresh token 轮换）用它代替 IsTokenRevoked + RevokeToken，并发请求只有一个能成功
+func ClaimToken(ctx context.Context, jti string, exp time.Time) (bool, error) {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return false, nil
+	}
+	return Ctx(ctx).SetNX(keyTokenBlacklistPrefix+jti, 1, ttl).Result()
+}
+
+// IsTokenRevoked token 是否已被吊销
+func IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := Ctx(ctx).Exists(keyTokenBlacklistPrefix + jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// SetActiveSession 记录用户当前有效的会话 ID，之前的会话随之失效
+func SetActiveSession(ctx context.Context, userID int64, sessionID string, ttl time.Duration) error {
+	return Ctx(ctx).Set(keyActiveSessionPrefix+strconv.FormatInt(userID, 10), sessionID, ttl).Err()
+}
+
+// TouchActiveSession 延长用户当前会话记录的过期时间，只续期不改值，刷新时不会覆盖其它设备刚登录的会话
+func TouchActiveSession(ctx context.Context, userID int64, ttl time.Duration) error {
+	return Ctx(ctx).Expire(keyActiveSessionPrefix+strconv.FormatInt(userID, 10), ttl).Err()
+}
+
+// GetActiveSession 用户当前有效的会话 ID，不存在时返回空字符串
+func GetActiveSession(ctx context.Context, userID int64) (string, error) {
+	sid, err := Ctx(ctx).Get(keyActiveSessionPrefix + strconv.FormatInt(userID, 10)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return sid, err
+}
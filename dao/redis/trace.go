@@ -0,0 +1,67 @@
+package redis
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("go_web_scaffolding/dao/redis")
+
+// Ctx 返回绑定了 ctx 的客户端，业务代码统一用它执行命令：
+//
+//	redis.Ctx(ctx).Get(key)
+//
+// ctx 里有链路追踪的 span 时，每条命令（pipeline 算一条）都会记录一个子 span
+// go-redis v6 没有 hook，这里在 WithContext 复制出来的客户端上包一层 process，不影响共享的客户端
+func Ctx(ctx context.Context) *redis.Client {
+	rdb := Client().WithContext(ctx)
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return rdb
+	}
+	rdb.WrapProcess(func(old func(redis.Cmder) error) func(redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			_, span := tracer.Start(ctx, "redis "+cmd.Name(), trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system", "redis"),
+					attribute.String("db.operation", cmd.Name()),
+				))
+			defer span.End()
+			err := old(cmd)
+			endSpan(span, err)
+			return err
+		}
+	})
+	rdb.WrapProcessPipeline(func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			names := make([]string, 0, len(cmds))
+			for _, cmd := range cmds {
+				names = append(names, cmd.Name())
+			}
+			_, span := tracer.Start(ctx, "redis pipeline", trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system", "redis"),
+					attribute.String("db.operation", strings.Join(names, " ")),
+					attribute.Int("db.redis.num_cmd", len(cmds)),
+				))
+			defer span.End()
+			err := old(cmds)
+			endSpan(span, err)
+			return err
+		}
+	})
+	return rdb
+}
+
+// endSpan redis.Nil 是正常的"不存在"，不算错误
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// keyTOTPPendingPrefix 开启两步验证时还没确认的密钥
+	keyTOTPPendingPrefix = "2fa:pending:"
+	// keyTOTPRecoveryPrefix 恢复码的摘要集合，每个恢复码只能用一次
+	keyTOTPRecoveryPrefix = "2fa:recovery:"
+	// keyTOTPUsedPrefix 已使用过的验证码周期，防止同一个验证码被重放
+	keyTOTPUsedPrefix = "2fa:used:"
+	// keyMFAChallengePrefix 密码校验通过、等待输入验证码的登录
+	keyMFAChallengePrefix = "2fa:challenge:"
+)
+
+func uidKey(prefix string, userID int64) string {
+	return prefix + strconv.FormatInt(userID, 10)
+}
+
+// SavePendingTOTP 保存待确认的密钥
+func SavePendingTOTP(ctx context.Context, userID int64, secret string, ttl time.Duration) error {
+	return Ctx(ctx).Set(uidKey(keyTOTPPendingPrefix, userID), secret, ttl).Err()
+}
+
+// GetPendingTOTP 待确认的密钥，不存在时返回空字符串
+func GetPendingTOTP(ctx context.Context, userID int64) (string, error) {
+	secret, err := Ctx(ctx).Get(uidKey(keyTOTPPendingPrefix, userID)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return secret, err
+}
+
+// DeletePendingTOTP 删除待确认的密钥
+func DeletePendingTOTP(ctx context.Context, userID int64) error {
+	return Ctx(ctx).Del(uidKey(keyTOTPPendingPrefix, userID)).Err()
+}
+
+// SetRecoveryCodes 替换用户的全部恢复码，hashes 为空时只删除
+func SetRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	key := uidKey(keyTOTPRecoveryPrefix, userID)
+	_, err := TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(key)
+		if len(hashes) > 0 {
+			members := make([]interface{}, len(hashes))
+			for i, h := range hashes {
+				members[i] = h
+			}
+			pipe.SAdd(key, members...)
+		}
+		return nil
+	})
+	return err
+}
+
+// UseRecoveryCode 使用一个恢复码，存在则删除并返回 true
+func UseRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error) {
+	n, err := Ctx(ctx).SRem(uidKey(keyTOTPRecoveryPrefix, userID), hash).Result()
+	return n > 0, err
+}
+
+// CountRecoveryCodes 剩余的恢复码数量
+func CountRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	return Ctx(ctx).SCard(uidKey(keyTOTPRecoveryPrefix, userID)).Result()
+}
+
+// MarkTOTPUsed 记录验证码周期已使用，第一次使用返回 true
+func MarkTOTPUsed(ctx context.Context, userID int64, step int64, ttl time.Duration) (bool, error) {
+	key := uidKey(keyTOTPUsedPrefix, userID) + ":" + strconv.FormatInt(step, 10)
+	return Ctx(ctx).SetNX(key, 1, ttl).Result()
+}
+
+// SaveMFAChallenge 保存等待两步验证的登录
+func SaveMFAChallenge(ctx context.Context, token string, userID int64, ttl time.Duration) error {
+	key := keyMFAChallengePrefix + token
+	_, err := TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(key, "user_id", userID)
+		pipe.Expire(key, ttl)
+		return nil
+	})
+	return err
+}
+
+// AttemptMFAChallenge 记录一次验证尝试，返回登录的用户和已尝试次数，challenge 不存在时 userID 为 0
+func AttemptMFAChallenge(ctx context.Context, token string) (userID, attempts int64, err error) {
+	key := keyMFAChallengePrefix + token
+	var get *redis.StringCmd
+	var incr *redis.IntCmd
+	_, err = TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.HGet(key, "user_id")
+		incr = pipe.HIncrBy(key, "attempts", 1)
+		return nil
+	})
+	if err == redis.Nil {
+		// challenge 不存在时 HINCRBY 会创建一个没有过期时间的 key，删掉
+		return 0, 0, Ctx(ctx).Del(key).Err()
+	}
+	if err != nil {
+		return
+	}
+	if userID, err = get.Int64(); err != nil {
+		return
+	}
+	return userID, incr.Val(), nil
+}
+
+// DeleteMFAChallenge 验证通过或失败次数过多时删除
+func DeleteMFAChallenge(ctx context.Context, token string) error {
+	return Ctx(ctx).Del(keyMFAChallengePrefix + token).Err()
+}
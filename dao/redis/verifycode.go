@@ -0,0 +1,91 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// keyVerifyCodePrefix 验证码，vcode:<scene>:<address>，hash 里保存摘要和尝试次数
+	keyVerifyCodePrefix = "vcode:"
+	// keyVerifyCooldownPrefix 同一地址两次发送的最小间隔
+	keyVerifyCooldownPrefix = "vcode:cooldown:"
+	// keyVerifyAddrCountPrefix/keyVerifyIPCountPrefix 发送次数限额
+	keyVerifyAddrCountPrefix = "vcode:count:addr:"
+	keyVerifyIPCountPrefix   = "vcode:count:ip:"
+)
+
+// SaveVerifyCode 保存验证码摘要，覆盖之前发送的验证码
+func SaveVerifyCode(ctx context.Context, scene, address, codeHash string, ttl time.Duration) error {
+	key := keyVerifyCodePrefix + scene + ":" + address
+	_, err := TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(key)
+		pipe.HSet(key, "hash", codeHash)
+		pipe.Expire(key, ttl)
+		return nil
+	})
+	return err
+}
+
+// AttemptVerifyCode 记录一次校验尝试，返回验证码摘要和已尝试次数，验证码不存在时摘要为空
+func AttemptVerifyCode(ctx context.Context, scene, address string) (codeHash string, attempts int64, err error) {
+	key := keyVerifyCodePrefix + scene + ":" + address
+	var get *redis.StringCmd
+	var incr *redis.IntCmd
+	_, err = TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.HGet(key, "hash")
+		incr = pipe.HIncrBy(key, "attempts", 1)
+		return nil
+	})
+	if err == redis.Nil {
+		// 验证码不存在时 HINCRBY 会创建一个没有过期时间的 key，删掉
+		return "", 0, Ctx(ctx).Del(key).Err()
+	}
+	if err != nil {
+		return
+	}
+	return get.Val(), incr.Val(), nil
+}
+
+// DeleteVerifyCode 校验通过或尝试次数过多时删除
+func DeleteVerifyCode(ctx context.Context, scene, address string) error {
+	return Ctx(ctx).Del(keyVerifyCodePrefix + scene + ":" + address).Err()
+}
+
+// ConsumeVerifyCode 删除验证码，返回 false 表示它已经不在了（被并发的请求用掉或者过期）
+func ConsumeVerifyCode(ctx context.Context, scene, address string) (bool, error) {
+	n, err := Ctx(ctx).Del(keyVerifyCodePrefix + scene + ":" + address).Result()
+	return n > 0, err
+}
+
+// VerifyCodeCooldownTTL 发送冷却的剩余时间，不在冷却中时为 0，只读不设置
+func VerifyCodeCooldownTTL(ctx context.Context, address string) (time.Duration, error) {
+	ttl, err := Ctx(ctx).PTTL(keyVerifyCooldownPrefix + address).Result()
+	if err != nil || ttl < 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// VerifyCodeCooldown 开始发送冷却，已经在冷却中时返回 false 和剩余时间
+func VerifyCodeCooldown(ctx context.Context, address string, d time.Duration) (bool, time.Duration, error) {
+	key := keyVerifyCooldownPrefix + address
+	ok, err := Ctx(ctx).SetNX(key, 1, d).Result()
+	if err != nil || ok {
+		return ok, 0, err
+	}
+	ttl, err := Ctx(ctx).PTTL(key).Result()
+	return false, ttl, err
+}
+
+// IncrVerifyCodeAddress 地址在窗口期内的发送次数
+func IncrVerifyCodeAddress(ctx context.Context, address string, window time.Duration) (int64, error) {
+	return IncrWindow(ctx, keyVerifyAddrCountPrefix+address, window)
+}
+
+// IncrVerifyCodeIP IP 在窗口期内的发送次数
+func IncrVerifyCodeIP(ctx context.Context, ip string, window time.Duration) (int64, error) {
+	return IncrWindow(ctx, keyVerifyIPCountPrefix+ip, window)
+}
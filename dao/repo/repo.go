@@ -0,0 +1,301 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/mysql"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 通用的单表 CRUD，省去每个 dao 都要手写一遍的样板代码
+// 模型需要实现 Model 接口，字段通过 sqlx 的 db 标签映射到列：
+//
+//	type User struct {
+//		ID         int64     `db:"id"`
+//		Username   string    `db:"username"`
+//		CreateTime time.Time `db:"create_time,auto"` // auto: 由数据库生成，插入时跳过
+//	}
+//
+//	func (User) TableName() string { return "user" }
+//
+//	u, err := repo.Get[models.User](ctx, 1)
+//
+// 有 deleted_at 列的模型自动开启软删除，见 softdelete.go
+// 多表关联、复杂条件的查询仍然在 dao/mysql 里手写 SQL
+// 在 mysql.WithTx 里调用时，传入事务的 ctx 就会在同一个事务里执行
+
+// Model 数据表模型
+type Model interface {
+	TableName() string
+}
+
+// pkColumn 主键列名约定
+const pkColumn = "id"
+
+var (
+	ErrUnknownColumn = errors.New("repo: unknown column")
+	ErrNoFields      = errors.New("repo: no fields to update")
+)
+
+// Page 分页参数，Page 从 1 开始
+type Page struct {
+	Page int
+	Size int
+}
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+func (p Page) limitOffset() (limit, offset int) {
+	limit = p.Size
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	page := p.Page
+	if page < 1 {
+		page = 1
+	}
+	return limit, (page - 1) * limit
+}
+
+// Filter 等值查询条件，key 为列名，只允许模型里声明过的列
+type Filter map[string]interface{}
+
+type column struct {
+	name string
+	auto bool
+}
+
+// columns 缓存每个模型类型的列信息
+var columns sync.Map // reflect.Type -> []column
+
+func columnsOf[T Model]() []column {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if cols, ok := columns.Load(t); ok {
+		return cols.([]column)
+	}
+	cols := parseColumns(t)
+	columns.Store(t, cols)
+	return cols
+}
+
+func parseColumns(t reflect.Type) (cols []column) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("db")
+		// 没有 db 标签的匿名结构体，把它的字段展开（比如公共的时间戳字段）
+		if !ok && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			cols = append(cols, parseColumns(f.Type)...)
+			continue
+		}
+		if !ok || tag == "-" || !f.IsExported() {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		c := column{name: parts[0]}
+		for _, opt := range parts[1:] {
+			if opt == "auto" {
+				c.auto = true
+			}
+		}
+		cols = append(cols, c)
+	}
+	return
+}
+
+func tableOf[T Model]() string {
+	var m T
+	return m.TableName()
+}
+
+func selectList[T Model]() string {
+	cols := columnsOf[T]()
+	names := make([]string, 0, len(cols))
+	for _, c := range cols {
+		names = append(names, "`"+c.name+"`")
+	}
+	return strings.Join(names, ", ")
+}
+
+func hasColumn[T Model](name string) bool {
+	for _, c := range columnsOf[T]() {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// where 把 Filter 转成 WHERE 子句，列名按字母序排列保证生成的 SQL 稳定
+// 软删除的模型默认加上 deleted_at IS NULL
+func where[T Model](ctx context.Context, filter Filter) (clause string, args []interface{}, err error) {
+	conds := make([]string, 0, len(filter)+1)
+	if cond := notDeleted[T](ctx); cond != "" {
+		conds = append(conds, cond)
+	}
+	keys := make([]string, 0, len(filter))
+	for k := range filter {
+		if !hasColumn[T](k) {
+			return "", nil, fmt.Errorf("%w: %s", ErrUnknownColumn, k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		conds = append(conds, "`"+k+"` = ?")
+		args = append(args, filter[k])
+	}
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+// andNotDeleted 主键条件后面追加软删除的过滤
+func andNotDeleted[T Model](ctx context.Context) string {
+	if cond := notDeleted[T](ctx); cond != "" {
+		return " AND " + cond
+	}
+	return ""
+}
+
+// Get 按主键查询，不存在（或已软删除）时返回 sql.ErrNoRows
+func Get[T Model](ctx context.Context, id int64) (*T, error) {
+	m := new(T)
+	sqlStr := fmt.Sprintf("SELECT %s FROM `%s` WHERE `%s` = ?%s", selectList[T](), tableOf[T](), pkColumn, andNotDeleted[T](ctx))
+	if err := mysql.Conn(ctx).GetContext(ctx, m, sqlStr, id); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// List 按条件分页查询，按主键倒序，同时返回满足条件的总数
+func List[T Model](ctx context.Context, filter Filter, page Page) (list []*T, total int64, err error) {
+	cond, args, err := where[T](ctx, filter)
+	if err != nil {
+		return
+	}
+	db := mysql.Conn(ctx)
+	table := tableOf[T]()
+
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM `%s`%s", table, cond)
+	if err = db.GetContext(ctx, &total, countSQL, args...); err != nil || total == 0 {
+		return
+	}
+
+	limit, offset := page.limitOffset()
+	listSQL := fmt.Sprintf("SELECT %s FROM `%s`%s ORDER BY `%s` DESC LIMIT ? OFFSET ?",
+		selectList[T](), table, cond, pkColumn)
+	list = make([]*T, 0, limit)
+	err = db.SelectContext(ctx, &list, listSQL, append(args, limit, offset)...)
+	return
+}
+
+// Insert 插入一行，返回主键
+// 主键为零值时由数据库生成，返回自增主键；调用方自己填了主键（snowflake、号段 ID）时原样返回，
+// 这时 LastInsertId 取到的是 0；带 auto 选项的列（创建时间等）跳过
+func Insert[T Model](ctx context.Context, m *T) (id int64, err error) {
+	v := reflect.ValueOf(m).Elem()
+	fields := mysql.DB().Mapper.FieldMap(v)
+
+	var names, params []string
+	var pk reflect.Value
+	for _, c := range columnsOf[T]() {
+		if c.auto {
+			continue
+		}
+		if c.name == pkColumn {
+			f, ok := fields[c.name]
+			if ok && f.IsZero() {
+				continue
+			}
+			pk = f
+		}
+		names = append(names, "`"+c.name+"`")
+		params = append(params, ":"+c.name)
+	}
+	sqlStr := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s)",
+		tableOf[T](), strings.Join(names, ", "), strings.Join(params, ", "))
+	if !pk.IsValid() {
+		return mysql.NamedInsertID(ctx, sqlStr, m)
+	}
+	if _, err = mysql.Conn(ctx).NamedExecContext(ctx, sqlStr, m); err != nil {
+		return
+	}
+	return pkInt64(pk)
+}
+
+// pkInt64 调用方填的主键转成 int64，只支持整数主键
+func pkInt64(v reflect.Value) (int64, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(v.Uint()), nil
+	}
+	return 0, fmt.Errorf("repo: primary key %s is not an integer", v.Type())
+}
+
+// Update 按主键更新指定列，返回受影响的行数
+func Update[T Model](ctx context.Context, id int64, fields map[string]interface{}) (n int64, err error) {
+	sets, args, err := setClause[T](fields)
+	if err != nil {
+		return
+	}
+	sqlStr := fmt.Sprintf("UPDATE `%s` SET %s WHERE `%s` = ?%s", tableOf[T](), sets, pkColumn, andNotDeleted[T](ctx))
+	res, err := mysql.Conn(ctx).ExecContext(ctx, sqlStr, append(args, id)...)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+// setClause 把要更新的列转成 SET 子句，列名按字母序排列，主键不允许更新
+func setClause[T Model](fields map[string]interface{}) (clause string, args []interface{}, err error) {
+	if len(fields) == 0 {
+		return "", nil, ErrNoFields
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if !hasColumn[T](k) || k == pkColumn {
+			return "", nil, fmt.Errorf("%w: %s", ErrUnknownColumn, k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sets := make([]string, 0, len(keys))
+	args = make([]interface{}, 0, len(keys)+1)
+	for _, k := range keys {
+		sets = append(sets, "`"+k+"` = ?")
+		args = append(args, fields[k])
+	}
+	return strings.Join(sets, ", "), args, nil
+}
+
+// Delete 按主键删除，返回受影响的行数
+// 软删除的模型只设置 deleted_at，已经删除过的行不重复删除；ctx 为 Unscoped 时物理删除
+func Delete[T Model](ctx context.Context, id int64) (n int64, err error) {
+	sqlStr := fmt.Sprintf("DELETE FROM `%s` WHERE `%s` = ?", tableOf[T](), pkColumn)
+	args := []interface{}{id}
+	if softDeletable[T]() && scopeOf(ctx) != scopeUnscoped {
+		// 删除时间由应用传入，SQLite 没有 NOW()
+		sqlStr = fmt.Sprintf("UPDATE `%s` SET `%s` = ? WHERE `%s` = ? AND `%s` IS NULL",
+			tableOf[T](), deletedColumn, pkColumn, deletedColumn)
+		args = []interface{}{time.Now(), id}
+	}
+	res, err := mysql.Conn(ctx).ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
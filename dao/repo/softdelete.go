@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/dao/mysql"
+	"time"
+)
+
+// 软删除：模型有 deleted_at 列时（可以直接嵌入 SoftDelete），Delete 只把 deleted_at 设为当前时间，
+// Get/List/Update 默认跳过已删除的行
+//
+//	type Article struct {
+//		ID    int64  `db:"id"`
+//		Title string `db:"title"`
+//		repo.SoftDelete
+//	}
+//
+//	repo.Delete[models.Article](ctx, id)                      // 标记删除
+//	repo.Get[models.Article](repo.WithDeleted(ctx), id)       // 查询时包含已删除的行
+//	repo.Delete[models.Article](repo.Unscoped(ctx), id)       // 物理删除
+//	repo.Restore[models.Article](ctx, id)                     // 恢复
+//
+// 表里要给 deleted_at 建索引（或者放在联合索引里），唯一索引要考虑已删除的行，比如把 deleted_at 加进唯一索引
+
+// deletedColumn 软删除列名约定
+const deletedColumn = "deleted_at"
+
+// SoftDelete 嵌入模型即可开启软删除，对应列 `deleted_at` timestamp NULL DEFAULT NULL
+type SoftDelete struct {
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+// Deleted 是否已被软删除
+func (s SoftDelete) Deleted() bool {
+	return s.DeletedAt != nil
+}
+
+type scopeKey struct{}
+
+type scope int
+
+const (
+	scopeDefault scope = iota
+	// scopeWithDeleted 查询包含已删除的行
+	scopeWithDeleted
+	// scopeUnscoped 完全忽略软删除，Delete 变成物理删除
+	scopeUnscoped
+)
+
+// WithDeleted 返回的 ctx 上的查询包含已软删除的行，Delete 仍然是软删除
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scopeWithDeleted)
+}
+
+// Unscoped 返回的 ctx 上完全忽略软删除：查询包含已删除的行，Delete 物理删除
+func Unscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scopeUnscoped)
+}
+
+func scopeOf(ctx context.Context) scope {
+	s, _ := ctx.Value(scopeKey{}).(scope)
+	return s
+}
+
+func softDeletable[T Model]() bool {
+	return hasColumn[T](deletedColumn)
+}
+
+// notDeleted 需要过滤已删除的行时返回条件，否则返回空字符串
+func notDeleted[T Model](ctx context.Context) string {
+	if !softDeletable[T]() || scopeOf(ctx) != scopeDefault {
+		return ""
+	}
+	return "`" + deletedColumn + "` IS NULL"
+}
+
+// Restore 恢复软删除的行，返回受影响的行数
+func Restore[T Model](ctx context.Context, id int64) (n int64, err error) {
+	if !softDeletable[T]() {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownColumn, deletedColumn)
+	}
+	sqlStr := fmt.Sprintf("UPDATE `%s` SET `%s` = NULL WHERE `%s` = ?", tableOf[T](), deletedColumn, pkColumn)
+	res, err := mysql.Conn(ctx).ExecContext(ctx, sqlStr, id)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
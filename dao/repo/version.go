@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/pkg/apperr"
+)
+
+// 乐观锁：模型有 version 列时，用 UpdateWithVersion 更新，只有版本号和读出来时一样才会更新成功，同时版本号加一
+// 两个请求读到同一版本后先后更新，后面那个会拿到 ErrVersionConflict，而不是悄悄覆盖前一个的修改
+//
+//	a, _ := repo.Get[models.Article](ctx, id)
+//	err := repo.UpdateWithVersion[models.Article](ctx, id, a.Version, map[string]interface{}{"title": title})
+//	if errors.Is(err, repo.ErrVersionConflict) {
+//		// 重新读取后重试，或者提示用户刷新
+//	}
+
+// versionColumn 乐观锁版本号列名约定，对应列 `version` int NOT NULL DEFAULT 0
+const versionColumn = "version"
+
+// ErrVersionConflict 版本号不一致，数据在读取之后被别人修改了
+// 是 apperr，controller 直接交给 response.Error 会返回 409
+var ErrVersionConflict = apperr.Conflict("record has been modified, please reload and retry")
+
+// UpdateWithVersion 按主键和版本号更新指定列，版本号自动加一
+// 行不存在（或已软删除）时返回 sql.ErrNoRows，版本号不一致时返回 ErrVersionConflict
+func UpdateWithVersion[T Model](ctx context.Context, id, version int64, fields map[string]interface{}) error {
+	if !hasColumn[T](versionColumn) {
+		return fmt.Errorf("%w: %s", ErrUnknownColumn, versionColumn)
+	}
+	if _, ok := fields[versionColumn]; ok {
+		return fmt.Errorf("%w: %s is managed by UpdateWithVersion", ErrUnknownColumn, versionColumn)
+	}
+	sets, args, err := setClause[T](fields)
+	if err != nil {
+		return err
+	}
+	table := tableOf[T]()
+	sqlStr := fmt.Sprintf("UPDATE `%s` SET %s, `%s` = `%s` + 1 WHERE `%s` = ? AND `%s` = ?%s",
+		table, sets, versionColumn, versionColumn, pkColumn, versionColumn, andNotDeleted[T](ctx))
+	res, err := mysql.Conn(ctx).ExecContext(ctx, sqlStr, append(args, id, version)...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	// 没有更新到任何行：区分是行不存在还是版本号变了
+	var exist int
+	err = mysql.Conn(ctx).GetContext(ctx, &exist,
+		fmt.Sprintf("SELECT 1 FROM `%s` WHERE `%s` = ?%s", table, pkColumn, andNotDeleted[T](ctx)), id)
+	if err == sql.ErrNoRows {
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		return err
+	}
+	return ErrVersionConflict
+}
@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/sentry"
+	"go_web_scaffolding/settings"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/trace/otel"
+	"go.uber.org/zap"
+)
+
+// GraphQL 接口，schema 在 schema.graphql 里，解析函数在 resolver.go 里
+// 和 REST 接口共用 logic 和 dao，登录校验和 RBAC 由路由上的 JWTAuthMiddleware、Authorize 完成
+//
+// 用 graph-gophers/graphql-go 而不是 gqlgen：它在启动时解析 schema 并按方法名绑定 resolver，
+// 不需要生成代码，改 schema 后不用跑 go generate，也不会多出一大块要跟着提交的生成文件；
+// 代价是类型在启动时而不是编译时校验，schema 和 resolver 对不上时 Handler 会直接返回错误
+//
+// 请求：POST /graphql {"query": "...", "operationName": "...", "variables": {...}}
+// 响应：标准的 GraphQL 响应，HTTP 状态码总是 200；错误的 extensions.code 是业务状态码
+// 超过复杂度限制、请求体格式错误时在执行前拒绝，返回 400
+
+const (
+	defaultMaxDepth      = 8
+	defaultMaxComplexity = 200
+	// maxParallelism 一个查询里同时执行的解析函数的上限
+	maxParallelism = 10
+)
+
+//go:embed schema.graphql
+var schemaString string
+
+type request struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler 解析 schema 并返回 /graphql 的处理函数，schema 和解析函数对不上时返回错误
+func Handler(cfg *settings.GraphQLConfig) (gin.HandlerFunc, error) {
+	maxDepth := cfg.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDepth
+	}
+	maxComplexity := cfg.MaxComplexity
+	if maxComplexity <= 0 {
+		maxComplexity = defaultMaxComplexity
+	}
+	opts := []graphql.SchemaOpt{
+		graphql.MaxDepth(maxDepth),
+		graphql.MaxParallelism(maxParallelism),
+		graphql.Tracer(otel.DefaultTracer()),
+		graphql.Logger(panicLogger{}),
+	}
+	if !cfg.Introspection {
+		opts = append(opts, graphql.DisableIntrospection())
+	}
+	schema, err := graphql.ParseSchema(schemaString, &resolver{}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("parse graphql schema: %w", err)
+	}
+
+	return func(c *gin.Context) {
+		var req request
+		if err := c.ShouldBindJSON(&req); err != nil {
+			badRequest(c, "invalid request body")
+			return
+		}
+		if err := checkComplexity(req.Query, req.OperationName, req.Variables, maxComplexity); err != nil {
+			// 语法错误直接返回解析器的错误信息，graphql-go 执行时也会报同样的错
+			if errors.Is(err, errTooComplex) {
+				logger.Module(c.Request.Context(), "graphql").Warn("query rejected", zap.Error(err))
+			}
+			badRequest(c, err.Error())
+			return
+		}
+		ctx := withLoaders(c.Request.Context())
+		c.JSON(http.StatusOK, schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+	}, nil
+}
+
+func badRequest(c *gin.Context, msg string) {
+	c.JSON(http.StatusBadRequest, &graphql.Response{Errors: []*gqlerrors.QueryError{{
+		Message:    msg,
+		Extensions: map[string]interface{}{"code": response.CodeInvalidParam},
+	}}})
+}
+
+// panicLogger 解析函数 panic 时 graphql-go 会 recover 并把它变成字段错误，这里记录日志并上报 Sentry
+type panicLogger struct{}
+
+func (panicLogger) LogPanic(ctx context.Context, value interface{}) {
+	sentry.CapturePanicContext(ctx, value, "")
+	logger.Module(ctx, "graphql").Error("resolver panic", zap.Any("error", value), zap.Stack("stack"))
+}
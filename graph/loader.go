@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"context"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/dataloader"
+)
+
+type loadersKey struct{}
+
+// loaders 每个请求一组，请求结束后连同缓存一起丢弃
+type loaders struct {
+	users         *dataloader.Loader[int64, *models.User]
+	oauthBindings *dataloader.Loader[int64, []*models.UserOAuth]
+}
+
+func withLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		users:         dataloader.New(fetchUsers),
+		oauthBindings: dataloader.New(fetchOAuthBindings),
+	})
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+func fetchUsers(ctx context.Context, ids []int64) (map[int64]*models.User, error) {
+	list, err := mysql.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]*models.User, len(list))
+	for _, u := range list {
+		res[u.UserID] = u
+	}
+	return res, nil
+}
+
+// fetchOAuthBindings 没有绑定的用户也放一个空列表，不算 not found
+func fetchOAuthBindings(ctx context.Context, userIDs []int64) (map[int64][]*models.UserOAuth, error) {
+	list, err := mysql.ListUserOAuthByUserIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64][]*models.UserOAuth, len(userIDs))
+	for _, id := range userIDs {
+		res[id] = nil
+	}
+	for _, o := range list {
+		res[o.UserID] = append(res[o.UserID], o)
+	}
+	return res, nil
+}
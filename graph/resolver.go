@@ -0,0 +1,182 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/dataloader"
+	"go_web_scaffolding/pkg/rbac"
+	"strconv"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"go.uber.org/zap"
+)
+
+// maxBatchIDs users 查询一次最多的 ID 数
+const maxBatchIDs = 100
+
+// resolver Query 的根，和 controller 一样只做参数转换，查询自己走 logic，按 ID 查询别人走 dataloader 批量查库
+type resolver struct{}
+
+func (r *resolver) Me(ctx context.Context) (*userResolver, error) {
+	u, err := logic.GetProfile(ctx, ctxutil.UserID(ctx))
+	if err != nil {
+		return nil, resolveError(ctx, err)
+	}
+	return &userResolver{u: u}, nil
+}
+
+func (r *resolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	u, err := loadersFrom(ctx).users.Load(ctx, id)
+	if errors.Is(err, dataloader.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolveError(ctx, err)
+	}
+	return &userResolver{u: u}, nil
+}
+
+func (r *resolver) Users(ctx context.Context, args struct{ IDs []graphql.ID }) ([]*userResolver, error) {
+	if len(args.IDs) > maxBatchIDs {
+		return nil, newError(response.CodeInvalidParam, "ids 最多 "+strconv.Itoa(maxBatchIDs)+" 个")
+	}
+	ids := make([]int64, len(args.IDs))
+	for i, s := range args.IDs {
+		id, err := parseID(s)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	users, errs := loadersFrom(ctx).users.LoadMany(ctx, ids)
+	res := make([]*userResolver, len(ids))
+	for i, u := range users {
+		switch {
+		case errs[i] == nil:
+			res[i] = &userResolver{u: u}
+		case !errors.Is(errs[i], dataloader.ErrNotFound):
+			return nil, resolveError(ctx, errs[i])
+		}
+	}
+	return res, nil
+}
+
+func parseID(id graphql.ID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil {
+		return 0, newError(response.CodeInvalidParam, "invalid id: "+string(id))
+	}
+	return n, nil
+}
+
+type userResolver struct {
+	u *models.User
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(r.u.UserID, 10))
+}
+
+func (r *userResolver) Username() string {
+	return r.u.Username
+}
+
+func (r *userResolver) Nickname() string {
+	return r.u.Nickname
+}
+
+func (r *userResolver) Avatar() string {
+	return r.u.Avatar
+}
+
+// Email 只返回给本人
+func (r *userResolver) Email(ctx context.Context) *string {
+	if ctxutil.UserID(ctx) != r.u.UserID {
+		return nil
+	}
+	return &r.u.Email
+}
+
+func (r *userResolver) CreateTime() graphql.Time {
+	return graphql.Time{Time: r.u.CreateTime}
+}
+
+// oauthBindingsObject 查看他人绑定关系需要的 casbin 权限，比如给管理员角色加策略 p, admin, graphql:oauthBindings, read
+const oauthBindingsObject = "graphql:oauthBindings"
+
+// OAuthBindings 同一个查询里多个用户的绑定关系合并成一次查询
+// 只返回给本人；开启 RBAC 时拥有 graphql:oauthBindings 读权限的用户（管理员）也能看别人的
+func (r *userResolver) OAuthBindings(ctx context.Context) ([]*oauthBindingResolver, error) {
+	if uid := ctxutil.UserID(ctx); uid != r.u.UserID {
+		if !rbac.Enabled() {
+			return []*oauthBindingResolver{}, nil
+		}
+		allowed, err := rbac.Enforce(strconv.FormatInt(uid, 10), oauthBindingsObject, "read")
+		if err != nil {
+			return nil, resolveError(ctx, err)
+		}
+		if !allowed {
+			return []*oauthBindingResolver{}, nil
+		}
+	}
+	list, err := loadersFrom(ctx).oauthBindings.Load(ctx, r.u.UserID)
+	if err != nil && !errors.Is(err, dataloader.ErrNotFound) {
+		return nil, resolveError(ctx, err)
+	}
+	res := make([]*oauthBindingResolver, 0, len(list))
+	for _, o := range list {
+		res = append(res, &oauthBindingResolver{o: o})
+	}
+	return res, nil
+}
+
+type oauthBindingResolver struct {
+	o *models.UserOAuth
+}
+
+func (r *oauthBindingResolver) Provider() string {
+	return r.o.Provider
+}
+
+func (r *oauthBindingResolver) CreateTime() graphql.Time {
+	return graphql.Time{Time: r.o.CreateTime}
+}
+
+// Error 返回给客户端的错误，extensions.code 是业务状态码，和 REST 接口的 code 一致
+type Error struct {
+	Code response.ResCode
+	Msg  string
+}
+
+func newError(code response.ResCode, msg string) *Error {
+	if msg == "" {
+		msg = code.Msg()
+	}
+	return &Error{Code: code, Msg: msg}
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// resolveError 业务错误按状态码返回，其它错误记录日志后统一返回"服务繁忙"，不把底层错误暴露给客户端
+func resolveError(ctx context.Context, err error) error {
+	if errors.Is(err, logic.ErrUserNotExist) {
+		return newError(response.CodeUserNotExist, "")
+	}
+	logger.Module(ctx, "graphql").Error("resolve failed", zap.Error(err))
+	return newError(response.CodeServerBusy, "")
+}
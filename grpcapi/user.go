@@ -0,0 +1,83 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	userv1 "go_web_scaffolding/api/user/v1"
+	"go_web_scaffolding/controller"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/ctxutil"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserServer 用户资料的 gRPC 实现，和 controller 一样只做参数转换，业务逻辑都在 logic 里
+// 经过 grpc-gateway 时同一套实现同时提供 /v1/user/profile 的 REST 接口
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+}
+
+func (s *UserServer) GetProfile(ctx context.Context, req *userv1.GetProfileRequest) (*userv1.User, error) {
+	u, err := logic.GetProfile(ctx, ctxutil.UserID(ctx))
+	if err != nil {
+		return nil, userError(ctx, err)
+	}
+	return toUser(u), nil
+}
+
+func (s *UserServer) UpdateProfile(ctx context.Context, req *userv1.UpdateProfileRequest) (*userv1.User, error) {
+	// 和 REST 接口共用同一套校验规则
+	p := &controller.ParamUpdateProfile{Nickname: req.GetNickname(), Avatar: req.GetAvatar()}
+	if err := validate(p); err != nil {
+		return nil, err
+	}
+	u, err := logic.UpdateProfile(ctx, ctxutil.UserID(ctx), &logic.ProfileParams{Nickname: p.Nickname, Avatar: p.Avatar})
+	if err != nil {
+		return nil, userError(ctx, err)
+	}
+	return toUser(u), nil
+}
+
+func toUser(u *models.User) *userv1.User {
+	return &userv1.User{
+		UserId:     u.UserID,
+		Username:   u.Username,
+		Nickname:   u.Nickname,
+		Email:      u.Email,
+		Avatar:     u.Avatar,
+		CreateTime: timestamppb.New(u.CreateTime),
+		UpdateTime: timestamppb.New(u.UpdateTime),
+	}
+}
+
+// userError 对应 controller 的 userError，业务错误转成对应的状态码，其它错误按内部错误处理
+func userError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, logic.ErrUserNotExist):
+		return response.CodeUserNotExist.GRPCError("")
+	default:
+		return response.GRPC(ctx, err)
+	}
+}
+
+// validate 按 binding 标签校验参数，失败时返回 InvalidArgument，提示里列出不合法的字段
+func validate(p interface{}) error {
+	err := binding.Validator.ValidateStruct(p)
+	if err == nil {
+		return nil
+	}
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return response.CodeInvalidParam.GRPCError("")
+	}
+	fields := make([]string, 0, len(ves))
+	for _, fe := range ves {
+		fields = append(fields, strings.ToLower(fe.Field())+": "+fe.Tag())
+	}
+	return response.CodeInvalidParam.GRPCError(response.CodeInvalidParam.Msg() + ": " + strings.Join(fields, ", "))
+}
@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"fmt"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/settings"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 访问日志默认和应用日志写在同一个文件里；配置了 log.access.filename 后单独写一个文件，
+// 格式可选：
+//
+//	json      每行一个 JSON，字段和写在应用日志里时一样，方便导入 ES/Loki
+//	common    Apache Common Log Format：127.0.0.1 - - [10/Oct/2000:13:55:36 +0800] "GET /a HTTP/1.1" 200 2326
+//	combined  在 common 后面再加上 "Referer" "User-Agent"，GoAccess、AWStats 等工具可以直接分析
+
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+var (
+	// accessLog json 格式的访问日志，nil 表示没有单独配置
+	accessLog *zap.Logger
+	// accessWriter common/combined 格式直接写文本行
+	accessWriter zapcore.WriteSyncer
+	accessFormat string
+)
+
+// initAccessLog 轮转相关的配置（压缩、按天切割、本地时间）和应用日志保持一致
+func initAccessLog(cfg *settings.LogConfig) (err error) {
+	accessLog, accessWriter = nil, nil
+	ac := cfg.Access
+	if ac == nil || ac.Filename == "" {
+		return
+	}
+	w := getLogWriter(&settings.LogConfig{
+		Filename:    ac.Filename,
+		MaxSize:     ac.MaxSize,
+		MaxAge:      ac.MaxAge,
+		MaxBackups:  ac.MaxBackups,
+		Compress:    cfg.Compress,
+		LocalTime:   cfg.LocalTime,
+		RotateDaily: cfg.RotateDaily,
+	})
+	switch ac.Format {
+	case "json", "":
+		encoderConfig := zap.NewProductionEncoderConfig()
+		encoderConfig.TimeKey = "time"
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoderConfig.EncodeDuration = zapcore.SecondsDurationEncoder
+		// 访问日志不需要级别和调用位置
+		encoderConfig.LevelKey = zapcore.OmitKey
+		encoderConfig.CallerKey = zapcore.OmitKey
+		accessLog = zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), w, zapcore.InfoLevel))
+	case "common", "combined":
+		accessWriter = zapcore.Lock(w)
+	default:
+		return fmt.Errorf("logger: unknown access log format %q", ac.Format)
+	}
+	accessFormat = ac.Format
+	return
+}
+
+// writeAccess 记录一条访问日志
+func writeAccess(c *gin.Context, start time.Time, cost time.Duration, path, query string) {
+	if accessWriter != nil {
+		writeCLF(c, start, path, query)
+		return
+	}
+	ctx := c.Request.Context()
+	fields := []zap.Field{
+		zap.Int("status", c.Writer.Status()),
+		zap.String("method", c.Request.Method),
+		zap.String("path", path),
+		zap.String("query", MaskQuery(query)),
+		zap.String("ip", c.ClientIP()),
+		zap.String("user-agent", c.Request.UserAgent()),
+		zap.String("errors", c.Errors.ByType(gin.ErrorTypePrivate).String()),
+		zap.Duration("cost", cost),
+	}
+	if accessLog != nil {
+		accessLog.Info(path, append(fields, contextFields(ctx)...)...)
+		return
+	}
+	Ctx(ctx).Info(path, fields...)
+}
+
+func writeCLF(c *gin.Context, start time.Time, path, query string) {
+	uri := path
+	if query != "" {
+		uri += "?" + MaskQuery(query)
+	}
+	size := c.Writer.Size()
+	if size < 0 {
+		size = 0
+	}
+	user := "-"
+	if uid := ctxutil.UserID(c.Request.Context()); uid != 0 {
+		user = strconv.FormatInt(uid, 10)
+	}
+	line := fmt.Sprintf("%s - %s [%s] %q %d %d",
+		c.ClientIP(), user, start.Format(clfTimeLayout),
+		c.Request.Method+" "+uri+" "+c.Request.Proto, c.Writer.Status(), size)
+	if accessFormat == "combined" {
+		line += fmt.Sprintf(" %q %q", dash(c.Request.Referer()), dash(c.Request.UserAgent()))
+	}
+	_, _ = accessWriter.Write([]byte(line + "\n"))
+}
+
+func dash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
+
+// syncAccess 程序退出前把访问日志刷到文件
+func syncAccess() {
+	if accessLog != nil {
+		_ = accessLog.Sync()
+	}
+	if accessWriter != nil {
+		_ = accessWriter.Sync()
+	}
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"context"
+	"go_web_scaffolding/pkg/ctxutil"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Ctx 返回带有请求上下文字段（request_id、trace_id、user_id、tenant）的 logger
+// 业务代码统一这样打日志，同一个请求的所有日志都能用 request_id 串起来：
+//
+//	logger.Ctx(ctx).Error("mysql.GetUser failed", zap.Error(err))
+func Ctx(ctx context.Context) *zap.Logger {
+	lg := zap.L()
+	if ctx == nil {
+		return lg
+	}
+	fields := contextFields(ctx)
+	if len(fields) == 0 {
+		return lg
+	}
+	return lg.With(fields...)
+}
+
+// contextFields ctx 里的请求 ID、trace_id、用户、租户
+func contextFields(ctx context.Context) []zap.Field {
+	fields := make([]zap.Field, 0, 4)
+	if id := ctxutil.RequestID(ctx); id != "" {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	// 开启链路追踪时带上 trace_id，可以从日志直接跳到 Jaeger/Tempo 里对应的链路
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields, zap.String("trace_id", sc.TraceID().String()))
+	}
+	if uid := ctxutil.UserID(ctx); uid != 0 {
+		fields = append(fields, zap.Int64("user_id", uid))
+	}
+	if tenant := ctxutil.Tenant(ctx); tenant != "" {
+		fields = append(fields, zap.String("tenant", tenant))
+	}
+	return fields
+}
+
+// Sync 把缓冲区中的日志刷到文件，并把外部输出缓冲区里的日志发完，程序退出前调用
+func Sync() {
+	_ = zap.L().Sync()
+	syncAccess()
+	closeSinks()
+}
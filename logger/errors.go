@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"context"
+	"go_web_scaffolding/pkg/dashboard"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// recentErrorsSize 保留的最近错误日志条数
+const recentErrorsSize = 50
+
+// ErrorEntry 一条错误日志的摘要
+type ErrorEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Caller  string    `json:"caller"`
+}
+
+// 环形缓冲区保存最近的错误日志，给管理接口展示
+var (
+	recentMu   sync.Mutex
+	recent     = make([]ErrorEntry, recentErrorsSize)
+	recentNext int
+	recentFull bool
+)
+
+func init() {
+	dashboard.Register("recent_errors", func(ctx context.Context) interface{} {
+		return RecentErrors()
+	})
+}
+
+// recordError 作为 zap 的 hook 挂在 logger 上，只记录 Error 及以上级别
+func recordError(e zapcore.Entry) error {
+	if e.Level < zapcore.ErrorLevel {
+		return nil
+	}
+	recentMu.Lock()
+	recent[recentNext] = ErrorEntry{
+		Time:    e.Time,
+		Level:   e.Level.CapitalString(),
+		Message: e.Message,
+		Caller:  e.Caller.TrimmedPath(),
+	}
+	recentNext = (recentNext + 1) % recentErrorsSize
+	if recentNext == 0 {
+		recentFull = true
+	}
+	recentMu.Unlock()
+	return nil
+}
+
+// RecentErrors 最近的错误日志，新的在前
+func RecentErrors() []ErrorEntry {
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	n := recentNext
+	if recentFull {
+		n = recentErrorsSize
+	}
+	res := make([]ErrorEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		res = append(res, recent[(recentNext-i+recentErrorsSize)%recentErrorsSize])
+	}
+	return res
+}
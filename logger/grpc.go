@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"context"
+	"go_web_scaffolding/pkg/alert"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/sentry"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC 版的 GinLogger 和 GinRecovery，拦截器的顺序和 gin 中间件一样：日志在外层，recovery 在里层，
+// panic 转换成的 Internal 也能记到访问日志里
+// 访问日志配置成 common/combined 时 gRPC 请求写在应用日志里，这两种格式表达不了 gRPC 的方法和状态码
+
+// GRPCUnaryLogger 每个请求记录一条访问日志
+func GRPCUnaryLogger() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		start := time.Now()
+		resp, err = handler(ctx, req)
+		writeGRPCAccess(ctx, info.FullMethod, time.Since(start), err)
+		return
+	}
+}
+
+// GRPCStreamLogger 流式调用在流结束时记录一条访问日志
+func GRPCStreamLogger() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		start := time.Now()
+		err = handler(srv, ss)
+		writeGRPCAccess(ss.Context(), info.FullMethod, time.Since(start), err)
+		return
+	}
+}
+
+func writeGRPCAccess(ctx context.Context, method string, cost time.Duration, err error) {
+	code := status.Code(err)
+	alert.Observe(grpcHTTPStatus(code))
+	ip := ctxutil.ClientIP(ctx)
+	if p, ok := peer.FromContext(ctx); ok && ip == "" {
+		ip = p.Addr.String()
+	}
+	fields := []zap.Field{
+		zap.String("protocol", "grpc"),
+		zap.String("code", code.String()),
+		zap.String("method", method),
+		zap.String("ip", ip),
+		zap.Duration("cost", cost),
+	}
+	if err != nil {
+		fields = append(fields, zap.String("errors", err.Error()))
+	}
+	if accessLog != nil {
+		accessLog.Info(method, append(fields, contextFields(ctx)...)...)
+		return
+	}
+	Ctx(ctx).Info(method, fields...)
+}
+
+// grpcHTTPStatus 告警按 HTTP 状态码统计错误率，服务端的错误算作 500
+func grpcHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded:
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+// GRPCUnaryRecovery recover 掉处理函数的 panic，记录日志、上报 Sentry 和告警后返回 codes.Internal
+func GRPCUnaryRecovery(stack bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recoverGRPC(ctx, info.FullMethod, p, stack)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// GRPCStreamRecovery 流式调用的 recovery
+func GRPCStreamRecovery(stack bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recoverGRPC(ss.Context(), info.FullMethod, p, stack)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// recoverGRPC 需要在 recover 所在的 defer 函数里直接调用，指纹和 Sentry 的堆栈才是 panic 发生处的
+func recoverGRPC(ctx context.Context, method string, p any, stack bool) error {
+	stat, logStack := recordPanic(p)
+	sentry.CapturePanicContext(ctx, p, stat.Fingerprint)
+	alert.Panic(ctx, stat.Fingerprint, p)
+	fields := []zap.Field{
+		zap.Any("error", p),
+		zap.String("method", method),
+		zap.String("fingerprint", stat.Fingerprint),
+		zap.Int64("count", stat.Count),
+	}
+	if stack && logStack {
+		fields = append(fields, zap.String("stack", string(debug.Stack())))
+	}
+	Ctx(ctx).Error("[Recovery from panic]", fields...)
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,101 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 日志级别可以在运行时修改，线上排查问题时临时打开 debug，不需要重新发布：
+//
+//	curl -X PUT -H 'X-Admin-Token: ...' localhost:8081/admin/log/level -d '{"level":"debug","duration":600}'
+//	kill -USR2 <pid>   # 在配置的级别和 debug 之间切换
+//
+// 指定了 duration 时到期自动恢复成配置文件里的级别，忘了改回来也不会一直打 debug 日志
+// 这里修改的是全局级别，log.levels 里单独配置了级别的模块不受影响
+
+var (
+	// atomicLevel 所有 core 共用，修改后立即对所有 logger 生效
+	atomicLevel = zap.NewAtomicLevel()
+	// baseLevel 配置文件里的级别，临时修改到期后恢复成它
+	baseLevel = zapcore.InfoLevel
+
+	levelMu    sync.Mutex
+	resetTimer *time.Timer
+	resetAt    time.Time
+)
+
+// LevelInfo 当前日志级别
+type LevelInfo struct {
+	Level   string            `json:"level"`
+	Default string            `json:"default"`
+	ResetAt *time.Time        `json:"reset_at,omitempty"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+// Level 当前日志级别，以及临时修改的到期时间
+func Level() LevelInfo {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	info := LevelInfo{Level: atomicLevel.Level().String(), Default: baseLevel.String()}
+	if resetTimer != nil {
+		t := resetAt
+		info.ResetAt = &t
+	}
+	if len(moduleLevels) > 0 {
+		info.Modules = make(map[string]string, len(moduleLevels))
+		for name, l := range moduleLevels {
+			info.Modules[name] = l.String()
+		}
+	}
+	return info
+}
+
+// SetLevel 修改日志级别，只允许 debug/info/warn/error
+// d 大于 0 时到期恢复成配置的级别，等于 0 时一直生效
+func SetLevel(level string, d time.Duration) error {
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+	if l < zapcore.DebugLevel || l > zapcore.ErrorLevel {
+		return fmt.Errorf("logger: level %q not allowed", level)
+	}
+	setLevel(l, d)
+	return nil
+}
+
+func setLevel(l zapcore.Level, d time.Duration) {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	if resetTimer != nil {
+		resetTimer.Stop()
+		resetTimer = nil
+	}
+	old := atomicLevel.Level()
+	atomicLevel.SetLevel(l)
+	if d > 0 && l != baseLevel {
+		resetAt = time.Now().Add(d)
+		resetTimer = time.AfterFunc(d, func() {
+			levelMu.Lock()
+			resetTimer = nil
+			atomicLevel.SetLevel(baseLevel)
+			levelMu.Unlock()
+			zap.L().Info("log level restored", zap.Stringer("level", baseLevel))
+		})
+	}
+	// 用 Warn 打印，切到 warn/error 时这条日志也能看到
+	zap.L().Warn("log level changed", zap.Stringer("from", old), zap.Stringer("to", l), zap.Duration("duration", d))
+}
+
+// toggleDebug 在 debug 和配置的级别之间切换，给 SIGUSR2 使用
+func toggleDebug() {
+	if atomicLevel.Level() == zapcore.DebugLevel {
+		setLevel(baseLevel, 0)
+		return
+	}
+	setLevel(zapcore.DebugLevel, 0)
+}
@@ -1,6 +1,9 @@
 package logger
 
 import (
+	"go_web_scaffolding/pkg/alert"
+	"go_web_scaffolding/pkg/k8s"
+	"go_web_scaffolding/pkg/sentry"
 	"go_web_scaffolding/settings"
 	"net"
 	"net/http"
@@ -18,14 +21,12 @@ import (
 
 // 此时的就不能全局，否则在main里会是：logger.Logger.Debug()，变量会很长
 // var Logger *zap.Logger
+// 初始化后通过 zap.L() 使用全局 logger，请求相关的日志用 logger.Ctx(ctx) 自动带上请求ID等字段
 
-func Init(cfg *settings.LogConfig) (err error) {
-	writeSyncer := getLogWriter(
-		cfg.Filename,
-		cfg.MaxSize,
-		cfg.MaxBackups,
-		cfg.MaxAge,
-	)
+// Init 初始化全局 logger，mode 为 dev、local 时额外输出一份到终端
+// 不开启 zap 的开发模式：它会让 DPanic 级别的日志直接 panic，默认配置的 mode 是 dev，照搬上线就会把服务打挂
+func Init(cfg *settings.LogConfig, mode string) (err error) {
+	writeSyncer := getLogWriter(cfg)
 
 	encoder := getEncoder()
 
@@ -43,12 +44,53 @@ func Init(cfg *settings.LogConfig) (err error) {
 	if err != nil {
 		return
 	}
+	// core 使用 AtomicLevel 而不是固定的级别，运行时可以通过 SetLevel 修改
+	baseLevel = *level
+	atomicLevel.SetLevel(*level)
+	//
+	if moduleLevels, err = parseModuleLevels(cfg.Levels); err != nil {
+		return
+	}
+	if err = initAccessLog(cfg); err != nil {
+		return
+	}
 	//
 	// 将 1编码器 2写入器 3级别 组装成core
-	core := zapcore.NewCore(encoder, writeSyncer, level)
+	// 级别统一由外层的 moduleCore 按模块判断，这里不再过滤
+	var core zapcore.Core = zapcore.NewCore(encoder, writeSyncer, zapcore.DebugLevel)
+	// dev、local 模式下同时输出到终端，用人能直接看的 console 格式（带颜色），文件里仍然是 JSON
+	// 其它模式只写文件，由日志采集读取
+	cores := []zapcore.Core{core}
+	if mode == "dev" || mode == "local" {
+		cores = append(cores, zapcore.NewCore(getConsoleEncoder(), zapcore.Lock(os.Stdout), zapcore.DebugLevel))
+	}
+	// 配置了外部输出（Kafka、Loki、syslog）时同时发送一份
+	closeSinks()
+	sinkCores, err := newSinkCores(cfg.Sinks, encoder)
+	if err != nil {
+		return
+	}
+	// 后面的步骤失败时关掉刚创建的 sink，不留下没有 logger 在用的后台发送协程
+	defer func() {
+		if err != nil {
+			closeSinks()
+		}
+	}()
+	core = zapcore.NewTee(append(cores, sinkCores...)...)
+	// 敏感字段脱敏放在最里面，所有输出都经过它
+	setMaskKeys(cfg.MaskKeys)
+	core = &maskCore{Core: core}
+	// 先按模块判断级别，通过的再采样，被级别过滤掉的日志不占采样的名额
+	if core, err = newSamplingCore(core, cfg.Sampling); err != nil {
+		return
+	}
+	core = &moduleCore{Core: core}
 	// New()是把核心零件组装成 完整的日志实例
 	// 其中，zap.AddCaller()是让 zap 沿着「函数调用链」向上找，记录「直接调用日志方法（如 Info/Error）的那一行代码」的位置。
-	lg := zap.New(core, zap.AddCaller())
+	// zap.Hooks 把错误日志同时记录到内存里的环形缓冲区，给管理接口展示最近的错误
+	lg := zap.New(core, zap.AddCaller(), zap.Hooks(recordError))
+	// 在 k8s 中运行时，每行日志都带上 pod/namespace/node，方便多副本排查
+	lg = lg.With(k8s.Fields()...)
 	// zap.ReplaceGlobals(lg)
 	// 核心作用：把自定义的日志实例设为「全局默认」，不用到处传参
 	zap.ReplaceGlobals(lg)
@@ -57,22 +99,30 @@ func Init(cfg *settings.LogConfig) (err error) {
 }
 
 // getLogWriter 创建一个支持日志文件切割/备份的 zap 日志写入器
-// 参数说明：
+// 参数说明（cfg 中的字段）：
 //
-//	filename: 日志文件的保存路径+文件名（例如："./logs/app.log"）
-//	maxSize: 单个日志文件的最大大小（单位：MB），超过则自动切割
-//	maxBackup: 保留的日志备份文件最大数量，超出则删除最旧的
-//	maxAge: 日志文件保留的最大天数，超出则自动删除
+//	Filename: 日志文件的保存路径+文件名（例如："./logs/app.log"）
+//	MaxSize: 单个日志文件的最大大小（单位：MB），超过则自动切割
+//	MaxBackups: 保留的日志备份文件最大数量，超出则删除最旧的
+//	MaxAge: 日志文件保留的最大天数，超出则自动删除
+//	Compress: 切割出来的备份文件是否用 gzip 压缩
+//	LocalTime: 备份文件名里的时间用本地时间，默认 UTC
+//	RotateDaily: 除了按大小，每天零点也切割一次，方便按天归档
 //
 // 返回值：zapcore.WriteSyncer - zap 日志核心的写入器接口，用于将日志写入文件
-func getLogWriter(filename string, maxSize, maxBackup, maxAge int) zapcore.WriteSyncer {
+func getLogWriter(cfg *settings.LogConfig) zapcore.WriteSyncer {
 	// 初始化 lumberjack.Logger 实例（日志文件切割器）
 	// lumberjack 是专门处理日志文件切割、备份、过期删除的工具
 	lumberJackLogger := &lumberjack.Logger{
-		Filename:   filename,  // 设置日志文件的存储路径和名称
-		MaxSize:    maxSize,   // 设置单个日志文件的最大大小（MB），比如设100表示文件到100MB就切割
-		MaxBackups: maxBackup, // 设置保留的日志备份文件最大数量，比如设10表示最多保留10个备份文件
-		MaxAge:     maxAge,    // 设置日志文件保留的最大天数，比如设7表示7天前的日志文件会被自动删除
+		Filename:   cfg.Filename,   // 设置日志文件的存储路径和名称
+		MaxSize:    cfg.MaxSize,    // 设置单个日志文件的最大大小（MB），比如设100表示文件到100MB就切割
+		MaxBackups: cfg.MaxBackups, // 设置保留的日志备份文件最大数量，比如设10表示最多保留10个备份文件
+		MaxAge:     cfg.MaxAge,     // 设置日志文件保留的最大天数，比如设7表示7天前的日志文件会被自动删除
+		Compress:   cfg.Compress,   // 备份文件压缩成 .gz，日志文本压缩率通常在 10 倍左右
+		LocalTime:  cfg.LocalTime,  // 备份文件名里的时间用本地时间
+	}
+	if cfg.RotateDaily {
+		go rotateDaily(lumberJackLogger, cfg.LocalTime)
 	}
 
 	// 将 lumberjack 的日志写入器适配为 zap 核心能识别的 WriteSyncer 接口
@@ -80,6 +130,31 @@ func getLogWriter(filename string, maxSize, maxBackup, maxAge int) zapcore.Write
 	return zapcore.AddSync(lumberJackLogger)
 }
 
+// rotateDaily lumberjack 只支持按大小切割，这里每天零点主动切割一次
+// 备份文件名带的是切割时的时间，比如 web_app-2025-12-04T00-00-00.000.log 里是 12 月 3 日的日志
+func rotateDaily(l *lumberjack.Logger, local bool) {
+	for {
+		now := time.Now()
+		if !local {
+			now = now.UTC()
+		}
+		next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+		time.Sleep(next.Sub(now))
+		if err := l.Rotate(); err != nil {
+			zap.L().Error("rotate log file failed", zap.Error(err))
+		}
+	}
+}
+
+// getConsoleEncoder 终端输出用的编码器，一行一条：时间 级别 调用者 消息 {字段}
+func getConsoleEncoder() zapcore.Encoder {
+	encoderConfig := zap.NewDevelopmentEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout("15:04:05.000")
+	encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	encoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
+	return zapcore.NewConsoleEncoder(encoderConfig)
+}
+
 // getEncoder 创建 zap 日志的 JSON 格式编码器（定义日志输出的格式规则）
 // 返回值：zapcore.Encoder - zap 日志的编码器接口，控制日志的输出格式
 func getEncoder() zapcore.Encoder {
@@ -112,7 +187,7 @@ func getEncoder() zapcore.Encoder {
 	return zapcore.NewJSONEncoder(encoderConfig)
 }
 
-// 使用zap接收gin框架日志
+// 使用zap接收gin框架日志，配置了 log.access 时写到单独的访问日志文件
 func GinLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -121,20 +196,14 @@ func GinLogger() gin.HandlerFunc {
 		c.Next()
 
 		cost := time.Since(start)
-		zap.L().Info(path,
-			zap.Int("status", c.Writer.Status()),
-			zap.String("method", c.Request.Method),
-			zap.String("path", path),
-			zap.String("query", query),
-			zap.String("ip", c.ClientIP()),
-			zap.String("user-agent", c.Request.UserAgent()),
-			zap.String("errors", c.Errors.ByType(gin.ErrorTypePrivate).String()),
-			zap.Duration("cost", cost),
-		)
+		// GinLogger 在 GinRecovery 外层，panic 返回的 500 也能统计到
+		alert.Observe(c.Writer.Status())
+		writeAccess(c, start, cost, path, query)
 	}
 }
 
-// GinRecovery recover掉项目可能出现的panic，并使用zap记录相关日志
+// GinRecovery recover掉项目可能出现的panic，并使用zap记录相关日志，开启 Sentry 时同时上报
+// 之后执行 OnPanic 注册的回调，响应可以通过 SetRecoveryResponse 替换
 func GinRecovery(stack bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
@@ -151,8 +220,10 @@ func GinRecovery(stack bool) gin.HandlerFunc {
 				}
 
 				httpRequest, _ := httputil.DumpRequest(c.Request, false)
+				httpRequest = []byte(maskRequestDump(string(httpRequest)))
+				lg := Ctx(c.Request.Context())
 				if brokenPipe {
-					zap.L().Error(c.Request.URL.Path,
+					lg.Error(c.Request.URL.Path,
 						zap.Any("error", err),
 						zap.String("request", string(httpRequest)),
 					)
@@ -162,21 +233,41 @@ func GinRecovery(stack bool) gin.HandlerFunc {
 					return
 				}
 
-				if stack {
-					zap.L().Error("[Recovery from panic]",
+				// 同一个指纹的 panic 在时间窗口内只打印一次完整堆栈，避免 panic 风暴把磁盘打满
+				stat, logStack := recordPanic(err)
+				sentry.CapturePanic(c.Request, err, stat.Fingerprint)
+				alert.Panic(c.Request.Context(), stat.Fingerprint, err)
+				if stack && logStack {
+					lg.Error("[Recovery from panic]",
 						zap.Any("error", err),
+						zap.String("fingerprint", stat.Fingerprint),
+						zap.Int64("count", stat.Count),
 						zap.String("request", string(httpRequest)),
 						zap.String("stack", string(debug.Stack())),
 					)
 				} else {
-					zap.L().Error("[Recovery from panic]",
+					lg.Error("[Recovery from panic]",
 						zap.Any("error", err),
+						zap.String("fingerprint", stat.Fingerprint),
+						zap.Int64("count", stat.Count),
 						zap.String("request", string(httpRequest)),
 					)
 				}
-				c.AbortWithStatus(http.StatusInternalServerError)
+				runPanicHooks(c, err, stat)
 			}
 		}()
 		c.Next()
+		reportErrors(c)
+	}
+}
+
+// reportErrors 处理函数返回 5xx 时，把通过 c.Error 记录的错误上报到 Sentry
+// 业务错误（参数错误、密码错误等）返回的是 4xx，不会上报
+func reportErrors(c *gin.Context) {
+	if c.Writer.Status() < http.StatusInternalServerError {
+		return
+	}
+	for _, e := range c.Errors.ByType(gin.ErrorTypePrivate) {
+		sentry.CaptureError(c.Request, e.Err)
 	}
 }
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/settings"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// initTemp 用临时目录里的日志文件初始化全局 logger，测试结束后恢复原来的全局 logger
+func initTemp(t *testing.T, mode string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "app.log")
+	prev := zap.L()
+	t.Cleanup(func() { zap.ReplaceGlobals(prev) })
+	if err := Init(&settings.LogConfig{Level: "debug", Filename: filename, MaxSize: 1}, mode); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return filename
+}
+
+// readLines 读出日志文件里的 JSON 行
+func readLines(t *testing.T, filename string) []map[string]interface{} {
+	t.Helper()
+	Sync()
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read log file: %v", err)
+	}
+	var lines []map[string]interface{}
+	for _, l := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		m := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(l), &m); err != nil {
+			t.Fatalf("invalid log line %q: %v", l, err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestInitReplacesGlobal(t *testing.T) {
+	filename := initTemp(t, "release")
+	zap.L().Info("hello", zap.String("k", "v"))
+
+	lines := readLines(t, filename)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	if lines[0]["msg"] != "hello" || lines[0]["k"] != "v" || lines[0]["level"] != "INFO" {
+		t.Errorf("unexpected line %v", lines[0])
+	}
+}
+
+func TestInitInvalidLevel(t *testing.T) {
+	prev := zap.L()
+	t.Cleanup(func() { zap.ReplaceGlobals(prev) })
+	err := Init(&settings.LogConfig{Level: "verbose", Filename: filepath.Join(t.TempDir(), "app.log")}, "release")
+	if err == nil {
+		t.Fatal("want error for invalid level")
+	}
+}
+
+// DPanic 不能让服务 panic，默认配置的 mode 是 dev，所以 dev 也要测
+func TestDPanicDoesNotPanic(t *testing.T) {
+	for _, mode := range []string{"dev", "release"} {
+		t.Run(mode, func(t *testing.T) {
+			initTemp(t, mode)
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("DPanic panicked in %s mode: %v", mode, r)
+				}
+			}()
+			zap.L().DPanic("should only be logged")
+		})
+	}
+}
+
+func TestCtxFields(t *testing.T) {
+	filename := initTemp(t, "release")
+	ctx := ctxutil.WithRequestID(context.Background(), "req-1")
+	ctx = ctxutil.WithUser(ctx, &ctxutil.User{ID: 42, Username: "gopher"})
+	ctx = ctxutil.WithTenant(ctx, "acme")
+	Ctx(ctx).Info("with ctx")
+
+	lines := readLines(t, filename)
+	got := lines[len(lines)-1]
+	if got["request_id"] != "req-1" || got["user_id"] != float64(42) || got["tenant"] != "acme" {
+		t.Errorf("missing context fields: %v", got)
+	}
+}
+
+func TestCtxWithoutFields(t *testing.T) {
+	initTemp(t, "release")
+	if Ctx(nil) != zap.L() {
+		t.Error("Ctx(nil) should return the global logger")
+	}
+	if Ctx(context.Background()) != zap.L() {
+		t.Error("Ctx without request fields should return the global logger")
+	}
+}
@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 日志脱敏：字段名包含 mask_keys 中任意一个关键字（不区分大小写）时，值替换成 ******
+//
+//	zap.String("password", p)           => "password": "******"
+//	GinLogger 的 query: token=abc&page=1 => token=******&page=1
+//	panic 日志里的请求行和请求头         => GET /path?token=****** / Authorization: ******
+//
+// 只看字段名不看内容，所以不要把敏感信息拼进日志消息或者普通字段里
+
+const maskedValue = "******"
+
+var defaultMaskKeys = []string{"password", "passwd", "token", "secret", "authorization", "cookie", "id_card", "api_key", "apikey"}
+
+// maskKeys Init 之后只读，已经过 normalizeKey
+var maskKeys = defaultMaskKeys
+
+func setMaskKeys(keys []string) {
+	if len(keys) == 0 {
+		maskKeys = defaultMaskKeys
+		return
+	}
+	maskKeys = make([]string, 0, len(keys))
+	for _, k := range keys {
+		maskKeys = append(maskKeys, normalizeKey(k))
+	}
+}
+
+// normalizeKey 转小写并把 - 换成 _，X-Api-Key 这样的请求头和 api_key 字段按同一个关键字匹配
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "-", "_")
+}
+
+// IsSensitive 字段名是否需要脱敏
+func IsSensitive(key string) bool {
+	key = normalizeKey(key)
+	for _, k := range maskKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskQuery 对 query string 中的敏感参数脱敏，其它参数保持原样和原来的顺序
+func MaskQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if IsSensitive(key) {
+			pairs[i] = key + "=" + maskedValue
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// maskRequestDump 对 httputil.DumpRequest 输出里请求行的 query 和敏感请求头脱敏
+func maskRequestDump(dump string) string {
+	lines := strings.Split(dump, "\r\n")
+	// 请求行：GET /path?token=xxx HTTP/1.1
+	if parts := strings.SplitN(lines[0], " ", 3); len(parts) == 3 {
+		if path, query, ok := strings.Cut(parts[1], "?"); ok {
+			lines[0] = parts[0] + " " + path + "?" + MaskQuery(query) + " " + parts[2]
+		}
+	}
+	for i := 1; i < len(lines); i++ {
+		line := lines[i]
+		if j := strings.IndexByte(line, ':'); j > 0 && IsSensitive(line[:j]) {
+			lines[i] = line[:j] + ": " + maskedValue
+		}
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+// maskCore 写入之前把敏感字段的值替换掉
+type maskCore struct {
+	zapcore.Core
+}
+
+func (c *maskCore) With(fields []zapcore.Field) zapcore.Core {
+	return &maskCore{Core: c.Core.With(maskFields(fields))}
+}
+
+func (c *maskCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *maskCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, maskFields(fields))
+}
+
+// maskFields 需要脱敏时返回新的切片，不修改调用方的 fields
+func maskFields(fields []zapcore.Field) []zapcore.Field {
+	var res []zapcore.Field
+	for i, f := range fields {
+		if !IsSensitive(f.Key) {
+			continue
+		}
+		if res == nil {
+			res = make([]zapcore.Field, len(fields))
+			copy(res, fields)
+		}
+		res[i] = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: maskedValue}
+	}
+	if res == nil {
+		return fields
+	}
+	return res
+}
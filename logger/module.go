@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 按模块单独设置日志级别，比如 dao 的 debug 日志太多，可以只把它调到 warn：
+//
+//	log:
+//	  level: "debug"
+//	  levels:
+//	    dao: "warn"
+//	    controller: "debug"
+//
+// 模块就是 logger 的名字（日志里的 logger 字段），用 Module 获取，名字里有 "." 时按第一段匹配，
+// 比如 dao.mysql 使用 dao 的级别；没有配置的模块和没有名字的 logger 使用 log.level
+
+// moduleLevels Init 之后只读
+var moduleLevels = map[string]zapcore.Level{}
+
+// Module 返回指定模块的 logger，带上请求上下文字段
+func Module(ctx context.Context, name string) *zap.Logger {
+	return Ctx(ctx).Named(name)
+}
+
+func parseModuleLevels(levels map[string]string) (res map[string]zapcore.Level, err error) {
+	res = make(map[string]zapcore.Level, len(levels))
+	for name, s := range levels {
+		var l zapcore.Level
+		if err = l.UnmarshalText([]byte(s)); err != nil {
+			return nil, fmt.Errorf("logger: module %s: %w", name, err)
+		}
+		res[name] = l
+	}
+	return res, nil
+}
+
+// moduleCore 按 logger 名字决定级别，里面的 core 不做级别过滤
+type moduleCore struct {
+	zapcore.Core
+}
+
+// Enabled 只要全局或者任意一个模块会输出这个级别就返回 true，具体是否输出在 Check 里按模块判断
+func (c *moduleCore) Enabled(l zapcore.Level) bool {
+	if atomicLevel.Enabled(l) {
+		return true
+	}
+	for _, ml := range moduleLevels {
+		if ml.Enabled(l) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *moduleCore) With(fields []zapcore.Field) zapcore.Core {
+	return &moduleCore{Core: c.Core.With(fields)}
+}
+
+func (c *moduleCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !levelFor(ent.LoggerName).Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func levelFor(name string) zapcore.LevelEnabler {
+	if name == "" || len(moduleLevels) == 0 {
+		return atomicLevel
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if l, ok := moduleLevels[name]; ok {
+		return l
+	}
+	return atomicLevel
+}
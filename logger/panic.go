@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// fingerprintFrames 参与计算指纹的栈帧数，取 panic 发生处往上的前几帧
+	fingerprintFrames = 5
+	// stackLogInterval 同一个指纹在这个时间窗口内只打印一次完整堆栈
+	stackLogInterval = time.Minute
+)
+
+// PanicStat 同一类 panic 的统计信息
+type PanicStat struct {
+	Fingerprint string    `json:"fingerprint"`
+	Type        string    `json:"type"`
+	Message     string    `json:"message"`
+	Frames      []string  `json:"frames"`
+	Count       int64     `json:"count"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+
+	lastStackLogged time.Time
+}
+
+var (
+	panicMu    sync.Mutex
+	panicStats = make(map[string]*PanicStat)
+)
+
+// recordPanic 计算 panic 指纹并累加计数
+// 指纹 = panic 值的类型 + 发生处的前几帧（函数名和行号），不包含 panic 信息本身，
+// 因为信息里经常带有 ID 之类的变量，会导致同一个 bug 被算成很多类
+// 返回值 logStack 表示本次是否需要打印完整堆栈
+func recordPanic(err any) (stat PanicStat, logStack bool) {
+	frames := panicFrames()
+	typ := fmt.Sprintf("%T", err)
+	h := sha1.New()
+	h.Write([]byte(typ))
+	for _, f := range frames {
+		h.Write([]byte(f))
+	}
+	fp := hex.EncodeToString(h.Sum(nil))[:16]
+
+	now := time.Now()
+	panicMu.Lock()
+	defer panicMu.Unlock()
+	s, ok := panicStats[fp]
+	if !ok {
+		s = &PanicStat{
+			Fingerprint: fp,
+			Type:        typ,
+			Message:     fmt.Sprint(err),
+			Frames:      frames,
+			FirstSeen:   now,
+		}
+		panicStats[fp] = s
+	}
+	s.Count++
+	s.LastSeen = now
+	if now.Sub(s.lastStackLogged) >= stackLogInterval {
+		s.lastStackLogged = now
+		logStack = true
+	}
+	return *s, logStack
+}
+
+// PanicStats 按次数从多到少返回所有 panic 统计，给管理接口使用
+func PanicStats() []PanicStat {
+	panicMu.Lock()
+	stats := make([]PanicStat, 0, len(panicStats))
+	for _, s := range panicStats {
+		stats = append(stats, *s)
+	}
+	panicMu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Count > stats[j].Count
+	})
+	return stats
+}
+
+// panicFrames 在 recover 所在的 defer 函数里调用，返回 panic 发生处往上的前几帧
+// runtime 包内的帧（gopanic 等）和本包的 recover 帧会被跳过
+func panicFrames() []string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	res := make([]string, 0, fingerprintFrames)
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "runtime.") &&
+			!strings.HasPrefix(f.Function, "go_web_scaffolding/logger.") {
+			res = append(res, fmt.Sprintf("%s:%d", f.Function, f.Line))
+			if len(res) == fingerprintFrames {
+				break
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return res
+}
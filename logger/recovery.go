@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GinRecovery 捕获到 panic 后的扩展点，不同部署可以按需挂上自己的处理：
+//
+//	logger.OnPanic(func(c *gin.Context, err any, stat logger.PanicStat) {
+//		panicCounter.WithLabelValues(stat.Fingerprint).Inc()
+//	})
+//	logger.SetRecoveryResponse(func(c *gin.Context, err any) {
+//		response.Abort(c, response.CodeServerBusy)
+//	})
+//
+// 钩子在记录日志之后、写响应之前按注册顺序执行，可以往响应头里加东西（比如请求 ID）
+// 钩子自己 panic 只记录日志，不影响后面的钩子和响应
+
+// PanicHook panic 回调，stat 是这一类 panic 的统计信息（指纹、累计次数）
+type PanicHook func(c *gin.Context, err any, stat PanicStat)
+
+// RecoveryResponse 写 panic 后的响应，需要自己调用 c.Abort
+type RecoveryResponse func(c *gin.Context, err any)
+
+var (
+	recoveryMu       sync.RWMutex
+	panicHooks       []PanicHook
+	recoveryResponse RecoveryResponse
+)
+
+// OnPanic 注册 panic 回调，在启动时调用
+func OnPanic(hook PanicHook) {
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+	panicHooks = append(panicHooks, hook)
+}
+
+// SetRecoveryResponse 替换 panic 后的响应，nil 恢复默认的不带响应体的 500
+func SetRecoveryResponse(fn RecoveryResponse) {
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+	recoveryResponse = fn
+}
+
+// runPanicHooks 依次执行 panic 回调并写响应
+func runPanicHooks(c *gin.Context, err any, stat PanicStat) {
+	recoveryMu.RLock()
+	hooks := panicHooks
+	respond := recoveryResponse
+	recoveryMu.RUnlock()
+
+	for _, hook := range hooks {
+		safeCall(c, func() { hook(c, err, stat) })
+	}
+	if respond != nil {
+		safeCall(c, func() { respond(c, err) })
+		// 自定义响应没写出任何东西（或者自己 panic 了）时，至少返回 500
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+	}
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
+func safeCall(c *gin.Context, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			Ctx(c.Request.Context()).Error("panic hook failed", zap.Any("error", r))
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/settings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 日志采样：热点接口在高并发下反复打同一条日志时，每个 tick 内同一级别、同一消息的日志
+// 只输出前 initial 条，之后每 thereafter 条输出一条，其余丢弃，避免把磁盘打满
+//
+//	log:
+//	  sampling:
+//	    tick: 1
+//	    levels:
+//	      debug: {initial: 100, thereafter: 0}
+//	      info:  {initial: 100, thereafter: 100}
+//
+// 没有配置的级别不采样，error 及以上一般不要配置，错误日志一条都不能少
+
+var sampledOut atomic.Int64
+
+func init() {
+	dashboard.Register("log_sampling", func(ctx context.Context) interface{} {
+		return map[string]interface{}{"dropped": sampledOut.Load()}
+	})
+}
+
+// samplingCore 按级别分别采样，每个级别一个 zap 自带的 sampler
+type samplingCore struct {
+	zapcore.Core
+	samplers map[zapcore.Level]zapcore.Core
+}
+
+// newSamplingCore 没有配置采样时原样返回 core
+func newSamplingCore(core zapcore.Core, cfg *settings.LogSamplingConfig) (zapcore.Core, error) {
+	if cfg == nil || len(cfg.Levels) == 0 {
+		return core, nil
+	}
+	tick := time.Duration(cfg.Tick) * time.Second
+	if tick <= 0 {
+		tick = time.Second
+	}
+	hook := zapcore.SamplerHook(func(_ zapcore.Entry, dec zapcore.SamplingDecision) {
+		if dec&zapcore.LogDropped > 0 {
+			sampledOut.Add(1)
+		}
+	})
+	samplers := make(map[zapcore.Level]zapcore.Core, len(cfg.Levels))
+	for name, rule := range cfg.Levels {
+		var l zapcore.Level
+		if err := l.UnmarshalText([]byte(name)); err != nil {
+			return nil, fmt.Errorf("logger: sampling level %s: %w", name, err)
+		}
+		if rule.Initial <= 0 {
+			return nil, fmt.Errorf("logger: sampling level %s: initial must be positive", name)
+		}
+		samplers[l] = zapcore.NewSamplerWithOptions(core, tick, rule.Initial, rule.Thereafter, hook)
+	}
+	return &samplingCore{Core: core, samplers: samplers}, nil
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	samplers := make(map[zapcore.Level]zapcore.Core, len(c.samplers))
+	for l, s := range c.samplers {
+		samplers[l] = s.With(fields)
+	}
+	return &samplingCore{Core: c.Core.With(fields), samplers: samplers}
+}
+
+func (c *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if s, ok := c.samplers[ent.Level]; ok {
+		return s.Check(ent, ce)
+	}
+	return c.Core.Check(ent, ce)
+}
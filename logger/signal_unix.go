@@ -0,0 +1,20 @@
+//go:build !windows
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchLevelSignal 收到 SIGUSR2 时在 debug 和配置的级别之间切换
+func WatchLevelSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR2)
+	go func() {
+		for range ch {
+			toggleDebug()
+		}
+	}()
+}
@@ -0,0 +1,6 @@
+//go:build windows
+
+package logger
+
+// WatchLevelSignal Windows 没有 SIGUSR2，只能通过管理接口修改日志级别
+func WatchLevelSignal() {}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/pkg/batcher"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/settings"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 除了本地文件，日志还可以直接发到外部系统，不需要在机器上再部署采集 agent：
+//
+//	log:
+//	  sinks:
+//	    - {type: loki, url: "http://loki:3100", labels: {job: web_app}}
+//	    - {type: kafka, brokers: ["kafka:9092"], topic: "app-logs", level: "warn"}
+//	    - {type: syslog, network: "udp", addr: "127.0.0.1:514", tag: "web_app"}
+//
+// 写日志只是把一行放进内存缓冲区，由后台协程攒批发送，外部系统慢或者挂了不会拖慢请求，
+// 缓冲区满了直接丢弃，丢弃和发送失败的条数在管理接口的 log_sinks 里能看到
+
+const defaultSinkBufferSize = 10000
+
+// sinkEntry 一行日志和写入时间，Loki 需要每行的时间戳
+type sinkEntry struct {
+	t    time.Time
+	line []byte
+}
+
+// sinkFlusher 把一批日志发出去
+type sinkFlusher interface {
+	flush(ctx context.Context, entries []sinkEntry) error
+	close() error
+}
+
+// sinkWriter 实现 zapcore.WriteSyncer，只负责放进缓冲区
+type sinkWriter struct {
+	b *batcher.Batcher[sinkEntry]
+	f sinkFlusher
+
+	// 关闭之后还可能有协程在打日志，直接丢弃，不能再往已关闭的缓冲区里放
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (w *sinkWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return len(p), nil
+	}
+	// zap 会复用 p 的底层数组，必须复制一份
+	line := make([]byte, len(p))
+	copy(line, p)
+	_ = w.b.Add(context.Background(), sinkEntry{t: time.Now(), line: line})
+	return len(p), nil
+}
+
+func (w *sinkWriter) close(ctx context.Context) {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	_ = w.b.Close(ctx)
+	_ = w.f.close()
+}
+
+func (w *sinkWriter) Sync() error {
+	return nil
+}
+
+var sinks []*sinkWriter
+
+func init() {
+	dashboard.Register("log_sinks", func(ctx context.Context) interface{} {
+		stats := make([]batcher.Stats, 0, len(sinks))
+		for _, s := range sinks {
+			stats = append(stats, s.b.Stats())
+		}
+		return stats
+	})
+}
+
+// newSinkCores 按配置创建外部输出，每个输出一个 core，可以单独设置最低级别
+// 任何一个创建失败时关闭已经创建的输出，不留下半初始化的 sink
+func newSinkCores(cfgs []*settings.LogSinkConfig, encoder zapcore.Encoder) (cores []zapcore.Core, err error) {
+	defer func() {
+		if err != nil {
+			closeSinks()
+		}
+	}()
+	for i, cfg := range cfgs {
+		level := zapcore.DebugLevel
+		if cfg.Level != "" {
+			if err = level.UnmarshalText([]byte(cfg.Level)); err != nil {
+				return nil, fmt.Errorf("logger: sink %d (%s): %w", i, cfg.Type, err)
+			}
+		}
+		w, err := newSink(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("logger: sink %d (%s): %w", i, cfg.Type, err)
+		}
+		sinks = append(sinks, w)
+		cores = append(cores, zapcore.NewCore(encoder, w, level))
+	}
+	return cores, nil
+}
+
+// sinkErrorLogger 记录 sink 发送失败，只写 stderr
+// 不能用 zap.L()：它的输出里包含 sink 本身，发送失败的日志又会进入同一个 sink，外部系统挂掉时越积越多
+var sinkErrorLogger = zap.New(zapcore.NewCore(
+	zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+	zapcore.Lock(os.Stderr),
+	zapcore.WarnLevel,
+))
+
+// newSink WriteSyncer 工厂，按 type 创建对应的输出
+func newSink(cfg *settings.LogSinkConfig) (*sinkWriter, error) {
+	var (
+		f   sinkFlusher
+		err error
+	)
+	switch cfg.Type {
+	case "kafka":
+		f, err = newKafkaSink(cfg)
+	case "loki":
+		f, err = newLokiSink(cfg)
+	case "syslog":
+		f, err = newSyslogSink(cfg)
+	default:
+		err = fmt.Errorf("unknown sink type %q", cfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	size := cfg.BufferSize
+	if size <= 0 {
+		size = defaultSinkBufferSize
+	}
+	b := batcher.New(batcher.Options{
+		Name:          "log_" + cfg.Type,
+		BufferSize:    size,
+		BatchSize:     500,
+		FlushInterval: time.Second,
+		Policy:        batcher.PolicyDrop,
+		Logger:        sinkErrorLogger,
+	}, f.flush)
+	return &sinkWriter{b: b, f: f}, nil
+}
+
+// closeSinks 把缓冲区剩下的日志发完再关闭连接，程序退出前调用
+func closeSinks() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, s := range sinks {
+		s.close(ctx)
+	}
+	sinks = nil
+}
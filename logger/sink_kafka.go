@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/settings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// kafkaSink 每行日志一条消息，key 为空，按分区轮询写入
+// 和 dao/kafka 一样使用 sarama，日志不要求幂等，acks=1 即可
+// 生产者在第一次发送时才创建，启动时 Kafka 不可用不影响服务启动，之后每批重新尝试连接
+type kafkaSink struct {
+	brokers []string
+	topic   string
+	conf    *sarama.Config
+
+	mu sync.Mutex
+	p  sarama.SyncProducer
+}
+
+func newKafkaSink(cfg *settings.LogSinkConfig) (sinkFlusher, error) {
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil, errors.New("brokers and topic are required")
+	}
+	conf := sarama.NewConfig()
+	if settings.Conf != nil && settings.Conf.Name != "" {
+		conf.ClientID = settings.Conf.Name
+	}
+	conf.Producer.RequiredAcks = sarama.WaitForLocal
+	conf.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	conf.Producer.Timeout = 5 * time.Second
+	// SyncProducer 要求开启
+	conf.Producer.Return.Successes = true
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return &kafkaSink{brokers: cfg.Brokers, topic: cfg.Topic, conf: conf}, nil
+}
+
+func (s *kafkaSink) producer() (sarama.SyncProducer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.p == nil {
+		p, err := sarama.NewSyncProducer(s.brokers, s.conf)
+		if err != nil {
+			return nil, err
+		}
+		s.p = p
+	}
+	return s.p, nil
+}
+
+func (s *kafkaSink) flush(ctx context.Context, entries []sinkEntry) error {
+	p, err := s.producer()
+	if err != nil {
+		return err
+	}
+	msgs := make([]*sarama.ProducerMessage, len(entries))
+	for i, e := range entries {
+		msgs[i] = &sarama.ProducerMessage{Topic: s.topic, Value: sarama.ByteEncoder(e.line), Timestamp: e.t}
+	}
+	return p.SendMessages(msgs)
+}
+
+func (s *kafkaSink) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.p == nil {
+		return nil
+	}
+	return s.p.Close()
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/pkg/k8s"
+	"go_web_scaffolding/settings"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lokiSink 调用 Loki 的 push 接口，一批日志作为一个 stream 发送
+// 标签只放 app、mode、pod 这类取值有限的字段，request_id 之类的放在日志内容里用 LogQL 过滤
+type lokiSink struct {
+	url    string
+	labels map[string]string
+	cfg    *settings.LogSinkConfig
+	client *http.Client
+}
+
+func newLokiSink(cfg *settings.LogSinkConfig) (sinkFlusher, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	labels := map[string]string{
+		"app":  settings.Conf.Name,
+		"mode": settings.Conf.Mode,
+	}
+	if pod := k8s.Pod().Name; pod != "" {
+		labels["pod"] = pod
+	}
+	for k, v := range cfg.Labels {
+		labels[k] = v
+	}
+	return &lokiSink{
+		url:    strings.TrimSuffix(cfg.URL, "/") + "/loki/api/v1/push",
+		labels: labels,
+		cfg:    cfg,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *lokiSink) flush(ctx context.Context, entries []sinkEntry) error {
+	values := make([][2]string, len(entries))
+	for i, e := range entries {
+		values[i] = [2]string{strconv.FormatInt(e.t.UnixNano(), 10), string(bytes.TrimRight(e.line, "\n"))}
+	}
+	body, err := json.Marshal(map[string][]lokiStream{"streams": {{Stream: s.labels, Values: values}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// 多租户部署的 Loki 需要 X-Scope-OrgID
+	if s.cfg.Tenant != "" {
+		req.Header.Set("X-Scope-OrgID", s.cfg.Tenant)
+	}
+	if s.cfg.Username != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("loki push status %d: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
+
+func (s *lokiSink) close() error {
+	return nil
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"go_web_scaffolding/settings"
+	"log/syslog"
+)
+
+// syslogSink 发到本机或远程的 syslog，network 为空时使用本机的 /dev/log
+// 所有日志都以 LOCAL0.INFO 发送，级别在 JSON 内容的 level 字段里
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func newSyslogSink(cfg *settings.LogSinkConfig) (sinkFlusher, error) {
+	tag := cfg.Tag
+	if tag == "" {
+		tag = settings.Conf.Name
+	}
+	w, err := syslog.Dial(cfg.Network, cfg.Addr, syslog.LOG_INFO|syslog.LOG_LOCAL0, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) flush(ctx context.Context, entries []sinkEntry) error {
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// syslog.Writer 断线后会自动重连
+		if _, err := s.w.Write(bytes.TrimRight(e.line, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package logger
+
+import (
+	"errors"
+	"go_web_scaffolding/settings"
+)
+
+func newSyslogSink(cfg *settings.LogSinkConfig) (sinkFlusher, error) {
+	return nil, errors.New("syslog is not supported on windows")
+}
@@ -0,0 +1,176 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/apperr"
+	"go_web_scaffolding/pkg/cache"
+	"go_web_scaffolding/pkg/pagination"
+	"go_web_scaffolding/pkg/query"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyPrefix 明文密钥的固定前缀，方便在日志和代码仓库里扫描泄露的密钥
+	apiKeyPrefix = "sk_"
+	// apiKeyCacheTTL 认证结果缓存时间，吊销和轮换时会主动删除缓存
+	apiKeyCacheTTL = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidAPIKey 密钥格式错误、不存在、已吊销或已过期
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrAPIKeyNotExist 管理接口操作的 api key 不存在
+	ErrAPIKeyNotExist = apperr.NotFound("api key not exist")
+)
+
+func apiKeyCacheKey(prefix string) string {
+	return "apikey:" + prefix
+}
+
+// newAPIKey 生成明文密钥 sk_<prefix>.<secret>，prefix 用于查库，secret 只参与摘要
+func newAPIKey() (key, prefix, hash string, err error) {
+	p := make([]byte, 6)
+	s := make([]byte, 32)
+	if _, err = rand.Read(p); err != nil {
+		return
+	}
+	if _, err = rand.Read(s); err != nil {
+		return
+	}
+	prefix = hex.EncodeToString(p)
+	key = apiKeyPrefix + prefix + "." + base64.RawURLEncoding.EncodeToString(s)
+	return key, prefix, hashAPIKey(key), nil
+}
+
+// hashAPIKey 密钥本身是高熵随机数，sha256 就够了，不需要 bcrypt 这种慢哈希
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey 创建 api key，返回的明文密钥只有这一次机会拿到
+// ttl 为 0 表示永不过期
+func CreateAPIKey(ctx context.Context, name string, scopes []string, ttl time.Duration) (key string, k *models.APIKey, err error) {
+	key, prefix, hash, err := newAPIKey()
+	if err != nil {
+		return
+	}
+	k = &models.APIKey{
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  strings.Join(scopes, ","),
+	}
+	if ttl > 0 {
+		exp := time.Now().Add(ttl)
+		k.ExpireTime = &exp
+	}
+	err = mysql.InsertAPIKey(ctx, k)
+	return
+}
+
+// ListAPIKeys 按条件分页列出 api key，不含密钥摘要，values 是请求的 query 参数，用于排序和筛选
+func ListAPIKeys(ctx context.Context, p pagination.Params, values url.Values) (*pagination.Result[*models.APIKey], error) {
+	q, err := query.Parse(values, mysql.APIKeyListSpec)
+	if err != nil {
+		return nil, err
+	}
+	keys, total, err := mysql.ListAPIKeys(ctx, q, p)
+	if err != nil {
+		return nil, err
+	}
+	if p.IsCursor() {
+		return pagination.CursorResult(keys, p, func(k *models.APIKey) int64 { return k.ID }), nil
+	}
+	return pagination.OffsetResult(keys, total, p), nil
+}
+
+// RotateAPIKey 轮换密钥，权限和过期时间不变，旧密钥立即失效
+func RotateAPIKey(ctx context.Context, id int64) (key string, err error) {
+	k, err := getAPIKey(ctx, id)
+	if err != nil {
+		return
+	}
+	if k.Revoked {
+		return "", ErrAPIKeyNotExist
+	}
+	key, prefix, hash, err := newAPIKey()
+	if err != nil {
+		return
+	}
+	if err = mysql.UpdateAPIKeySecret(ctx, id, prefix, hash); err != nil {
+		return
+	}
+	err = cache.Delete(ctx, apiKeyCacheKey(k.Prefix))
+	return
+}
+
+// RevokeAPIKey 吊销 api key
+func RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	k, err := getAPIKey(ctx, id)
+	if err != nil {
+		return
+	}
+	if err = mysql.RevokeAPIKey(ctx, id); err != nil {
+		return
+	}
+	return cache.Delete(ctx, apiKeyCacheKey(k.Prefix))
+}
+
+// cachedAPIKey 缓存在 redis 里的 api key，models.APIKey 序列化时不带摘要，这里单独保存
+type cachedAPIKey struct {
+	APIKey  *models.APIKey `json:"api_key"`
+	KeyHash string         `json:"key_hash"`
+}
+
+func getAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	k, err := mysql.GetAPIKeyByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotExist
+	}
+	return k, err
+}
+
+// AuthenticateAPIKey 校验明文密钥，返回对应的 api key
+// 按前缀查询的结果会缓存，不存在的前缀也会负缓存，随机猜测的请求不会打到 MySQL
+func AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error) {
+	prefix, _, ok := strings.Cut(strings.TrimPrefix(key, apiKeyPrefix), ".")
+	if !ok || !strings.HasPrefix(key, apiKeyPrefix) || prefix == "" {
+		return nil, ErrInvalidAPIKey
+	}
+	c, err := cache.GetOrLoad(ctx, apiKeyCacheKey(prefix), apiKeyCacheTTL, func(ctx context.Context) (*cachedAPIKey, error) {
+		k, err := mysql.GetAPIKeyByPrefix(ctx, prefix)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, cache.ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &cachedAPIKey{APIKey: k, KeyHash: k.KeyHash}, nil
+	})
+	if errors.Is(err, cache.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(c.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	k := c.APIKey
+	if k.Revoked || k.Expired(time.Now()) {
+		return nil, ErrInvalidAPIKey
+	}
+	return k, nil
+}
@@ -0,0 +1,121 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/pkg/jwt"
+	"time"
+)
+
+// ErrSessionReplaced 单设备登录模式下，账号已在其它设备登录
+var ErrSessionReplaced = errors.New("session replaced by another login")
+
+// ErrSessionRevoked 改密码、重置密码之后，之前签发的 token 都失效了
+var ErrSessionRevoked = errors.New("session revoked")
+
+// IssueToken 登录成功后签发 token，开启单设备登录时记录本次会话为该用户唯一有效的会话
+func IssueToken(ctx context.Context, userID int64, username string) (aToken, rToken string, err error) {
+	gen, err := redis.GetTokenGeneration(ctx, userID)
+	if err != nil {
+		return
+	}
+	aToken, rToken, sessionID, err := jwt.GenToken(userID, username, gen)
+	if err != nil {
+		return
+	}
+	if jwt.SingleSession() {
+		err = redis.SetActiveSession(ctx, userID, sessionID, jwt.RefreshTokenTTL())
+	}
+	return
+}
+
+// CheckSession 校验 token 所属的会话是否仍然有效：没有被 RevokeSessions 整体吊销，
+// 单设备登录模式下还要是用户当前唯一的会话
+func CheckSession(ctx context.Context, mc *jwt.MyClaims) error {
+	gen, err := redis.GetTokenGeneration(ctx, mc.UserID)
+	if err != nil {
+		return err
+	}
+	if mc.Generation < gen {
+		return ErrSessionRevoked
+	}
+	if !jwt.SingleSession() {
+		return nil
+	}
+	sid, err := redis.GetActiveSession(ctx, mc.UserID)
+	if err != nil {
+		return err
+	}
+	if sid != mc.SessionID {
+		return ErrSessionReplaced
+	}
+	return nil
+}
+
+// ValidateSession 重新校验已经通过登录校验的 token：没有过期、没有注销、会话仍然有效
+// WebSocket 这种建立后一直不断开的连接定时调用，失效时返回 ErrSessionRevoked 或 ErrSessionReplaced
+func ValidateSession(ctx context.Context, mc *jwt.MyClaims) error {
+	if mc.ExpiresAt != nil && time.Now().After(mc.ExpiresAt.Time) {
+		return ErrSessionRevoked
+	}
+	revoked, err := redis.IsTokenRevoked(ctx, mc.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrSessionRevoked
+	}
+	return CheckSession(ctx, mc)
+}
+
+// RevokeSessions 让用户所有已经签发的 token 失效，所有设备都要重新登录
+func RevokeSessions(ctx context.Context, userID int64) error {
+	_, err := redis.IncrTokenGeneration(ctx, userID)
+	return err
+}
+
+// RefreshToken 用 refresh token 换取新的一对 token
+// refresh token 只能使用一次，换取成功后旧的 refresh token 被吊销，泄露后被重放可以及时发现
+func RefreshToken(ctx context.Context, rToken string) (aToken, newRToken string, err error) {
+	mc, err := jwt.ParseRefreshToken(rToken)
+	if err != nil {
+		return
+	}
+	if err = CheckSession(ctx, mc); err != nil {
+		return
+	}
+	// 先吊销再签发，同一个 refresh token 并发刷新时只有抢到的那个请求能拿到新 token
+	claimed, err := redis.ClaimToken(ctx, mc.ID, mc.ExpiresAt.Time)
+	if err != nil {
+		return
+	}
+	if !claimed {
+		return "", "", jwt.ErrInvalidToken
+	}
+	// 单设备登录的会话记录登录时只保存 refresh token 的有效期，每次刷新都要续上，否则一直在用的会话也会被当成已被顶掉
+	if jwt.SingleSession() {
+		if err = redis.TouchActiveSession(ctx, mc.UserID, jwt.RefreshTokenTTL()); err != nil {
+			return
+		}
+	}
+	return jwt.RenewToken(mc)
+}
+
+// Logout 吊销当前的 access token，传了 refresh token 的话一并吊销
+func Logout(ctx context.Context, rToken string) (err error) {
+	if mc, ok := jwt.ClaimsFromContext(ctx); ok {
+		if err = redis.RevokeToken(ctx, mc.ID, mc.ExpiresAt.Time); err != nil {
+			return
+		}
+	}
+	if rToken == "" {
+		return
+	}
+	mc, err := jwt.ParseRefreshToken(rToken)
+	if err != nil {
+		// 无效的 refresh token 本来就不能用，忽略即可
+		return nil
+	}
+	return redis.RevokeToken(ctx, mc.ID, mc.ExpiresAt.Time)
+}
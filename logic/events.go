@@ -0,0 +1,55 @@
+package logic
+
+import (
+	"context"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/audit"
+	"go_web_scaffolding/pkg/eventbus"
+	"go_web_scaffolding/pkg/i18n"
+	"go_web_scaffolding/pkg/push"
+	"go_web_scaffolding/pkg/ws"
+)
+
+// 领域事件名
+const (
+	EventUserSignUp         = "user.signup"
+	EventUserProfileUpdated = "user.profile_updated"
+)
+
+// UserRegistered 新用户注册成功，包括第三方登录第一次自动创建账号
+type UserRegistered struct {
+	*models.User
+}
+
+func (UserRegistered) EventName() string { return EventUserSignUp }
+
+// UserProfileUpdated 用户修改了昵称或头像
+type UserProfileUpdated struct {
+	*models.User
+}
+
+func (UserProfileUpdated) EventName() string { return EventUserProfileUpdated }
+
+func init() {
+	// 所有领域事件都写入 domain_event 表，事后追溯；audit 只是放进缓冲区，同步执行就行
+	eventbus.SubscribeAll("audit", func(ctx context.Context, e eventbus.Event) error {
+		audit.Event(ctx, e.EventName(), e)
+		return nil
+	})
+	// 资料改了通知用户的其它在线设备刷新
+	eventbus.Subscribe("ws", func(ctx context.Context, e UserProfileUpdated) error {
+		msg, err := ws.NewMessage(EventUserProfileUpdated, e.User)
+		if err != nil {
+			return err
+		}
+		return ws.SendToUser(ctx, e.UserID, msg)
+	}, eventbus.Async())
+	// 资料被修改推送到用户的手机上，不是本人操作的话能及时发现
+	eventbus.Subscribe("push", func(ctx context.Context, e UserProfileUpdated) error {
+		return push.ToUsers(ctx, []int64{e.UserID}, &push.Notification{
+			Title: i18n.T(ctx, "push.profile_updated.title"),
+			Body:  i18n.T(ctx, "push.profile_updated.body"),
+			Data:  map[string]string{"type": EventUserProfileUpdated},
+		})
+	}, eventbus.Async())
+}
@@ -0,0 +1,178 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/apperr"
+	"go_web_scaffolding/pkg/storage"
+	"go_web_scaffolding/settings"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultMaxUploadSize 没有配置 upload.max_size 时单个文件的上限
+const defaultMaxUploadSize = 10 << 20
+
+var (
+	ErrFileNotExist   = apperr.NotFound("file not exist")
+	ErrFileTooLarge   = apperr.New(0, http.StatusRequestEntityTooLarge, "file too large")
+	ErrFileTypeDenied = apperr.New(0, http.StatusUnsupportedMediaType, "file type not allowed")
+)
+
+// MaxUploadSize 单个文件最大字节数
+func MaxUploadSize() int64 {
+	if cfg := settings.Conf.UploadConfig; cfg != nil && cfg.MaxSize > 0 {
+		return cfg.MaxSize << 20
+	}
+	return defaultMaxUploadSize
+}
+
+// UploadFile 校验大小和类型后存进 storage 并记录元信息
+// 类型按文件开头的内容识别，不相信客户端给的 Content-Type 和扩展名，防止把 html 当图片传上来
+func UploadFile(ctx context.Context, userID int64, fh *multipart.FileHeader) (*models.File, error) {
+	if fh.Size > MaxUploadSize() {
+		return nil, ErrFileTooLarge
+	}
+	src, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if !allowedType(contentType) {
+		return nil, ErrFileTypeDenied
+	}
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	name, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	f := &models.File{
+		UserID:      userID,
+		Name:        displayName(fh.Filename),
+		Path:        time.Now().Format("2006/01/02") + "/" + name + fileExt(fh.Filename, contentType),
+		Size:        fh.Size,
+		ContentType: contentType,
+	}
+	h := sha256.New()
+	if err = storage.Put(ctx, f.Path, io.TeeReader(src, h), f.Size, contentType); err != nil {
+		return nil, err
+	}
+	f.SHA256 = hex.EncodeToString(h.Sum(nil))
+	if err = mysql.InsertFile(ctx, f); err != nil {
+		// 没有记录的文件没人能找到，删掉
+		if e := storage.Delete(ctx, f.Path); e != nil {
+			logger.Ctx(ctx).Warn("delete orphan file failed", zap.String("path", f.Path), zap.Error(e))
+		}
+		return nil, err
+	}
+	f.CreateTime = time.Now()
+	f.URL = storage.URL(f.Path)
+	return f, nil
+}
+
+// allowedType 是否在 upload.allowed_types 里，image/* 匹配所有图片
+func allowedType(contentType string) bool {
+	cfg := settings.Conf.UploadConfig
+	if cfg == nil || len(cfg.AllowedTypes) == 0 {
+		return true
+	}
+	for _, t := range cfg.AllowedTypes {
+		if t == contentType || strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredExt 一个类型对应多个扩展名时用哪个，mime.ExtensionsByType 按字母排序，jpeg 排在第一个的是 .jfif
+var preferredExt = map[string]string{
+	"image/jpeg": ".jpg",
+	"text/html":  ".html",
+	"text/plain": ".txt",
+}
+
+// maxFileNameLen file.name 列的长度，按字符算
+const maxFileNameLen = 255
+
+// displayName 上传时的文件名只用来展示，去掉非法的 UTF-8，超长时截断，尽量保留扩展名
+func displayName(filename string) string {
+	name := []rune(strings.TrimSpace(strings.ToValidUTF8(filename, "")))
+	if len(name) <= maxFileNameLen {
+		return string(name)
+	}
+	ext := []rune(path.Ext(string(name)))
+	if len(ext) > 16 {
+		ext = nil
+	}
+	return string(name[:maxFileNameLen-len(ext)]) + string(ext)
+}
+
+// fileExt 上传的扩展名和识别出来的类型一致时沿用，否则按类型取，识别不出来的不带扩展名
+func fileExt(filename, contentType string) string {
+	ext := strings.ToLower(path.Ext(filename))
+	if t, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext)); ext != "" && t == contentType {
+		return ext
+	}
+	if ext, ok := preferredExt[contentType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// GetFile 查询自己上传的文件
+func GetFile(ctx context.Context, userID, id int64) (*models.File, error) {
+	f, err := mysql.GetFileByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && f.UserID != userID {
+		return nil, ErrFileNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.URL = storage.URL(f.Path)
+	return f, nil
+}
+
+// DeleteFile 删除自己上传的文件，先删记录再删文件，文件删除失败只记日志
+func DeleteFile(ctx context.Context, userID, id int64) error {
+	f, err := GetFile(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	n, err := mysql.DeleteFile(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrFileNotExist
+	}
+	if err = storage.Delete(ctx, f.Path); err != nil {
+		logger.Ctx(ctx).Warn("delete file from storage failed", zap.String("path", f.Path), zap.Error(err))
+	}
+	return nil
+}
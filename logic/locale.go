@@ -0,0 +1,47 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/cache"
+	"go_web_scaffolding/pkg/locale"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// preferenceCacheTTL 语言和时区偏好的缓存时间，每个登录的请求都要读，修改资料时主动删除
+const preferenceCacheTTL = 10 * time.Minute
+
+func preferenceCacheKey(userID int64) string {
+	return "user:pref:" + strconv.FormatInt(userID, 10)
+}
+
+func init() {
+	// 请求没有指定语言、时区时，middlewares.Locale 用用户资料里保存的偏好
+	locale.PreferenceFunc = loadPreference
+}
+
+func loadPreference(ctx context.Context, userID int64) (locale.Preference, bool) {
+	p, err := cache.GetOrLoad(ctx, preferenceCacheKey(userID), preferenceCacheTTL, func(ctx context.Context) (locale.Preference, error) {
+		u, err := mysql.GetUserByID(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return locale.Preference{}, cache.ErrNotFound
+		}
+		if err != nil {
+			return locale.Preference{}, err
+		}
+		return locale.Preference{Locale: u.Locale, Timezone: u.Timezone}, nil
+	})
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			logger.Ctx(ctx).Warn("load user preference failed", zap.Int64("user_id", userID), zap.Error(err))
+		}
+		return locale.Preference{}, false
+	}
+	return p, p.Locale != "" || p.Timezone != ""
+}
@@ -0,0 +1,184 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/eventbus"
+	"go_web_scaffolding/pkg/idgen"
+	"go_web_scaffolding/pkg/oauth"
+	"regexp"
+)
+
+var (
+	// ErrInvalidOAuthState state 不存在、已过期、已使用或与平台不匹配
+	ErrInvalidOAuthState = errors.New("invalid oauth state")
+	// ErrOAuthBound 第三方账号已经绑定了其它用户
+	ErrOAuthBound = errors.New("oauth account already bound to another user")
+	// ErrOAuthEmailExist 第三方账号的邮箱已经注册过本站账号，需要登录那个账号后再绑定
+	ErrOAuthEmailExist = errors.New("oauth email already registered")
+)
+
+// oauthState 保存在 redis 里的 state 数据
+type oauthState struct {
+	Provider string `json:"provider"`
+	// UserID 已登录用户发起的绑定，0 表示登录
+	UserID int64 `json:"user_id"`
+	// Nonce 发起授权的浏览器 cookie 里的随机数的摘要，回调时比对，
+	// 别人拿着自己的 state 诱导受害者完成回调时 cookie 对不上
+	Nonce string `json:"nonce"`
+}
+
+func nonceDigest(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// OAuthAuthURL 生成跳转到第三方授权页的地址，nonce 由调用方写到 cookie 里，回调时带回来校验
+// bindUserID 不为 0 时表示给已登录用户绑定第三方账号，回调时不会创建新用户
+func OAuthAuthURL(ctx context.Context, provider string, bindUserID int64) (url, nonce string, err error) {
+	p, err := oauth.Get(provider)
+	if err != nil {
+		return
+	}
+	state, err := randomHex(16)
+	if err != nil {
+		return
+	}
+	if nonce, err = randomHex(16); err != nil {
+		return
+	}
+	data, _ := json.Marshal(&oauthState{Provider: provider, UserID: bindUserID, Nonce: nonceDigest(nonce)})
+	if err = redis.SaveOAuthState(ctx, state, string(data), oauth.StateTTL()); err != nil {
+		return
+	}
+	return p.AuthCodeURL(state), nonce, nil
+}
+
+// OAuthCallback 处理第三方回调：校验 state 和发起授权时下发的 nonce，换取身份，找到或创建本站用户后登录
+// 关联顺序：已绑定的账号 -> 发起绑定的登录用户 -> 新建用户；不按邮箱自动关联已有账号
+func OAuthCallback(ctx context.Context, provider, code, state, nonce string) (*LoginResult, error) {
+	p, err := oauth.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	data, err := redis.TakeOAuthState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	st := new(oauthState)
+	if data == "" || json.Unmarshal([]byte(data), st) != nil || st.Provider != provider {
+		return nil, ErrInvalidOAuthState
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(nonceDigest(nonce)), []byte(st.Nonce)) != 1 {
+		return nil, ErrInvalidOAuthState
+	}
+	id, err := p.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	u, err := linkOAuthUser(ctx, id, st.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return completeLogin(ctx, u)
+}
+
+func linkOAuthUser(ctx context.Context, id *oauth.Identity, bindUserID int64) (*models.User, error) {
+	binding, err := mysql.GetUserOAuth(ctx, id.Provider, id.OpenID)
+	switch {
+	case err == nil:
+		if bindUserID != 0 && binding.UserID != bindUserID {
+			return nil, ErrOAuthBound
+		}
+		return mysql.GetUserByID(ctx, binding.UserID)
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	o := &models.UserOAuth{Provider: id.Provider, OpenID: id.OpenID}
+	if bindUserID != 0 {
+		u, err := mysql.GetUserByID(ctx, bindUserID)
+		if err != nil {
+			return nil, err
+		}
+		o.UserID = u.UserID
+		return u, mysql.InsertUserOAuth(ctx, o)
+	}
+
+	username, err := oauthUsername(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := idgen.NextInt64(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u := &models.User{UserID: userID, Username: username, Nickname: id.Name, Avatar: id.Avatar}
+	// 平台说验证过的邮箱也不能证明是本站那个账号的主人，同邮箱的账号只能登录后自己绑定
+	if id.EmailVerified && id.Email != "" {
+		exist, err := mysql.CheckEmailExist(ctx, id.Email)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			return nil, ErrOAuthEmailExist
+		}
+		u.Email = id.Email
+	}
+	if err = mysql.CreateUserWithOAuth(ctx, u, o); err != nil {
+		return nil, err
+	}
+	eventbus.Publish(ctx, UserRegistered{u})
+	return u, nil
+}
+
+var usernameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+
+// oauthUsername 用第三方昵称生成一个没被占用的用户名，昵称被占用时加随机后缀
+func oauthUsername(ctx context.Context, id *oauth.Identity) (string, error) {
+	base := usernameInvalid.ReplaceAllString(id.Name, "")
+	if base == "" {
+		base = id.Provider + "_user"
+	}
+	if len(base) > 32 {
+		base = base[:32]
+	}
+	name := base
+	for i := 0; i < 5; i++ {
+		exist, err := mysql.CheckUserExist(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		if !exist {
+			return name, nil
+		}
+		suffix, err := randomHex(3)
+		if err != nil {
+			return "", err
+		}
+		name = base + "_" + suffix
+	}
+	// 短后缀连续撞了 5 次，换一个足够长的，插入时唯一索引兜底
+	suffix, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+	return base + "_" + suffix, nil
+}
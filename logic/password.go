@@ -0,0 +1,120 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/loginguard"
+	"go_web_scaffolding/pkg/mailer"
+	"go_web_scaffolding/pkg/password"
+	"go_web_scaffolding/settings"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// passwordResetCooldown 同一账号两次申请重置密码的最小间隔
+const passwordResetCooldown = time.Minute
+
+// ErrInvalidResetToken 重置链接无效、已过期或已使用
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ForgotPassword 给邮箱发送重置密码链接
+// 邮箱不存在、申请太频繁或者邮件限额用完时也返回成功，不让调用方借此探测哪些邮箱注册过
+func ForgotPassword(ctx context.Context, email string) error {
+	u, err := mysql.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = mailer.CheckLimit(ctx, u.Email); err != nil {
+		return swallowRateLimited(err)
+	}
+	ok, err := redis.PasswordResetCooldown(ctx, u.UserID, passwordResetCooldown)
+	if err != nil || !ok {
+		return err
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	// redis 里只存摘要；链接只在待发送的邮件里，发出去或者过期后就删了
+	if err = redis.SavePasswordResetToken(ctx, u.UserID, hashResetToken(token), password.ResetTTL()); err != nil {
+		return err
+	}
+	link := password.ResetURL() + "?token=" + url.QueryEscape(token)
+	msg, err := mailer.NewMessage(u.Email, "password_reset.html", map[string]interface{}{
+		"App":     settings.Conf.Name,
+		"Name":    u.Username,
+		"Minutes": int(password.ResetTTL().Minutes()),
+		"Link":    link,
+	})
+	if err != nil {
+		return err
+	}
+	return swallowRateLimited(mailer.EnqueueSecret(ctx, msg, password.ResetTTL()))
+}
+
+// swallowRateLimited 邮件限额用完时当作成功，和邮箱不存在的响应一样
+func swallowRateLimited(err error) error {
+	if errors.Is(err, mailer.ErrRateLimited) {
+		return nil
+	}
+	return err
+}
+
+// ResetPassword 校验重置 token 并设置新密码，token 只能使用一次
+// 新密码不满足强度要求时 token 不作废，用户可以换个密码重试
+func ResetPassword(ctx context.Context, token, newPassword string) error {
+	tokenHash := hashResetToken(token)
+	userID, err := redis.GetPasswordResetToken(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+	if userID == 0 {
+		return ErrInvalidResetToken
+	}
+	u, err := mysql.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err = password.Validate(newPassword, u.Username); err != nil {
+		return err
+	}
+	// 并发使用同一个 token 时只有一个能成功
+	if userID, err = redis.TakePasswordResetToken(ctx, tokenHash); err != nil {
+		return err
+	}
+	if userID != u.UserID {
+		return ErrInvalidResetToken
+	}
+	hashed, err := password.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	if err = mysql.UpdateUserPassword(ctx, userID, hashed); err != nil {
+		return err
+	}
+	// 密码可能是泄露了才来重置的，拿着旧密码登录的设备全部下线
+	if err = RevokeSessions(ctx, userID); err != nil {
+		return err
+	}
+	// 忘记密码时多半已经输错过几次，重置成功后清掉失败计数
+	loginguard.Success(ctx, u.Username, "")
+	logger.Module(ctx, "logic").Info("password reset", zap.Int64("user_id", userID))
+	return nil
+}
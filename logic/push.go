@@ -0,0 +1,37 @@
+package logic
+
+import (
+	"context"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/apperr"
+)
+
+// ErrPushDeviceNotExist 设备不存在或者不在当前用户名下
+var ErrPushDeviceNotExist = apperr.NotFound("push device not exist")
+
+// RegisterPushDevice 登记当前用户的设备，客户端每次启动拿到 token 后都调用一次
+func RegisterPushDevice(ctx context.Context, userID int64, platform, token string) error {
+	return mysql.UpsertPushDevice(ctx, &models.PushDevice{UserID: userID, Platform: platform, Token: token})
+}
+
+// ListPushDevices 当前用户登记过的设备
+func ListPushDevices(ctx context.Context, userID int64) ([]*models.PushDevice, error) {
+	list, err := mysql.ListPushDevicesByUser(ctx, userID)
+	if list == nil {
+		list = []*models.PushDevice{}
+	}
+	return list, err
+}
+
+// DeletePushDevice 注销设备，退出登录时调用，之后这台设备不再收到推送
+func DeletePushDevice(ctx context.Context, userID int64, token string) error {
+	n, err := mysql.DeletePushDevice(ctx, userID, token)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPushDeviceNotExist
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/dao/es"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/apperr"
+	"go_web_scaffolding/pkg/eventbus"
+	"go_web_scaffolding/pkg/pagination"
+	"strconv"
+	"time"
+)
+
+const (
+	userIndex = "user"
+	// maxResultWindow es 默认的 index.max_result_window，from + size 不能超过它
+	maxResultWindow = 10000
+)
+
+var (
+	ErrSearchDisabled = apperr.Unavailable("search is not enabled")
+	ErrSearchTooDeep  = apperr.BadRequest("search result window is too large, please refine your query")
+)
+
+// userDoc 用户在搜索索引里的文档，只放可以公开的字段
+type userDoc struct {
+	UserID     int64     `json:"user_id,string"`
+	Username   string    `json:"username"`
+	Nickname   string    `json:"nickname"`
+	Avatar     string    `json:"avatar"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// UserHit 搜索到的用户，highlight 是命中的字段高亮片段（<em> 包裹，其余内容已做 HTML 转义）
+type UserHit struct {
+	userDoc
+	Highlight map[string][]string `json:"highlight,omitempty"`
+}
+
+func init() {
+	// 中文昵称需要分词时把 analyzer 换成 ik_max_word（需要安装 ik 插件）
+	es.RegisterIndex(userIndex, map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"user_id":     map[string]string{"type": "keyword"},
+				"username":    map[string]string{"type": "text"},
+				"nickname":    map[string]string{"type": "text"},
+				"avatar":      map[string]interface{}{"type": "keyword", "index": false},
+				"create_time": map[string]string{"type": "date"},
+			},
+		},
+	})
+	eventbus.Subscribe("search", func(ctx context.Context, e UserRegistered) error {
+		return indexUser(ctx, e.User)
+	})
+	eventbus.Subscribe("search", func(ctx context.Context, e UserProfileUpdated) error {
+		return indexUser(ctx, e.User)
+	})
+}
+
+// indexUser 用户注册和修改资料后把公开字段写入搜索索引，es.Index 只是放进写入缓冲区，同步执行就行
+func indexUser(ctx context.Context, u *models.User) error {
+	if !es.Enabled() {
+		return nil
+	}
+	return es.Index(ctx, userIndex, strconv.FormatInt(u.UserID, 10), newUserDoc(u))
+}
+
+func newUserDoc(u *models.User) *userDoc {
+	return &userDoc{UserID: u.UserID, Username: u.Username, Nickname: u.Nickname, Avatar: u.Avatar, CreateTime: u.CreateTime}
+}
+
+// reindexBatch 重建索引时每批从数据库读取和写入 es 的用户数
+const reindexBatch = 500
+
+// ReindexUsers 用数据库里的用户全量重建搜索索引：开启搜索之前注册的用户、es 不可用时丢掉的写入都靠它补上
+// 已有的文档整篇覆盖，可以重复执行；返回写入的总数和 es 拒绝的条数
+func ReindexUsers(ctx context.Context) (total, failed int, err error) {
+	if !es.Enabled() {
+		return 0, 0, ErrSearchDisabled
+	}
+	var afterID int64
+	for {
+		list, err := mysql.ListUsersAfter(ctx, afterID, reindexBatch)
+		if err != nil {
+			return total, failed, err
+		}
+		if len(list) == 0 {
+			return total, failed, nil
+		}
+		items := make([]*es.BulkItem, 0, len(list))
+		for _, u := range list {
+			items = append(items, &es.BulkItem{Index: userIndex, ID: strconv.FormatInt(u.UserID, 10), Doc: newUserDoc(u)})
+		}
+		n, err := es.Bulk(ctx, items)
+		if err != nil {
+			return total, failed, err
+		}
+		total += len(items)
+		failed += n
+		afterID = list[len(list)-1].UserID
+	}
+}
+
+// SearchUsers 按用户名和昵称搜索用户，只支持页码模式
+func SearchUsers(ctx context.Context, q string, p pagination.Params) (*pagination.Result[*UserHit], error) {
+	if p.Offset()+p.Limit() > maxResultWindow {
+		return nil, ErrSearchTooDeep
+	}
+	res, err := es.Search(ctx, userIndex, map[string]interface{}{
+		"from": p.Offset(),
+		"size": p.Limit(),
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  q,
+				"fields": []string{"username^2", "nickname"},
+			},
+		},
+		// 昵称是用户自己填的，html 编码器先转义原文再加 <em>，前端可以直接当 HTML 渲染
+		"highlight": map[string]interface{}{
+			"encoder": "html",
+			"fields": map[string]interface{}{
+				"username": map[string]interface{}{},
+				"nickname": map[string]interface{}{},
+			},
+		},
+	})
+	if errors.Is(err, es.ErrDisabled) {
+		return nil, ErrSearchDisabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*UserHit, 0, len(res.Hits))
+	for i := range res.Hits {
+		h := &UserHit{Highlight: res.Hits[i].Highlight}
+		if err := res.Hits[i].Bind(&h.userDoc); err != nil {
+			return nil, err
+		}
+		list = append(list, h)
+	}
+	return pagination.OffsetResult(list, res.Total, p), nil
+}
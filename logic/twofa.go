@@ -0,0 +1,227 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/loginguard"
+	"go_web_scaffolding/pkg/totp"
+	"go_web_scaffolding/settings"
+	"strings"
+	"time"
+)
+
+const (
+	// totpPendingTTL 扫码后需要在这个时间内输入验证码确认开启
+	totpPendingTTL = 10 * time.Minute
+	// totpSkew 允许前后各一个周期的时钟误差
+	totpSkew = 1
+	// mfaChallengeTTL 密码校验通过后输入验证码的时限
+	mfaChallengeTTL = 5 * time.Minute
+	// maxMFAAttempts 同一次登录最多尝试输入验证码的次数，超过后需要重新登录
+	maxMFAAttempts = 5
+	// recoveryCodeCount 开启两步验证时生成的恢复码数量
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrTOTPEnabled     = errors.New("two-factor authentication already enabled")
+	ErrTOTPNotEnabled  = errors.New("two-factor authentication not enabled")
+	ErrTOTPSetup       = errors.New("two-factor setup expired, please start again")
+	ErrInvalidTOTPCode = errors.New("invalid verification code")
+	ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+)
+
+// LoginResult 登录结果
+// 开启了两步验证的用户密码校验通过后只返回 MFAToken，带着它和验证码调用 VerifyMFA 才能拿到 token
+type LoginResult struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+// completeLogin 身份校验通过后的统一出口，所有登录方式都从这里签发 token
+func completeLogin(ctx context.Context, u *models.User) (*LoginResult, error) {
+	if u.TOTPSecret != "" {
+		token, err := randomHex(16)
+		if err != nil {
+			return nil, err
+		}
+		if err = redis.SaveMFAChallenge(ctx, token, u.UserID, mfaChallengeTTL); err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFARequired: true, MFAToken: token}, nil
+	}
+	aToken, rToken, err := IssueToken(ctx, u.UserID, u.Username)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{AccessToken: aToken, RefreshToken: rToken}, nil
+}
+
+// VerifyMFA 登录的第二步：校验验证码或恢复码，通过后签发 token
+func VerifyMFA(ctx context.Context, mfaToken, code string) (*LoginResult, error) {
+	userID, attempts, err := redis.AttemptMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if userID == 0 {
+		return nil, ErrInvalidMFAToken
+	}
+	if attempts > maxMFAAttempts {
+		_ = redis.DeleteMFAChallenge(ctx, mfaToken)
+		return nil, ErrInvalidMFAToken
+	}
+	u, err := mysql.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// 验证码输错和密码输错一样计入防暴力破解
+	ip := ctxutil.ClientIP(ctx)
+	if err = loginguard.Before(ctx, u.Username, ip); err != nil {
+		return nil, err
+	}
+	if err = verifyTOTP(ctx, u, code); err != nil {
+		if errors.Is(err, ErrInvalidTOTPCode) {
+			loginguard.Failure(ctx, u.Username, ip)
+		}
+		return nil, err
+	}
+	loginguard.Success(ctx, u.Username, ip)
+	if err = redis.DeleteMFAChallenge(ctx, mfaToken); err != nil {
+		return nil, err
+	}
+	aToken, rToken, err := IssueToken(ctx, u.UserID, u.Username)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{AccessToken: aToken, RefreshToken: rToken}, nil
+}
+
+// SetupTOTP 开启两步验证的第一步：生成密钥，返回给前端展示二维码
+func SetupTOTP(ctx context.Context, userID int64) (secret, uri string, err error) {
+	u, err := mysql.GetUserByID(ctx, userID)
+	if err != nil {
+		return
+	}
+	if u.TOTPSecret != "" {
+		return "", "", ErrTOTPEnabled
+	}
+	if secret, err = totp.GenerateSecret(); err != nil {
+		return
+	}
+	if err = redis.SavePendingTOTP(ctx, userID, secret, totpPendingTTL); err != nil {
+		return
+	}
+	return secret, totp.URI(settings.Conf.Name, u.Username, secret), nil
+}
+
+// EnableTOTP 开启两步验证的第二步：用验证器 App 上的验证码确认，返回一次性恢复码
+func EnableTOTP(ctx context.Context, userID int64, code string) (recoveryCodes []string, err error) {
+	secret, err := redis.GetPendingTOTP(ctx, userID)
+	if err != nil {
+		return
+	}
+	if secret == "" {
+		return nil, ErrTOTPSetup
+	}
+	if _, ok := totp.Validate(secret, code, time.Now(), totpSkew); !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+	if recoveryCodes, err = resetRecoveryCodes(ctx, userID); err != nil {
+		return
+	}
+	if err = mysql.UpdateUserTOTPSecret(ctx, userID, secret); err != nil {
+		return
+	}
+	err = redis.DeletePendingTOTP(ctx, userID)
+	return
+}
+
+// DisableTOTP 关闭两步验证，需要验证码或恢复码确认
+func DisableTOTP(ctx context.Context, userID int64, code string) (err error) {
+	u, err := mysql.GetUserByID(ctx, userID)
+	if err != nil {
+		return
+	}
+	if u.TOTPSecret == "" {
+		return ErrTOTPNotEnabled
+	}
+	if err = verifyTOTP(ctx, u, code); err != nil {
+		return
+	}
+	if err = mysql.UpdateUserTOTPSecret(ctx, userID, ""); err != nil {
+		return
+	}
+	return redis.SetRecoveryCodes(ctx, userID, nil)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧的恢复码全部作废
+func RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) (recoveryCodes []string, err error) {
+	u, err := mysql.GetUserByID(ctx, userID)
+	if err != nil {
+		return
+	}
+	if u.TOTPSecret == "" {
+		return nil, ErrTOTPNotEnabled
+	}
+	if err = verifyTOTP(ctx, u, code); err != nil {
+		return
+	}
+	return resetRecoveryCodes(ctx, userID)
+}
+
+// verifyTOTP 校验 6 位验证码，或者 xxxxx-xxxxx 格式的恢复码
+func verifyTOTP(ctx context.Context, u *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	if strings.Contains(code, "-") {
+		ok, err := redis.UseRecoveryCode(ctx, u.UserID, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTOTPCode
+		}
+		return nil
+	}
+	step, ok := totp.Validate(u.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok {
+		return ErrInvalidTOTPCode
+	}
+	// 同一个验证码在有效期内只能用一次
+	first, err := redis.MarkTOTPUsed(ctx, u.UserID, step, time.Duration(2*totpSkew+1)*30*time.Second)
+	if err != nil {
+		return err
+	}
+	if !first {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+func resetRecoveryCodes(ctx context.Context, userID int64) (codes []string, err error) {
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		s, err := randomHex(5)
+		if err != nil {
+			return nil, err
+		}
+		code := s[:5] + "-" + s[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	err = redis.SetRecoveryCodes(ctx, userID, hashes)
+	return
+}
+
+// hashRecoveryCode 恢复码只保存摘要
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(code)))
+	return hex.EncodeToString(sum[:])
+}
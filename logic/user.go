@@ -0,0 +1,225 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/apperr"
+	"go_web_scaffolding/pkg/audit"
+	"go_web_scaffolding/pkg/cache"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/eventbus"
+	"go_web_scaffolding/pkg/i18n"
+	"go_web_scaffolding/pkg/idgen"
+	"go_web_scaffolding/pkg/loginguard"
+	"go_web_scaffolding/pkg/password"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrUserExist       = errors.New("username already exists")
+	ErrEmailExist      = errors.New("email already registered")
+	ErrUserNotExist    = errors.New("user not exist")
+	ErrInvalidPassword = errors.New("invalid username or password")
+
+	ErrUnsupportedLocale = apperr.BadRequest("unsupported locale")
+	ErrInvalidTimezone   = apperr.BadRequest("invalid timezone")
+)
+
+// SignUpParams 注册参数
+type SignUpParams struct {
+	Username string
+	Password string
+	Email    string
+	Code     string // 邮箱验证码
+}
+
+// SignUp 注册：校验邮箱验证码和密码强度，用户名和邮箱都不能重复
+func SignUp(ctx context.Context, p *SignUpParams) (u *models.User, err error) {
+	email := strings.ToLower(strings.TrimSpace(p.Email))
+	exist, err := mysql.CheckUserExist(ctx, p.Username)
+	if err != nil {
+		return
+	}
+	if exist {
+		return nil, ErrUserExist
+	}
+	if exist, err = mysql.CheckEmailExist(ctx, email); err != nil {
+		return
+	}
+	if exist {
+		return nil, ErrEmailExist
+	}
+	if err = password.Validate(p.Password, p.Username); err != nil {
+		return
+	}
+	// 最后再校验验证码，前面的检查失败时验证码不会被消耗
+	if err = CheckEmailCode(ctx, SceneRegister, email, p.Code); err != nil {
+		return
+	}
+	hashed, err := password.Hash(p.Password)
+	if err != nil {
+		return
+	}
+	userID, err := idgen.NextInt64(ctx)
+	if err != nil {
+		return
+	}
+	u = &models.User{UserID: userID, Username: p.Username, Password: hashed, Nickname: p.Username, Email: email}
+	// 验证码和用户一起提交：插入失败验证码还能再用，同一个验证码并发注册时只有一个能成功
+	err = mysql.WithTx(ctx, func(ctx context.Context) error {
+		if err := mysql.InsertUser(ctx, u); err != nil {
+			return err
+		}
+		return ConsumeEmailCode(ctx, SceneRegister, email)
+	})
+	// 前面查过用户名没被占用，并发注册同一个用户名时由唯一索引拦住
+	if mysql.IsDuplicate(err) {
+		return nil, ErrUserExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	audit.Log(ctx, "user.signup", u.Username, "")
+	eventbus.Publish(ctx, UserRegistered{u})
+	return
+}
+
+// dummyHash 用户不存在时也算一次哈希，响应时间不暴露用户名是否注册过
+var dummyHash = sync.OnceValue(func() string {
+	h, _ := password.Hash("dummy password for timing")
+	return h
+})
+
+// Login 用户名密码登录，开启了两步验证的用户还需要调用 VerifyMFA
+func Login(ctx context.Context, username, plain string) (*LoginResult, error) {
+	ip := ctxutil.ClientIP(ctx)
+	if err := loginguard.Before(ctx, username, ip); err != nil {
+		return nil, err
+	}
+	u, err := mysql.GetUserByUsername(ctx, username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	// 用户不存在和密码错误返回同一个错误，不暴露用户名是否注册过
+	// 第三方登录创建的账号没有密码，也不能用密码登录
+	if err != nil || u.Password == "" {
+		_ = password.Verify(plain, dummyHash())
+		loginguard.Failure(ctx, username, ip)
+		return nil, ErrInvalidPassword
+	}
+	if password.Verify(plain, u.Password) != nil {
+		loginguard.Failure(ctx, username, ip)
+		return nil, ErrInvalidPassword
+	}
+	loginguard.Success(ctx, username, ip)
+
+	// 哈希算法或参数调整过，趁有明文的时候升级
+	if password.NeedsRehash(u.Password) {
+		if hashed, err := password.Hash(plain); err == nil {
+			if err = mysql.UpdateUserPassword(ctx, u.UserID, hashed); err != nil {
+				logger.Module(ctx, "logic").Warn("rehash password failed", zap.Int64("user_id", u.UserID), zap.Error(err))
+			}
+		}
+	}
+	return completeLogin(ctx, u)
+}
+
+// GetProfile 查询用户资料
+func GetProfile(ctx context.Context, userID int64) (*models.User, error) {
+	u, err := mysql.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotExist
+	}
+	return u, err
+}
+
+// ProfileParams 修改资料的参数，空字段不修改
+type ProfileParams struct {
+	Nickname string
+	Avatar   string
+	Locale   string // 有翻译的语言，如 zh-CN、en
+	Timezone string // IANA 时区名，如 Asia/Shanghai
+}
+
+// UpdateProfile 更新昵称、头像和语言、时区偏好，空字段不修改
+func UpdateProfile(ctx context.Context, userID int64, p *ProfileParams) (*models.User, error) {
+	if p.Locale != "" && !i18n.Supported(p.Locale) {
+		return nil, ErrUnsupportedLocale
+	}
+	if p.Timezone != "" {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return nil, ErrInvalidTimezone
+		}
+	}
+	u, err := GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Nickname != "" {
+		u.Nickname = p.Nickname
+	}
+	if p.Avatar != "" {
+		u.Avatar = p.Avatar
+	}
+	if p.Locale != "" {
+		u.Locale = p.Locale
+	}
+	if p.Timezone != "" {
+		u.Timezone = p.Timezone
+	}
+	if err = mysql.UpdateUserProfile(ctx, u); err != nil {
+		return nil, err
+	}
+	if p.Locale != "" || p.Timezone != "" {
+		if err = cache.Delete(ctx, preferenceCacheKey(userID)); err != nil {
+			logger.Ctx(ctx).Warn("delete preference cache failed", zap.Int64("user_id", userID), zap.Error(err))
+		}
+	}
+	eventbus.Publish(ctx, UserProfileUpdated{u})
+	return u, nil
+}
+
+// ChangePassword 修改密码，需要校验旧密码；第三方登录创建的账号没有旧密码，需要走忘记密码设置
+// 旧密码输错和登录一样计入防暴力破解；改成功后其它设备全部下线，当前设备换发新的 token
+func ChangePassword(ctx context.Context, userID int64, oldPassword, newPassword string) (*LoginResult, error) {
+	u, err := GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	ip := ctxutil.ClientIP(ctx)
+	if err = loginguard.Before(ctx, u.Username, ip); err != nil {
+		return nil, err
+	}
+	if u.Password == "" || password.Verify(oldPassword, u.Password) != nil {
+		loginguard.Failure(ctx, u.Username, ip)
+		return nil, ErrInvalidPassword
+	}
+	loginguard.Success(ctx, u.Username, ip)
+	if err = password.Validate(newPassword, u.Username); err != nil {
+		return nil, err
+	}
+	hashed, err := password.Hash(newPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err = mysql.UpdateUserPassword(ctx, userID, hashed); err != nil {
+		return nil, err
+	}
+	audit.Log(ctx, "user.change_password", u.Username, "")
+	if err = RevokeSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+	aToken, rToken, err := IssueToken(ctx, u.UserID, u.Username)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{AccessToken: aToken, RefreshToken: rToken}, nil
+}
@@ -0,0 +1,215 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/mailer"
+	"go_web_scaffolding/settings"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// 验证码的使用场景，不同场景的验证码互不通用
+const (
+	// SceneRegister 注册，发给还没注册的邮箱
+	SceneRegister = "register"
+	// SceneConfirm 已登录用户的敏感操作确认，只能发给自己的邮箱
+	SceneConfirm = "confirm"
+)
+
+var (
+	// ErrCodeTooFrequent 发送太频繁，用 errors.As 取出 *CodeRateLimitError 拿到剩余时间
+	ErrCodeTooFrequent = errors.New("verify code sent too frequently")
+	// ErrInvalidCode 验证码错误、已过期或尝试次数过多
+	ErrInvalidCode = errors.New("invalid or expired verify code")
+	// ErrUnknownScene 不支持的验证码场景
+	ErrUnknownScene = errors.New("unknown verify code scene")
+)
+
+// CodeRateLimitError 发送限额，RetryAfter 为 0 表示当前窗口的次数用完了
+type CodeRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CodeRateLimitError) Error() string {
+	return ErrCodeTooFrequent.Error()
+}
+
+func (e *CodeRateLimitError) Unwrap() error {
+	return ErrCodeTooFrequent
+}
+
+func codeConfig() settings.VerifyCodeConfig {
+	cfg := settings.VerifyCodeConfig{TTL: 600, Cooldown: 60, MaxPerAddress: 10, MaxPerIP: 30, MaxAttempts: 5}
+	if c := settings.Conf.VerifyCodeConfig; c != nil {
+		if c.TTL > 0 {
+			cfg.TTL = c.TTL
+		}
+		if c.Cooldown > 0 {
+			cfg.Cooldown = c.Cooldown
+		}
+		if c.MaxPerAddress > 0 {
+			cfg.MaxPerAddress = c.MaxPerAddress
+		}
+		if c.MaxPerIP > 0 {
+			cfg.MaxPerIP = c.MaxPerIP
+		}
+		if c.MaxAttempts > 0 {
+			cfg.MaxAttempts = c.MaxAttempts
+		}
+	}
+	return cfg
+}
+
+func hashCode(scene, address, code string) string {
+	sum := sha256.Sum256([]byte(scene + ":" + address + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// SendEmailCode 给邮箱发送 6 位数字验证码
+// 同一地址有发送间隔和每天的次数限制，同一 IP 有每小时的次数限制，防止被用来轰炸别人的邮箱
+// 所有限制都检查通过后才开始发送冷却，被拒绝的请求不会让正常用户也要等一个冷却期
+// 收件地址的邮件限额用完时返回 mailer.ErrRateLimited（429）
+func SendEmailCode(ctx context.Context, scene, email string) error {
+	if scene != SceneRegister && scene != SceneConfirm {
+		return ErrUnknownScene
+	}
+	cfg := codeConfig()
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	ttl, err := redis.VerifyCodeCooldownTTL(ctx, email)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		return &CodeRateLimitError{RetryAfter: ttl}
+	}
+	if ip := ctxutil.ClientIP(ctx); ip != "" {
+		n, err := redis.IncrVerifyCodeIP(ctx, ip, time.Hour)
+		if err != nil {
+			return err
+		}
+		if n > cfg.MaxPerIP {
+			return &CodeRateLimitError{}
+		}
+	}
+	// 邮件限额用完时发不出去，不能再扣这个地址的验证码次数
+	if err = mailer.CheckLimit(ctx, email); err != nil {
+		return err
+	}
+	n, err := redis.IncrVerifyCodeAddress(ctx, email, 24*time.Hour)
+	if err != nil {
+		return err
+	}
+	if n > cfg.MaxPerAddress {
+		return &CodeRateLimitError{}
+	}
+	if scene == SceneRegister {
+		exist, err := mysql.CheckEmailExist(ctx, email)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return ErrEmailExist
+		}
+	}
+	// 并发的请求都通过了前面的检查时只有一个能开始冷却
+	ok, ttl, err := redis.VerifyCodeCooldown(ctx, email, time.Duration(cfg.Cooldown)*time.Second)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &CodeRateLimitError{RetryAfter: ttl}
+	}
+
+	v, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("%06d", v.Int64())
+	ttlDur := time.Duration(cfg.TTL) * time.Second
+	if err = redis.SaveVerifyCode(ctx, scene, email, hashCode(scene, email, code), ttlDur); err != nil {
+		return err
+	}
+	msg, err := mailer.NewMessage(email, "verify_code.html", map[string]interface{}{
+		"App":     settings.Conf.Name,
+		"Code":    code,
+		"Minutes": int(ttlDur.Minutes()),
+	})
+	if err != nil {
+		return err
+	}
+	return mailer.EnqueueSecret(ctx, msg, ttlDur)
+}
+
+// SendConfirmCode 给当前登录用户自己的邮箱发送敏感操作确认码
+func SendConfirmCode(ctx context.Context, userID int64) error {
+	u, err := mysql.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u.Email == "" {
+		return ErrInvalidCode
+	}
+	return SendEmailCode(ctx, SceneConfirm, u.Email)
+}
+
+// VerifyEmailCode 校验验证码，通过后立即作废，错误次数过多时也作废
+func VerifyEmailCode(ctx context.Context, scene, email, code string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if err := CheckEmailCode(ctx, scene, email, code); err != nil {
+		return err
+	}
+	return redis.DeleteVerifyCode(ctx, scene, email)
+}
+
+// CheckEmailCode 只校验不作废，计入尝试次数，错误次数过多时作废；
+// 给前端提前校验用，真正使用验证码的操作（比如注册）再用 ConsumeEmailCode 消耗掉
+func CheckEmailCode(ctx context.Context, scene, email, code string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	codeHash, attempts, err := redis.AttemptVerifyCode(ctx, scene, email)
+	if err != nil {
+		return err
+	}
+	if codeHash == "" {
+		return ErrInvalidCode
+	}
+	if attempts > codeConfig().MaxAttempts {
+		_ = redis.DeleteVerifyCode(ctx, scene, email)
+		return ErrInvalidCode
+	}
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(hashCode(scene, email, strings.TrimSpace(code)))) != 1 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// ConsumeEmailCode 消耗已经用 CheckEmailCode 校验过的验证码，已经被并发的请求用掉时返回 ErrInvalidCode
+func ConsumeEmailCode(ctx context.Context, scene, email string) error {
+	ok, err := redis.ConsumeVerifyCode(ctx, scene, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// VerifyConfirmCode 校验当前登录用户的敏感操作确认码
+func VerifyConfirmCode(ctx context.Context, userID int64, code string) error {
+	u, err := mysql.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return VerifyEmailCode(ctx, SceneConfirm, u.Email, code)
+}
@@ -0,0 +1,160 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/apperr"
+	"go_web_scaffolding/pkg/pagination"
+	"go_web_scaffolding/pkg/webhook"
+	"net/url"
+	"strings"
+)
+
+// webhookSecretPrefix 签名密钥的固定前缀，和 api key 一样方便扫描泄露
+const webhookSecretPrefix = "whsec_"
+
+var (
+	// ErrWebhookNotExist 管理接口操作的 webhook 不存在
+	ErrWebhookNotExist = apperr.NotFound("webhook not exist")
+	// ErrWebhookDeliveryNotExist 投递记录不存在，或者已经过了保留时间被清理
+	ErrWebhookDeliveryNotExist = apperr.NotFound("webhook delivery not exist")
+	// ErrInvalidWebhookURL 只支持 http 和 https
+	ErrInvalidWebhookURL = apperr.BadRequest("invalid webhook url")
+	// ErrWebhookDeliveryPending 还在待投递（可能正在发送），等它结束后再重新投递
+	ErrWebhookDeliveryPending = apperr.Conflict("webhook delivery is still pending")
+	// ErrInvalidWebhookEvents 事件名不能为空，也不能包含逗号
+	ErrInvalidWebhookEvents = apperr.BadRequest("invalid webhook events")
+)
+
+// WebhookParams 创建和修改 webhook 的参数，Events 为空表示订阅所有事件
+type WebhookParams struct {
+	URL         string
+	Events      []string
+	Description string
+	Enabled     bool
+}
+
+// apply 校验参数并写到 w 上
+func (p *WebhookParams) apply(w *models.Webhook) error {
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	events := p.Events
+	if len(events) == 0 {
+		events = []string{"*"}
+	}
+	for _, e := range events {
+		if e == "" || strings.Contains(e, ",") {
+			return ErrInvalidWebhookEvents
+		}
+	}
+	w.URL = u.String()
+	w.Events = strings.Join(events, ",")
+	w.Description = p.Description
+	w.Enabled = p.Enabled
+	return nil
+}
+
+// CreateWebhook 创建 webhook，返回的签名密钥只有这一次机会拿到
+func CreateWebhook(ctx context.Context, p *WebhookParams) (secret string, w *models.Webhook, err error) {
+	w = new(models.Webhook)
+	if err = p.apply(w); err != nil {
+		return
+	}
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	secret = webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(b)
+	w.Secret = secret
+	if err = mysql.InsertWebhook(ctx, w); err != nil {
+		return
+	}
+	err = webhook.Invalidate(ctx)
+	return
+}
+
+// UpdateWebhook 修改 webhook，停用后还没投递的记录会直接标记为死信
+func UpdateWebhook(ctx context.Context, id int64, p *WebhookParams) (w *models.Webhook, err error) {
+	if w, err = getWebhook(ctx, id); err != nil {
+		return
+	}
+	if err = p.apply(w); err != nil {
+		return
+	}
+	if err = mysql.UpdateWebhook(ctx, w); err != nil {
+		return
+	}
+	err = webhook.Invalidate(ctx)
+	return
+}
+
+// ListWebhooks 列出所有 webhook，不含签名密钥
+func ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	list, err := mysql.ListWebhooks(ctx)
+	if list == nil {
+		list = []*models.Webhook{}
+	}
+	return list, err
+}
+
+// DeleteWebhook 删除 webhook，投递日志保留到过期清理
+func DeleteWebhook(ctx context.Context, id int64) error {
+	n, err := mysql.DeleteWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrWebhookNotExist
+	}
+	return webhook.Invalidate(ctx)
+}
+
+func getWebhook(ctx context.Context, id int64) (*models.Webhook, error) {
+	w, err := mysql.GetWebhookByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotExist
+	}
+	return w, err
+}
+
+// ListWebhookDeliveries 分页列出一个 webhook 的投递日志，按 id 倒序
+func ListWebhookDeliveries(ctx context.Context, webhookID int64, p pagination.Params) (*pagination.Result[*models.WebhookDelivery], error) {
+	if _, err := getWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	list, total, err := mysql.ListWebhookDeliveries(ctx, webhookID, p)
+	if err != nil {
+		return nil, err
+	}
+	if p.IsCursor() {
+		return pagination.CursorResult(list, p, func(d *models.WebhookDelivery) int64 { return d.ID }), nil
+	}
+	return pagination.OffsetResult(list, total, p), nil
+}
+
+// RedeliverWebhook 重新投递已经结束的记录，不管之前成功还是失败，尝试次数从 0 开始，请求体和投递 ID 不变
+// 还在待投递的返回 ErrWebhookDeliveryPending
+func RedeliverWebhook(ctx context.Context, id int64) error {
+	n, err := mysql.ResetWebhookDelivery(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err = mysql.GetWebhookDeliveryByID(ctx, id); errors.Is(err, sql.ErrNoRows) {
+			return ErrWebhookDeliveryNotExist
+		}
+		if err != nil {
+			return err
+		}
+		return ErrWebhookDeliveryPending
+	}
+	webhook.Notify()
+	return nil
+}
@@ -3,24 +3,62 @@ package main
 import (
 	"context"
 	"fmt"
+	"go_web_scaffolding/controller"
+	"go_web_scaffolding/dao/clickhouse"
+	"go_web_scaffolding/dao/es"
+	"go_web_scaffolding/dao/kafka"
+	"go_web_scaffolding/dao/mongo"
 	"go_web_scaffolding/dao/mysql"
 	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/alert"
+	"go_web_scaffolding/pkg/audit"
+	"go_web_scaffolding/pkg/breaker"
+	"go_web_scaffolding/pkg/cache"
+	"go_web_scaffolding/pkg/captcha"
+	"go_web_scaffolding/pkg/delay"
+	"go_web_scaffolding/pkg/health"
+	"go_web_scaffolding/pkg/httpclient"
+	"go_web_scaffolding/pkg/i18n"
+	"go_web_scaffolding/pkg/idgen"
+	"go_web_scaffolding/pkg/jobs"
+	"go_web_scaffolding/pkg/jwt"
+	"go_web_scaffolding/pkg/lifecycle"
+	"go_web_scaffolding/pkg/locale"
+	"go_web_scaffolding/pkg/loginguard"
+	"go_web_scaffolding/pkg/mailer"
+	"go_web_scaffolding/pkg/metrics"
+	"go_web_scaffolding/pkg/migrate"
+	"go_web_scaffolding/pkg/oauth"
+	"go_web_scaffolding/pkg/outbox"
+	"go_web_scaffolding/pkg/pagination"
+	"go_web_scaffolding/pkg/password"
+	"go_web_scaffolding/pkg/pubsub"
+	"go_web_scaffolding/pkg/push"
+	"go_web_scaffolding/pkg/rbac"
+	"go_web_scaffolding/pkg/sentry"
+	"go_web_scaffolding/pkg/server"
+	"go_web_scaffolding/pkg/session"
+	"go_web_scaffolding/pkg/sms"
+	"go_web_scaffolding/pkg/snowflake"
+	"go_web_scaffolding/pkg/storage"
+	"go_web_scaffolding/pkg/stream"
+	"go_web_scaffolding/pkg/tmpl"
+	"go_web_scaffolding/pkg/tracing"
+	"go_web_scaffolding/pkg/version"
+	"go_web_scaffolding/pkg/webhook"
+	"go_web_scaffolding/pkg/workerpool"
+	"go_web_scaffolding/pkg/ws"
 	"go_web_scaffolding/routes"
 	"go_web_scaffolding/settings"
-	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
-var logger *zap.Logger
-var sugarLogger *zap.SugaredLogger
-
 func main() {
 	// 1. 加载配置
 	if err := settings.Init(); err != nil {
@@ -29,57 +67,347 @@ func main() {
 	}
 
 	// 2. 初始化日志
-	if err := logger.Init(settings.Conf.LogConfig); err != nil {
-		fmt.Printf("init setting failed error:%v\n", err)
+	if err := logger.Init(settings.Conf.LogConfig, settings.Conf.Mode); err != nil {
+		fmt.Printf("init logger failed error:%v\n", err)
 		return
 	}
 	// 延迟注册一下，把缓冲区的文件追加到日志文件中
-	defer zap.L().Sync()
+	defer logger.Sync()
+	// kill -USR2 <pid> 临时打开 debug 日志，再发一次恢复
+	logger.WatchLevelSignal()
 	// zap.ReplaceGlobals(lg)后 通过zap.L()调用
 	zap.L().Debug("logger init success...")
 
+	// 子命令（如 app migrate up）执行完直接退出，失败时退出码为 1，部署脚本和 CI 据此中止
+	if handled, err := runCommand(os.Args[1:]); handled {
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			// os.Exit 不会执行 defer，先把日志刷出去
+			logger.Sync()
+			os.Exit(1)
+		}
+		return
+	}
+	build := version.Get()
+	zap.L().Info("starting",
+		zap.String("name", settings.Conf.Name),
+		zap.String("version", build.Version),
+		zap.String("git_commit", build.GitCommit),
+		zap.String("build_time", build.BuildTime),
+		zap.String("go_version", build.GoVersion),
+	)
+
+	// 链路追踪要在 MySQL、Redis 之前初始化，它们的埋点依赖全局的 TracerProvider
+	if err := tracing.Init(settings.Conf.TraceConfig); err != nil {
+		fmt.Printf("init tracing failed error:%v\n", err)
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = tracing.Shutdown(ctx)
+	}()
+
+	if err := sentry.Init(settings.Conf.SentryConfig); err != nil {
+		fmt.Printf("init sentry failed error:%v\n", err)
+		return
+	}
+	defer sentry.Flush(2 * time.Second)
+
+	if err := alert.Init(settings.Conf.AlertConfig); err != nil {
+		fmt.Printf("init alert failed error:%v\n", err)
+		return
+	}
+	alert.Start()
+	defer alert.Stop()
+
+	// 熔断器在 MySQL、Redis 建连接池时就会用到
+	if err := breaker.Init(settings.Conf.BreakerConfig); err != nil {
+		fmt.Printf("init breaker failed error:%v\n", err)
+		return
+	}
+
+	if err := httpclient.Init(settings.Conf.HTTPClientConfig); err != nil {
+		fmt.Printf("init http client failed error:%v\n", err)
+		return
+	}
+
+	// 后台任务的协程池，号段预取、连接池切换等会用到
+	if err := workerpool.Init(settings.Conf.WorkerPoolConfig); err != nil {
+		fmt.Printf("init worker pool failed error:%v\n", err)
+		return
+	}
+
 	// 3. 初始化MySQL连接
 	if err := mysql.Init(settings.Conf.MySQLConfig); err != nil {
-		fmt.Printf("init setting failed error:%v\n", err)
+		fmt.Printf("init mysql failed error:%v\n", err)
 		return
 	}
 	defer mysql.Close()
 
+	// dev 模式下启动时自动执行迁移，省去手动建表；local 模式总是自动迁移
+	if mode := settings.Conf.Mode; (mode == "dev" || mode == "local") && settings.Conf.MySQLConfig.AutoMigrate {
+		if err := migrate.Up(context.Background()); err != nil {
+			fmt.Printf("auto migrate failed error:%v\n", err)
+			return
+		}
+	}
+
+	// 审计日志异步批量写库，退出时先把缓冲区写完再关闭 MySQL
+	if err := audit.Init(settings.Conf.AuditConfig); err != nil {
+		fmt.Printf("init audit failed error:%v\n", err)
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		audit.Close(ctx)
+	}()
+
 	// 4. 初始化Redis连接
-	if err := redis.Init(); err != nil {
-		fmt.Printf("init setting failed error:%v\n", err)
+	if err := redis.Init(settings.Conf.RedisConfig); err != nil {
+		fmt.Printf("init redis failed error:%v\n", err)
 		return
 	}
 	defer redis.Close()
-	// 5. 注册路由
-	r := routes.Setup()
-	// 6. 启动服务（优雅关机）
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", viper.GetInt("app.port")),
-		Handler: r,
+
+	if err := mongo.Init(settings.Conf.MongoConfig); err != nil {
+		fmt.Printf("init mongo failed error:%v\n", err)
+		return
 	}
+	defer mongo.Close()
 
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
-		}
+	// 搜索索引异步批量写入，退出时把缓冲区写完
+	if err := es.Init(settings.Conf.ESConfig); err != nil {
+		fmt.Printf("init es failed error:%v\n", err)
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		es.Close(ctx)
 	}()
 
-	// 等待中断信号量来优雅关闭服务器，为关闭服务器设置一个5秒的超时
+	// 分析数据异步批量写入，退出时把缓冲区写完
+	if err := clickhouse.Init(settings.Conf.ClickHouseConfig); err != nil {
+		fmt.Printf("init clickhouse failed error:%v\n", err)
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		clickhouse.Close(ctx)
+	}()
+
+	// 消息队列，退出时先停消费者再关生产者（死信要靠生产者写出去）
+	if err := kafka.Init(settings.Conf.KafkaConfig); err != nil {
+		fmt.Printf("init kafka failed error:%v\n", err)
+		return
+	}
+	defer kafka.Close()
+
+	// 连接池指标，退出时先于 MySQL、Redis 关闭
+	if cfg := settings.Conf.MetricsConfig; cfg != nil && cfg.Enable {
+		metrics.StartPoolSampler(time.Duration(cfg.PoolInterval) * time.Second)
+		defer metrics.StopPoolSampler()
+	}
+
+	if err := cache.Init(settings.Conf.CacheConfig); err != nil {
+		fmt.Printf("init cache failed error:%v\n", err)
+		return
+	}
+
+	if err := session.Init(settings.Conf.SessionConfig); err != nil {
+		fmt.Printf("init session failed error:%v\n", err)
+		return
+	}
+
+	if err := jwt.Init(settings.Conf.JWTConfig); err != nil {
+		fmt.Printf("init jwt failed error:%v\n", err)
+		return
+	}
+
+	if err := locale.Init(settings.Conf.LocaleConfig); err != nil {
+		fmt.Printf("init locale failed error:%v\n", err)
+		return
+	}
+	if err := i18n.Init(settings.Conf.LocaleConfig); err != nil {
+		fmt.Printf("init i18n failed error:%v\n", err)
+		return
+	}
+
+	// 参数校验错误的翻译，按请求的语言返回
+	if err := controller.InitTrans(); err != nil {
+		fmt.Printf("init validator trans failed error:%v\n", err)
+		return
+	}
+
+	if err := snowflake.Init(settings.Conf.SnowflakeConfig); err != nil {
+		fmt.Printf("init snowflake failed error:%v\n", err)
+		return
+	}
+
+	// 号段模式启动时要从 MySQL 取第一个号段
+	if err := idgen.Init(settings.Conf.IDGenConfig); err != nil {
+		fmt.Printf("init idgen failed error:%v\n", err)
+		return
+	}
+
+	if err := pagination.Init(settings.Conf.PaginationConfig); err != nil {
+		fmt.Printf("init pagination failed error:%v\n", err)
+		return
+	}
+
+	if err := health.Init(settings.Conf.HealthConfig); err != nil {
+		fmt.Printf("init health failed error:%v\n", err)
+		return
+	}
+
+	// dev 模式下邮件模板和页面模板都在监听目录，退出时停止监听
+	defer tmpl.Close()
+	if err := mailer.Init(settings.Conf.MailConfig); err != nil {
+		fmt.Printf("init mail failed error:%v\n", err)
+		return
+	}
+
+	if err := sms.Init(settings.Conf.SMSConfig); err != nil {
+		fmt.Printf("init sms failed error:%v\n", err)
+		return
+	}
+
+	if err := push.Init(settings.Conf.PushConfig); err != nil {
+		fmt.Printf("init push failed error:%v\n", err)
+		return
+	}
+
+	var storageCfg *settings.StorageConfig
+	if cfg := settings.Conf.UploadConfig; cfg != nil {
+		storageCfg = cfg.Storage
+	}
+	if err := storage.Init(storageCfg); err != nil {
+		fmt.Printf("init storage failed error:%v\n", err)
+		return
+	}
+
+	if err := captcha.Init(settings.Conf.CaptchaConfig); err != nil {
+		fmt.Printf("init captcha failed error:%v\n", err)
+		return
+	}
+
+	if err := password.Init(settings.Conf.PasswordConfig); err != nil {
+		fmt.Printf("init password failed error:%v\n", err)
+		return
+	}
+
+	if err := loginguard.Init(settings.Conf.LoginGuardConfig); err != nil {
+		fmt.Printf("init login guard failed error:%v\n", err)
+		return
+	}
+
+	if err := oauth.Init(settings.Conf.OAuthConfig); err != nil {
+		fmt.Printf("init oauth failed error:%v\n", err)
+		return
+	}
+
+	// 权限策略变更通过 pubsub 通知其它实例，需要在 pubsub.Start 之前注册
+	if err := rbac.Init(settings.Conf.RBACConfig); err != nil {
+		fmt.Printf("init rbac failed error:%v\n", err)
+		return
+	}
+
+	// WebSocket 跨实例推送也走 pubsub
+	if err := ws.Init(settings.Conf.WSConfig); err != nil {
+		fmt.Printf("init ws failed error:%v\n", err)
+		return
+	}
+
+	// 启动 redis 广播订阅，退出时先于 redis 连接关闭
+	pubsub.Start()
+	defer pubsub.Stop()
+
+	if err := stream.Init(settings.Conf.StreamConfig); err != nil {
+		fmt.Printf("init stream failed error:%v\n", err)
+		return
+	}
+	stream.Start()
+	defer stream.Stop()
+
+	kafka.Start()
+	defer kafka.Stop()
+
+	if err := delay.Init(settings.Conf.DelayConfig); err != nil {
+		fmt.Printf("init delay queue failed error:%v\n", err)
+		return
+	}
+	delay.Start()
+	defer delay.Stop()
+
+	// 发件箱 relay 依赖 MySQL 和 redis stream，退出时先于它们停止
+	if err := outbox.Init(settings.Conf.OutboxConfig); err != nil {
+		fmt.Printf("init outbox failed error:%v\n", err)
+		return
+	}
+	outbox.Start()
+	defer outbox.Stop()
+
+	// webhook 在 Init 时订阅领域事件，relay 退出时等手上这批投递完
+	if err := webhook.Init(settings.Conf.WebhookConfig); err != nil {
+		fmt.Printf("init webhook failed error:%v\n", err)
+		return
+	}
+	webhook.Start()
+	defer webhook.Stop()
+
+	// 后台任务 worker 退出时等待执行中的任务，和 http server 共用 shutdown.timeout
+	if err := jobs.Init(settings.Conf.JobsConfig); err != nil {
+		fmt.Printf("init jobs failed error:%v\n", err)
+		return
+	}
+	jobs.Start()
+	defer jobs.Stop()
+	// 5. 注册路由并启动服务
+	// 公共服务先添加，关闭时先关；配置了独立管理端口时管理接口单独监听，最后关闭
+	mgr := server.NewManager()
+	mgr.Add(server.NewHTTP("public", fmt.Sprintf(":%d", settings.Conf.Port), routes.Setup()))
+	if cfg := settings.Conf.AdminConfig; cfg != nil && cfg.Port > 0 {
+		mgr.Add(server.NewHTTP("admin", fmt.Sprintf(":%d", cfg.Port), routes.SetupAdmin()))
+	}
+	if cfg := settings.Conf.GRPCConfig; cfg != nil && cfg.Enable {
+		mgr.Add(server.NewGRPC("grpc", fmt.Sprintf(":%d", cfg.Port), routes.SetupGRPC(cfg)))
+	}
+	if cfg := settings.Conf.MetricsConfig; cfg != nil && cfg.Enable && cfg.Port > 0 {
+		mgr.Add(server.NewHTTP("metrics", fmt.Sprintf(":%d", cfg.Port), routes.SetupMetrics()))
+	}
+	mgr.Start()
+
+	// 6. 等待中断信号量来优雅关闭服务器
 	quit := make(chan os.Signal, 1) // 创建一个接收信号的通道
 	// kill 默认会发送syscall.SIGTERM信号
 	// kill -2 发送 syscall.SIGINT 信号，我们常用的Ctrl+C就是触发系统的SIGINT信号
 	// kill -9 发送 syscall.SIGKILL 信号，但是不能被捕获，所以不需要添加它
 	// signal.Notify把收到的 syscall.SIGINT或syscall.SIGTERM 信号转发给quit
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM) // 此处不会阻塞
-	<-quit                                               // 阻塞在此处，当接收到上述两种信号时才会往下执行
-	zap.L().Info("Shutdown Server ...")
-	// 创建一个5秒超时的context
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	select {                                             // 阻塞在此处，当接收到上述两种信号或者有服务异常退出时才会往下执行
+	case <-quit:
+		zap.L().Info("Shutdown Server ...")
+		// 先进入跛脚鸭状态：就绪检查返回失败，等 k8s 把流量摘掉（preStop 里已经等过的话这里直接返回）
+		lifecycle.Drain(time.Duration(settings.Conf.ShutdownConfig.LameDuck) * time.Second)
+	case err := <-mgr.Errors():
+		zap.L().Error("server failed, shutting down", zap.Error(err))
+	}
+	// 创建一个超时的context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(settings.Conf.ShutdownConfig.Timeout)*time.Second)
 	defer cancel()
-	// 5秒内优雅关闭服务（将未处理玩的请求处理完再关闭服务），超过5秒就超时退出
-	if err := srv.Shutdown(ctx); err != nil {
-		zap.L().Fatal("Server Shutdown", zap.Error(err))
+	// 超时时间内按顺序优雅关闭所有服务（将未处理玩的请求处理完再关闭服务），超时就退出
+	if err := mgr.Shutdown(ctx); err != nil {
+		zap.L().Error("Server Shutdown", zap.Error(err))
+	}
+	// 已经升级的 WebSocket 连接不归 http.Server 管，单独通知客户端断开
+	if err := ws.Shutdown(ctx); err != nil {
+		zap.L().Error("ws shutdown", zap.Error(err))
+	}
+	// 请求里提交的后台任务可能还在执行，等它们做完再关闭 MySQL、Redis，和 http server 共用同一个超时
+	if err := workerpool.Shutdown(ctx); err != nil {
+		zap.L().Error("worker pool shutdown", zap.Error(err))
 	}
-
 }
@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/settings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth 管理接口鉴权中间件
+// 请求头 X-Admin-Token 必须和配置的 admin.token 一致，未配置 token 时管理接口整体关闭
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := settings.Conf.AdminConfig
+		if cfg == nil || cfg.Token == "" {
+			response.Abort(c, response.CodeAdminDisabled)
+			return
+		}
+		token := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) != 1 {
+			response.Abort(c, response.CodeInvalidAdminToken)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"go_web_scaffolding/dao/clickhouse"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/ctxutil"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accessEventTable 访问事件在 ClickHouse 里的表名，建表语句见 models.AccessEvent
+const accessEventTable = "access_event"
+
+// AccessEvents 每个请求写一条访问事件到 ClickHouse，异步攒批，缓冲区满时直接丢弃
+// 和 Metrics 一样用路由模板而不是实际路径，没有匹配到路由的记为 unmatched
+func AccessEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := c.Request.Context()
+		_ = clickhouse.Async(ctx, accessEventTable, &models.AccessEvent{
+			Time:      start,
+			RequestID: ctxutil.RequestID(ctx),
+			Method:    c.Request.Method,
+			Route:     route,
+			Status:    c.Writer.Status(),
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			UserID:    ctxutil.UserID(ctx),
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/ctxutil"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// APIKeyAuth 机器调用方的鉴权中间件
+// 请求头 X-API-Key 携带 api key，scopes 为访问该组接口需要的全部权限
+func APIKeyAuth(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		key := c.GetHeader("X-API-Key")
+		if key == "" {
+			response.Abort(c, response.CodeInvalidAPIKey)
+			return
+		}
+		k, err := logic.AuthenticateAPIKey(ctx, key)
+		if err != nil {
+			if errors.Is(err, logic.ErrInvalidAPIKey) {
+				response.Abort(c, response.CodeInvalidAPIKey)
+				return
+			}
+			logger.Module(ctx, "middleware").Error("logic.AuthenticateAPIKey failed", zap.Error(err))
+			response.Abort(c, response.CodeServiceUnavailable)
+			return
+		}
+		for _, s := range scopes {
+			if !k.HasScope(s) {
+				response.Abort(c, response.CodeInsufficientScope)
+				return
+			}
+		}
+		ctx = ctxutil.WithAPIClient(ctx, &ctxutil.APIClient{KeyID: k.ID, Name: k.Name, Scopes: k.Scopes})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
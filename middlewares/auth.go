@@ -0,0 +1,94 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/logic"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/jwt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// JWTAuthMiddleware 基于JWT的认证中间件
+func JWTAuthMiddleware() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// 客户端携带Token有三种方式 1.放在请求头 2.放在请求体 3.放在URI
+		// 这里假设Token放在Header的Authorization中，并使用Bearer开头
+		// Authorization: Bearer xxxxxxx.xxx.xxx
+		authHeader := c.Request.Header.Get("Authorization")
+		if authHeader == "" {
+			authHeader = wsProtocolToken(c.Request)
+		}
+		if authHeader == "" {
+			response.Abort(c, response.CodeNeedLogin)
+			return
+		}
+		ctx, code := authenticate(c.Request.Context(), authHeader)
+		if code != response.CodeSuccess {
+			response.Abort(c, code)
+			return
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// wsProtocolToken 浏览器建立 WebSocket 时不能自定义请求头，token 通过子协议传过来：
+// new WebSocket(url, ["bearer", token])，请求头是 Sec-WebSocket-Protocol: bearer, xxx
+// 不放在 URL 参数里是因为 URL 会被记到访问日志和代理的日志里
+func wsProtocolToken(r *http.Request) string {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return ""
+	}
+	parts := strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",")
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) != "bearer" {
+		return ""
+	}
+	return "Bearer " + strings.TrimSpace(parts[1])
+}
+
+// authenticate 校验 Authorization 头里的 Bearer token，通过时返回带有用户信息的 ctx
+// HTTP 和 gRPC 共用，失败时返回对应的错误码，由调用方转换成各自的响应
+func authenticate(ctx context.Context, authHeader string) (context.Context, response.ResCode) {
+	// 按空格分割
+	parts := strings.SplitN(authHeader, " ", 2)
+	if !(len(parts) == 2 && parts[0] == "Bearer") {
+		return ctx, response.CodeInvalidToken
+	}
+	// parts[1]是获取到的tokenString，我们使用之前定义好的解析JWT的函数来解析它
+	mc, err := jwt.ParseToken(parts[1])
+	if err != nil {
+		return ctx, response.CodeInvalidToken
+	}
+	// 已注销的 token 在过期之前都会留在黑名单里
+	revoked, err := redis.IsTokenRevoked(ctx, mc.ID)
+	if err != nil {
+		logger.Module(ctx, "middleware").Error("redis.IsTokenRevoked failed", zap.Error(err))
+		return ctx, response.CodeServiceUnavailable
+	}
+	if revoked {
+		return ctx, response.CodeInvalidToken
+	}
+	// 改过密码，或者单设备登录模式下在其它设备登录后，这里的会话就失效了
+	if err := logic.CheckSession(ctx, mc); err != nil {
+		if errors.Is(err, logic.ErrSessionReplaced) {
+			return ctx, response.CodeSessionReplaced
+		}
+		if errors.Is(err, logic.ErrSessionRevoked) {
+			return ctx, response.CodeInvalidToken
+		}
+		logger.Module(ctx, "middleware").Error("logic.CheckSession failed", zap.Error(err))
+		return ctx, response.CodeServiceUnavailable
+	}
+	// 将当前请求的用户信息保存到请求的上下文上，后续的处理函数通过 ctxutil.CurrentUser 获取
+	ctx = ctxutil.WithUser(ctx, &ctxutil.User{ID: mc.UserID, Username: mc.Username})
+	ctx = jwt.WithClaims(ctx, mc)
+	return ctx, response.CodeSuccess
+}
@@ -0,0 +1,195 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/settings"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// defaultBodyLogMaxSize 请求体和响应体各最多记录的大小，KB
+const defaultBodyLogMaxSize = 4
+
+// jsonStringField 截断后不是合法 JSON 时，用正则找出 "key": "value" 做脱敏
+var jsonStringField = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"\s*:\s*"((?:[^"\\]|\\.)*)"`)
+
+// BodyLog 对配置的路由以 debug 级别记录请求体和响应体，排查客户端对接问题时使用
+// 日志模块名是 body，平时保持 info 级别就不会记录，需要时用管理接口或者 log.levels.body 打开 debug
+// 敏感字段按 log.mask_keys 和 redact_keys 脱敏，超过 max_size 的部分截断
+func BodyLog(cfg *settings.BodyLogConfig) gin.HandlerFunc {
+	routes := make(map[string]bool, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		routes[r] = true
+	}
+	redact := make([]string, 0, len(cfg.RedactKeys))
+	for _, k := range cfg.RedactKeys {
+		redact = append(redact, strings.ToLower(k))
+	}
+	sensitive := func(key string) bool {
+		if logger.IsSensitive(key) {
+			return true
+		}
+		key = strings.ToLower(key)
+		for _, k := range redact {
+			if strings.Contains(key, k) {
+				return true
+			}
+		}
+		return false
+	}
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultBodyLogMaxSize
+	}
+	limit := maxSize * 1024
+
+	return func(c *gin.Context) {
+		if !routes[c.FullPath()] && !routes["*"] {
+			c.Next()
+			return
+		}
+		lg := logger.Module(c.Request.Context(), "body")
+		// 没有打开 debug 时不读取 body，不增加任何开销
+		if lg.Check(zap.DebugLevel, "http body") == nil {
+			c.Next()
+			return
+		}
+
+		// 只读取前 limit 字节，剩下的原样留给 handler，不会把大文件上传整个读进内存
+		var reqBody []byte
+		if c.Request.Body != nil {
+			reqBody, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(limit)+1))
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(reqBody), c.Request.Body), c.Request.Body}
+		}
+		w := &bodyWriter{ResponseWriter: c.Writer, limit: limit + 1}
+		c.Writer = w
+
+		c.Next()
+
+		lg.Debug("http body",
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", w.Status()),
+			zap.String("request_body", formatBody(c.GetHeader("Content-Type"), reqBody, limit, sensitive)),
+			zap.String("response_body", formatBody(w.Header().Get("Content-Type"), w.buf.Bytes(), limit, sensitive)),
+		)
+	}
+}
+
+// readCloser 读的是拼接后的 body，关闭时关闭原来的 body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyWriter 写响应的同时保留前 limit 字节
+type bodyWriter struct {
+	gin.ResponseWriter
+	buf   bytes.Buffer
+	limit int
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyWriter) capture(b []byte) {
+	if n := w.limit - w.buf.Len(); n > 0 {
+		if len(b) > n {
+			b = b[:n]
+		}
+		w.buf.Write(b)
+	}
+}
+
+// formatBody JSON 和表单按字段脱敏，其它类型（文件上传、二进制）只记录类型和大小
+func formatBody(contentType string, body []byte, limit int, sensitive func(string) bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+	truncated := len(body) > limit
+	if truncated {
+		body = body[:limit]
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	var s string
+	switch {
+	case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+		s = redactJSON(body, sensitive)
+	case mt == "application/x-www-form-urlencoded":
+		s = redactForm(string(body), sensitive)
+	default:
+		return "[" + mt + ", " + strconv.Itoa(len(body)) + " bytes]"
+	}
+	if truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+// redactJSON 完整的 JSON 递归替换敏感字段；截断后解析不了的，用正则替换字符串类型的敏感字段
+func redactJSON(body []byte, sensitive func(string) bool) string {
+	var v interface{}
+	// UseNumber 保留数字原样，雪花 ID 之类的大整数转成 float64 会丢精度
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err == nil && !dec.More() {
+		if b, err := json.Marshal(redactValue(v, sensitive)); err == nil {
+			return string(b)
+		}
+	}
+	return jsonStringField.ReplaceAllStringFunc(string(body), func(m string) string {
+		sub := jsonStringField.FindStringSubmatch(m)
+		if !sensitive(sub[1]) {
+			return m
+		}
+		return `"` + sub[1] + `":"******"`
+	})
+}
+
+func redactForm(s string, sensitive func(string) bool) string {
+	pairs := strings.Split(s, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if sensitive(key) {
+			pairs[i] = key + "=******"
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func redactValue(v interface{}, sensitive func(string) bool) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if sensitive(k) {
+				val[k] = "******"
+				continue
+			}
+			val[k] = redactValue(item, sensitive)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item, sensitive)
+		}
+	}
+	return v
+}
@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/captcha"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequireCaptcha 要求请求头携带有效的图形验证码，挡住注册、登录这类接口上的机器人
+// X-Captcha-Id 为 GET /api/v1/captcha 返回的 captcha_id，X-Captcha-Code 为用户输入的答案
+func RequireCaptcha() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !captcha.Enabled() {
+			c.Next()
+			return
+		}
+		ok, err := captcha.Verify(c.Request.Context(), c.GetHeader("X-Captcha-Id"), c.GetHeader("X-Captcha-Code"))
+		if err != nil {
+			logger.Module(c.Request.Context(), "middleware").Error("captcha.Verify failed", zap.Error(err))
+			response.Abort(c, response.CodeServiceUnavailable)
+			return
+		}
+		if !ok {
+			response.Abort(c, response.CodeInvalidCaptcha)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/i18n"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRequestTimeout 客户端通过 X-Request-Timeout 声明的超时上限
+const maxRequestTimeout = 30 * time.Second
+
+// RequestContext 把请求级别的元数据（客户端IP、路由、租户、语言、截止时间）写入 request 的 context
+// 请求 ID 由 RequestID 中间件写入
+// 后面的 handler、logic、dao 统一通过 ctxutil 读取，不再使用 c.Set/c.Get 的字符串 key
+func RequestContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		ctx = ctxutil.WithClientIP(ctx, c.ClientIP())
+		if route := c.FullPath(); route != "" {
+			ctx = ctxutil.WithRoute(ctx, c.Request.Method+" "+route)
+		}
+
+		if tenant := c.GetHeader("X-Tenant-ID"); tenant != "" {
+			ctx = ctxutil.WithTenant(ctx, tenant)
+		}
+
+		// Accept-Language: fr-CH,fr;q=0.9,en;q=0.8 按权重取第一个有翻译的语言，都没有时取第一个
+		if lang := i18n.Match(c.GetHeader("Accept-Language")); lang != "" {
+			ctx = ctxutil.WithLocale(ctx, lang)
+		}
+
+		// X-Request-Timeout: 500ms 客户端愿意等待的时间，超过上限按上限算
+		if v := c.GetHeader("X-Request-Timeout"); v != "" {
+			if timeout, err := time.ParseDuration(v); err == nil && timeout > 0 {
+				if timeout > maxRequestTimeout {
+					timeout = maxRequestTimeout
+				}
+				var cancel func()
+				ctx, cancel = ctxutil.WithDeadline(ctx, time.Now().Add(timeout))
+				defer cancel()
+			}
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,162 @@
+package middlewares
+
+import (
+	"context"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/i18n"
+	"go_web_scaffolding/pkg/metrics"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC 的拦截器，和 HTTP 中间件做同样的事情，业务代码通过 ctxutil 读取的请求信息两边一致
+// 每个拦截器都有 unary 和 stream 两个版本，流式调用通过 serverStream 替换 Context
+
+// serverStream 替换 grpc.ServerStream 的 Context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// GRPCUnaryContext 对应 RequestID + RequestContext：请求 ID（metadata x-request-id，没有时生成，并在响应头里返回）、
+// 客户端 IP、方法名、租户（x-tenant-id）、语言（accept-language）；截止时间由 gRPC 自己传递
+func GRPCUnaryContext() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, id := grpcContext(ctx, info.FullMethod)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+		return handler(ctx, req)
+	}
+}
+
+// GRPCStreamContext 流式调用的 GRPCUnaryContext
+func GRPCStreamContext() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, id := grpcContext(ss.Context(), info.FullMethod)
+		_ = ss.SetHeader(metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func grpcContext(ctx context.Context, method string) (context.Context, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := first(md, RequestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	ctx = ctxutil.WithRequestID(ctx, id)
+	ctx = ctxutil.WithRoute(ctx, "GRPC "+method)
+	if p, ok := peer.FromContext(ctx); ok {
+		ip := p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		// 来自本机 gateway 的请求，peer 是 127.0.0.1，真实 IP 由 gateway 放在 metadata 里；
+		// 其它来源带的这个 key 一律不认，防止直连 gRPC 端口的客户端伪造 IP 绕过按 IP 的限流
+		if fwd := first(md, GatewayClientIPKey); fwd != "" && isLoopback(ip) {
+			ip = fwd
+		}
+		ctx = ctxutil.WithClientIP(ctx, ip)
+	}
+	if tenant := first(md, "X-Tenant-ID"); tenant != "" {
+		ctx = ctxutil.WithTenant(ctx, tenant)
+	}
+	if lang := i18n.Match(first(md, "Accept-Language")); lang != "" {
+		ctx = ctxutil.WithLocale(ctx, lang)
+	}
+	return ctx, id
+}
+
+// GatewayClientIPKey gateway 转发真实客户端 IP 用的 metadata key
+const GatewayClientIPKey = "x-gateway-client-ip"
+
+func isLoopback(ip string) bool {
+	addr := net.ParseIP(ip)
+	return addr != nil && addr.IsLoopback()
+}
+
+// first metadata 里 key 的第一个值，md.Get 会把 key 转成小写
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// GRPCUnaryMetrics 记录请求数、耗时和正在处理的请求数
+func GRPCUnaryMetrics() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		metrics.GRPCRequestsInFlight.Inc()
+		defer observeGRPC(info.FullMethod, time.Now(), &err)
+		return handler(ctx, req)
+	}
+}
+
+// GRPCStreamMetrics 流式调用的耗时是整个流的持续时间
+func GRPCStreamMetrics() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		metrics.GRPCRequestsInFlight.Inc()
+		defer observeGRPC(info.FullMethod, time.Now(), &err)
+		return handler(srv, ss)
+	}
+}
+
+// observeGRPC 在请求开始时 defer 调用，err 指向拦截器的命名返回值
+func observeGRPC(method string, start time.Time, err *error) {
+	metrics.GRPCRequestsInFlight.Dec()
+	code := status.Code(*err).String()
+	metrics.GRPCRequestsTotal.WithLabelValues(method, code).Inc()
+	metrics.GRPCRequestDuration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+}
+
+// GRPCUnaryAuth 对应 JWTAuthMiddleware，token 放在 metadata 的 authorization 里：Bearer xxx
+// public 返回 true 的方法不需要登录，比如健康检查和服务反射
+func GRPCUnaryAuth(public func(fullMethod string) bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if public != nil && public(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err := grpcAuth(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// GRPCStreamAuth 流式调用的 GRPCUnaryAuth
+func GRPCStreamAuth(public func(fullMethod string) bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if public != nil && public(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := grpcAuth(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func grpcAuth(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	authHeader := first(md, "Authorization")
+	if authHeader == "" {
+		return ctx, response.CodeNeedLogin.GRPCError("")
+	}
+	ctx, code := authenticate(ctx, authHeader)
+	if code != response.CodeSuccess {
+		return ctx, code.GRPCError("")
+	}
+	return ctx, nil
+}
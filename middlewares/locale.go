@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/locale"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Locale 解析当前请求的语言和时区写入 context，优先级：
+//
+//	语言：?lang 参数 > X-Locale 请求头 > 用户偏好 > Accept-Language（RequestContext 已解析）> 默认
+//	时区：X-Timezone 请求头（IANA 名称，如 Asia/Shanghai）> 用户偏好 > GeoIP > 默认
+//
+// 用户偏好需要知道当前用户，所以在需要登录的路由组里，鉴权中间件之后要再挂一次
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		lang := c.Query("lang")
+		if lang == "" {
+			lang = c.GetHeader("X-Locale")
+		}
+		var loc *time.Location
+		if tz := c.GetHeader("X-Timezone"); tz != "" {
+			loc, _ = time.LoadLocation(tz)
+		}
+
+		if (lang == "" || loc == nil) && locale.PreferenceFunc != nil {
+			if uid := ctxutil.UserID(ctx); uid > 0 {
+				if pref, ok := locale.PreferenceFunc(ctx, uid); ok {
+					if lang == "" {
+						lang = pref.Locale
+					}
+					if loc == nil && pref.Timezone != "" {
+						loc, _ = time.LoadLocation(pref.Timezone)
+					}
+				}
+			}
+		}
+
+		if loc == nil && locale.GeoIPFunc != nil && ctxutil.Timezone(ctx) == nil {
+			if tz, ok := locale.GeoIPFunc(c.ClientIP()); ok {
+				loc, _ = time.LoadLocation(tz)
+			}
+		}
+
+		if lang != "" {
+			ctx = ctxutil.WithLocale(ctx, lang)
+		}
+		if loc != nil {
+			ctx = ctxutil.WithTimezone(ctx, loc)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
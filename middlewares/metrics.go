@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/pkg/metrics"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 记录请求数、耗时和正在处理的请求数
+// 没有匹配到路由的请求（扫描器之类）统一记为 unmatched，不用实际路径做标签
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.RequestsInFlight.Inc()
+		defer metrics.RequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.RequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.RequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsAuth 保护和其它接口共用端口的 /metrics，请求头 Authorization: Bearer <metrics.token>
+// Prometheus 的抓取配置里用 authorization.credentials 带上同一个 token
+func MetricsAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			response.Abort(c, response.CodeNeedLogin)
+			return
+		}
+		c.Next()
+	}
+}
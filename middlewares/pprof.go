@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/settings"
+	"net"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// PprofGuard 保护 /debug/pprof，IP 白名单和 basic auth 可以同时配置，同时配置时两个都要满足
+// 两个都没配置时拒绝所有请求，避免一打开开关就把 pprof 暴露出去
+// 白名单按连接的对端地址判断，X-Forwarded-For 谁都能伪造
+func PprofGuard(cfg *settings.PprofConfig) gin.HandlerFunc {
+	var nets []*net.IPNet
+	for _, s := range cfg.AllowIPs {
+		// 单个 IP 按 /32（IPv6 为 /128）处理
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			zap.L().Named("middleware").Warn("invalid pprof allow ip, ignored", zap.String("ip", s), zap.Error(err))
+			continue
+		}
+		nets = append(nets, n)
+	}
+
+	return func(c *gin.Context) {
+		if len(nets) == 0 && cfg.Username == "" {
+			response.Abort(c, response.CodeForbidden)
+			return
+		}
+		if len(nets) > 0 && !ipAllowed(nets, c.RemoteIP()) {
+			response.Abort(c, response.CodeForbidden)
+			return
+		}
+		if cfg.Username != "" {
+			user, pass, ok := c.Request.BasicAuth()
+			if !ok ||
+				subtle.ConstantTimeCompare([]byte(user), []byte(cfg.Username)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.Password)) != 1 {
+				// 浏览器收到这个头会弹出登录框
+				c.Header("WWW-Authenticate", `Basic realm="pprof"`)
+				response.Abort(c, response.CodeNeedLogin)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func ipAllowed(nets []*net.IPNet, clientIP string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/rbac"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Authorize 基于 casbin 的权限校验中间件，放在 JWTAuthMiddleware 之后
+// subject 为当前用户 ID，object 为请求路径，action 为 HTTP 方法；未开启 RBAC 时直接放行
+func Authorize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rbac.Enabled() {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		u, ok := ctxutil.CurrentUser(ctx)
+		if !ok {
+			response.Abort(c, response.CodeNeedLogin)
+			return
+		}
+		allowed, err := rbac.Enforce(strconv.FormatInt(u.ID, 10), c.Request.URL.Path, c.Request.Method)
+		if err != nil {
+			logger.Module(ctx, "middleware").Error("rbac.Enforce failed", zap.Error(err))
+			_ = c.Error(err)
+			response.Abort(c, response.CodeServerBusy)
+			return
+		}
+		if !allowed {
+			response.Abort(c, response.CodeForbidden)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"go_web_scaffolding/pkg/ctxutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求 ID 的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen 上游传入的请求 ID 的最大长度，超过的重新生成
+const maxRequestIDLen = 64
+
+// RequestID 读取上游（网关、调用方）传入的 X-Request-ID，没有或者不合法时生成一个
+// 写入 context 后 logger.Ctx 打的每一行日志都会带上 request_id，同时在响应头里返回，
+// 客户端反馈问题时带上这个 ID，就能在日志文件里 grep 出这个请求的全部日志
+// 需要挂在所有中间件的最前面
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(ctxutil.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// validRequestID 只接受字母、数字和 -_.，防止有人通过请求头往日志里注入换行等内容
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_' || ch == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
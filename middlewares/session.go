@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/session"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Session 从 cookie 中加载会话放进请求 context，未登录的请求照常放行
+// 会话剩余有效期不足一半时自动续期并重新下发 cookie
+func Session() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := session.CookieValue(c)
+		if id == "" {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		s, err := session.Get(ctx, id)
+		if err != nil {
+			if err != session.ErrNotFound {
+				logger.Module(ctx, "middleware").Error("session.Get failed", zap.Error(err))
+			}
+			c.Next()
+			return
+		}
+		if session.NeedRefresh(s) {
+			if err := session.Refresh(ctx, s); err != nil {
+				logger.Module(ctx, "middleware").Error("session.Refresh failed", zap.Error(err))
+			} else {
+				session.SetCookie(c, s)
+			}
+		}
+		ctx = session.WithContext(ctx, s)
+		ctx = ctxutil.WithUser(ctx, &ctxutil.User{ID: s.UserID, Username: s.Username})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// RequireSession 必须已登录，需要放在 Session 之后
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := session.FromContext(c.Request.Context()); !ok {
+			response.Abort(c, response.CodeNeedLogin)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,95 @@
+-- 初始表结构，和 models 里注释的建表语句一致
+
+-- +goose Up
+CREATE TABLE `user` (
+  `id` bigint(20) NOT NULL COMMENT '雪花算法生成',
+  `username` varchar(64) NOT NULL,
+  `password` varchar(255) NOT NULL DEFAULT '',
+  `nickname` varchar(64) NOT NULL DEFAULT '',
+  `email` varchar(128) NOT NULL DEFAULT '',
+  `avatar` varchar(512) NOT NULL DEFAULT '',
+  `totp_secret` varchar(64) NOT NULL DEFAULT '',
+  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_username` (`username`),
+  KEY `idx_email` (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `user_oauth` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `user_id` bigint(20) NOT NULL,
+  `provider` varchar(32) NOT NULL,
+  `open_id` varchar(128) NOT NULL,
+  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_provider_open_id` (`provider`, `open_id`),
+  KEY `idx_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `casbin_rule` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `ptype` varchar(8) NOT NULL,
+  `v0` varchar(128) NOT NULL DEFAULT '',
+  `v1` varchar(128) NOT NULL DEFAULT '',
+  `v2` varchar(128) NOT NULL DEFAULT '',
+  `v3` varchar(128) NOT NULL DEFAULT '',
+  `v4` varchar(128) NOT NULL DEFAULT '',
+  `v5` varchar(128) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_rule` (`ptype`, `v0`, `v1`, `v2`, `v3`, `v4`, `v5`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `id_segment` (
+  `biz_tag` varchar(64) NOT NULL,
+  `max_id` bigint(20) NOT NULL DEFAULT 0,
+  `step` int(11) NOT NULL DEFAULT 1000,
+  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`biz_tag`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `api_key` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL,
+  `prefix` varchar(16) NOT NULL,
+  `key_hash` char(64) NOT NULL,
+  `scopes` varchar(512) NOT NULL DEFAULT '',
+  `expire_time` timestamp NULL DEFAULT NULL,
+  `revoked` tinyint(1) NOT NULL DEFAULT 0,
+  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_prefix` (`prefix`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `audit_log` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `user_id` bigint(20) NOT NULL DEFAULT 0,
+  `action` varchar(64) NOT NULL,
+  `resource` varchar(128) NOT NULL DEFAULT '',
+  `detail` text,
+  `ip` varchar(64) NOT NULL DEFAULT '',
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `domain_event` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL,
+  `payload` text,
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- +goose Down
+DROP TABLE IF EXISTS `domain_event`;
+DROP TABLE IF EXISTS `audit_log`;
+DROP TABLE IF EXISTS `api_key`;
+DROP TABLE IF EXISTS `id_segment`;
+DROP TABLE IF EXISTS `casbin_rule`;
+DROP TABLE IF EXISTS `user_oauth`;
+DROP TABLE IF EXISTS `user`;
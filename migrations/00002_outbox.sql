@@ -0,0 +1,20 @@
+-- 事务发件箱，见 models.OutboxMessage
+
+-- +goose Up
+CREATE TABLE `outbox` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `topic` varchar(128) NOT NULL,
+  `payload` mediumtext NOT NULL,
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `status` tinyint(4) NOT NULL DEFAULT 0 COMMENT '0 待投递 1 已投递 2 死信',
+  `attempts` int(11) NOT NULL DEFAULT 0,
+  `last_error` varchar(512) NOT NULL DEFAULT '',
+  `next_retry_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_status_next_retry` (`status`, `next_retry_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- +goose Down
+DROP TABLE IF EXISTS `outbox`;
@@ -0,0 +1,36 @@
+-- webhook 订阅和投递日志，见 models.Webhook、models.WebhookDelivery
+
+-- +goose Up
+CREATE TABLE `webhook` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `url` varchar(512) NOT NULL,
+  `secret` varchar(128) NOT NULL,
+  `events` varchar(512) NOT NULL DEFAULT '*',
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `enabled` tinyint(1) NOT NULL DEFAULT 1,
+  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `webhook_delivery` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `webhook_id` bigint(20) NOT NULL,
+  `event` varchar(64) NOT NULL,
+  `payload` mediumtext NOT NULL,
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `status` tinyint(4) NOT NULL DEFAULT 0 COMMENT '0 待投递 1 成功 2 死信',
+  `attempts` int(11) NOT NULL DEFAULT 0,
+  `response_code` int(11) NOT NULL DEFAULT 0,
+  `last_error` varchar(512) NOT NULL DEFAULT '',
+  `next_retry_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_status_next_retry` (`status`, `next_retry_time`),
+  KEY `idx_webhook_id` (`webhook_id`, `id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- +goose Down
+DROP TABLE IF EXISTS `webhook_delivery`;
+DROP TABLE IF EXISTS `webhook`;
@@ -0,0 +1,19 @@
+-- 上传的文件，见 models.File
+
+-- +goose Up
+CREATE TABLE `file` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `user_id` bigint(20) NOT NULL,
+  `name` varchar(255) NOT NULL COMMENT '上传时的文件名',
+  `path` varchar(255) NOT NULL,
+  `size` bigint(20) NOT NULL,
+  `content_type` varchar(128) NOT NULL,
+  `sha256` char(64) NOT NULL,
+  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_path` (`path`),
+  KEY `idx_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- +goose Down
+DROP TABLE IF EXISTS `file`;
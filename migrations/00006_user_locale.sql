@@ -0,0 +1,9 @@
+-- 用户的语言和时区偏好，见 models.User
+
+-- +goose Up
+ALTER TABLE `user`
+  ADD COLUMN `locale` varchar(16) NOT NULL DEFAULT '' COMMENT '为空时按请求头',
+  ADD COLUMN `timezone` varchar(64) NOT NULL DEFAULT '' COMMENT 'IANA 时区名';
+
+-- +goose Down
+ALTER TABLE `user` DROP COLUMN `locale`, DROP COLUMN `timezone`;
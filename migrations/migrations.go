@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// 数据库迁移脚本，编译进二进制，通过 `app migrate up` 或者 dev 模式的 auto_migrate 执行
+// 文件名格式为 <版本号>_<说明>.sql，版本号递增，已经发布的脚本不要再修改，改表结构请新增一个脚本
+// 每个脚本用 -- +goose Up / -- +goose Down 分隔升级和回滚的语句
+//
+// 根目录是 MySQL 的脚本，postgres、sqlite 目录下是同样版本号的 PostgreSQL、SQLite 脚本，新增脚本时每个目录都要写一份
+
+//go:embed *.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// FS MySQL 的迁移脚本
+var FS = For("mysql")
+
+// For 按数据库返回迁移脚本，driver 为 mysql、postgres 或 sqlite
+func For(driver string) fs.FS {
+	if driver == "postgres" || driver == "sqlite" {
+		sub, _ := fs.Sub(files, driver)
+		return sub
+	}
+	return files
+}
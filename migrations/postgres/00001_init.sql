@@ -0,0 +1,109 @@
+-- 初始表结构，PostgreSQL 版本，和 ../00001_init.sql 一一对应
+-- update_time 没有 ON UPDATE CURRENT_TIMESTAMP，用触发器 set_update_time 代替
+
+-- +goose Up
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION set_update_time() RETURNS trigger AS $$
+BEGIN
+  NEW.update_time = CURRENT_TIMESTAMP;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TABLE "user" (
+  id bigint NOT NULL,
+  username varchar(64) NOT NULL,
+  password varchar(255) NOT NULL DEFAULT '',
+  nickname varchar(64) NOT NULL DEFAULT '',
+  email varchar(128) NOT NULL DEFAULT '',
+  avatar varchar(512) NOT NULL DEFAULT '',
+  totp_secret varchar(64) NOT NULL DEFAULT '',
+  create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT idx_username UNIQUE (username)
+);
+CREATE INDEX idx_user_email ON "user" (email);
+CREATE TRIGGER user_update_time BEFORE UPDATE ON "user" FOR EACH ROW EXECUTE FUNCTION set_update_time();
+
+CREATE TABLE user_oauth (
+  id bigserial NOT NULL,
+  user_id bigint NOT NULL,
+  provider varchar(32) NOT NULL,
+  open_id varchar(128) NOT NULL,
+  create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT idx_provider_open_id UNIQUE (provider, open_id)
+);
+CREATE INDEX idx_user_oauth_user_id ON user_oauth (user_id);
+
+CREATE TABLE casbin_rule (
+  id bigserial NOT NULL,
+  ptype varchar(8) NOT NULL,
+  v0 varchar(128) NOT NULL DEFAULT '',
+  v1 varchar(128) NOT NULL DEFAULT '',
+  v2 varchar(128) NOT NULL DEFAULT '',
+  v3 varchar(128) NOT NULL DEFAULT '',
+  v4 varchar(128) NOT NULL DEFAULT '',
+  v5 varchar(128) NOT NULL DEFAULT '',
+  PRIMARY KEY (id),
+  CONSTRAINT idx_rule UNIQUE (ptype, v0, v1, v2, v3, v4, v5)
+);
+
+CREATE TABLE id_segment (
+  biz_tag varchar(64) NOT NULL,
+  max_id bigint NOT NULL DEFAULT 0,
+  step int NOT NULL DEFAULT 1000,
+  update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (biz_tag)
+);
+CREATE TRIGGER id_segment_update_time BEFORE UPDATE ON id_segment FOR EACH ROW EXECUTE FUNCTION set_update_time();
+
+CREATE TABLE api_key (
+  id bigserial NOT NULL,
+  name varchar(64) NOT NULL,
+  prefix varchar(16) NOT NULL,
+  key_hash char(64) NOT NULL,
+  scopes varchar(512) NOT NULL DEFAULT '',
+  expire_time timestamp NULL DEFAULT NULL,
+  revoked smallint NOT NULL DEFAULT 0,
+  create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT idx_prefix UNIQUE (prefix)
+);
+CREATE TRIGGER api_key_update_time BEFORE UPDATE ON api_key FOR EACH ROW EXECUTE FUNCTION set_update_time();
+
+CREATE TABLE audit_log (
+  id bigserial NOT NULL,
+  user_id bigint NOT NULL DEFAULT 0,
+  action varchar(64) NOT NULL,
+  resource varchar(128) NOT NULL DEFAULT '',
+  detail text,
+  ip varchar(64) NOT NULL DEFAULT '',
+  request_id varchar(64) NOT NULL DEFAULT '',
+  create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_audit_log_user_id ON audit_log (user_id);
+
+CREATE TABLE domain_event (
+  id bigserial NOT NULL,
+  name varchar(64) NOT NULL,
+  payload text,
+  request_id varchar(64) NOT NULL DEFAULT '',
+  create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_domain_event_name ON domain_event (name);
+
+-- +goose Down
+DROP TABLE IF EXISTS domain_event;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS api_key;
+DROP TABLE IF EXISTS id_segment;
+DROP TABLE IF EXISTS casbin_rule;
+DROP TABLE IF EXISTS user_oauth;
+DROP TABLE IF EXISTS "user";
+DROP FUNCTION IF EXISTS set_update_time();
@@ -0,0 +1,21 @@
+-- 事务发件箱，PostgreSQL 版本，见 models.OutboxMessage
+
+-- +goose Up
+CREATE TABLE outbox (
+  id bigserial NOT NULL,
+  topic varchar(128) NOT NULL,
+  payload text NOT NULL,
+  request_id varchar(64) NOT NULL DEFAULT '',
+  status smallint NOT NULL DEFAULT 0,
+  attempts int NOT NULL DEFAULT 0,
+  last_error varchar(512) NOT NULL DEFAULT '',
+  next_retry_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_outbox_status_next_retry ON outbox (status, next_retry_time);
+CREATE TRIGGER outbox_update_time BEFORE UPDATE ON outbox FOR EACH ROW EXECUTE FUNCTION set_update_time();
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox;
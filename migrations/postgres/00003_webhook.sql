@@ -0,0 +1,38 @@
+-- webhook 订阅和投递日志，PostgreSQL 版本，见 models.Webhook、models.WebhookDelivery
+
+-- +goose Up
+CREATE TABLE webhook (
+  id bigserial NOT NULL,
+  url varchar(512) NOT NULL,
+  secret varchar(128) NOT NULL,
+  events varchar(512) NOT NULL DEFAULT '*',
+  description varchar(255) NOT NULL DEFAULT '',
+  enabled smallint NOT NULL DEFAULT 1,
+  create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE TRIGGER webhook_update_time BEFORE UPDATE ON webhook FOR EACH ROW EXECUTE FUNCTION set_update_time();
+
+CREATE TABLE webhook_delivery (
+  id bigserial NOT NULL,
+  webhook_id bigint NOT NULL,
+  event varchar(64) NOT NULL,
+  payload text NOT NULL,
+  request_id varchar(64) NOT NULL DEFAULT '',
+  status smallint NOT NULL DEFAULT 0,
+  attempts int NOT NULL DEFAULT 0,
+  response_code int NOT NULL DEFAULT 0,
+  last_error varchar(512) NOT NULL DEFAULT '',
+  next_retry_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_webhook_delivery_status_next_retry ON webhook_delivery (status, next_retry_time);
+CREATE INDEX idx_webhook_delivery_webhook_id ON webhook_delivery (webhook_id, id);
+CREATE TRIGGER webhook_delivery_update_time BEFORE UPDATE ON webhook_delivery FOR EACH ROW EXECUTE FUNCTION set_update_time();
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_delivery;
+DROP TABLE IF EXISTS webhook;
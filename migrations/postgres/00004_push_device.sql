@@ -0,0 +1,18 @@
+-- 推送设备，PostgreSQL 版本，见 models.PushDevice
+
+-- +goose Up
+CREATE TABLE push_device (
+  id bigserial NOT NULL,
+  user_id bigint NOT NULL,
+  platform varchar(16) NOT NULL,
+  token varchar(255) NOT NULL,
+  create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_push_device_token ON push_device (token);
+CREATE INDEX idx_push_device_user_id ON push_device (user_id);
+CREATE TRIGGER push_device_update_time BEFORE UPDATE ON push_device FOR EACH ROW EXECUTE FUNCTION set_update_time();
+
+-- +goose Down
+DROP TABLE IF EXISTS push_device;
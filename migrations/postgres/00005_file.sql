@@ -0,0 +1,19 @@
+-- 上传的文件，PostgreSQL 版本，见 models.File
+
+-- +goose Up
+CREATE TABLE file (
+  id bigserial NOT NULL,
+  user_id bigint NOT NULL,
+  name varchar(255) NOT NULL,
+  path varchar(255) NOT NULL,
+  size bigint NOT NULL,
+  content_type varchar(128) NOT NULL,
+  sha256 char(64) NOT NULL,
+  create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_file_path ON file (path);
+CREATE INDEX idx_file_user_id ON file (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS file;
@@ -0,0 +1,104 @@
+-- 初始表结构，SQLite 版本，只给 local 模式使用，和 ../00001_init.sql 一一对应
+-- 时间默认值用本地时间，和连接参数 _loc=auto 一致；update_time 用触发器代替 ON UPDATE CURRENT_TIMESTAMP
+
+-- +goose Up
+CREATE TABLE `user` (
+  `id` INTEGER NOT NULL PRIMARY KEY,
+  `username` varchar(64) NOT NULL,
+  `password` varchar(255) NOT NULL DEFAULT '',
+  `nickname` varchar(64) NOT NULL DEFAULT '',
+  `email` varchar(128) NOT NULL DEFAULT '',
+  `avatar` varchar(512) NOT NULL DEFAULT '',
+  `totp_secret` varchar(64) NOT NULL DEFAULT '',
+  `create_time` datetime NOT NULL DEFAULT (datetime('now')),
+  `update_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+CREATE UNIQUE INDEX `idx_user_username` ON `user` (`username`);
+CREATE INDEX `idx_user_email` ON `user` (`email`);
+-- +goose StatementBegin
+CREATE TRIGGER `user_update_time` AFTER UPDATE ON `user` FOR EACH ROW BEGIN
+  UPDATE `user` SET `update_time` = datetime('now') WHERE `id` = NEW.`id`;
+END;
+-- +goose StatementEnd
+
+CREATE TABLE `user_oauth` (
+  `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `user_id` bigint NOT NULL,
+  `provider` varchar(32) NOT NULL,
+  `open_id` varchar(128) NOT NULL,
+  `create_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+CREATE UNIQUE INDEX `idx_user_oauth_provider_open_id` ON `user_oauth` (`provider`, `open_id`);
+CREATE INDEX `idx_user_oauth_user_id` ON `user_oauth` (`user_id`);
+
+CREATE TABLE `casbin_rule` (
+  `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `ptype` varchar(8) NOT NULL,
+  `v0` varchar(128) NOT NULL DEFAULT '',
+  `v1` varchar(128) NOT NULL DEFAULT '',
+  `v2` varchar(128) NOT NULL DEFAULT '',
+  `v3` varchar(128) NOT NULL DEFAULT '',
+  `v4` varchar(128) NOT NULL DEFAULT '',
+  `v5` varchar(128) NOT NULL DEFAULT ''
+);
+CREATE UNIQUE INDEX `idx_casbin_rule` ON `casbin_rule` (`ptype`, `v0`, `v1`, `v2`, `v3`, `v4`, `v5`);
+
+CREATE TABLE `id_segment` (
+  `biz_tag` varchar(64) NOT NULL PRIMARY KEY,
+  `max_id` bigint NOT NULL DEFAULT 0,
+  `step` int NOT NULL DEFAULT 1000,
+  `update_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+-- +goose StatementBegin
+CREATE TRIGGER `id_segment_update_time` AFTER UPDATE ON `id_segment` FOR EACH ROW BEGIN
+  UPDATE `id_segment` SET `update_time` = datetime('now') WHERE `biz_tag` = NEW.`biz_tag`;
+END;
+-- +goose StatementEnd
+
+CREATE TABLE `api_key` (
+  `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `name` varchar(64) NOT NULL,
+  `prefix` varchar(16) NOT NULL,
+  `key_hash` char(64) NOT NULL,
+  `scopes` varchar(512) NOT NULL DEFAULT '',
+  `expire_time` datetime NULL DEFAULT NULL,
+  `revoked` tinyint NOT NULL DEFAULT 0,
+  `create_time` datetime NOT NULL DEFAULT (datetime('now')),
+  `update_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+CREATE UNIQUE INDEX `idx_api_key_prefix` ON `api_key` (`prefix`);
+-- +goose StatementBegin
+CREATE TRIGGER `api_key_update_time` AFTER UPDATE ON `api_key` FOR EACH ROW BEGIN
+  UPDATE `api_key` SET `update_time` = datetime('now') WHERE `id` = NEW.`id`;
+END;
+-- +goose StatementEnd
+
+CREATE TABLE `audit_log` (
+  `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `user_id` bigint NOT NULL DEFAULT 0,
+  `action` varchar(64) NOT NULL,
+  `resource` varchar(128) NOT NULL DEFAULT '',
+  `detail` text,
+  `ip` varchar(64) NOT NULL DEFAULT '',
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `create_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+CREATE INDEX `idx_audit_log_user_id` ON `audit_log` (`user_id`);
+
+CREATE TABLE `domain_event` (
+  `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `name` varchar(64) NOT NULL,
+  `payload` text,
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `create_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+CREATE INDEX `idx_domain_event_name` ON `domain_event` (`name`);
+
+-- +goose Down
+DROP TABLE IF EXISTS `domain_event`;
+DROP TABLE IF EXISTS `audit_log`;
+DROP TABLE IF EXISTS `api_key`;
+DROP TABLE IF EXISTS `id_segment`;
+DROP TABLE IF EXISTS `casbin_rule`;
+DROP TABLE IF EXISTS `user_oauth`;
+DROP TABLE IF EXISTS `user`;
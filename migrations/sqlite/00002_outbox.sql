@@ -0,0 +1,24 @@
+-- 事务发件箱，SQLite 版本，见 models.OutboxMessage
+
+-- +goose Up
+CREATE TABLE `outbox` (
+  `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `topic` varchar(128) NOT NULL,
+  `payload` text NOT NULL,
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `status` tinyint NOT NULL DEFAULT 0,
+  `attempts` int NOT NULL DEFAULT 0,
+  `last_error` varchar(512) NOT NULL DEFAULT '',
+  `next_retry_time` datetime NOT NULL DEFAULT (datetime('now')),
+  `create_time` datetime NOT NULL DEFAULT (datetime('now')),
+  `update_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+CREATE INDEX `idx_outbox_status_next_retry` ON `outbox` (`status`, `next_retry_time`);
+-- +goose StatementBegin
+CREATE TRIGGER `outbox_update_time` AFTER UPDATE ON `outbox` FOR EACH ROW BEGIN
+  UPDATE `outbox` SET `update_time` = datetime('now') WHERE `id` = NEW.`id`;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS `outbox`;
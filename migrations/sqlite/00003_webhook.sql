@@ -0,0 +1,44 @@
+-- webhook 订阅和投递日志，SQLite 版本，见 models.Webhook、models.WebhookDelivery
+
+-- +goose Up
+CREATE TABLE `webhook` (
+  `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `url` varchar(512) NOT NULL,
+  `secret` varchar(128) NOT NULL,
+  `events` varchar(512) NOT NULL DEFAULT '*',
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `enabled` tinyint NOT NULL DEFAULT 1,
+  `create_time` datetime NOT NULL DEFAULT (datetime('now')),
+  `update_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+-- +goose StatementBegin
+CREATE TRIGGER `webhook_update_time` AFTER UPDATE ON `webhook` FOR EACH ROW BEGIN
+  UPDATE `webhook` SET `update_time` = datetime('now') WHERE `id` = NEW.`id`;
+END;
+-- +goose StatementEnd
+
+CREATE TABLE `webhook_delivery` (
+  `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `webhook_id` bigint NOT NULL,
+  `event` varchar(64) NOT NULL,
+  `payload` text NOT NULL,
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `status` tinyint NOT NULL DEFAULT 0,
+  `attempts` int NOT NULL DEFAULT 0,
+  `response_code` int NOT NULL DEFAULT 0,
+  `last_error` varchar(512) NOT NULL DEFAULT '',
+  `next_retry_time` datetime NOT NULL DEFAULT (datetime('now')),
+  `create_time` datetime NOT NULL DEFAULT (datetime('now')),
+  `update_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+CREATE INDEX `idx_webhook_delivery_status_next_retry` ON `webhook_delivery` (`status`, `next_retry_time`);
+CREATE INDEX `idx_webhook_delivery_webhook_id` ON `webhook_delivery` (`webhook_id`, `id`);
+-- +goose StatementBegin
+CREATE TRIGGER `webhook_delivery_update_time` AFTER UPDATE ON `webhook_delivery` FOR EACH ROW BEGIN
+  UPDATE `webhook_delivery` SET `update_time` = datetime('now') WHERE `id` = NEW.`id`;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS `webhook_delivery`;
+DROP TABLE IF EXISTS `webhook`;
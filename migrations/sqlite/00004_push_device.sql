@@ -0,0 +1,21 @@
+-- 推送设备，SQLite 版本，见 models.PushDevice
+
+-- +goose Up
+CREATE TABLE `push_device` (
+  `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `user_id` bigint NOT NULL,
+  `platform` varchar(16) NOT NULL,
+  `token` varchar(255) NOT NULL,
+  `create_time` datetime NOT NULL DEFAULT (datetime('now')),
+  `update_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+CREATE UNIQUE INDEX `idx_push_device_token` ON `push_device` (`token`);
+CREATE INDEX `idx_push_device_user_id` ON `push_device` (`user_id`);
+-- +goose StatementBegin
+CREATE TRIGGER `push_device_update_time` AFTER UPDATE ON `push_device` FOR EACH ROW BEGIN
+  UPDATE `push_device` SET `update_time` = datetime('now') WHERE `id` = NEW.`id`;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS `push_device`;
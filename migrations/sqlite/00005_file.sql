@@ -0,0 +1,18 @@
+-- 上传的文件，SQLite 版本，见 models.File
+
+-- +goose Up
+CREATE TABLE `file` (
+  `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  `user_id` bigint NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `path` varchar(255) NOT NULL,
+  `size` bigint NOT NULL,
+  `content_type` varchar(128) NOT NULL,
+  `sha256` char(64) NOT NULL,
+  `create_time` datetime NOT NULL DEFAULT (datetime('now'))
+);
+CREATE UNIQUE INDEX `idx_file_path` ON `file` (`path`);
+CREATE INDEX `idx_file_user_id` ON `file` (`user_id`);
+
+-- +goose Down
+DROP TABLE IF EXISTS `file`;
@@ -0,0 +1,9 @@
+-- 用户的语言和时区偏好，SQLite 版本，见 models.User
+
+-- +goose Up
+ALTER TABLE `user` ADD COLUMN `locale` varchar(16) NOT NULL DEFAULT '';
+ALTER TABLE `user` ADD COLUMN `timezone` varchar(64) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE `user` DROP COLUMN `locale`;
+ALTER TABLE `user` DROP COLUMN `timezone`;
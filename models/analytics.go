@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// AccessEvent 一次 HTTP 请求的访问事件，写入 ClickHouse 做流量分析，字段名对应 ClickHouse 的列名
+//
+//	CREATE TABLE access_event (
+//	  `time` DateTime64(3),
+//	  `date` Date DEFAULT toDate(time),
+//	  `request_id` String,
+//	  `method` LowCardinality(String),
+//	  `route` LowCardinality(String),
+//	  `status` UInt16,
+//	  `latency_ms` Float64,
+//	  `user_id` Int64,
+//	  `ip` String,
+//	  `user_agent` String
+//	) ENGINE = MergeTree
+//	PARTITION BY toYYYYMM(date)
+//	ORDER BY (date, route, time)
+//	TTL date + INTERVAL 90 DAY;
+type AccessEvent struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	Status    int       `json:"status"`
+	LatencyMS float64   `json:"latency_ms"`
+	UserID    int64     `json:"user_id"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey 给机器调用方使用的访问密钥，只保存 sha256 摘要，明文只在创建/轮换时返回一次
+//
+//	CREATE TABLE `api_key` (
+//	  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+//	  `name` varchar(64) NOT NULL,
+//	  `prefix` varchar(16) NOT NULL,
+//	  `key_hash` char(64) NOT NULL,
+//	  `scopes` varchar(512) NOT NULL DEFAULT '',
+//	  `expire_time` timestamp NULL DEFAULT NULL,
+//	  `revoked` tinyint(1) NOT NULL DEFAULT 0,
+//	  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`id`),
+//	  UNIQUE KEY `idx_prefix` (`prefix`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type APIKey struct {
+	ID         int64      `db:"id" json:"id"`
+	Name       string     `db:"name" json:"name"`
+	Prefix     string     `db:"prefix" json:"prefix"`
+	KeyHash    string     `db:"key_hash" json:"-"`
+	Scopes     string     `db:"scopes" json:"scopes"` // 逗号分隔，* 表示全部权限
+	ExpireTime *time.Time `db:"expire_time" json:"expire_time"`
+	Revoked    bool       `db:"revoked" json:"revoked"`
+	CreateTime time.Time  `db:"create_time" json:"create_time"`
+	UpdateTime time.Time  `db:"update_time" json:"update_time"`
+}
+
+// Expired 是否已过期，没有设置过期时间的永不过期
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpireTime != nil && !now.Before(*k.ExpireTime)
+}
+
+// HasScope 是否拥有 scope 权限
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range strings.Split(k.Scopes, ",") {
+		if s = strings.TrimSpace(s); s == "*" || s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// AuditLog 审计日志
+//
+//	CREATE TABLE `audit_log` (
+//	  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+//	  `user_id` bigint(20) NOT NULL DEFAULT 0,
+//	  `action` varchar(64) NOT NULL,
+//	  `resource` varchar(128) NOT NULL DEFAULT '',
+//	  `detail` text,
+//	  `ip` varchar(64) NOT NULL DEFAULT '',
+//	  `request_id` varchar(64) NOT NULL DEFAULT '',
+//	  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`id`),
+//	  KEY `idx_user_id` (`user_id`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type AuditLog struct {
+	ID         int64     `db:"id" json:"id"`
+	UserID     int64     `db:"user_id" json:"user_id"`
+	Action     string    `db:"action" json:"action"`
+	Resource   string    `db:"resource" json:"resource"`
+	Detail     string    `db:"detail" json:"detail"`
+	IP         string    `db:"ip" json:"ip"`
+	RequestID  string    `db:"request_id" json:"request_id"`
+	CreateTime time.Time `db:"create_time" json:"create_time"`
+}
+
+// DomainEvent 持久化的领域事件，用于事后追溯
+//
+//	CREATE TABLE `domain_event` (
+//	  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+//	  `name` varchar(64) NOT NULL,
+//	  `payload` text,
+//	  `request_id` varchar(64) NOT NULL DEFAULT '',
+//	  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`id`),
+//	  KEY `idx_name` (`name`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type DomainEvent struct {
+	ID         int64     `db:"id" json:"id"`
+	Name       string    `db:"name" json:"name"`
+	Payload    string    `db:"payload" json:"payload"`
+	RequestID  string    `db:"request_id" json:"request_id"`
+	CreateTime time.Time `db:"create_time" json:"create_time"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// CasbinRule casbin 的策略规则，一行对应一条 p（权限）或 g（角色继承）
+//
+//	CREATE TABLE `casbin_rule` (
+//	  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+//	  `ptype` varchar(8) NOT NULL,
+//	  `v0` varchar(128) NOT NULL DEFAULT '',
+//	  `v1` varchar(128) NOT NULL DEFAULT '',
+//	  `v2` varchar(128) NOT NULL DEFAULT '',
+//	  `v3` varchar(128) NOT NULL DEFAULT '',
+//	  `v4` varchar(128) NOT NULL DEFAULT '',
+//	  `v5` varchar(128) NOT NULL DEFAULT '',
+//	  PRIMARY KEY (`id`),
+//	  UNIQUE KEY `idx_rule` (`ptype`, `v0`, `v1`, `v2`, `v3`, `v4`, `v5`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type CasbinRule struct {
+	ID    int64  `db:"id" json:"id"`
+	PType string `db:"ptype" json:"ptype"`
+	V0    string `db:"v0" json:"v0"`
+	V1    string `db:"v1" json:"v1"`
+	V2    string `db:"v2" json:"v2"`
+	V3    string `db:"v3" json:"v3"`
+	V4    string `db:"v4" json:"v4"`
+	V5    string `db:"v5" json:"v5"`
+}
+
+// Values 按 v0~v5 的顺序返回字段值
+func (r *CasbinRule) Values() []string {
+	return []string{r.V0, r.V1, r.V2, r.V3, r.V4, r.V5}
+}
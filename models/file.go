@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// File 上传的文件，文件内容在 pkg/storage 里，这里只记元信息；path 是存储路径，url 按配置拼出来，不入库
+//
+//	CREATE TABLE `file` (
+//	  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+//	  `user_id` bigint(20) NOT NULL,
+//	  `name` varchar(255) NOT NULL COMMENT '上传时的文件名',
+//	  `path` varchar(255) NOT NULL,
+//	  `size` bigint(20) NOT NULL,
+//	  `content_type` varchar(128) NOT NULL,
+//	  `sha256` char(64) NOT NULL,
+//	  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`id`),
+//	  UNIQUE KEY `idx_path` (`path`),
+//	  KEY `idx_user_id` (`user_id`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type File struct {
+	ID          int64     `db:"id" json:"id,string"`
+	UserID      int64     `db:"user_id" json:"user_id,string"`
+	Name        string    `db:"name" json:"name"`
+	Path        string    `db:"path" json:"path"`
+	Size        int64     `db:"size" json:"size"`
+	ContentType string    `db:"content_type" json:"content_type"`
+	SHA256      string    `db:"sha256" json:"sha256"`
+	CreateTime  time.Time `db:"create_time" json:"create_time"`
+
+	URL string `db:"-" json:"url"`
+}
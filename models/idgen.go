@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// IDSegment 号段模式的 ID 分配表，每个业务一行，每次取号把 max_id 加上 step
+//
+//	CREATE TABLE `id_segment` (
+//	  `biz_tag` varchar(64) NOT NULL,
+//	  `max_id` bigint(20) NOT NULL DEFAULT 0,
+//	  `step` int(11) NOT NULL DEFAULT 1000,
+//	  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`biz_tag`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type IDSegment struct {
+	BizTag     string    `db:"biz_tag" json:"biz_tag"`
+	MaxID      int64     `db:"max_id" json:"max_id"`
+	Step       int64     `db:"step" json:"step"`
+	UpdateTime time.Time `db:"update_time" json:"update_time"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// UserOAuth 第三方账号和本站用户的绑定关系，一个用户可以绑定多个第三方账号
+//
+//	CREATE TABLE `user_oauth` (
+//	  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+//	  `user_id` bigint(20) NOT NULL,
+//	  `provider` varchar(32) NOT NULL,
+//	  `open_id` varchar(128) NOT NULL,
+//	  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`id`),
+//	  UNIQUE KEY `idx_provider_open_id` (`provider`, `open_id`),
+//	  KEY `idx_user_id` (`user_id`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type UserOAuth struct {
+	ID         int64     `db:"id" json:"id"`
+	UserID     int64     `db:"user_id" json:"user_id"`
+	Provider   string    `db:"provider" json:"provider"`
+	OpenID     string    `db:"open_id" json:"open_id"`
+	CreateTime time.Time `db:"create_time" json:"create_time"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 发件箱消息状态
+const (
+	OutboxPending int8 = 0 // 待投递
+	OutboxSent    int8 = 1 // 已投递
+	OutboxDead    int8 = 2 // 重试次数用完，需要人工处理
+)
+
+// OutboxMessage 事务发件箱里的一条待发布消息，和业务数据在同一个事务里写入
+//
+//	CREATE TABLE `outbox` (
+//	  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+//	  `topic` varchar(128) NOT NULL,
+//	  `payload` mediumtext NOT NULL,
+//	  `request_id` varchar(64) NOT NULL DEFAULT '',
+//	  `status` tinyint(4) NOT NULL DEFAULT 0 COMMENT '0 待投递 1 已投递 2 死信',
+//	  `attempts` int(11) NOT NULL DEFAULT 0,
+//	  `last_error` varchar(512) NOT NULL DEFAULT '',
+//	  `next_retry_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`id`),
+//	  KEY `idx_status_next_retry` (`status`, `next_retry_time`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type OutboxMessage struct {
+	ID            int64     `db:"id" json:"id"`
+	Topic         string    `db:"topic" json:"topic"`
+	Payload       string    `db:"payload" json:"payload"`
+	RequestID     string    `db:"request_id" json:"request_id"`
+	Status        int8      `db:"status" json:"status"`
+	Attempts      int       `db:"attempts" json:"attempts"`
+	LastError     string    `db:"last_error" json:"last_error"`
+	NextRetryTime time.Time `db:"next_retry_time" json:"next_retry_time"`
+	CreateTime    time.Time `db:"create_time" json:"create_time"`
+	UpdateTime    time.Time `db:"update_time" json:"update_time"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// 推送平台
+const (
+	PlatformIOS     = "ios"     // APNs
+	PlatformAndroid = "android" // FCM
+)
+
+// PushDevice 用户登录过的移动设备和推送 token，token 全局唯一，换账号登录时归到新用户名下
+//
+//	CREATE TABLE `push_device` (
+//	  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+//	  `user_id` bigint(20) NOT NULL,
+//	  `platform` varchar(16) NOT NULL COMMENT 'ios / android',
+//	  `token` varchar(255) NOT NULL,
+//	  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`id`),
+//	  UNIQUE KEY `idx_token` (`token`),
+//	  KEY `idx_user_id` (`user_id`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type PushDevice struct {
+	ID         int64     `db:"id" json:"id"`
+	UserID     int64     `db:"user_id" json:"user_id,string"`
+	Platform   string    `db:"platform" json:"platform"`
+	Token      string    `db:"token" json:"token"`
+	CreateTime time.Time `db:"create_time" json:"create_time"`
+	UpdateTime time.Time `db:"update_time" json:"update_time"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// User 用户，第三方登录创建的账号没有密码
+//
+//	CREATE TABLE `user` (
+//	  `id` bigint(20) NOT NULL COMMENT '雪花算法生成',
+//	  `username` varchar(64) NOT NULL,
+//	  `password` varchar(255) NOT NULL DEFAULT '',
+//	  `nickname` varchar(64) NOT NULL DEFAULT '',
+//	  `email` varchar(128) NOT NULL DEFAULT '',
+//	  `avatar` varchar(512) NOT NULL DEFAULT '',
+//	  `totp_secret` varchar(64) NOT NULL DEFAULT '',
+//	  `locale` varchar(16) NOT NULL DEFAULT '' COMMENT '为空时按请求头',
+//	  `timezone` varchar(64) NOT NULL DEFAULT '' COMMENT 'IANA 时区名',
+//	  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`id`),
+//	  UNIQUE KEY `idx_username` (`username`),
+//	  KEY `idx_email` (`email`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type User struct {
+	UserID     int64     `db:"id" json:"user_id,string"`
+	Username   string    `db:"username" json:"username"`
+	Password   string    `db:"password" json:"-"`
+	Nickname   string    `db:"nickname" json:"nickname"`
+	Email      string    `db:"email" json:"email"`
+	Avatar     string    `db:"avatar" json:"avatar"`
+	TOTPSecret string    `db:"totp_secret" json:"-"`     // 为空表示没有开启两步验证
+	Locale     string    `db:"locale" json:"locale"`     // 语言偏好，为空时按请求头
+	Timezone   string    `db:"timezone" json:"timezone"` // 时区偏好，为空时按请求头
+	CreateTime time.Time `db:"create_time" json:"create_time"`
+	UpdateTime time.Time `db:"update_time" json:"update_time"`
+}
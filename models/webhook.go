@@ -0,0 +1,78 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Webhook 订阅领域事件的外部 URL，事件发生后带 HMAC 签名 POST 过去
+//
+//	CREATE TABLE `webhook` (
+//	  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+//	  `url` varchar(512) NOT NULL,
+//	  `secret` varchar(128) NOT NULL,
+//	  `events` varchar(512) NOT NULL DEFAULT '*',
+//	  `description` varchar(255) NOT NULL DEFAULT '',
+//	  `enabled` tinyint(1) NOT NULL DEFAULT 1,
+//	  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`id`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type Webhook struct {
+	ID          int64     `db:"id" json:"id"`
+	URL         string    `db:"url" json:"url"`
+	Secret      string    `db:"secret" json:"-"`      // 签名密钥，只在创建时返回一次
+	Events      string    `db:"events" json:"events"` // 逗号分隔的事件名，* 表示全部事件
+	Description string    `db:"description" json:"description"`
+	Enabled     bool      `db:"enabled" json:"enabled"`
+	CreateTime  time.Time `db:"create_time" json:"create_time"`
+	UpdateTime  time.Time `db:"update_time" json:"update_time"`
+}
+
+// Subscribed 是否订阅了事件 name
+func (w *Webhook) Subscribed(name string) bool {
+	events := strings.Split(w.Events, ",")
+	return slices.Contains(events, "*") || slices.Contains(events, name)
+}
+
+// webhook 投递状态
+const (
+	WebhookPending int8 = 0 // 待投递，失败后等待重试也是这个状态
+	WebhookSuccess int8 = 1 // 对方返回了 2xx
+	WebhookDead    int8 = 2 // 重试次数用完，可以在管理接口重新投递
+)
+
+// WebhookDelivery 一次事件投递，同时也是投递日志，保留最近一次的响应码和错误
+//
+//	CREATE TABLE `webhook_delivery` (
+//	  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+//	  `webhook_id` bigint(20) NOT NULL,
+//	  `event` varchar(64) NOT NULL,
+//	  `payload` mediumtext NOT NULL,
+//	  `request_id` varchar(64) NOT NULL DEFAULT '',
+//	  `status` tinyint(4) NOT NULL DEFAULT 0 COMMENT '0 待投递 1 成功 2 死信',
+//	  `attempts` int(11) NOT NULL DEFAULT 0,
+//	  `response_code` int(11) NOT NULL DEFAULT 0,
+//	  `last_error` varchar(512) NOT NULL DEFAULT '',
+//	  `next_retry_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	  `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+//	  PRIMARY KEY (`id`),
+//	  KEY `idx_status_next_retry` (`status`, `next_retry_time`),
+//	  KEY `idx_webhook_id` (`webhook_id`, `id`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+type WebhookDelivery struct {
+	ID            int64     `db:"id" json:"id"`
+	WebhookID     int64     `db:"webhook_id" json:"webhook_id"`
+	Event         string    `db:"event" json:"event"`
+	Payload       string    `db:"payload" json:"payload"`
+	RequestID     string    `db:"request_id" json:"request_id"`
+	Status        int8      `db:"status" json:"status"`
+	Attempts      int       `db:"attempts" json:"attempts"`
+	ResponseCode  int       `db:"response_code" json:"response_code"`
+	LastError     string    `db:"last_error" json:"last_error"`
+	NextRetryTime time.Time `db:"next_retry_time" json:"next_retry_time"`
+	CreateTime    time.Time `db:"create_time" json:"create_time"`
+	UpdateTime    time.Time `db:"update_time" json:"update_time"`
+}
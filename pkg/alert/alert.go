@@ -0,0 +1,243 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/k8s"
+	"go_web_scaffolding/settings"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 出现 panic 或者一个时间窗口内 5xx 比例超过阈值时，往钉钉/Slack 群里发告警
+//
+// 告警不会每次都立即发送：同一个窗口内的告警先攒起来，窗口结束时合并成一条消息发送；
+// 同一个告警（同一个 panic 指纹、错误率告警）发送后在 dedup_window 内不再重复发送，
+// 只累计次数，静默期过后再发时带上这段时间内的次数，故障期间群里不会被刷屏
+
+const (
+	defaultWindow      = time.Minute
+	defaultDedupWindow = 10 * time.Minute
+	defaultMinRequests = 20
+	sendTimeout        = 10 * time.Second
+	// maxPending 一个窗口内最多攒多少种告警，超过的直接丢弃，防止内存无限增长
+	maxPending = 100
+)
+
+// item 一种告警在当前窗口内的汇总
+type item struct {
+	key   string
+	title string
+	text  string
+	count int64
+	first time.Time
+}
+
+// sent 告警上次发送的时间和之后被静默的次数
+type sent struct {
+	at         time.Time
+	suppressed int64
+}
+
+var (
+	cfg      *settings.AlertConfig
+	notifier Notifier
+
+	mu      sync.Mutex
+	pending = make(map[string]*item)
+	history = make(map[string]*sent)
+
+	total, errs        atomic.Int64
+	sentCnt, failedCnt atomic.Int64
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+)
+
+func init() {
+	dashboard.Register("alert", func(ctx context.Context) interface{} {
+		mu.Lock()
+		n := len(pending)
+		mu.Unlock()
+		return map[string]interface{}{
+			"enabled": notifier != nil,
+			"pending": n,
+			"sent":    sentCnt.Load(),
+			"failed":  failedCnt.Load(),
+		}
+	})
+}
+
+// Init 没有开启或者没有配置 webhook 时不发送告警
+func Init(c *settings.AlertConfig) (err error) {
+	if c == nil || !c.Enable || c.Webhook == "" {
+		return
+	}
+	switch c.Channel {
+	case "dingtalk", "":
+		notifier = &dingTalk{webhook: c.Webhook, secret: c.Secret}
+	case "slack":
+		notifier = &slack{webhook: c.Webhook}
+	default:
+		return fmt.Errorf("alert: unknown channel %q", c.Channel)
+	}
+	cfg = c
+	return
+}
+
+// Start 启动后台协程，每个窗口结束时检查错误率并发送攒下的告警
+func Start() {
+	if notifier == nil {
+		return
+	}
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(window())
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				tick()
+			}
+		}
+	}()
+}
+
+// Stop 停止后台协程，退出前把还没发送的告警发出去
+func Stop() {
+	if cancel == nil {
+		return
+	}
+	cancel()
+	wg.Wait()
+	flush()
+}
+
+// Panic 记录一次 panic，fingerprint 相同的 panic 算同一个告警
+func Panic(ctx context.Context, fingerprint string, err any) {
+	if notifier == nil {
+		return
+	}
+	text := fmt.Sprintf("- error: %v\n- fingerprint: %s", err, fingerprint)
+	if id := ctxutil.RequestID(ctx); id != "" {
+		text += "\n- request_id: " + id
+	}
+	add("panic:"+fingerprint, "panic", text)
+}
+
+// Observe 记录一次请求的状态码，用来计算窗口内的 5xx 比例
+func Observe(status int) {
+	if notifier == nil {
+		return
+	}
+	total.Add(1)
+	if status >= 500 {
+		errs.Add(1)
+	}
+}
+
+// add 把告警放进当前窗口，同一个 key 只累加次数
+func add(key, title, text string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if it, ok := pending[key]; ok {
+		it.count++
+		return
+	}
+	if len(pending) >= maxPending {
+		return
+	}
+	pending[key] = &item{key: key, title: title, text: text, count: 1, first: time.Now()}
+}
+
+// tick 窗口结束：检查错误率，然后发送
+func tick() {
+	t, e := total.Swap(0), errs.Swap(0)
+	minReq := int64(cfg.MinRequests)
+	if minReq <= 0 {
+		minReq = defaultMinRequests
+	}
+	if cfg.ErrorRate > 0 && t >= minReq && float64(e)/float64(t) >= cfg.ErrorRate {
+		add("error_rate", "error rate too high",
+			fmt.Sprintf("- 5xx: %d / %d (%.1f%%) in %s\n- threshold: %.1f%%",
+				e, t, float64(e)*100/float64(t), window(), cfg.ErrorRate*100))
+	}
+	flush()
+}
+
+// flush 去掉静默期内的告警，剩下的合并成一条消息发送
+func flush() {
+	now := time.Now()
+	dedup := time.Duration(cfg.DedupWindow) * time.Second
+	if dedup <= 0 {
+		dedup = defaultDedupWindow
+	}
+
+	mu.Lock()
+	items := make([]*item, 0, len(pending))
+	for key, it := range pending {
+		h, ok := history[key]
+		if ok && now.Sub(h.at) < dedup {
+			h.suppressed += it.count
+			continue
+		}
+		if ok && h.suppressed > 0 {
+			it.text += fmt.Sprintf("\n- 静默期内另外发生 %d 次", h.suppressed)
+		}
+		history[key] = &sent{at: now}
+		items = append(items, it)
+	}
+	pending = make(map[string]*item)
+	// 清理早就过了静默期的记录
+	for key, h := range history {
+		if now.Sub(h.at) > 2*dedup {
+			delete(history, key)
+		}
+	}
+	mu.Unlock()
+
+	if len(items) == 0 {
+		return
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].first.Before(items[j].first) })
+	title := fmt.Sprintf("[%s] %d alert(s)", settings.Conf.Name, len(items))
+	var b strings.Builder
+	fmt.Fprintf(&b, "mode: %s  pod: %s\n\n", settings.Conf.Mode, k8s.Pod().Name)
+	for _, it := range items {
+		fmt.Fprintf(&b, "**%s** ×%d\n%s\n\n", it.title, it.count, it.text)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	if err := notifier.Send(ctx, title, b.String()); err != nil {
+		failedCnt.Add(1)
+		zap.L().Warn("send alert failed", zap.Int("items", len(items)), zap.Error(err))
+		// 没发出去的不算发送过，下个窗口再出现时不会被静默
+		mu.Lock()
+		for _, it := range items {
+			delete(history, it.key)
+		}
+		mu.Unlock()
+		return
+	}
+	sentCnt.Add(1)
+}
+
+func window() time.Duration {
+	if cfg != nil && cfg.Window > 0 {
+		return time.Duration(cfg.Window) * time.Second
+	}
+	return defaultWindow
+}
@@ -0,0 +1,104 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go_web_scaffolding/pkg/breaker"
+	"go_web_scaffolding/pkg/retry"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Notifier 把一条汇总后的告警发到 IM 群里
+type Notifier interface {
+	Send(ctx context.Context, title, text string) error
+}
+
+var httpClient = &http.Client{Timeout: 5 * time.Second, Transport: breaker.Transport(nil)}
+
+// dingTalk 钉钉群机器人，安全设置选"加签"时需要配置 secret
+type dingTalk struct {
+	webhook string
+	secret  string
+}
+
+func (d *dingTalk) Send(ctx context.Context, title, text string) error {
+	u := d.webhook
+	if d.secret != "" {
+		ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(d.secret))
+		mac.Write([]byte(ts + "\n" + d.secret))
+		sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		u += "&timestamp=" + ts + "&sign=" + url.QueryEscape(sign)
+	}
+	body := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": title,
+			"text":  "### " + title + "\n\n" + text,
+		},
+	}
+	var res struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := postJSON(ctx, u, body, &res); err != nil {
+		return err
+	}
+	if res.ErrCode != 0 {
+		return fmt.Errorf("alert: dingtalk errcode %d: %s", res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+// slack Incoming Webhook
+type slack struct {
+	webhook string
+}
+
+func (s *slack) Send(ctx context.Context, title, text string) error {
+	return postJSON(ctx, s.webhook, map[string]string{"text": "*" + title + "*\n" + text}, nil)
+}
+
+// sendRetry 网络错误、429 和 5xx 重试，整个发送过程受 sendTimeout 限制
+var sendRetry = retry.Policy{MaxAttempts: 3, Initial: 500 * time.Millisecond, Jitter: 0.2}
+
+// postJSON 发送 JSON 请求，非 2xx 按失败处理，v 不为 nil 时解析响应
+func postJSON(ctx context.Context, url string, body, v interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return retry.Do(ctx, sendRetry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			err = fmt.Errorf("alert: webhook status %d: %s", resp.StatusCode, msg)
+			if !retry.RetryableStatus(resp.StatusCode) {
+				err = retry.Permanent(err)
+			}
+			return err
+		}
+		if v == nil {
+			return nil
+		}
+		return retry.Permanent(json.NewDecoder(resp.Body).Decode(v))
+	})
+}
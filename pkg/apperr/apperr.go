@@ -0,0 +1,124 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 带业务状态码、HTTP 状态码和提示信息的错误，logic 层直接返回它，controller 用 response.Error
+// 统一转成响应，不需要每个接口再写一遍 errors.Is 的映射：
+//
+//	var ErrOrderNotExist = apperr.NotFound("订单不存在")
+//
+//	return ErrOrderNotExist                       // 直接返回
+//	return apperr.Internal(err)                   // 内部错误，客户端只看到"服务繁忙"，err 记录到日志
+//	return ErrOrderNotExist.Wrap(err)             // 保留底层错误，errors.Is(err, ErrOrderNotExist) 仍然成立
+//
+// Msg 会原样返回给客户端，不要放 SQL、堆栈之类的内部信息，这些放在 cause 里，只会打到日志
+
+// Error 应用错误
+type Error struct {
+	// Code 业务状态码（controller/response 的 ResCode），0 表示按 HTTP 状态码取默认的业务状态码
+	Code int64
+	// Status HTTP 状态码
+	Status int
+	// Msg 返回给客户端的提示，为空时使用业务状态码的默认提示
+	Msg string
+	// Data 需要一起返回给客户端的数据，比如校验失败的字段
+	Data interface{}
+
+	cause error
+	// origin Wrap/WithMsg 复制出来的错误指向最初定义的那个，errors.Is 用它判断
+	origin *Error
+}
+
+// New 创建应用错误，一般定义成包级变量
+func New(code int64, status int, msg string) *Error {
+	return &Error{Code: code, Status: status, Msg: msg}
+}
+
+func BadRequest(msg string) *Error      { return New(0, http.StatusBadRequest, msg) }
+func Unauthorized(msg string) *Error    { return New(0, http.StatusUnauthorized, msg) }
+func Forbidden(msg string) *Error       { return New(0, http.StatusForbidden, msg) }
+func NotFound(msg string) *Error        { return New(0, http.StatusNotFound, msg) }
+func Conflict(msg string) *Error        { return New(0, http.StatusConflict, msg) }
+func TooManyRequests(msg string) *Error { return New(0, http.StatusTooManyRequests, msg) }
+func Unavailable(msg string) *Error     { return New(0, http.StatusServiceUnavailable, msg) }
+
+// Internal 内部错误，客户端只能看到默认的提示，cause 只记录到日志
+func Internal(cause error) *Error {
+	return New(0, http.StatusInternalServerError, "").Wrap(cause)
+}
+
+func (e *Error) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.cause != nil {
+		return msg + ": " + e.cause.Error()
+	}
+	return msg
+}
+
+// Unwrap 支持 errors.Is/As 继续检查底层错误
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is 由同一个错误 Wrap/WithMsg 出来的错误都算相等
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return e.root() == t.root()
+}
+
+func (e *Error) root() *Error {
+	if e.origin != nil {
+		return e.origin
+	}
+	return e
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	c.origin = e.root()
+	return &c
+}
+
+// Wrap 返回带有底层错误的副本
+func (e *Error) Wrap(cause error) *Error {
+	c := e.clone()
+	c.cause = cause
+	return c
+}
+
+// WithMsg 返回修改了提示的副本
+func (e *Error) WithMsg(msg string) *Error {
+	c := e.clone()
+	c.Msg = msg
+	return c
+}
+
+// WithData 返回带有数据的副本
+func (e *Error) WithData(data interface{}) *Error {
+	c := e.clone()
+	c.Data = data
+	return c
+}
+
+// Cause 底层错误
+func (e *Error) Cause() error {
+	return e.cause
+}
+
+// As 从错误链里取出应用错误
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/batcher"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 审计日志和领域事件不在请求里同步写库，先进入有界缓冲区，由后台协程批量写入 MySQL
+// 缓冲区满时按配置阻塞或丢弃，丢弃数量可以在管理接口查看
+
+var (
+	logs   *batcher.Batcher[*models.AuditLog]
+	events *batcher.Batcher[*models.DomainEvent]
+)
+
+func init() {
+	dashboard.Register("audit", func(ctx context.Context) interface{} {
+		if logs == nil {
+			return nil
+		}
+		return []batcher.Stats{logs.Stats(), events.Stats()}
+	})
+}
+
+func Init(cfg *settings.AuditConfig) (err error) {
+	// 没有配置 audit 段时使用 batcher 的默认值，审计日志不能因为漏配而关闭
+	if cfg == nil {
+		cfg = new(settings.AuditConfig)
+	}
+	opts := batcher.Options{
+		BufferSize:    cfg.BufferSize,
+		BatchSize:     cfg.BatchSize,
+		FlushInterval: time.Duration(cfg.FlushInterval) * time.Millisecond,
+		Policy:        batcher.Policy(cfg.Policy),
+		BlockTimeout:  time.Duration(cfg.BlockTimeout) * time.Millisecond,
+	}
+	opts.Name = "audit_log"
+	logs = batcher.New(opts, mysql.BatchInsertAuditLogs)
+	opts.Name = "domain_event"
+	events = batcher.New(opts, mysql.BatchInsertDomainEvents)
+	return
+}
+
+// Log 记录一条审计日志，用户、IP、请求ID 从 ctx 中获取
+// Init 之前（比如命令行子命令）调用直接忽略
+func Log(ctx context.Context, action, resource, detail string) {
+	if logs == nil {
+		return
+	}
+	err := logs.Add(ctx, &models.AuditLog{
+		UserID:     ctxutil.UserID(ctx),
+		Action:     action,
+		Resource:   resource,
+		Detail:     detail,
+		IP:         ctxutil.ClientIP(ctx),
+		RequestID:  ctxutil.RequestID(ctx),
+		CreateTime: time.Now(),
+	})
+	if err != nil {
+		logger.Ctx(ctx).Debug("audit log dropped", zap.String("action", action), zap.Error(err))
+	}
+}
+
+// Event 持久化一条领域事件，payload 序列化为 JSON
+// 业务代码通过 eventbus 发布事件，由 eventbus 的订阅者调用这里，不要直接调用
+func Event(ctx context.Context, name string, payload interface{}) {
+	if events == nil {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Ctx(ctx).Error("marshal domain event failed", zap.String("name", name), zap.Error(err))
+		return
+	}
+	e := &models.DomainEvent{
+		Name:       name,
+		Payload:    string(data),
+		RequestID:  ctxutil.RequestID(ctx),
+		CreateTime: time.Now(),
+	}
+	err = events.Add(ctx, e)
+	if err != nil {
+		logger.Ctx(ctx).Debug("domain event dropped", zap.String("name", name), zap.Error(err))
+	}
+}
+
+// Close 把缓冲区里剩余的数据写完，需要在 MySQL 连接关闭之前调用
+func Close(ctx context.Context) {
+	if logs == nil {
+		return
+	}
+	if err := logs.Close(ctx); err != nil {
+		zap.L().Error("flush audit logs failed", zap.Error(err))
+	}
+	if err := events.Close(ctx); err != nil {
+		zap.L().Error("flush domain events failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,198 @@
+package batcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Policy 缓冲区满时的处理策略
+type Policy string
+
+const (
+	// PolicyBlock 阻塞等待缓冲区有空位，最多等待 BlockTimeout，超时后丢弃
+	PolicyBlock Policy = "block"
+	// PolicyDrop 立即丢弃新数据，不影响请求耗时
+	PolicyDrop Policy = "drop"
+)
+
+var (
+	ErrDropped = errors.New("batcher: buffer full, item dropped")
+	ErrClosed  = errors.New("batcher: closed")
+)
+
+// Options 批量写入器配置
+type Options struct {
+	Name          string        // 名字，用于日志和统计
+	BufferSize    int           // 缓冲区容量
+	BatchSize     int           // 攒够多少条写一次
+	FlushInterval time.Duration // 不足一批时最长等待多久写一次
+	Policy        Policy
+	BlockTimeout  time.Duration
+	// Logger 记录写入失败，默认 zap.L()；日志输出本身用批量写入器时要换成不经过它的 logger，否则失败日志会写回自己
+	Logger *zap.Logger
+}
+
+// Stats 运行统计
+type Stats struct {
+	Name     string `json:"name"`
+	Pending  int    `json:"pending"`
+	Enqueued int64  `json:"enqueued"`
+	Dropped  int64  `json:"dropped"`
+	Flushed  int64  `json:"flushed"`
+	Failed   int64  `json:"failed"`
+}
+
+// Batcher 有界异步批量写入器
+// 请求路径上只把数据放进缓冲区，后台协程攒批后调用 flush 写库，突发流量时按 Policy 施加背压或丢弃
+type Batcher[T any] struct {
+	opts  Options
+	flush func(ctx context.Context, items []T) error
+	ch    chan T
+	done  chan struct{}
+	once  sync.Once
+
+	// mu 保护 closed 和 ch 的关闭：Add 持读锁发送，Close 持写锁关闭，避免向已关闭的 channel 发送而 panic
+	mu     sync.RWMutex
+	closed bool
+
+	enqueued, dropped, flushed, failed atomic.Int64
+}
+
+// New 创建并启动批量写入器，使用完需要调用 Close
+func New[T any](opts Options, flush func(ctx context.Context, items []T) error) *Batcher[T] {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1024
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.Policy == "" {
+		opts.Policy = PolicyDrop
+	}
+	b := &Batcher[T]{
+		opts:  opts,
+		flush: flush,
+		ch:    make(chan T, opts.BufferSize),
+		done:  make(chan struct{}),
+	}
+	go b.loop()
+	return b
+}
+
+// Add 放入缓冲区，缓冲区满且按策略丢弃时返回 ErrDropped，Close 之后返回 ErrClosed
+func (b *Batcher[T]) Add(ctx context.Context, item T) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrClosed
+	}
+
+	select {
+	case b.ch <- item:
+		b.enqueued.Add(1)
+		return nil
+	default:
+	}
+
+	if b.opts.Policy == PolicyBlock {
+		timer := time.NewTimer(b.opts.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case b.ch <- item:
+			b.enqueued.Add(1)
+			return nil
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+	}
+
+	// 丢弃不逐条打日志，否则高峰期日志量和写库量一样大，通过统计观察
+	b.dropped.Add(1)
+	return ErrDropped
+}
+
+// Stats 运行统计
+func (b *Batcher[T]) Stats() Stats {
+	return Stats{
+		Name:     b.opts.Name,
+		Pending:  len(b.ch),
+		Enqueued: b.enqueued.Load(),
+		Dropped:  b.dropped.Load(),
+		Flushed:  b.flushed.Load(),
+		Failed:   b.failed.Load(),
+	}
+}
+
+// Close 停止接收并把缓冲区剩余数据写完，最多等待 ctx 超时
+// Close 之后再调用 Add 返回 ErrClosed
+func (b *Batcher[T]) Close(ctx context.Context) error {
+	b.once.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		close(b.ch)
+		b.mu.Unlock()
+	})
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Batcher[T]) loop() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]T, 0, b.opts.BatchSize)
+	for {
+		select {
+		case item, ok := <-b.ch:
+			if !ok {
+				b.write(batch)
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) >= b.opts.BatchSize {
+				b.write(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			b.write(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (b *Batcher[T]) logger() *zap.Logger {
+	if b.opts.Logger != nil {
+		return b.opts.Logger
+	}
+	return zap.L()
+}
+
+func (b *Batcher[T]) write(batch []T) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := b.flush(ctx, batch); err != nil {
+		b.failed.Add(int64(len(batch)))
+		b.logger().Error("batcher flush failed",
+			zap.String("name", b.opts.Name),
+			zap.Int("size", len(batch)),
+			zap.Error(err))
+		return
+	}
+	b.flushed.Add(int64(len(batch)))
+}
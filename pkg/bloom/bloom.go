@@ -0,0 +1,168 @@
+package bloom
+
+import (
+	"context"
+	"go_web_scaffolding/dao/redis"
+	"hash/fnv"
+	"math"
+
+	goredis "github.com/go-redis/redis"
+)
+
+// batchSize 重建时每攒够这么多条数据用 pipeline 提交一次
+const batchSize = 1000
+
+const addScript = "bloom:add"
+
+func init() {
+	// 写入过滤器，正在重建（临时 key 存在）时同时写入临时 key，RENAME 之后重建期间新增的数据也还在
+	// 和 RENAME 一样在 redis 里原子执行，不会出现检查时还在重建、写入时已经换掉的情况
+	redis.RegisterScript(addScript, `
+local rebuilding = redis.call('EXISTS', KEYS[2]) == 1
+for _, offset in ipairs(ARGV) do
+	redis.call('SETBIT', KEYS[1], offset, 1)
+	if rebuilding then
+		redis.call('SETBIT', KEYS[2], offset, 1)
+	end
+end
+return 0
+`)
+}
+
+// Filter 基于 redis bitmap 的布隆过滤器，用来挡住查询不存在 ID 的请求（缓存穿透）
+// 判断为不存在的一定不存在，可以直接返回；判断为存在的有 fpRate 的概率误判，继续走缓存和 MySQL
+//
+//	if ok, _ := userFilter.Exists(ctx, strconv.FormatInt(id, 10)); !ok {
+//		return nil, ErrUserNotExist
+//	}
+type Filter struct {
+	key string
+	m   uint64 // bit 数
+	k   uint64 // 哈希函数个数
+}
+
+// New 根据预计元素个数 n 和期望误判率 fpRate 计算 bit 数和哈希函数个数
+func New(key string, n uint64, fpRate float64) *Filter {
+	if n == 0 {
+		n = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k == 0 {
+		k = 1
+	}
+	return &Filter{key: key, m: m, k: k}
+}
+
+// Add 新增数据时调用，把 items 写入过滤器
+func (f *Filter) Add(ctx context.Context, items ...string) error {
+	if len(items) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(items)*int(f.k))
+	for _, item := range items {
+		for _, offset := range f.offsets(item) {
+			args = append(args, offset)
+		}
+	}
+	return redis.EvalScript(ctx, addScript, []string{f.key, f.rebuildingKey()}, args...).Err()
+}
+
+// Exists 判断 item 是否可能存在
+func (f *Filter) Exists(ctx context.Context, item string) (bool, error) {
+	pipe := redis.Ctx(ctx).Pipeline()
+	cmds := make([]*goredis.IntCmd, 0, f.k)
+	for _, offset := range f.offsets(item) {
+		cmds = append(cmds, pipe.GetBit(f.key, int64(offset)))
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return false, err
+	}
+	for _, cmd := range cmds {
+		if cmd.Val() == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Rebuild 从数据源全量重建过滤器
+// 布隆过滤器不支持删除，数据大量删除后误判率会升高，需要定期重建
+// load 负责分批从数据库读出所有 key 并调用 add，重建写到临时 key，完成后 RENAME 原子替换，重建期间查询不受影响
+// 重建期间 Add 会同时写入临时 key，load 读完之后才新增的数据不会在替换时丢掉
+func (f *Filter) Rebuild(ctx context.Context, load func(ctx context.Context, add func(items ...string) error) error) (err error) {
+	rdb := redis.Ctx(ctx)
+	tmpKey := f.rebuildingKey()
+	if err = rdb.Del(tmpKey).Err(); err != nil {
+		return
+	}
+
+	// 没有任何数据时也要有一个 key，否则 RENAME 会失败
+	if err = rdb.SetBit(tmpKey, int64(f.m-1), 0).Err(); err != nil {
+		return
+	}
+
+	buf := make([]string, 0, batchSize)
+	flush := func() error {
+		if len(buf) == 0 {
+			return nil
+		}
+		err := f.add(ctx, tmpKey, buf)
+		buf = buf[:0]
+		return err
+	}
+	err = load(ctx, func(items ...string) error {
+		for _, item := range items {
+			buf = append(buf, item)
+			if len(buf) >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		_ = rdb.Del(tmpKey).Err()
+		return
+	}
+	return rdb.Rename(tmpKey, f.key).Err()
+}
+
+// rebuildingKey 重建时的临时 key
+func (f *Filter) rebuildingKey() string {
+	return f.key + ":rebuilding"
+}
+
+func (f *Filter) add(ctx context.Context, key string, items []string) error {
+	if len(items) == 0 {
+		return nil
+	}
+	pipe := redis.Ctx(ctx).Pipeline()
+	for _, item := range items {
+		for _, offset := range f.offsets(item) {
+			pipe.SetBit(key, int64(offset), 1)
+		}
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+// offsets 用双重哈希 h1 + i*h2 模拟 k 个哈希函数
+func (f *Filter) offsets(item string) []uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(item))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32
+	offsets := make([]uint64, f.k)
+	for i := uint64(0); i < f.k; i++ {
+		offsets[i] = (h1 + i*h2) % f.m
+	}
+	return offsets
+}
@@ -0,0 +1,240 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/settings"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker/v2"
+	"go.uber.org/zap"
+)
+
+// 熔断器：依赖（MySQL、Redis、外部 HTTP 接口）持续失败时直接返回 ErrOpen，不再把请求压到已经出问题的服务上，
+// 避免每个请求都等到超时，goroutine 和连接池被占满后拖垮整个服务
+//
+//	closed     正常放行，统计周期（interval）内请求数达到 min_requests 且失败比例达到 failure_ratio 时打开
+//	open       直接返回 ErrOpen，timeout 秒之后进入 half-open
+//	half-open  放行 max_requests 个试探请求，连续成功这么多个就关闭，有一个失败就重新打开
+//
+// 每个依赖实例一个熔断器，名字是 "类型:地址"，如 mysql:10.0.0.1:3306、redis:10.0.0.2:6379、http:api.weixin.qq.com
+// 配置先按名字查找，找不到再按冒号前的类型查找，都没有时使用默认值
+// 哪些错误算依赖失败由调用方判断：SQL 语法错误、redis.Nil、HTTP 4xx 说明依赖本身是好的，不能算失败；
+// 调用方自己取消（context.Canceled）的请求不计入统计
+
+// ErrOpen 熔断器拒绝请求，errors.Is(err, breaker.ErrOpen) 判断
+var ErrOpen = errors.New("breaker: circuit open")
+
+// OpenError 熔断器拒绝请求时返回的错误，带上熔断器名字，方便从日志里看出是哪个依赖
+type OpenError struct {
+	Name  string
+	State string
+}
+
+func (e *OpenError) Error() string {
+	return "breaker: " + e.Name + " is " + e.State
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+const (
+	defaultInterval     = 10 * time.Second
+	defaultTimeout      = 30 * time.Second
+	defaultMinRequests  = 20
+	defaultFailureRatio = 0.5
+)
+
+// Breaker 一个依赖的熔断器，nil 表示没有开启熔断，所有方法都可以在 nil 上调用，总是放行
+type Breaker struct {
+	name string
+	cb   *gobreaker.TwoStepCircuitBreaker[struct{}]
+}
+
+var (
+	mu       sync.RWMutex
+	enabled  bool
+	rules    map[string]*settings.BreakerRule
+	breakers = make(map[string]*Breaker)
+)
+
+func init() {
+	dashboard.Register("breaker", func(ctx context.Context) interface{} {
+		return Stats()
+	})
+}
+
+// Init 按配置开启熔断，需要在 MySQL、Redis 之前初始化，它们建连接池时就会取熔断器
+func Init(cfg *settings.BreakerConfig) (err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = cfg != nil && cfg.Enable
+	rules = nil
+	if enabled {
+		rules = make(map[string]*settings.BreakerRule, len(cfg.Rules))
+		for _, r := range cfg.Rules {
+			if r != nil && r.Name != "" {
+				rules[r.Name] = r
+			}
+		}
+	}
+	breakers = make(map[string]*Breaker)
+	return
+}
+
+// Get 返回名字对应的熔断器，第一次调用时按配置创建，没有开启熔断时返回 nil
+// isFailure 判断一个错误是不是依赖失败，只在创建时使用，同一个名字要传同样的判断
+func Get(name string, isFailure func(err error) bool) *Breaker {
+	mu.RLock()
+	b, ok := breakers[name]
+	on := enabled
+	mu.RUnlock()
+	if ok || !on {
+		return b
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if b, ok = breakers[name]; ok {
+		return b
+	}
+	b = newBreaker(name, ruleOf(name), isFailure)
+	breakers[name] = b
+	return b
+}
+
+// ruleOf 按名字、类型的顺序查找配置，调用方持有 mu
+func ruleOf(name string) *settings.BreakerRule {
+	if r, ok := rules[name]; ok {
+		return r
+	}
+	if i := strings.IndexByte(name, ':'); i > 0 {
+		if r, ok := rules[name[:i]]; ok {
+			return r
+		}
+	}
+	return &settings.BreakerRule{}
+}
+
+func newBreaker(name string, r *settings.BreakerRule, isFailure func(err error) bool) *Breaker {
+	interval := time.Duration(r.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	timeout := time.Duration(r.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	minRequests := r.MinRequests
+	if minRequests == 0 {
+		minRequests = defaultMinRequests
+	}
+	ratio := r.FailureRatio
+	if ratio <= 0 {
+		ratio = defaultFailureRatio
+	}
+	stateGauge.WithLabelValues(name).Set(float64(gobreaker.StateClosed))
+	return &Breaker{
+		name: name,
+		cb: gobreaker.NewTwoStepCircuitBreaker[struct{}](gobreaker.Settings{
+			Name:        name,
+			MaxRequests: r.MaxRequests,
+			Interval:    interval,
+			// 滑动窗口，每个桶是统计周期的十分之一，不会在周期切换的瞬间清零
+			BucketPeriod: interval / 10,
+			Timeout:      timeout,
+			ReadyToTrip: func(c gobreaker.Counts) bool {
+				return c.Requests >= minRequests && float64(c.TotalFailures) >= float64(c.Requests)*ratio
+			},
+			OnStateChange: onStateChange,
+			IsSuccessful: func(err error) bool {
+				return err == nil || isFailure == nil || !isFailure(err)
+			},
+			IsExcluded: func(err error) bool {
+				return errors.Is(err, context.Canceled)
+			},
+		}),
+	}
+}
+
+func onStateChange(name string, from, to gobreaker.State) {
+	lg := zap.L().Named("breaker")
+	if to == gobreaker.StateOpen {
+		lg.Error("circuit opened", zap.String("name", name), zap.String("from", from.String()))
+	} else {
+		lg.Info("circuit state changed", zap.String("name", name),
+			zap.String("from", from.String()), zap.String("to", to.String()))
+	}
+	stateGauge.WithLabelValues(name).Set(float64(to))
+}
+
+// Allow 判断请求能否放行，放行时返回 done，请求结束后必须用请求的错误调用一次
+// 拒绝时返回 *OpenError
+func (b *Breaker) Allow() (done func(err error), err error) {
+	if b == nil {
+		return noop, nil
+	}
+	done, err = b.cb.Allow()
+	if err != nil {
+		rejected.WithLabelValues(b.name).Inc()
+		return nil, &OpenError{Name: b.name, State: b.cb.State().String()}
+	}
+	return done, nil
+}
+
+func noop(error) {}
+
+// Do 在熔断器保护下执行 fn
+func (b *Breaker) Do(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	done(err)
+	return err
+}
+
+// Name 熔断器名字
+func (b *Breaker) Name() string {
+	if b == nil {
+		return ""
+	}
+	return b.name
+}
+
+// State closed、half-open 或 open，nil 返回 closed
+func (b *Breaker) State() string {
+	if b == nil {
+		return gobreaker.StateClosed.String()
+	}
+	return b.cb.State().String()
+}
+
+// Stats 所有熔断器的状态和当前统计周期的计数，给管理接口展示
+func Stats() []map[string]interface{} {
+	mu.RLock()
+	list := make([]*Breaker, 0, len(breakers))
+	for _, b := range breakers {
+		list = append(list, b)
+	}
+	mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+
+	res := make([]map[string]interface{}, 0, len(list))
+	for _, b := range list {
+		c := b.cb.Counts()
+		res = append(res, map[string]interface{}{
+			"name":     b.name,
+			"state":    b.cb.State().String(),
+			"requests": c.Requests,
+			"failures": c.TotalFailures,
+		})
+	}
+	return res
+}
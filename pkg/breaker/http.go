@@ -0,0 +1,55 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// StatusError HTTP 接口返回了 5xx，只用来告诉熔断器这次请求失败了，调用方拿到的仍然是正常的响应
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return "breaker: http status " + strconv.Itoa(e.Code)
+}
+
+// Transport 给 http.Client 用的 RoundTripper，按请求的 host 取熔断器 http:<host>
+// 连接失败、超时和 5xx 算失败；熔断打开时不发请求，直接返回 *OpenError
+// next 为 nil 时使用 http.DefaultTransport
+//
+//	client := &http.Client{Timeout: 5 * time.Second, Transport: breaker.Transport(nil)}
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next}
+}
+
+type transport struct {
+	next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := Get("http:"+req.URL.Host, isHTTPFailure).Allow()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		done(err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		done(&StatusError{Code: resp.StatusCode})
+	default:
+		done(nil)
+	}
+	return resp, err
+}
+
+// isHTTPFailure 传到这里的错误都是连接失败、超时或者 5xx，只有调用方取消的请求不算
+func isHTTPFailure(err error) bool {
+	return !errors.Is(err, context.Canceled)
+}
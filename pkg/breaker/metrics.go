@@ -0,0 +1,23 @@
+package breaker
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	stateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "app",
+		Subsystem: "breaker",
+		Name:      "state",
+		Help:      "熔断器状态，0 closed，1 half-open，2 open",
+	}, []string{"name"})
+	rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "breaker",
+		Name:      "rejected_total",
+		Help:      "被熔断器拒绝的请求数",
+	}, []string{"name"})
+)
+
+// Collectors 熔断指标，由 pkg/metrics 注册（dao 依赖本包，本包不能反过来依赖 metrics）
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{stateGauge, rejected}
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/settings"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound loader 查不到数据时返回它，GetOrLoad 会把"不存在"也缓存起来（负缓存）
+// 这样大量请求不存在的 key 时不会每次都打到 MySQL
+var ErrNotFound = errors.New("cache: not found")
+
+// nullValue 负缓存在 redis 里的占位值
+const nullValue = "__null__"
+
+var (
+	// jitterRatio TTL 随机抖动比例，取值 (0,1]，0.1 表示在 ttl 基础上随机增加 0~10%
+	// 避免同一批写入的 key 在同一时刻集体过期（缓存雪崩）
+	jitterRatio = 0.1
+	// negativeTTL 负缓存的过期时间，不宜太长，否则数据新增后要等很久才能查到
+	negativeTTL = time.Minute
+
+	group singleflight.Group
+
+	hits, negativeHits, misses, loadErrors atomic.Int64
+)
+
+// Stats 缓存命中统计
+type Stats struct {
+	Hits         int64   `json:"hits"`
+	NegativeHits int64   `json:"negative_hits"`
+	Misses       int64   `json:"misses"`
+	LoadErrors   int64   `json:"load_errors"`
+	HitRate      float64 `json:"hit_rate"`
+}
+
+func init() {
+	dashboard.Register("cache", func(ctx context.Context) interface{} {
+		return GetStats()
+	})
+}
+
+// GetStats 进程启动以来的命中统计，负缓存命中也算命中
+func GetStats() Stats {
+	s := Stats{
+		Hits:         hits.Load(),
+		NegativeHits: negativeHits.Load(),
+		Misses:       misses.Load(),
+		LoadErrors:   loadErrors.Load(),
+	}
+	if total := s.Hits + s.NegativeHits + s.Misses; total > 0 {
+		s.HitRate = float64(s.Hits+s.NegativeHits) / float64(total)
+	}
+	return s
+}
+
+func Init(cfg *settings.CacheConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	// 超出 (0,1] 的配置忽略，保留默认值
+	if cfg.JitterRatio > 0 && cfg.JitterRatio <= 1 {
+		jitterRatio = cfg.JitterRatio
+	}
+	if cfg.NegativeTTL > 0 {
+		negativeTTL = time.Duration(cfg.NegativeTTL) * time.Second
+	}
+	return
+}
+
+// GetOrLoad 旁路缓存（cache-aside）
+// 先查 redis，命中直接反序列化返回；未命中调用 loader 从数据源加载，再回写 redis
+// loader 返回 ErrNotFound 时写入负缓存，之后同一个 key 在 negativeTTL 内直接返回 ErrNotFound
+// redis 出错时不影响业务，降级为直接调用 loader
+// 并发未命中由 singleflight 合并，共享的是第一个请求的 ctx，它被取消时同一批等待者都会拿到错误
+func GetOrLoad[T any](ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (v T, err error) {
+	rdb := redis.Ctx(ctx)
+
+	val, err := rdb.Get(key).Result()
+	switch {
+	case err == nil:
+		if val == nullValue {
+			negativeHits.Add(1)
+			return v, ErrNotFound
+		}
+		if err = json.Unmarshal([]byte(val), &v); err == nil {
+			hits.Add(1)
+			return v, nil
+		}
+		// 缓存里的数据格式不对（比如结构体改过字段），当作未命中重新加载
+		logger.Ctx(ctx).Warn("cache unmarshal failed", zap.String("key", key), zap.Error(err))
+	case err != goredis.Nil:
+		logger.Ctx(ctx).Warn("cache get failed", zap.String("key", key), zap.Error(err))
+	}
+
+	misses.Add(1)
+	// 同一个 key 并发未命中时只让一个请求去加载，其它请求等待并共享结果，防止缓存击穿
+	// 合并的 key 带上类型，不同类型的调用方误用同一个缓存 key 时不会拿到别的类型的结果
+	res, err, _ := group.Do(fmt.Sprintf("%T|%s", (*T)(nil), key), func() (interface{}, error) {
+		return load(ctx, rdb, key, ttl, loader)
+	})
+	if err != nil {
+		return v, err
+	}
+	v, _ = res.(T)
+	return v, nil
+}
+
+// load 调用 loader 加载数据并回写缓存
+func load[T any](ctx context.Context, rdb *goredis.Client, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (v T, err error) {
+	v, err = loader(ctx)
+	if errors.Is(err, ErrNotFound) {
+		if e := rdb.Set(key, nullValue, negativeTTL).Err(); e != nil {
+			logger.Ctx(ctx).Warn("cache set null failed", zap.String("key", key), zap.Error(e))
+		}
+		return v, ErrNotFound
+	}
+	if err != nil {
+		loadErrors.Add(1)
+		return
+	}
+
+	data, e := json.Marshal(v)
+	if e != nil {
+		logger.Ctx(ctx).Warn("cache marshal failed", zap.String("key", key), zap.Error(e))
+		return v, nil
+	}
+	if e = rdb.Set(key, data, withJitter(ttl)).Err(); e != nil {
+		logger.Ctx(ctx).Warn("cache set failed", zap.String("key", key), zap.Error(e))
+	}
+	return v, nil
+}
+
+// Delete 删除缓存，数据更新后调用，下次读取时重新加载
+func Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return redis.Ctx(ctx).Del(keys...).Err()
+}
+
+// withJitter 在 ttl 的基础上随机增加 [0, ttl*jitterRatio) 的时间
+func withJitter(ttl time.Duration) time.Duration {
+	if ttl <= 0 || jitterRatio <= 0 {
+		return ttl
+	}
+	max := int64(float64(ttl) * jitterRatio)
+	if max <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(max))
+}
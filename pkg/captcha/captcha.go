@@ -0,0 +1,64 @@
+package captcha
+
+import (
+	"context"
+	"crypto/subtle"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/settings"
+	"strings"
+	"time"
+
+	"github.com/mojocn/base64Captcha"
+)
+
+var (
+	enabled bool
+	ttl     = 5 * time.Minute
+	// driver 纯数字验证码：高 80、宽 240、4 位
+	driver = base64Captcha.NewDriverDigit(80, 240, 4, 0.7, 80)
+)
+
+func Init(cfg *settings.CaptchaConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	enabled = cfg.Enable
+	if cfg.TTL > 0 {
+		ttl = time.Duration(cfg.TTL) * time.Second
+	}
+	if cfg.Length > 0 && cfg.Width > 0 && cfg.Height > 0 {
+		driver = base64Captcha.NewDriverDigit(cfg.Height, cfg.Width, cfg.Length, 0.7, 80)
+	}
+	return
+}
+
+// Enabled 关闭时 RequireCaptcha 中间件直接放行，方便本地开发和压测
+func Enabled() bool {
+	return enabled
+}
+
+// Generate 生成一个图形验证码，image 为 data:image/png;base64 格式，前端可以直接作为 img 的 src
+// 答案保存在 redis，多实例部署时任意实例都可以校验
+func Generate(ctx context.Context) (id, image string, err error) {
+	id, question, answer := driver.GenerateIdQuestionAnswer()
+	item, err := driver.DrawCaptcha(question)
+	if err != nil {
+		return
+	}
+	if err = redis.SaveCaptcha(ctx, id, answer, ttl); err != nil {
+		return
+	}
+	return id, item.EncodeB64string(), nil
+}
+
+// Verify 校验验证码，不论对错验证码都会作废，防止对同一张图片反复尝试
+func Verify(ctx context.Context, id, answer string) (bool, error) {
+	if id == "" || answer == "" {
+		return false, nil
+	}
+	want, err := redis.TakeCaptcha(ctx, id)
+	if err != nil || want == "" {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.ToLower(want)), []byte(strings.ToLower(strings.TrimSpace(answer)))) == 1, nil
+}
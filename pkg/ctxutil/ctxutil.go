@@ -0,0 +1,144 @@
+package ctxutil
+
+import (
+	"context"
+	"time"
+)
+
+// ctxKey 不导出的 key 类型，别的包不可能构造出相同的 key，避免像 gin Keys 那样用字符串互相覆盖
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	userKey
+	tenantKey
+	localeKey
+	deadlineKey
+	clientIPKey
+	timezoneKey
+	apiClientKey
+	routeKey
+)
+
+// User 当前请求的登录用户，由鉴权中间件写入
+type User struct {
+	ID       int64
+	Username string
+}
+
+// APIClient 通过 api key 认证的机器调用方，由 APIKeyAuth 中间件写入
+type APIClient struct {
+	KeyID  int64
+	Name   string
+	Scopes string
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID 取不到时返回空字符串
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithRoute 当前请求匹配的路由，如 "GET /api/v1/users/:id"，慢查询等日志用它定位是哪个接口
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey, route)
+}
+
+func Route(ctx context.Context) string {
+	r, _ := ctx.Value(routeKey).(string)
+	return r
+}
+
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey).(string)
+	return ip
+}
+
+func WithUser(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, userKey, u)
+}
+
+// CurrentUser 未登录时 ok 为 false
+func CurrentUser(ctx context.Context) (u *User, ok bool) {
+	u, ok = ctx.Value(userKey).(*User)
+	return u, ok && u != nil
+}
+
+// UserID 未登录时返回 0
+func UserID(ctx context.Context) int64 {
+	if u, ok := CurrentUser(ctx); ok {
+		return u.ID
+	}
+	return 0
+}
+
+func WithAPIClient(ctx context.Context, c *APIClient) context.Context {
+	return context.WithValue(ctx, apiClientKey, c)
+}
+
+// CurrentAPIClient 不是 api key 认证的请求时 ok 为 false
+func CurrentAPIClient(ctx context.Context) (c *APIClient, ok bool) {
+	c, ok = ctx.Value(apiClientKey).(*APIClient)
+	return c, ok && c != nil
+}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+func Tenant(ctx context.Context) string {
+	t, _ := ctx.Value(tenantKey).(string)
+	return t
+}
+
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey, locale)
+}
+
+// Locale 没有设置时返回 def
+func Locale(ctx context.Context, def string) string {
+	if l, _ := ctx.Value(localeKey).(string); l != "" {
+		return l
+	}
+	return def
+}
+
+func WithTimezone(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, timezoneKey, loc)
+}
+
+// Timezone 没有设置时返回 nil
+func Timezone(ctx context.Context) *time.Location {
+	loc, _ := ctx.Value(timezoneKey).(*time.Location)
+	return loc
+}
+
+// WithDeadline 给 ctx 设置截止时间，同时把截止时间作为元数据记录下来
+// 记录下来的值用于日志和透传给下游服务，真正的取消由 context.WithDeadline 负责
+func WithDeadline(ctx context.Context, d time.Time) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithDeadline(ctx, d)
+	return context.WithValue(ctx, deadlineKey, d), cancel
+}
+
+// Deadline 返回请求声明的截止时间
+func Deadline(ctx context.Context) (d time.Time, ok bool) {
+	d, ok = ctx.Value(deadlineKey).(time.Time)
+	return
+}
+
+// Remaining 距离截止时间还剩多久，没有截止时间时 ok 为 false
+func Remaining(ctx context.Context) (left time.Duration, ok bool) {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(d), true
+}
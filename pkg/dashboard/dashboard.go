@@ -0,0 +1,73 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Provider 返回某个子系统当前的运行状态，结果会被序列化为 JSON
+// 会在管理接口请求时被调用，需要访问外部依赖的请使用 ctx 控制超时
+type Provider func(ctx context.Context) interface{}
+
+var (
+	mu        sync.RWMutex
+	providers = make(map[string]Provider)
+)
+
+// Register 注册运行状态提供者，各子系统在自己的 init 里调用，同名覆盖
+func Register(name string, p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[name] = p
+}
+
+// Names 已注册的子系统名
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Snapshot 并发调用所有提供者，汇总成一份运行状态快照
+// 单个提供者 panic 只影响自己那一项
+func Snapshot(ctx context.Context) map[string]interface{} {
+	mu.RLock()
+	ps := make(map[string]Provider, len(providers))
+	for name, p := range providers {
+		ps[name] = p
+	}
+	mu.RUnlock()
+
+	var (
+		wg     sync.WaitGroup
+		resMu  sync.Mutex
+		result = make(map[string]interface{}, len(ps))
+	)
+	for name, p := range ps {
+		wg.Add(1)
+		go func(name string, p Provider) {
+			defer wg.Done()
+			var v interface{}
+			func() {
+				defer func() {
+					if err := recover(); err != nil {
+						v = map[string]interface{}{"error": fmt.Sprint(err)}
+					}
+				}()
+				v = p(ctx)
+			}()
+			resMu.Lock()
+			result[name] = v
+			resMu.Unlock()
+		}(name, p)
+	}
+	wg.Wait()
+	return result
+}
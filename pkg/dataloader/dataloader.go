@@ -0,0 +1,182 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 把同一个请求里分散的按 key 查询合并成一次批量查询，解决 GraphQL 解析嵌套字段时的 N+1 问题：
+//
+//	users := dataloader.New(func(ctx context.Context, ids []int64) (map[int64]*models.User, error) {
+//		return 按 ids 一次查出来，key 是 id
+//	})
+//	u, err := users.Load(ctx, id)
+//
+// 第一次 Load 之后等待 Wait，期间所有 Load 的 key 合并成一批，攒够 MaxBatch 个时立即查询
+// 结果按 key 缓存在 Loader 上，没有过期和失效，所以 Loader 要每个请求创建一个，不能跨请求共享
+
+// ErrNotFound fetch 返回的结果里没有这个 key
+var ErrNotFound = errors.New("dataloader: not found")
+
+const (
+	defaultWait     = 2 * time.Millisecond
+	defaultMaxBatch = 100
+)
+
+// FetchFunc 批量查询，返回的 map 里没有的 key 按 ErrNotFound 处理
+type FetchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Options 零值使用默认值：等待 2ms，每批最多 100 个
+type Options struct {
+	Wait     time.Duration
+	MaxBatch int
+}
+
+// Loader 一个请求内的批量加载器
+type Loader[K comparable, V any] struct {
+	fetch    FetchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	cache map[K]*result[V]
+	batch *batch[K, V]
+}
+
+type result[V any] struct {
+	done chan struct{}
+	v    V
+	err  error
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	results []*result[V]
+	timer   *time.Timer
+}
+
+// New 创建 Loader，opts 最多取第一个
+func New[K comparable, V any](fetch FetchFunc[K, V], opts ...Options) *Loader[K, V] {
+	l := &Loader[K, V]{
+		fetch:    fetch,
+		wait:     defaultWait,
+		maxBatch: defaultMaxBatch,
+		cache:    make(map[K]*result[V]),
+	}
+	if len(opts) > 0 {
+		if opts[0].Wait > 0 {
+			l.wait = opts[0].Wait
+		}
+		if opts[0].MaxBatch > 0 {
+			l.maxBatch = opts[0].MaxBatch
+		}
+	}
+	return l
+}
+
+// Load 加载一个 key，同一个 key 在 Loader 的生命周期内只查询一次
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	r := l.get(ctx, key)
+	l.mu.Unlock()
+	return wait(ctx, r)
+}
+
+// LoadMany 加载多个 key，所有 key 放进同一批（超过 MaxBatch 时拆成多批），结果和 keys 一一对应
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) ([]V, []error) {
+	rs := make([]*result[V], len(keys))
+	l.mu.Lock()
+	for i, k := range keys {
+		rs[i] = l.get(ctx, k)
+	}
+	l.mu.Unlock()
+
+	vals := make([]V, len(keys))
+	errs := make([]error, len(keys))
+	for i, r := range rs {
+		vals[i], errs[i] = wait(ctx, r)
+	}
+	return vals, errs
+}
+
+func wait[V any](ctx context.Context, r *result[V]) (V, error) {
+	select {
+	case <-r.done:
+		return r.v, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// get 取缓存的结果，没有时加入当前批次，调用方持有 mu
+func (l *Loader[K, V]) get(ctx context.Context, key K) *result[V] {
+	if r, ok := l.cache[key]; ok {
+		return r
+	}
+	r := &result[V]{done: make(chan struct{})}
+	l.cache[key] = r
+
+	b := l.batch
+	if b == nil {
+		b = &batch[K, V]{}
+		b.timer = time.AfterFunc(l.wait, func() { l.dispatch(ctx, b) })
+		l.batch = b
+	}
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, r)
+	if len(b.keys) >= l.maxBatch {
+		b.timer.Stop()
+		l.batch = nil
+		go l.run(ctx, b)
+	}
+	return r
+}
+
+// dispatch 等待时间到了，批次已经因为攒满被取走时什么也不做
+func (l *Loader[K, V]) dispatch(ctx context.Context, b *batch[K, V]) {
+	l.mu.Lock()
+	if l.batch != b {
+		l.mu.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mu.Unlock()
+	l.run(ctx, b)
+}
+
+func (l *Loader[K, V]) run(ctx context.Context, b *batch[K, V]) {
+	vals, err := l.safeFetch(ctx, b.keys)
+	if err != nil {
+		// 失败的 key 不缓存，同一个请求里后面再 Load 会重新查询
+		l.mu.Lock()
+		for _, k := range b.keys {
+			delete(l.cache, k)
+		}
+		l.mu.Unlock()
+	}
+	for i, k := range b.keys {
+		r := b.results[i]
+		if err != nil {
+			r.err = err
+		} else if v, ok := vals[k]; ok {
+			r.v = v
+		} else {
+			r.err = ErrNotFound
+		}
+		close(r.done)
+	}
+}
+
+// safeFetch fetch panic 时转成错误返回，不然等待的 Load 会一直阻塞到 ctx 结束
+func (l *Loader[K, V]) safeFetch(ctx context.Context, keys []K) (vals map[K]V, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("dataloader: fetch panic: %v", p)
+		}
+	}()
+	return l.fetch(ctx, keys)
+}
@@ -0,0 +1,286 @@
+package delay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/settings"
+	"sync"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// 延时任务队列：任务按执行时间存入 ZSET，轮询协程把到期任务分发给对应 topic 的处理函数
+// 例如下单时 Schedule("order:close", orderID, ..., now+30min)，支付成功后 Cancel 掉
+//
+// 每个 topic 使用三个 key：
+//
+//	delay:{topic}             待执行任务 ZSET，score 为执行时间（毫秒）
+//	delay:{topic}:processing  执行中任务 ZSET，score 为可见性超时时间，进程崩溃后超时任务会被放回待执行
+//	delay:{topic}:data        任务内容 HASH，field 为任务 ID
+
+// Task 延时任务
+type Task struct {
+	ID       string          `json:"id"`
+	Topic    string          `json:"-"`
+	Payload  json.RawMessage `json:"payload"`
+	Attempts int             `json:"attempts"`
+}
+
+// Bind 把 Payload 反序列化到 v
+func (t *Task) Bind(v interface{}) error {
+	return json.Unmarshal(t.Payload, v)
+}
+
+// Handler 返回错误时任务按尝试次数退避后重试，超过 maxAttempts 次后丢弃并记录错误日志
+type Handler func(ctx context.Context, task *Task) error
+
+const (
+	popScript     = "delay:pop"
+	requeueScript = "delay:requeue"
+	ackScript     = "delay:ack"
+	retryScript   = "delay:retry"
+)
+
+func init() {
+	// 原子地取出到期任务并移入执行中集合，已被取消（没有任务内容）的直接清理
+	redis.RegisterScript(popScript, `
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+local res = {}
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[1], id)
+	local data = redis.call('HGET', KEYS[3], id)
+	if data then
+		redis.call('ZADD', KEYS[2], ARGV[3], id)
+		table.insert(res, data)
+	end
+end
+return res
+`)
+	// 把可见性超时的执行中任务放回待执行集合
+	redis.RegisterScript(requeueScript, `
+local ids = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('ZADD', KEYS[1], ARGV[1], id)
+end
+return #ids
+`)
+	// 执行结束后只移除这一个任务；执行期间同一个 id 又被 Schedule 过（重新出现在待执行集合里）时保留新的任务内容
+	redis.RegisterScript(ackScript, `
+redis.call('ZREM', KEYS[2], ARGV[1])
+if redis.call('ZSCORE', KEYS[1], ARGV[1]) then
+	return 0
+end
+redis.call('HDEL', KEYS[3], ARGV[1])
+return 1
+`)
+	// 失败后按退避时间放回待执行集合，执行期间被重新 Schedule 过时以新的任务为准，被 Cancel 过时不再重试
+	redis.RegisterScript(retryScript, `
+redis.call('ZREM', KEYS[2], ARGV[1])
+if redis.call('ZSCORE', KEYS[1], ARGV[1]) or redis.call('HEXISTS', KEYS[3], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+return 1
+`)
+}
+
+var (
+	interval    = time.Second
+	batch       = int64(100)
+	visibility  = time.Minute
+	maxAttempts = 5
+)
+
+func Init(cfg *settings.DelayConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	if cfg.Interval > 0 {
+		interval = time.Duration(cfg.Interval) * time.Millisecond
+	}
+	if cfg.Batch > 0 {
+		batch = cfg.Batch
+	}
+	if cfg.Visibility > 0 {
+		visibility = time.Duration(cfg.Visibility) * time.Second
+	}
+	if cfg.MaxAttempts > 0 {
+		maxAttempts = cfg.MaxAttempts
+	}
+	return
+}
+
+func readyKey(topic string) string      { return "delay:" + topic }
+func processingKey(topic string) string { return "delay:" + topic + ":processing" }
+func dataKey(topic string) string       { return "delay:" + topic + ":data" }
+
+func millis(t time.Time) float64 {
+	return float64(t.UnixNano() / int64(time.Millisecond))
+}
+
+// Schedule 在 at 时刻执行 topic 的任务，id 由调用方指定（比如订单号），同一个 id 重复调用会覆盖之前的任务
+func Schedule(ctx context.Context, topic, id string, payload interface{}, at time.Time) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&Task{ID: id, Payload: raw})
+	if err != nil {
+		return err
+	}
+	_, err = redis.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HSet(dataKey(topic), id, data)
+		pipe.ZAdd(readyKey(topic), goredis.Z{Score: millis(at), Member: id})
+		return nil
+	})
+	return err
+}
+
+// ScheduleAfter 在 d 之后执行
+func ScheduleAfter(ctx context.Context, topic, id string, payload interface{}, d time.Duration) error {
+	return Schedule(ctx, topic, id, payload, time.Now().Add(d))
+}
+
+// Cancel 取消尚未执行的任务，任务不存在时不报错
+func Cancel(ctx context.Context, topic, id string) error {
+	_, err := redis.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.ZRem(readyKey(topic), id)
+		pipe.HDel(dataKey(topic), id)
+		return nil
+	})
+	return err
+}
+
+var (
+	mu       sync.Mutex
+	handlers = make(map[string]Handler)
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+)
+
+// Handle 注册 topic 的处理函数，需要在 Start 之前调用
+func Handle(topic string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[topic] = h
+}
+
+// Start 为每个 topic 启动轮询协程
+func Start() {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handlers) == 0 {
+		return
+	}
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	for topic, h := range handlers {
+		wg.Add(1)
+		go func(topic string, h Handler) {
+			defer wg.Done()
+			poll(ctx, topic, h)
+		}(topic, h)
+	}
+}
+
+// Stop 停止轮询并等待正在执行的任务完成
+func Stop() {
+	if cancel == nil {
+		return
+	}
+	cancel()
+	wg.Wait()
+}
+
+func poll(ctx context.Context, topic string, h Handler) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// 一次取满 batch 说明还有积压，继续取不等下一个 tick
+		for ctx.Err() == nil {
+			n, err := dispatch(ctx, topic, h)
+			if err != nil {
+				zap.L().Error("dispatch delay tasks failed", zap.String("topic", topic), zap.Error(err))
+				break
+			}
+			if int64(n) < batch {
+				break
+			}
+		}
+	}
+}
+
+func dispatch(ctx context.Context, topic string, h Handler) (n int, err error) {
+	keys := []string{readyKey(topic), processingKey(topic), dataKey(topic)}
+	now := time.Now()
+	if err = redis.EvalScript(ctx, requeueScript, keys, millis(now)).Err(); err != nil {
+		return
+	}
+	res, err := redis.EvalScript(ctx, popScript, keys, millis(now), batch, millis(now.Add(visibility))).Result()
+	if err != nil {
+		return
+	}
+	items, _ := res.([]interface{})
+	for _, item := range items {
+		data, _ := item.(string)
+		task := new(Task)
+		if err := json.Unmarshal([]byte(data), task); err != nil {
+			zap.L().Error("invalid delay task", zap.String("topic", topic), zap.String("data", data), zap.Error(err))
+			continue
+		}
+		task.Topic = topic
+		run(ctx, h, task)
+	}
+	return len(items), nil
+}
+
+func run(ctx context.Context, h Handler, task *Task) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return h(ctx, task)
+	}()
+
+	topic := task.Topic
+	keys := []string{readyKey(topic), processingKey(topic), dataKey(topic)}
+	if err == nil {
+		if err = redis.EvalScript(ctx, ackScript, keys, task.ID).Err(); err != nil {
+			zap.L().Error("ack delay task failed", zap.String("topic", topic), zap.String("id", task.ID), zap.Error(err))
+		}
+		return
+	}
+
+	task.Attempts++
+	log := zap.L().With(
+		zap.String("topic", topic),
+		zap.String("id", task.ID),
+		zap.Int("attempts", task.Attempts),
+		zap.Error(err))
+	if task.Attempts >= maxAttempts {
+		log.Error("delay task failed, giving up", zap.ByteString("payload", task.Payload))
+		_ = redis.EvalScript(ctx, ackScript, keys, task.ID).Err()
+		return
+	}
+
+	log.Warn("delay task failed, retrying")
+	data, _ := json.Marshal(task)
+	retryAt := time.Now().Add(time.Duration(task.Attempts*task.Attempts) * interval * 10)
+	err = redis.EvalScript(ctx, retryScript, keys, task.ID, data, millis(retryAt)).Err()
+	if err != nil {
+		log.Error("reschedule delay task failed", zap.Time("retry_at", retryAt), zap.NamedError("reschedule_error", err))
+	}
+}
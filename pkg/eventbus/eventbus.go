@@ -0,0 +1,161 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/workerpool"
+	"reflect"
+	"slices"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// 进程内的领域事件总线：业务代码只管发布发生了什么，谁关心谁订阅，互相不用 import
+//
+//	type UserRegistered struct{ *models.User }
+//	func (UserRegistered) EventName() string { return "user.signup" }
+//
+//	eventbus.Subscribe("search", indexUser, eventbus.Async())    // 订阅方在自己的 init 里注册
+//	eventbus.Publish(ctx, UserRegistered{u})                    // 业务数据写成功之后发布
+//
+// 按事件的 Go 类型分发，发布和订阅要用同一个类型（都用值，或者都用指针）
+// 默认在 Publish 的调用方协程里按注册顺序同步执行，适合很快的操作，比如删缓存、写入有界缓冲区；
+// Async 的订阅者交给 workerpool 执行，不阻塞请求，适合调外部服务、发通知
+// 订阅者返回错误或 panic 只记录日志和指标，不影响其它订阅者，也不影响发布方
+//
+// 事件不持久化，进程退出时还没执行、协程池满了提交不进去的异步订阅者就丢了；必须送达的消息用 outbox 或 jobs
+
+// Event 领域事件，EventName 用于日志、指标和持久化
+type Event interface {
+	EventName() string
+}
+
+// Option 订阅选项
+type Option func(*subscriber)
+
+// Async 异步执行，在 workerpool 的 "eventbus" 池里运行
+func Async() Option {
+	return func(s *subscriber) {
+		s.async = true
+	}
+}
+
+type subscriber struct {
+	name  string
+	async bool
+	fn    func(ctx context.Context, e Event) error
+}
+
+var (
+	mu sync.RWMutex
+	// byType 按事件类型注册的订阅者；all 订阅所有事件，比如持久化
+	byType = make(map[reflect.Type][]*subscriber)
+	all    []*subscriber
+)
+
+func init() {
+	dashboard.Register("eventbus", func(ctx context.Context) interface{} {
+		mu.RLock()
+		defer mu.RUnlock()
+		res := make(map[string][]string, len(byType)+1)
+		for t, subs := range byType {
+			res[t.String()] = names(subs)
+		}
+		if len(all) > 0 {
+			res["*"] = names(all)
+		}
+		return res
+	})
+}
+
+func names(subs []*subscriber) []string {
+	list := make([]string, 0, len(subs))
+	for _, s := range subs {
+		if s.async {
+			list = append(list, s.name+" (async)")
+			continue
+		}
+		list = append(list, s.name)
+	}
+	return list
+}
+
+// Subscribe 订阅 E 类型的事件，name 是订阅者的名字，用于日志和指标，需要在启动时调用
+func Subscribe[E Event](name string, fn func(ctx context.Context, e E) error, opts ...Option) {
+	s := newSubscriber(name, func(ctx context.Context, e Event) error {
+		return fn(ctx, e.(E))
+	}, opts)
+	mu.Lock()
+	defer mu.Unlock()
+	t := reflect.TypeFor[E]()
+	byType[t] = append(byType[t], s)
+}
+
+// SubscribeAll 订阅所有事件，在按类型的订阅者之后执行
+func SubscribeAll(name string, fn func(ctx context.Context, e Event) error, opts ...Option) {
+	s := newSubscriber(name, fn, opts)
+	mu.Lock()
+	defer mu.Unlock()
+	all = append(all, s)
+}
+
+func newSubscriber(name string, fn func(ctx context.Context, e Event) error, opts []Option) *subscriber {
+	s := &subscriber{name: name, fn: fn}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Publish 发布事件，同步的订阅者执行完才返回，异步的提交到协程池就返回，池子满了时丢弃并记录日志
+func Publish(ctx context.Context, e Event) {
+	mu.RLock()
+	subs := slices.Concat(byType[reflect.TypeOf(e)], all)
+	mu.RUnlock()
+
+	published.WithLabelValues(e.EventName()).Inc()
+	for _, s := range subs {
+		if !s.async {
+			s.handle(ctx, e)
+			continue
+		}
+		// 池子满了不等，直接丢掉：Publish 在请求里调用，订阅者慢的时候不能把请求也拖住
+		err := workerpool.Get("eventbus").TrySubmit(ctx, func(ctx context.Context) error {
+			s.handle(ctx, e)
+			return nil
+		})
+		if err != nil {
+			handled.WithLabelValues(e.EventName(), s.name, "dropped").Inc()
+			logger.Module(ctx, "eventbus").Warn("event dropped",
+				zap.String("event", e.EventName()),
+				zap.String("subscriber", s.name),
+				zap.Error(err))
+		}
+	}
+}
+
+// handle 执行一个订阅者，错误和 panic 都在这里消化掉
+func (s *subscriber) handle(ctx context.Context, e Event) {
+	result := "success"
+	defer func() {
+		if r := recover(); r != nil {
+			result = "panic"
+			logger.Module(ctx, "eventbus").Error("subscriber panic",
+				zap.String("event", e.EventName()),
+				zap.String("subscriber", s.name),
+				zap.String("error", fmt.Sprint(r)),
+				zap.Stack("stack"))
+		}
+		handled.WithLabelValues(e.EventName(), s.name, result).Inc()
+	}()
+	if err := s.fn(ctx, e); err != nil {
+		result = "error"
+		logger.Module(ctx, "eventbus").Warn("subscriber failed",
+			zap.String("event", e.EventName()),
+			zap.String("subscriber", s.name),
+			zap.Error(err))
+	}
+}
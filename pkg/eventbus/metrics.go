@@ -0,0 +1,23 @@
+package eventbus
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	published = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "eventbus",
+		Name:      "published_total",
+		Help:      "发布的事件数",
+	}, []string{"event"})
+	handled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "eventbus",
+		Name:      "handled_total",
+		Help:      "订阅者处理的事件数，result: success/error/panic/dropped（异步提交失败）",
+	}, []string{"event", "subscriber", "result"})
+)
+
+// Collectors 事件总线指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{published, handled}
+}
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/settings"
+	"sync"
+	"time"
+)
+
+// 就绪检查依赖的外部服务（MySQL、Redis 等）在各自包的 init 里注册检查函数，
+// /readyz 并发执行所有检查，任意一项失败就返回 503，k8s 据此停止向本 Pod 转发流量
+//
+// 检查必须很快，每项都有独立的超时，一个依赖卡住不会拖慢其它检查，也不会让探针本身超时
+
+// Checker 检查某个依赖是否可用，需要访问外部依赖的请使用 ctx 控制超时
+type Checker func(ctx context.Context) error
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Result 单个依赖的检查结果
+type Result struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+const defaultTimeout = time.Second
+
+var (
+	mu       sync.RWMutex
+	checkers = make(map[string]Checker)
+	timeout  = defaultTimeout
+)
+
+// Init 设置每项检查的超时时间
+func Init(cfg *settings.HealthConfig) (err error) {
+	if cfg != nil && cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Millisecond
+	}
+	return
+}
+
+// Register 注册依赖检查，各子系统在自己的 init 里调用，同名覆盖
+func Register(name string, c Checker) {
+	mu.Lock()
+	defer mu.Unlock()
+	checkers[name] = c
+}
+
+// Check 并发执行所有检查，ok 表示全部通过
+// 单个检查 panic 按失败处理
+func Check(ctx context.Context) (ok bool, results map[string]Result) {
+	mu.RLock()
+	cs := make(map[string]Checker, len(checkers))
+	for name, c := range checkers {
+		cs[name] = c
+	}
+	mu.RUnlock()
+
+	var (
+		wg    sync.WaitGroup
+		resMu sync.Mutex
+	)
+	ok = true
+	results = make(map[string]Result, len(cs))
+	for name, c := range cs {
+		wg.Add(1)
+		go func(name string, c Checker) {
+			defer wg.Done()
+			res := run(ctx, c)
+			resMu.Lock()
+			defer resMu.Unlock()
+			results[name] = res
+			if res.Status != StatusUp {
+				ok = false
+			}
+		}(name, c)
+	}
+	wg.Wait()
+	return
+}
+
+func run(ctx context.Context, c Checker) (res Result) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return c(ctx)
+	}()
+	res.Latency = time.Since(start).String()
+	if err != nil {
+		res.Status, res.Error = StatusDown, err.Error()
+		return
+	}
+	res.Status = StatusUp
+	return
+}
@@ -0,0 +1,203 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go_web_scaffolding/pkg/breaker"
+	"go_web_scaffolding/settings"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// 调用第三方接口的公共客户端，业务代码不要自己 new http.Client：
+//
+//	var res struct{ ... }
+//	err := httpclient.GetJSON(ctx, "https://api.example.com/v1/items?id=1", &res)
+//
+// 所有请求共用一个连接池，每个请求都会：
+//
+//   - 按域名使用 http_client.hosts 里的超时，没有配置的使用默认值；重试时每一次单独计时
+//   - 幂等请求（GET、HEAD、PUT、DELETE 等，以及带 Idempotency-Key 头的请求）在连接失败、超时、429 和 5xx 时按退避重试
+//   - 经过 http:<host> 熔断器，熔断打开时直接返回 breaker.ErrOpen
+//   - 记录一个 client span，并把 traceparent 带给对方
+//   - 记录请求数和耗时指标，失败或者慢请求记 warn 日志
+//
+// 日志和指标里只有域名和路径，不记录 query，微信等接口把 secret 放在 query 里
+
+const (
+	defaultTimeout             = 10 * time.Second
+	defaultSlowThreshold       = 3 * time.Second
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 10
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// rule 一个域名的超时和重试
+type rule struct {
+	timeout time.Duration
+	retries int
+	slow    time.Duration
+}
+
+type config struct {
+	base  http.RoundTripper
+	def   rule
+	hosts map[string]rule
+}
+
+func (c *config) rule(host string) rule {
+	if r, ok := c.hosts[host]; ok {
+		return r
+	}
+	return c.def
+}
+
+var (
+	current atomic.Pointer[config]
+	client  = &http.Client{Transport: &transport{}}
+)
+
+func init() {
+	current.Store(newConfig(&settings.HTTPClientConfig{}))
+}
+
+// Init 按配置重建连接池，没有调用时使用默认值
+func Init(cfg *settings.HTTPClientConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	old := current.Swap(newConfig(cfg))
+	if t, ok := old.base.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
+	return
+}
+
+func newConfig(cfg *settings.HTTPClientConfig) *config {
+	pool := http.DefaultTransport.(*http.Transport).Clone()
+	pool.MaxIdleConns = orDefault(cfg.MaxIdleConns, defaultMaxIdleConns)
+	pool.MaxIdleConnsPerHost = orDefault(cfg.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost)
+	pool.IdleConnTimeout = defaultIdleConnTimeout
+	if cfg.IdleConnTimeout > 0 {
+		pool.IdleConnTimeout = time.Duration(cfg.IdleConnTimeout) * time.Second
+	}
+
+	c := &config{
+		base: breaker.Transport(pool),
+		def: rule{
+			timeout: defaultTimeout,
+			slow:    defaultSlowThreshold,
+		},
+		hosts: make(map[string]rule, len(cfg.Hosts)),
+	}
+	c.def = c.def.merge(&cfg.HTTPHostConfig)
+	for host, hc := range cfg.Hosts {
+		if hc != nil {
+			c.hosts[host] = c.def.merge(hc)
+		}
+	}
+	return c
+}
+
+// merge 用 hc 里配置了的字段覆盖 r
+func (r rule) merge(hc *settings.HTTPHostConfig) rule {
+	if hc.Timeout > 0 {
+		r.timeout = time.Duration(hc.Timeout) * time.Millisecond
+	}
+	if hc.Retries > 0 {
+		r.retries = hc.Retries
+	}
+	if hc.SlowThreshold > 0 {
+		r.slow = time.Duration(hc.SlowThreshold) * time.Millisecond
+	}
+	return r
+}
+
+func orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// Client 共享的客户端，可以交给需要 *http.Client 的第三方库（比如 oauth2）
+// 超时由 Transport 按域名控制，不要再设置 Client.Timeout，否则会把所有重试算在一起
+func Client() *http.Client {
+	return client
+}
+
+// Do 发送请求，等同于 Client().Do(req)
+func Do(req *http.Request) (*http.Response, error) {
+	return client.Do(req)
+}
+
+// StatusError 接口返回了非 2xx，Body 是响应体的前 512 字节
+type StatusError struct {
+	Method string
+	URL    string
+	Code   int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpclient: %s %s status %d: %s", e.Method, e.URL, e.Code, e.Body)
+}
+
+// GetJSON 发送 GET 请求并把响应解析到 v，非 2xx 返回 *StatusError
+func GetJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(req, v)
+}
+
+// PostJSON 把 body 编码成 JSON 发送 POST 请求，v 不为 nil 时解析响应，非 2xx 返回 *StatusError
+// POST 默认不重试，对方支持幂等键时用 Do 并设置 Idempotency-Key 头
+func PostJSON(ctx context.Context, url string, body, v interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doJSON(req, v)
+}
+
+func doJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{
+			Method: req.Method,
+			URL:    req.URL.Host + req.URL.Path,
+			Code:   resp.StatusCode,
+			Body:   string(msg),
+		}
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// hostOf 不带端口的域名，用来查配置
+func hostOf(req *http.Request) string {
+	if h, _, err := net.SplitHostPort(req.URL.Host); err == nil {
+		return h
+	}
+	return req.URL.Host
+}
@@ -0,0 +1,30 @@
+package httpclient
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "http_client",
+		Name:      "requests_total",
+		Help:      "调用外部接口的请求数（每次重试单独计数），status 为状态码，连接失败和超时为 error",
+	}, []string{"host", "method", "status"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "app",
+		Subsystem: "http_client",
+		Name:      "request_duration_seconds",
+		Help:      "调用外部接口的耗时，到收到响应头为止",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"host", "method"})
+	retriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "http_client",
+		Name:      "retries_total",
+		Help:      "调用外部接口的重试次数",
+	}, []string{"host", "method"})
+)
+
+// Collectors 外部接口调用指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{requestsTotal, requestDuration, retriesTotal}
+}
@@ -0,0 +1,178 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/retry"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+var tracer = otel.Tracer("go_web_scaffolding/pkg/httpclient")
+
+// retryWait 重试前的等待，外部接口一般几百毫秒内恢复不了，重试次数也不多，不需要等太久
+var retryWait = retry.Policy{Initial: 200 * time.Millisecond, Max: 2 * time.Second, Jitter: 0.2}
+
+// retryStatus 响应是 429 或 5xx，还有重试机会时用它告诉 retry 需要重试
+type retryStatus struct {
+	code int
+}
+
+func (e *retryStatus) Error() string {
+	return "httpclient: status " + strconv.Itoa(e.code)
+}
+
+type transport struct{}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := current.Load()
+	host := hostOf(req)
+	r := c.rule(host)
+	attempts := 1
+	if r.retries > 0 && replayable(req) {
+		attempts = r.retries + 1
+	}
+
+	p := retryWait
+	p.MaxAttempts = attempts
+	p.Retryable = retryable
+	p.OnRetry = func(attempt int, err error, wait time.Duration) {
+		retriesTotal.WithLabelValues(host, req.Method).Inc()
+	}
+	attempt := 0
+	resp, err := retry.DoValue(req.Context(), p, func(ctx context.Context) (*http.Response, error) {
+		attempt++
+		resp, err := t.once(c, req, r, host, attempt)
+		if err != nil || attempt >= attempts || !retry.RetryableStatus(resp.StatusCode) {
+			return resp, err
+		}
+		// 要重试时先把响应读出来关掉，释放连接；如果最后没有重试（ctx 结束了），调用方拿到的仍然是完整的响应
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		return resp, &retryStatus{code: resp.StatusCode}
+	})
+	var rs *retryStatus
+	if errors.As(err, &rs) && resp != nil {
+		return resp, nil
+	}
+	return resp, err
+}
+
+// once 发送一次请求，超时从这里开始计时，到响应体关闭为止
+func (t *transport) once(c *config, req *http.Request, r rule, host string, attempt int) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", host),
+			attribute.String("url.path", req.URL.Path),
+			attribute.Int("http.request.resend_count", attempt-1),
+		))
+	defer span.End()
+
+	cancel := context.CancelFunc(func() {})
+	if r.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+	}
+	out := req.Clone(ctx)
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, retry.Permanent(err)
+		}
+		out.Body = body
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(out.Header))
+
+	start := time.Now()
+	resp, err := c.base.RoundTrip(out)
+	cost := time.Since(start)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	requestsTotal.WithLabelValues(host, req.Method, status).Inc()
+	requestDuration.WithLabelValues(host, req.Method).Observe(cost.Seconds())
+
+	lg := logger.Module(req.Context(), "httpclient")
+	fields := []zap.Field{
+		zap.String("method", req.Method),
+		zap.String("host", host),
+		zap.String("path", req.URL.Path),
+		zap.String("status", status),
+		zap.Duration("cost", cost),
+		zap.Int("attempt", attempt),
+	}
+	if err != nil {
+		cancel()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if !errors.Is(err, context.Canceled) {
+			lg.Warn("http request failed", append(fields, zap.Error(err))...)
+		}
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		span.SetStatus(codes.Error, resp.Status)
+		lg.Warn("http request failed", fields...)
+	case cost > r.slow:
+		lg.Warn("slow http request", fields...)
+	default:
+		lg.Debug("http request", fields...)
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody 响应体关闭时取消单次请求的超时
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// replayable 请求能不能重发：方法是幂等的（或者带了幂等键），请求体可以重新读取
+func replayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// retryable 连接失败、超时、429 和 5xx 重试；URL 不对这类错误重试也没用
+func retryable(err error) bool {
+	if !retry.Temporary(err) {
+		return false
+	}
+	var rs *retryStatus
+	var ne net.Error
+	return errors.As(err, &rs) || errors.As(err, &ne) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
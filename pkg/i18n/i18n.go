@@ -0,0 +1,160 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"go_web_scaffolding/pkg/locale"
+	"go_web_scaffolding/settings"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// 返回给客户端的提示按请求的语言翻译，语言由 middlewares.Locale 解析后放在 context 里
+//
+//	msg := i18n.T(ctx, "bind.type_mismatch", field, typ)     // 翻译后按 fmt.Sprintf 填参数
+//	msg := i18n.Text(ctx, "code.1001", code.Msg())          // 没有翻译时用 fallback
+//
+// 翻译文件是 locales/<语言>.yaml，一个语言一个文件，key 到译文的平铺映射，编译进二进制
+// locale.messages_dir 可以指定一个目录放同样格式的文件，覆盖内置的同名条目，或者增加新的语言
+//
+// 查找顺序：请求的完整语言（zh-tw）> 主语言（zh）> 默认语言；找到了支持的语言就只在它里面找，
+// 找不到 key 时返回 fallback，不会拿另一种语言的译文凑数
+
+//go:embed locales/*.yaml
+var embedded embed.FS
+
+// bundles 语言 -> key -> 译文，语言统一小写，启动时加载完之后只读
+var bundles = make(map[string]map[string]string)
+
+func init() {
+	// 不调用 Init 也能用内置的翻译，比如命令行工具
+	if err := load(embedded, "locales"); err != nil {
+		panic(err)
+	}
+}
+
+// Init 加载 messages_dir 里的翻译文件，需要在 locale.Init 之后调用
+func Init(cfg *settings.LocaleConfig) (err error) {
+	if cfg == nil || cfg.MessagesDir == "" {
+		return
+	}
+	return load(os.DirFS(cfg.MessagesDir), ".")
+}
+
+func load(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		b, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return err
+		}
+		var msgs map[string]string
+		if err = yaml.Unmarshal(b, &msgs); err != nil {
+			return fmt.Errorf("i18n: parse %s: %w", f, err)
+		}
+		lang := strings.ToLower(strings.TrimSuffix(path.Base(f), ".yaml"))
+		if bundles[lang] == nil {
+			bundles[lang] = make(map[string]string, len(msgs))
+		}
+		for k, v := range msgs {
+			bundles[lang][k] = v
+		}
+	}
+	return nil
+}
+
+// Languages 有翻译文件的语言
+func Languages() []string {
+	list := make([]string, 0, len(bundles))
+	for lang := range bundles {
+		list = append(list, lang)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Supported 是否有这个语言（或者它的主语言）的翻译
+func Supported(tag string) bool {
+	return bundle(tag) != nil
+}
+
+// bundle 语言标签对应的翻译，先找完整的标签，再找主语言
+func bundle(tag string) map[string]string {
+	tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	if b, ok := bundles[tag]; ok {
+		return b
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	return bundles[primary]
+}
+
+// current 当前请求使用的翻译，请求的语言没有翻译时用默认语言
+func current(ctx context.Context) map[string]string {
+	if b := bundle(locale.Locale(ctx)); b != nil {
+		return b
+	}
+	return bundle(locale.DefaultLocale())
+}
+
+// Text 按当前请求的语言翻译 key，没有翻译时返回 fallback
+func Text(ctx context.Context, key, fallback string) string {
+	if msg, ok := current(ctx)[key]; ok {
+		return msg
+	}
+	return fallback
+}
+
+// T 按当前请求的语言翻译 key，有参数时按 fmt.Sprintf 格式化；没有翻译时用 key 本身
+func T(ctx context.Context, key string, args ...interface{}) string {
+	msg := Text(ctx, key, key)
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Match 从 Accept-Language 里按权重挑一个有翻译的语言，都没有时返回第一个，请求头为空时返回空
+// Accept-Language: fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5
+func Match(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var list []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			list = append(list, candidate{tag: tag, q: q})
+		}
+	}
+	if len(list) == 0 {
+		return ""
+	}
+	// 权重相同的保持原来的顺序
+	sort.SliceStable(list, func(i, j int) bool { return list[i].q > list[j].q })
+	for _, c := range list {
+		if Supported(c.tag) {
+			return c.tag
+		}
+	}
+	return list[0].tag
+}
@@ -0,0 +1,81 @@
+package idgen
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/settings"
+)
+
+// 业务代码统一通过本包生成 ID，具体用哪种算法由配置 idgen.strategy 决定：
+//
+//	snowflake：默认，本地生成不依赖外部服务，但每个实例要配置不同的 machine_id
+//	segment：  号段模式，从 MySQL 批量取一段号在内存里发，不需要管理机器 ID，ID 连续递增
+//	uuidv7：   按时间排序的 UUID，不需要任何协调，但只能生成字符串，适合主键是 char(36) 的表
+//
+// 用户表这类 bigint 主键调用 NextInt64，当前策略生成不了数字（uuidv7）时退回 snowflake
+
+// Generator ID 生成器
+type Generator interface {
+	// Name 策略名称
+	Name() string
+	// Next 生成字符串形式的 ID，数字 ID 转成十进制字符串
+	Next(ctx context.Context) (string, error)
+}
+
+// NumericGenerator 能生成 int64 ID 的生成器
+type NumericGenerator interface {
+	Generator
+	NextInt64(ctx context.Context) (int64, error)
+}
+
+var (
+	current  Generator = snowflakeGenerator{}
+	fallback           = snowflakeGenerator{}
+)
+
+func init() {
+	dashboard.Register("idgen", func(ctx context.Context) interface{} {
+		status := map[string]interface{}{"strategy": current.Name()}
+		if s, ok := current.(*segmentGenerator); ok {
+			status["segment"] = s.stats()
+		}
+		return status
+	})
+}
+
+// Init 按配置选择生成器，snowflake 需要先初始化
+func Init(cfg *settings.IDGenConfig) (err error) {
+	if cfg == nil || cfg.Strategy == "" {
+		return
+	}
+	switch cfg.Strategy {
+	case "snowflake":
+		current = snowflakeGenerator{}
+	case "uuidv7":
+		current = uuidGenerator{}
+	case "segment":
+		current, err = newSegmentGenerator(cfg)
+	default:
+		err = fmt.Errorf("idgen: unknown strategy %q", cfg.Strategy)
+	}
+	return
+}
+
+// Next 用当前策略生成一个字符串 ID
+func Next(ctx context.Context) (string, error) {
+	return current.Next(ctx)
+}
+
+// NextInt64 用当前策略生成一个数字 ID，当前策略不支持数字时用 snowflake
+func NextInt64(ctx context.Context) (int64, error) {
+	if g, ok := current.(NumericGenerator); ok {
+		return g.NextInt64(ctx)
+	}
+	return fallback.NextInt64(ctx)
+}
+
+// Strategy 当前使用的策略名称
+func Strategy() string {
+	return current.Name()
+}
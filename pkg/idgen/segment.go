@@ -0,0 +1,145 @@
+package idgen
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/pkg/workerpool"
+	"go_web_scaffolding/settings"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBizTag = "default"
+	// preloadRatio 当前号段剩余不足 step 的这个比例时，后台预取下一个号段（双 buffer）
+	// 取号段只是一次 MySQL 事务，正常情况下用完之前早就取回来了，发号不会被 MySQL 卡住
+	preloadRatio = 0.2
+	allocTimeout = 5 * time.Second
+)
+
+// segment 内存里的一个号段，可发的 ID 为 [next, end)，size 为号段长度
+// 右边不包含，零值的号段剩余为 0，不会发出 ID 0
+type segment struct {
+	next int64
+	end  int64
+	size int64
+}
+
+func (s *segment) remaining() int64 {
+	return s.end - s.next
+}
+
+// segmentGenerator 号段模式（美团 Leaf-segment 的思路）
+type segmentGenerator struct {
+	bizTag string
+	step   int64 // 配置的步长，0 表示使用表里的 step
+
+	mu      sync.Mutex
+	cur     segment
+	buf     *segment
+	loading bool
+}
+
+func newSegmentGenerator(cfg *settings.IDGenConfig) (g *segmentGenerator, err error) {
+	g = &segmentGenerator{bizTag: cfg.BizTag, step: cfg.Step}
+	if g.bizTag == "" {
+		g.bizTag = defaultBizTag
+	}
+	// 启动时就取第一个号段，表里没有这个 biz_tag 时直接报错
+	ctx, cancel := context.WithTimeout(context.Background(), allocTimeout)
+	defer cancel()
+	seg, err := g.alloc(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g.cur = seg
+	return g, nil
+}
+
+func (g *segmentGenerator) Name() string {
+	return "segment"
+}
+
+func (g *segmentGenerator) Next(ctx context.Context) (string, error) {
+	id, err := g.NextInt64(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (g *segmentGenerator) NextInt64(ctx context.Context) (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.cur.remaining() <= 0 {
+		if g.buf != nil {
+			g.cur, g.buf = *g.buf, nil
+		} else {
+			// 预取没赶上（或者失败了），只能同步去取，持有锁期间其它请求一起等着
+			seg, err := g.alloc(ctx)
+			if err != nil {
+				return 0, err
+			}
+			g.cur = seg
+		}
+	}
+
+	id := g.cur.next
+	g.cur.next++
+	if g.buf == nil && !g.loading && float64(g.cur.remaining()) < float64(g.cur.size)*preloadRatio {
+		g.loading = true
+		// 池子满了或者正在关机就不预取了，号段用完时同步去取
+		if err := workerpool.Get("idgen").TrySubmit(ctx, g.preload); err != nil {
+			g.loading = false
+		}
+	}
+	return id, nil
+}
+
+// preload 后台预取下一个号段，超时由协程池控制
+func (g *segmentGenerator) preload(ctx context.Context) error {
+	seg, err := g.alloc(ctx)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.loading = false
+	if err != nil {
+		return fmt.Errorf("idgen preload segment %q: %w", g.bizTag, err)
+	}
+	if g.buf == nil {
+		g.buf = &seg
+	}
+	return nil
+}
+
+func (g *segmentGenerator) alloc(ctx context.Context) (segment, error) {
+	s, err := mysql.AllocIDSegment(ctx, g.bizTag, g.step)
+	if errors.Is(err, sql.ErrNoRows) {
+		return segment{}, fmt.Errorf("idgen: biz_tag %q not found in id_segment", g.bizTag)
+	}
+	if err != nil {
+		return segment{}, err
+	}
+	if s.Step <= 0 {
+		return segment{}, fmt.Errorf("idgen: biz_tag %q has non-positive step %d", g.bizTag, s.Step)
+	}
+	// 更新前的 max_id 已经被之前的号段发出去了，这次从它的下一个开始，到更新后的 max_id 为止
+	start := s.MaxID - s.Step + 1
+	return segment{next: start, end: s.MaxID + 1, size: s.Step}, nil
+}
+
+func (g *segmentGenerator) stats() map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return map[string]interface{}{
+		"biz_tag":   g.bizTag,
+		"max_id":    g.cur.end - 1,
+		"remaining": g.cur.remaining(),
+		"buffered":  g.buf != nil,
+	}
+}
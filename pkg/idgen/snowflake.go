@@ -0,0 +1,26 @@
+package idgen
+
+import (
+	"context"
+	"go_web_scaffolding/pkg/snowflake"
+	"strconv"
+)
+
+// snowflakeGenerator 包装 pkg/snowflake
+type snowflakeGenerator struct{}
+
+func (snowflakeGenerator) Name() string {
+	return "snowflake"
+}
+
+func (g snowflakeGenerator) Next(ctx context.Context) (string, error) {
+	id, err := g.NextInt64(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (snowflakeGenerator) NextInt64(ctx context.Context) (int64, error) {
+	return snowflake.GenID(), nil
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// 死信：重试次数用完或者永久失败的任务，asynq 会一直保留，修复问题后可以重新执行
+// 管理接口只返回任务的元信息和 payload 的大小，不返回 payload 本身，里面可能有邮件正文这类敏感内容
+
+// ErrNotFound 队列或任务不存在
+var ErrNotFound = errors.New("jobs: task not found")
+
+// DeadLetter 死信任务
+type DeadLetter struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Queue string `json:"queue"`
+	// PayloadSize 业务 payload 的字节数
+	PayloadSize int       `json:"payload_size"`
+	Retried     int       `json:"retried"`
+	LastErr     string    `json:"last_err"`
+	FailedAt    time.Time `json:"failed_at"`
+}
+
+// DeadLetters 分页列出队列里的死信，page 从 1 开始
+func DeadLetters(queue string, page, size int) (list []*DeadLetter, err error) {
+	if !enabled {
+		return nil, ErrDisabled
+	}
+	tasks, err := inspector.ListArchivedTasks(queue, asynq.Page(page), asynq.PageSize(size))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	list = make([]*DeadLetter, 0, len(tasks))
+	for _, t := range tasks {
+		d := &DeadLetter{
+			ID:       t.ID,
+			Type:     t.Type,
+			Queue:    t.Queue,
+			Retried:  t.Retried,
+			LastErr:  t.LastErr,
+			FailedAt: t.LastFailedAt,
+		}
+		var env envelope
+		if json.Unmarshal(t.Payload, &env) == nil {
+			d.PayloadSize = len(env.Payload)
+		}
+		list = append(list, d)
+	}
+	return
+}
+
+// RetryDeadLetter 把死信放回队列立即执行，重试次数不会重置
+func RetryDeadLetter(queue, id string) error {
+	if !enabled {
+		return ErrDisabled
+	}
+	return notFound(inspector.RunTask(queue, id))
+}
+
+// DeleteDeadLetter 删除死信
+func DeleteDeadLetter(queue, id string) error {
+	if !enabled {
+		return ErrDisabled
+	}
+	return notFound(inspector.DeleteTask(queue, id))
+}
+
+func notFound(err error) error {
+	if errors.Is(err, asynq.ErrQueueNotFound) || errors.Is(err, asynq.ErrTaskNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
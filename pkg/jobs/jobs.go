@@ -0,0 +1,266 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/retry"
+	"go_web_scaffolding/settings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+)
+
+// 后台任务队列，基于 asynq，任务存在 redis 里，进程重启不会丢：
+//
+//	jobs.Register(TypeSendEmail, func(ctx context.Context, t *jobs.Task) error { ... })  // Start 之前注册
+//	jobs.Enqueue(ctx, TypeSendEmail, payload)                                              // 请求里入队，立即返回
+//	jobs.Enqueue(ctx, typ, payload, asynq.ProcessIn(time.Minute), asynq.Queue("low"))      // 延后执行、指定队列
+//
+// 处理函数返回错误时按指数退避重试（10 秒起步，最长 1 小时），次数用完或者返回 retry.Permanent
+// 包装的错误时任务进入死信（asynq 里叫 archived），不会自动删除，在管理后台查看、重新执行或删除
+//
+// 和 delay 的区别：delay 是按业务 ID 可取消的定时任务，jobs 是"尽快做完、失败重试"的异步任务
+// asynq 用自己的 go-redis v9 客户端，连接的是启动时 redis 段的地址，不跟随 profile 切换
+
+// Task 交给处理函数的任务
+type Task struct {
+	ID      string
+	Type    string
+	Payload json.RawMessage
+	// Retried 已经重试过的次数，第一次执行时为 0
+	Retried  int
+	MaxRetry int
+}
+
+// Bind 把 Payload 反序列化到 v
+func (t *Task) Bind(v interface{}) error {
+	return json.Unmarshal(t.Payload, v)
+}
+
+// Handler 返回 nil 表示完成，其它错误按退避重试，retry.Permanent 包装的错误直接进入死信
+type Handler func(ctx context.Context, t *Task) error
+
+// envelope 存在 redis 里的任务内容，带上入队请求的 request_id，日志能串起来
+type envelope struct {
+	RequestID string          `json:"request_id,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// ErrDisabled 没有开启任务队列
+var ErrDisabled = errors.New("jobs: disabled")
+
+// backoff 重试间隔
+var backoff = retry.Policy{Initial: 10 * time.Second, Max: time.Hour, Jitter: 0.2}
+
+var (
+	enabled   bool
+	client    *asynq.Client
+	inspector *asynq.Inspector
+	server    *asynq.Server
+	mux       = asynq.NewServeMux()
+	defaults  []asynq.Option
+)
+
+// Init 连接 redis 并创建客户端，开启 worker 时同时创建 server，需要在 redis.Init 之后调用
+// 未开启时不做任何事，Enqueue 返回 ErrDisabled
+func Init(cfg *settings.JobsConfig) (err error) {
+	if cfg == nil || !cfg.Enable {
+		return
+	}
+	rdb := redis.Client()
+	if rdb == nil {
+		return errors.New("jobs: redis not initialized")
+	}
+	o := rdb.Options()
+	opt := asynq.RedisClientOpt{Addr: o.Addr, Password: o.Password, DB: o.DB}
+
+	// 不指定队列的任务都进 default，没有配置它的话这些任务永远不会被处理
+	queues := map[string]int{"default": 1}
+	for q, w := range cfg.Queues {
+		queues[q] = w
+	}
+	maxRetry := cfg.MaxRetry
+	if maxRetry <= 0 {
+		maxRetry = 10
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 60
+	}
+	defaults = []asynq.Option{asynq.MaxRetry(maxRetry), asynq.Timeout(time.Duration(timeout) * time.Second)}
+	if cfg.Retention > 0 {
+		defaults = append(defaults, asynq.Retention(time.Duration(cfg.Retention)*time.Hour))
+	}
+
+	client = asynq.NewClient(opt)
+	inspector = asynq.NewInspector(opt)
+	if cfg.Worker {
+		concurrency := cfg.Concurrency
+		if concurrency <= 0 {
+			concurrency = 10
+		}
+		server = asynq.NewServer(opt, asynq.Config{
+			Concurrency:     concurrency,
+			Queues:          queues,
+			RetryDelayFunc:  retryDelay,
+			ErrorHandler:    asynq.ErrorHandlerFunc(handleError),
+			Logger:          zap.L().Named("jobs").Sugar(),
+			ShutdownTimeout: shutdownTimeout(),
+		})
+	}
+	dashboard.Register("jobs", func(ctx context.Context) interface{} {
+		return stats()
+	})
+	enabled = true
+	return
+}
+
+// shutdownTimeout 关机时等待执行中的任务的时间，和 HTTP 服务的优雅关闭时间一致
+// 超时没做完的任务会被放回队列，由其它实例重新执行，所以处理函数要能重复执行
+func shutdownTimeout() time.Duration {
+	if c := settings.Conf.ShutdownConfig; c != nil && c.Timeout > 0 {
+		return time.Duration(c.Timeout) * time.Second
+	}
+	return 0
+}
+
+// Register 注册任务类型的处理函数，需要在 Start 之前调用
+func Register(typ string, h Handler) {
+	mux.HandleFunc(typ, wrap(typ, h))
+}
+
+// Enqueue 入队，返回任务 ID；opts 可以覆盖默认的队列、重试次数和超时，比如 asynq.Queue("critical")
+func Enqueue(ctx context.Context, typ string, payload interface{}, opts ...asynq.Option) (id string, err error) {
+	if !enabled {
+		return "", ErrDisabled
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(&envelope{RequestID: ctxutil.RequestID(ctx), Payload: raw})
+	if err != nil {
+		return
+	}
+	info, err := client.EnqueueContext(ctx, asynq.NewTask(typ, data), append(defaults[:len(defaults):len(defaults)], opts...)...)
+	if err != nil {
+		return "", fmt.Errorf("jobs: enqueue %s: %w", typ, err)
+	}
+	enqueued.WithLabelValues(typ).Inc()
+	return info.ID, nil
+}
+
+// Enabled 是否开启了任务队列
+func Enabled() bool {
+	return enabled
+}
+
+// Start 开始处理任务，没有开启 worker 时不做任何事
+func Start() {
+	if server == nil {
+		return
+	}
+	if err := server.Start(mux); err != nil {
+		zap.L().Error("jobs start worker failed", zap.Error(err))
+	}
+}
+
+// Stop 停止取新任务，等待执行中的任务完成（最多 shutdown.timeout），然后关闭连接
+func Stop() {
+	if server != nil {
+		server.Shutdown()
+	}
+	if client != nil {
+		_ = client.Close()
+		_ = inspector.Close()
+	}
+}
+
+func wrap(typ string, h Handler) asynq.HandlerFunc {
+	return func(ctx context.Context, t *asynq.Task) error {
+		var env envelope
+		if err := json.Unmarshal(t.Payload(), &env); err != nil {
+			return fmt.Errorf("invalid payload: %v: %w", err, asynq.SkipRetry)
+		}
+		if env.RequestID != "" {
+			ctx = ctxutil.WithRequestID(ctx, env.RequestID)
+		}
+		task := &Task{Type: typ, Payload: env.Payload}
+		task.ID, _ = asynq.GetTaskID(ctx)
+		task.Retried, _ = asynq.GetRetryCount(ctx)
+		task.MaxRetry, _ = asynq.GetMaxRetry(ctx)
+
+		start := time.Now()
+		err := h(ctx, task)
+		duration.WithLabelValues(typ).Observe(time.Since(start).Seconds())
+		if err != nil {
+			processed.WithLabelValues(typ, "failure").Inc()
+			if retry.IsPermanent(err) {
+				return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+			}
+			return err
+		}
+		processed.WithLabelValues(typ, "success").Inc()
+		return nil
+	}
+}
+
+func retryDelay(n int, err error, t *asynq.Task) time.Duration {
+	return backoff.Backoff(n + 1)
+}
+
+// handleError 每次失败都会调用，重试次数用完时任务进入死信，记 error 日志
+func handleError(ctx context.Context, t *asynq.Task, err error) {
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	id, _ := asynq.GetTaskID(ctx)
+	queue, _ := asynq.GetQueueName(ctx)
+	fields := []zap.Field{
+		zap.String("type", t.Type()),
+		zap.String("id", id),
+		zap.String("queue", queue),
+		zap.Int("retried", retried),
+		zap.Error(err),
+	}
+	log := logger.Module(ctx, "jobs")
+	if retried >= maxRetry || errors.Is(err, asynq.SkipRetry) {
+		dead.WithLabelValues(t.Type()).Inc()
+		log.Error("task moved to dead letter", fields...)
+		return
+	}
+	log.Warn("task failed, will retry", fields...)
+}
+
+func stats() interface{} {
+	// 还没有入队过任务的队列在 redis 里不存在，不用查
+	existing, err := inspector.Queues()
+	if err != nil {
+		return map[string]interface{}{"worker": server != nil, "error": err.Error()}
+	}
+	res := make(map[string]interface{}, len(existing))
+	for _, q := range existing {
+		info, err := inspector.GetQueueInfo(q)
+		if err != nil {
+			res[q] = map[string]interface{}{"error": err.Error()}
+			continue
+		}
+		res[q] = map[string]interface{}{
+			"pending":   info.Pending,
+			"active":    info.Active,
+			"scheduled": info.Scheduled,
+			"retry":     info.Retry,
+			"dead":      info.Archived,
+			"processed": info.Processed,
+			"failed":    info.Failed,
+			"paused":    info.Paused,
+		}
+	}
+	return map[string]interface{}{"worker": server != nil, "queues": res}
+}
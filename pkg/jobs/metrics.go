@@ -0,0 +1,36 @@
+package jobs
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	enqueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "jobs",
+		Name:      "enqueued_total",
+		Help:      "入队的后台任务数",
+	}, []string{"type"})
+	processed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "jobs",
+		Name:      "processed_total",
+		Help:      "执行的后台任务数（每次重试单独计数），result: success/failure",
+	}, []string{"type", "result"})
+	duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "app",
+		Subsystem: "jobs",
+		Name:      "duration_seconds",
+		Help:      "后台任务的执行耗时",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"type"})
+	dead = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "jobs",
+		Name:      "dead_total",
+		Help:      "进入死信的后台任务数",
+	}, []string{"type"})
+)
+
+// Collectors 后台任务指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{enqueued, processed, duration, dead}
+}
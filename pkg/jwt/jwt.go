@@ -0,0 +1,162 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"go_web_scaffolding/settings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 双 token 方案：
+// access token 有效期短，每次请求放在 Authorization: Bearer 头里；
+// refresh token 有效期长，只用来调用 /refresh 换取新的一对 token
+
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrTokenType      = errors.New("token type mismatch")
+	secret            []byte
+	issuer            = "go_web_scaffolding"
+	accessTokenTTL    = 2 * time.Hour
+	refreshTokenTTL   = 7 * 24 * time.Hour
+	singleSession     bool
+	errSecretNotFound = errors.New("jwt secret not configured")
+)
+
+// MyClaims 自定义声明结构体并内嵌 jwt.RegisteredClaims
+// jwt 包自带的 RegisteredClaims 只包含了官方字段，我们这里额外记录 user_id、username 和 token 类型
+type MyClaims struct {
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	TokenType string `json:"token_type"`
+	// SessionID 一次登录产生的会话 ID，刷新 token 时保持不变，用于单点登录时判断是否被挤下线
+	SessionID string `json:"sid"`
+	// Generation 签发时用户 token 的代数，改密码后代数加一，之前签发的 token 都作废
+	Generation int64 `json:"gen,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func Init(cfg *settings.JWTConfig) (err error) {
+	if cfg == nil || cfg.Secret == "" {
+		return errSecretNotFound
+	}
+	secret = []byte(cfg.Secret)
+	if cfg.Issuer != "" {
+		issuer = cfg.Issuer
+	}
+	if cfg.AccessTTL > 0 {
+		accessTokenTTL = time.Duration(cfg.AccessTTL) * time.Second
+	}
+	if cfg.RefreshTTL > 0 {
+		refreshTokenTTL = time.Duration(cfg.RefreshTTL) * time.Second
+	}
+	singleSession = cfg.SingleSession
+	return
+}
+
+// SingleSession 是否开启单设备登录：新登录会让该用户之前签发的所有 token 失效
+func SingleSession() bool {
+	return singleSession
+}
+
+// RefreshTokenTTL refresh token 的有效期，也是一次登录会话的最长有效期
+func RefreshTokenTTL() time.Duration {
+	return refreshTokenTTL
+}
+
+// GenToken 登录时调用，开启一个新的会话并生成一对 access token 和 refresh token，gen 为用户 token 当前的代数
+func GenToken(userID int64, username string, gen int64) (aToken, rToken, sessionID string, err error) {
+	sessionID = newJTI()
+	aToken, rToken, err = genPair(userID, username, sessionID, gen)
+	return
+}
+
+// RenewToken 刷新时调用，沿用原来的会话 ID 和代数生成新的一对 token
+func RenewToken(mc *MyClaims) (aToken, rToken string, err error) {
+	return genPair(mc.UserID, mc.Username, mc.SessionID, mc.Generation)
+}
+
+func genPair(userID int64, username, sessionID string, gen int64) (aToken, rToken string, err error) {
+	if aToken, err = genToken(userID, username, sessionID, gen, TypeAccess, accessTokenTTL); err != nil {
+		return
+	}
+	rToken, err = genToken(userID, username, sessionID, gen, TypeRefresh, refreshTokenTTL)
+	return
+}
+
+func genToken(userID int64, username, sessionID string, gen int64, typ string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	c := MyClaims{
+		UserID:     userID,
+		Username:   username,
+		TokenType:  typ,
+		SessionID:  sessionID,
+		Generation: gen,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newJTI(),
+			Issuer:    issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	// 使用指定的签名方法创建签名对象，使用指定的 secret 签名并获得完整的编码后的字符串 token
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(secret)
+}
+
+// newJTI token 的唯一 ID，用于注销时加入黑名单
+func newJTI() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ParseToken 解析并校验 access token
+func ParseToken(tokenString string) (*MyClaims, error) {
+	return parse(tokenString, TypeAccess)
+}
+
+// ParseRefreshToken 解析并校验 refresh token
+func ParseRefreshToken(tokenString string) (*MyClaims, error) {
+	return parse(tokenString, TypeRefresh)
+}
+
+func parse(tokenString, typ string) (*MyClaims, error) {
+	mc := new(MyClaims)
+	token, err := jwt.ParseWithClaims(tokenString, mc, func(token *jwt.Token) (interface{}, error) {
+		return secret, nil
+	},
+		jwt.WithIssuer(issuer),
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	if mc.TokenType != typ {
+		return nil, ErrTokenType
+	}
+	return mc, nil
+}
+
+type ctxKey struct{}
+
+// WithClaims 把当前请求的 token 声明放进 ctx，由鉴权中间件调用
+func WithClaims(ctx context.Context, mc *MyClaims) context.Context {
+	return context.WithValue(ctx, ctxKey{}, mc)
+}
+
+// ClaimsFromContext 取出当前请求的 token 声明
+func ClaimsFromContext(ctx context.Context) (mc *MyClaims, ok bool) {
+	mc, ok = ctx.Value(ctxKey{}).(*MyClaims)
+	return mc, ok && mc != nil
+}
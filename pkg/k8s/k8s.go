@@ -0,0 +1,75 @@
+package k8s
+
+import (
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// PodInfo 通过 Downward API 注入的 Pod 元数据，不在 k8s 里运行时全部为空
+//
+//	env:
+//	  - name: POD_NAME
+//	    valueFrom: {fieldRef: {fieldPath: metadata.name}}
+//	  - name: POD_NAMESPACE
+//	    valueFrom: {fieldRef: {fieldPath: metadata.namespace}}
+//	  - name: POD_IP
+//	    valueFrom: {fieldRef: {fieldPath: status.podIP}}
+//	  - name: NODE_NAME
+//	    valueFrom: {fieldRef: {fieldPath: spec.nodeName}}
+type PodInfo struct {
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	Node      string `json:"node,omitempty"`
+}
+
+var pod = PodInfo{
+	Name:      os.Getenv("POD_NAME"),
+	Namespace: os.Getenv("POD_NAMESPACE"),
+	IP:        os.Getenv("POD_IP"),
+	Node:      os.Getenv("NODE_NAME"),
+}
+
+// Pod 返回当前 Pod 的元数据
+func Pod() PodInfo {
+	return pod
+}
+
+// InCluster 是否运行在 k8s 中
+func InCluster() bool {
+	return pod.Name != "" || os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+}
+
+// Fields 作为日志公共字段附加到每一行日志上，多副本排查问题时能区分是哪个 Pod 打的
+func Fields() []zap.Field {
+	var fields []zap.Field
+	if pod.Name != "" {
+		fields = append(fields, zap.String("pod", pod.Name))
+	}
+	if pod.Namespace != "" {
+		fields = append(fields, zap.String("namespace", pod.Namespace))
+	}
+	if pod.Node != "" {
+		fields = append(fields, zap.String("node", pod.Node))
+	}
+	return fields
+}
+
+// Attributes 以 OpenTelemetry 资源属性的命名返回 Pod 元数据，给链路追踪、指标等模块使用
+func Attributes() map[string]string {
+	attrs := make(map[string]string)
+	if pod.Name != "" {
+		attrs["k8s.pod.name"] = pod.Name
+	}
+	if pod.Namespace != "" {
+		attrs["k8s.namespace.name"] = pod.Namespace
+	}
+	if pod.IP != "" {
+		attrs["k8s.pod.ip"] = pod.IP
+	}
+	if pod.Node != "" {
+		attrs["k8s.node.name"] = pod.Node
+	}
+	return attrs
+}
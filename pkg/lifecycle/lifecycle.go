@@ -0,0 +1,37 @@
+package lifecycle
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	lameDuck  atomic.Bool
+	drainOnce sync.Once
+	drained   = make(chan struct{})
+)
+
+// IsLameDuck 是否已进入跛脚鸭状态，此时就绪检查返回失败，但服务仍然正常处理请求
+func IsLameDuck() bool {
+	return lameDuck.Load()
+}
+
+// Drain 进入跛脚鸭状态并等待 d，让 k8s 有时间把 Pod 从 Service 的 Endpoints 里摘掉，
+// 摘掉之前还在路由过来的请求照常处理，等待结束后再关闭 server 就不会有请求被拒绝
+//
+// preStop 钩子和 SIGTERM 都会调用它，只有第一次调用真正等待，
+// 之后的调用等第一次结束即返回，所以 preStop 里已经等过的话收到 SIGTERM 后可以立即关闭
+func Drain(d time.Duration) {
+	drainOnce.Do(func() {
+		lameDuck.Store(true)
+		zap.L().Info("enter lame duck mode", zap.Duration("drain", d))
+		go func() {
+			time.Sleep(d)
+			close(drained)
+		}()
+	})
+	<-drained
+}
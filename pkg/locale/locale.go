@@ -0,0 +1,150 @@
+package locale
+
+import (
+	"context"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/settings"
+	"strconv"
+	"strings"
+	"time"
+	// 编译进时区数据库（约 450KB），scratch、distroless 镜像里没有 /usr/share/zoneinfo，
+	// 否则 time.LoadLocation 除了 UTC 全部失败
+	_ "time/tzdata"
+)
+
+// 每个请求的语言和时区由 middlewares.Locale 解析后放进 context，
+// 返回给客户端的时间、数字通过本包的函数按用户的时区和语言格式化，而不是统一用服务器本地时间
+
+// Preference 用户在个人资料里保存的偏好
+type Preference struct {
+	Locale   string
+	Timezone string
+}
+
+var (
+	defaultLocale   = "zh-CN"
+	defaultLocation = time.Local
+
+	// PreferenceFunc 按用户 ID 读取个人偏好，由用户模块注册，未注册时跳过
+	PreferenceFunc func(ctx context.Context, userID int64) (Preference, bool)
+	// GeoIPFunc 按客户端 IP 推断时区，由部署方接入 GeoIP 数据库后注册，未注册时跳过
+	GeoIPFunc func(ip string) (timezone string, ok bool)
+)
+
+func Init(cfg *settings.LocaleConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	if cfg.DefaultLocale != "" {
+		defaultLocale = cfg.DefaultLocale
+	}
+	if cfg.DefaultTimezone != "" {
+		if defaultLocation, err = time.LoadLocation(cfg.DefaultTimezone); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// DefaultLocale 配置的默认语言
+func DefaultLocale() string {
+	return defaultLocale
+}
+
+// DefaultLocation 配置的默认时区
+func DefaultLocation() *time.Location {
+	return defaultLocation
+}
+
+// Locale 当前请求的语言
+func Locale(ctx context.Context) string {
+	return ctxutil.Locale(ctx, defaultLocale)
+}
+
+// Lang 当前请求语言的主标签，如 zh-CN 返回 zh
+func Lang(ctx context.Context) string {
+	return lang(Locale(ctx))
+}
+
+// Location 当前请求的时区
+func Location(ctx context.Context) *time.Location {
+	if loc := ctxutil.Timezone(ctx); loc != nil {
+		return loc
+	}
+	return defaultLocation
+}
+
+// In 把时间转换到当前请求的时区
+func In(ctx context.Context, t time.Time) time.Time {
+	return t.In(Location(ctx))
+}
+
+// FormatTime 按当前请求的时区和语言格式化时间
+func FormatTime(ctx context.Context, t time.Time) string {
+	t = In(ctx, t)
+	switch lang(Locale(ctx)) {
+	case "zh", "ja":
+		return t.Format("2006-01-02 15:04:05")
+	case "en":
+		if Locale(ctx) == "en-US" {
+			return t.Format("01/02/2006 3:04:05 PM")
+		}
+		return t.Format("02/01/2006 15:04:05")
+	default:
+		return t.Format("02.01.2006 15:04:05")
+	}
+}
+
+// FormatDate 按当前请求的时区和语言格式化日期
+func FormatDate(ctx context.Context, t time.Time) string {
+	t = In(ctx, t)
+	switch lang(Locale(ctx)) {
+	case "zh", "ja":
+		return t.Format("2006-01-02")
+	case "en":
+		if Locale(ctx) == "en-US" {
+			return t.Format("01/02/2006")
+		}
+		return t.Format("02/01/2006")
+	default:
+		return t.Format("02.01.2006")
+	}
+}
+
+// FormatNumber 按当前请求的语言格式化数字，decimals 为保留的小数位数
+// 例如 1234567.891 在 zh/en 下为 1,234,567.89，在 de 下为 1.234.567,89，在 fr 下为 1 234 567,89
+func FormatNumber(ctx context.Context, n float64, decimals int) string {
+	group, point := ",", "."
+	switch lang(Locale(ctx)) {
+	case "de", "es", "it", "id", "pt", "tr":
+		group, point = ".", ","
+	case "fr", "ru", "pl", "cs":
+		group, point = " ", ","
+	}
+
+	s := strconv.FormatFloat(n, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(r)
+	}
+	if fracPart != "" {
+		b.WriteString(point)
+		b.WriteString(fracPart)
+	}
+	return sign + b.String()
+}
+
+// lang 取语言标签的主语言部分，zh-CN -> zh
+func lang(locale string) string {
+	l, _, _ := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	return strings.ToLower(l)
+}
@@ -0,0 +1,180 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/settings"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrLocked 账号或 IP 被临时锁定，用 errors.As 取出 *LockedError 拿到剩余时间
+var ErrLocked = errors.New("login locked")
+
+// LockedError 锁定的对象和剩余时间
+type LockedError struct {
+	Scope      string
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("login locked by %s, retry after %s", e.Scope, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+const (
+	scopeAccount = "account"
+	scopeIP      = "ip"
+)
+
+var (
+	// maxAccountFailures 同一账号在窗口期内允许的失败次数，达到后锁定账号
+	maxAccountFailures int64 = 5
+	// maxIPFailures 同一 IP 在窗口期内允许的失败次数，达到后锁定 IP
+	maxIPFailures int64 = 20
+	window              = 15 * time.Minute
+	lockDuration        = 15 * time.Minute
+	// baseDelay/maxDelay 渐进延迟：第 n 次失败后下一次登录先等待 baseDelay*2^(n-1)，最多 maxDelay
+	baseDelay = 200 * time.Millisecond
+	maxDelay  = 5 * time.Second
+	// stuffingThreshold 同一 IP 在窗口期内尝试的不同账号数达到它时判定为疑似撞库
+	stuffingThreshold int64 = 10
+
+	locks, stuffingAlerts atomic.Int64
+)
+
+func init() {
+	dashboard.Register("loginguard", func(ctx context.Context) interface{} {
+		return map[string]interface{}{
+			"locks":           locks.Load(),
+			"stuffing_alerts": stuffingAlerts.Load(),
+		}
+	})
+}
+
+func Init(cfg *settings.LoginGuardConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	if cfg.MaxAccountFailures > 0 {
+		maxAccountFailures = cfg.MaxAccountFailures
+	}
+	if cfg.MaxIPFailures > 0 {
+		maxIPFailures = cfg.MaxIPFailures
+	}
+	if cfg.Window > 0 {
+		window = time.Duration(cfg.Window) * time.Second
+	}
+	if cfg.LockDuration > 0 {
+		lockDuration = time.Duration(cfg.LockDuration) * time.Second
+	}
+	if cfg.BaseDelay >= 0 {
+		baseDelay = time.Duration(cfg.BaseDelay) * time.Millisecond
+	}
+	if cfg.MaxDelay > 0 {
+		maxDelay = time.Duration(cfg.MaxDelay) * time.Millisecond
+	}
+	if cfg.StuffingThreshold > 0 {
+		stuffingThreshold = cfg.StuffingThreshold
+	}
+	return
+}
+
+// normalize 账号不区分大小写，避免换个大小写绕过计数
+func normalize(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
+
+// Before 校验密码之前调用：账号或 IP 被锁定时返回 *LockedError，否则按之前的失败次数等待一段时间
+// redis 出错时放行，不能因为风控组件故障导致所有人无法登录
+func Before(ctx context.Context, account, ip string) error {
+	account = normalize(account)
+	for _, s := range []struct{ scope, id string }{{scopeIP, ip}, {scopeAccount, account}} {
+		ttl, err := redis.LoginLockTTL(ctx, s.scope, s.id)
+		if err != nil {
+			logger.Ctx(ctx).Warn("loginguard check lock failed", zap.Error(err))
+			return nil
+		}
+		if ttl > 0 {
+			return &LockedError{Scope: s.scope, RetryAfter: ttl}
+		}
+	}
+
+	n, err := redis.GetLoginFailures(ctx, scopeAccount, account)
+	if err != nil || n == 0 || baseDelay <= 0 {
+		return nil
+	}
+	d := baseDelay << (n - 1)
+	if d > maxDelay || d <= 0 {
+		d = maxDelay
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Failure 登录失败后调用，累计失败次数，达到阈值时锁定，并检测撞库
+func Failure(ctx context.Context, account, ip string) {
+	account = normalize(account)
+	lg := logger.Ctx(ctx)
+
+	if n, err := redis.IncrLoginFailure(ctx, scopeAccount, account, window); err != nil {
+		lg.Warn("loginguard incr account failure failed", zap.Error(err))
+	} else if n >= maxAccountFailures {
+		lock(ctx, scopeAccount, account)
+	}
+	if n, err := redis.IncrLoginFailure(ctx, scopeIP, ip, window); err != nil {
+		lg.Warn("loginguard incr ip failure failed", zap.Error(err))
+	} else if n >= maxIPFailures {
+		lock(ctx, scopeIP, ip)
+	}
+
+	// 同一个 IP 短时间内用大量不同账号尝试登录，基本就是拿泄露的账号密码在撞库
+	accounts, err := redis.AddLoginAccount(ctx, ip, account, window)
+	if err != nil {
+		lg.Warn("loginguard count accounts failed", zap.Error(err))
+		return
+	}
+	if accounts >= stuffingThreshold {
+		if first, _ := redis.MarkStuffingAlert(ctx, ip, window); first {
+			stuffingAlerts.Add(1)
+			lg.Error("suspected credential stuffing",
+				zap.String("ip", ip),
+				zap.Int64("accounts", accounts),
+				zap.Duration("window", window))
+		}
+	}
+}
+
+// Success 登录成功后调用，清空账号的失败次数；IP 的计数不清，防止攻击者夹带一个正确账号刷新计数
+func Success(ctx context.Context, account, ip string) {
+	if err := redis.ClearLoginFailures(ctx, scopeAccount, normalize(account)); err != nil {
+		logger.Ctx(ctx).Warn("loginguard clear failures failed", zap.Error(err))
+	}
+}
+
+func lock(ctx context.Context, scope, id string) {
+	if err := redis.LockLogin(ctx, scope, id, lockDuration); err != nil {
+		logger.Ctx(ctx).Warn("loginguard lock failed", zap.Error(err))
+		return
+	}
+	locks.Add(1)
+	logger.Ctx(ctx).Warn("login locked", zap.String("scope", scope), zap.String("id", id), zap.Duration("duration", lockDuration))
+	// 锁定后清零，解锁后重新计数
+	_ = redis.ClearLoginFailures(ctx, scope, id)
+}
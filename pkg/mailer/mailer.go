@@ -0,0 +1,231 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/pkg/apperr"
+	"go_web_scaffolding/pkg/jobs"
+	"go_web_scaffolding/pkg/retry"
+	"go_web_scaffolding/settings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+)
+
+// 发邮件：模板在 web/templates/email 下，主题写在模板的 <title> 里
+//
+//	msg, err := mailer.NewMessage(u.Email, "password_reset.html", map[string]interface{}{"Name": u.Username, "Link": link})
+//	if err != nil {
+//		return err
+//	}
+//	return mailer.Enqueue(ctx, msg)    // 放进后台任务队列，失败按退避重试；没有开启任务队列时同步发送
+//
+// 模板在入队时渲染，任务里存的是渲染好的邮件，重试时不受模板修改的影响
+// 带验证码、重置链接的邮件用 EnqueueSecret：任务里只有邮件 ID，正文单独存在 redis 里，和凭证同时过期，
+// 死信、任务保留期里都不会留下能用的凭证
+// 每个收件地址每小时最多发 rate_limit 封，超过时返回 ErrRateLimited，防止被人拿接口刷别人的邮箱，
+// 验证码这类业务自己的限额另外算
+//
+// 发信方式由 provider 决定：smtp 或者 log（不发送，只记录收件人和标题），其它服务商实现 Provider 后用 SetProvider 替换
+
+// TypeSendEmail 发送邮件的后台任务，SMTP 慢的时候要好几秒，不能让请求等着
+const TypeSendEmail = "email:send"
+
+// TypeSendSecretEmail 发送 EnqueueSecret 入队的邮件，payload 只有邮件 ID
+const TypeSendSecretEmail = "email:send_secret"
+
+// secretMaxRetry 敏感邮件的重试次数，按 jobs 的退避（10 秒起步翻倍）一分多钟就放弃，不会在凭证过期之后才送到
+const secretMaxRetry = 3
+
+// errMailExpired 敏感邮件的正文已经过期
+var errMailExpired = errors.New("mailer: mail expired before sent")
+
+// secretRef 敏感邮件任务的 payload
+type secretRef struct {
+	ID string `json:"id"`
+}
+
+// rateWindow 收件地址限额的窗口
+const rateWindow = time.Hour
+
+// ErrRateLimited 收件地址在一小时内收到的邮件超过了限额，接口返回 429
+var ErrRateLimited = apperr.TooManyRequests("too many mails to this address")
+
+// Message 一封邮件
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+	// HTML 为 true 时 Body 按 text/html 发送
+	HTML bool
+}
+
+// Provider 发信服务
+type Provider interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+var (
+	provider  Provider = logProvider{}
+	name               = "log"
+	rateLimit int64
+)
+
+func init() {
+	jobs.Register(TypeSendEmail, func(ctx context.Context, t *jobs.Task) error {
+		var msg Message
+		if err := t.Bind(&msg); err != nil {
+			return retry.Permanent(err)
+		}
+		return send(ctx, &msg)
+	})
+	jobs.Register(TypeSendSecretEmail, func(ctx context.Context, t *jobs.Task) error {
+		var ref secretRef
+		if err := t.Bind(&ref); err != nil {
+			return retry.Permanent(err)
+		}
+		data, err := redis.GetMailBody(ctx, ref.ID)
+		if err != nil {
+			return err
+		}
+		if data == "" {
+			return retry.Permanent(errMailExpired)
+		}
+		var msg Message
+		if err = json.Unmarshal([]byte(data), &msg); err != nil {
+			return retry.Permanent(err)
+		}
+		if err = send(ctx, &msg); err != nil {
+			return err
+		}
+		if err = redis.DeleteMailBody(ctx, ref.ID); err != nil {
+			zap.L().Warn("delete sent mail failed", zap.String("id", ref.ID), zap.Error(err))
+		}
+		return nil
+	})
+}
+
+// Init 按配置选择发信方式并加载邮件模板，没有配置 SMTP 服务器时邮件不发送，只记录收件人和标题
+func Init(c *settings.MailConfig) (err error) {
+	if c != nil {
+		rateLimit = c.RateLimit
+		switch c.Provider {
+		case "", "smtp":
+			if c.Host != "" {
+				provider, name = &smtpProvider{host: c.Host, port: c.Port, username: c.Username, password: c.Password, from: c.From}, "smtp"
+			}
+		case "log":
+		default:
+			return fmt.Errorf("mailer: unknown provider %q", c.Provider)
+		}
+	}
+	dev := settings.Conf.Mode == "dev" || settings.Conf.Mode == "local"
+	return loadTemplates(dev)
+}
+
+// SetProvider 替换发信方式，比如接入服务商的 HTTP 接口，需要在 Init 之后、开始处理请求之前调用
+func SetProvider(n string, p Provider) {
+	name, provider = n, p
+}
+
+// Send 同步发送，先检查收件地址的限额
+func Send(ctx context.Context, msg *Message) error {
+	if err := allow(ctx, msg); err != nil {
+		return err
+	}
+	return send(ctx, msg)
+}
+
+// Enqueue 放到后台任务队列发送，先检查收件地址的限额；没有开启任务队列时同步发送
+func Enqueue(ctx context.Context, msg *Message) error {
+	if !jobs.Enabled() {
+		return Send(ctx, msg)
+	}
+	if err := allow(ctx, msg); err != nil {
+		return err
+	}
+	_, err := jobs.Enqueue(ctx, TypeSendEmail, msg)
+	return err
+}
+
+// EnqueueSecret 发送带验证码、重置链接的邮件，ttl 是里面凭证的有效期，过期后不再发送
+// 没有开启任务队列时同步发送
+func EnqueueSecret(ctx context.Context, msg *Message, ttl time.Duration) error {
+	if !jobs.Enabled() {
+		return Send(ctx, msg)
+	}
+	if err := allow(ctx, msg); err != nil {
+		return err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	id := hex.EncodeToString(b)
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err = redis.SaveMailBody(ctx, id, string(data), ttl); err != nil {
+		return err
+	}
+	_, err = jobs.Enqueue(ctx, TypeSendSecretEmail, &secretRef{ID: id}, asynq.MaxRetry(secretMaxRetry))
+	return err
+}
+
+// CheckLimit 只检查不计数，收件地址已经用完限额时返回 ErrRateLimited
+// 验证码、重置链接这类邮件在生成凭证、扣业务限额之前先调用，发不出去的邮件不该让用户白白消耗次数
+// redis 出错时放行，和 allow 一样
+func CheckLimit(ctx context.Context, to string) error {
+	if rateLimit <= 0 {
+		return nil
+	}
+	n, err := redis.GetMailRecipient(ctx, to)
+	if err != nil {
+		zap.L().Warn("mail rate limit check failed", zap.String("to", to), zap.Error(err))
+		return nil
+	}
+	if n >= rateLimit {
+		sent.WithLabelValues(name, "rate_limited").Inc()
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// allow 每个收件地址计数一次，有一个超过限额就整封不发
+// redis 出错时放行，限额只是防刷，不能因为它发不出验证码
+func allow(ctx context.Context, msg *Message) error {
+	if rateLimit <= 0 {
+		return nil
+	}
+	for _, to := range msg.To {
+		n, err := redis.IncrMailRecipient(ctx, to, rateWindow)
+		if err != nil {
+			zap.L().Warn("mail rate limit check failed", zap.String("to", to), zap.Error(err))
+			continue
+		}
+		if n > rateLimit {
+			sent.WithLabelValues(name, "rate_limited").Inc()
+			return ErrRateLimited
+		}
+	}
+	return nil
+}
+
+func send(ctx context.Context, msg *Message) error {
+	start := time.Now()
+	err := provider.Send(ctx, msg)
+	duration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		sent.WithLabelValues(name, "failure").Inc()
+		return fmt.Errorf("mailer: send via %s: %w", name, err)
+	}
+	sent.WithLabelValues(name, "success").Inc()
+	return nil
+}
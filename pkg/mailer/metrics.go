@@ -0,0 +1,24 @@
+package mailer
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	sent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "mail",
+		Name:      "sent_total",
+		Help:      "发送的邮件数，result: success/failure/rate_limited",
+	}, []string{"provider", "result"})
+	duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "app",
+		Subsystem: "mail",
+		Name:      "send_duration_seconds",
+		Help:      "发信耗时",
+		Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"provider"})
+)
+
+// Collectors 邮件指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{sent, duration}
+}
@@ -0,0 +1,126 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"go_web_scaffolding/logger"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// smtpProvider 通过 SMTP 服务器发信
+type smtpProvider struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+// sendTimeout 一封邮件从建立连接到 QUIT 的最长时间，ctx 的截止时间更早时以 ctx 为准
+// 没有开启任务队列时邮件在请求里同步发送，SMTP 服务器没有响应也不能让请求一直挂着
+const sendTimeout = 30 * time.Second
+
+// Send net/smtp 不支持 ctx，自己建立连接并设置读写截止时间，ctx 取消时直接关闭连接
+func (p *smtpProvider) Send(ctx context.Context, msg *Message) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	addr := net.JoinHostPort(p.host, strconv.Itoa(p.port))
+	conn, err := (&net.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err = conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	if err = p.deliver(conn, msg); err != nil && ctx.Err() != nil {
+		// 连接是被 ctx 关掉的，返回超时或取消，而不是 use of closed network connection
+		return ctx.Err()
+	}
+	return err
+}
+
+// deliver 在已经建立的连接上完成一次 SMTP 会话
+func (p *smtpProvider) deliver(conn net.Conn, msg *Message) error {
+	// 465 端口是隐式 TLS，连上之后先握手；其它端口服务器支持 STARTTLS 时再升级
+	if p.port == 465 {
+		conn = tls.Client(conn, &tls.Config{ServerName: p.host})
+	}
+	c, err := smtp.NewClient(conn, p.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if p.port != 465 {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(&tls.Config{ServerName: p.host}); err != nil {
+				return err
+			}
+		}
+	}
+	if p.username != "" {
+		if err = c.Auth(smtp.PlainAuth("", p.username, p.password, p.host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(p.from); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err = c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(build(p.from, msg)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// build 拼装邮件头和正文，主题按 RFC 2047 编码，中文不会乱码
+func build(from string, msg *Message) []byte {
+	contentType := "text/plain"
+	if msg.HTML {
+		contentType = "text/html"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: %s; charset=UTF-8\r\n", contentType)
+	buf.WriteString("\r\n")
+	buf.WriteString(msg.Body)
+	return buf.Bytes()
+}
+
+// logProvider 没有配置 SMTP 服务器时使用，只记录收件人和标题，
+// 正文里有验证码、重置链接这类凭证，不能写进日志
+type logProvider struct{}
+
+func (logProvider) Send(ctx context.Context, msg *Message) error {
+	logger.Ctx(ctx).Info("mail not sent, no smtp server configured",
+		zap.Strings("to", msg.To),
+		zap.String("subject", msg.Subject))
+	return nil
+}
@@ -0,0 +1,64 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"go_web_scaffolding/pkg/tmpl"
+	"go_web_scaffolding/web"
+	"html"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// templateDir 邮件模板在模板根目录下的子目录，模板名是相对它的路径，比如 "verify_code.html"
+const templateDir = "email"
+
+// titleRe 邮件主题取模板里 <title> 的内容，主题和正文写在同一个文件里，改文案只改一处
+var titleRe = regexp.MustCompile(`(?is)<title>(.*?)</title>`)
+
+var templates *tmpl.Loader
+
+// loadTemplates dev 模式从磁盘读取并监听变化，读不到（工作目录不是项目根目录）时退回编译进二进制的模板
+func loadTemplates(dev bool) (err error) {
+	sub, err := fs.Sub(web.Templates(), templateDir)
+	if err != nil {
+		return
+	}
+	if dev {
+		if templates, err = tmpl.New(sub, path.Join(web.TemplateDir, templateDir), true); err == nil {
+			return
+		}
+		zap.L().Warn("load mail templates from disk failed, using embedded", zap.Error(err))
+	}
+	templates, err = tmpl.New(sub, "", false)
+	return
+}
+
+// Render 用 data 渲染模板 name，返回主题和 HTML 正文
+func Render(name string, data interface{}) (subject, body string, err error) {
+	if templates == nil {
+		return "", "", errors.New("mailer: templates not loaded")
+	}
+	var buf bytes.Buffer
+	if err = templates.Template().ExecuteTemplate(&buf, name, data); err != nil {
+		return
+	}
+	body = buf.String()
+	if m := titleRe.FindStringSubmatch(body); m != nil {
+		subject = html.UnescapeString(strings.TrimSpace(m[1]))
+	}
+	return
+}
+
+// NewMessage 用模板生成一封发给 to 的 HTML 邮件
+func NewMessage(to, name string, data interface{}) (*Message, error) {
+	subject, body, err := Render(name, data)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{To: []string{to}, Subject: subject, Body: body, HTML: true}, nil
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 所有指标注册在本包的 registry 上，通过 /metrics 给 Prometheus 抓取
+// 用独立的 registry 而不是 prometheus.DefaultRegisterer，避免依赖库偷偷注册的指标混进来
+// 其它模块要加指标时调用 MustRegister
+
+const namespace = "app"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	// RequestsTotal 请求数，route 是 gin 注册的路由模板（如 /api/v1/apikeys/:id），不是实际路径，避免标签基数爆炸
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP 请求总数",
+	}, []string{"method", "route", "status"})
+
+	// RequestDuration 请求耗时
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP 请求耗时（秒）",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route", "status"})
+
+	// RequestsInFlight 正在处理的请求数
+	RequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "正在处理的 HTTP 请求数",
+	})
+
+	// GRPCRequestsTotal gRPC 请求数，method 是完整方法名（如 /grpc.health.v1.Health/Check），code 是 gRPC 状态码
+	GRPCRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "gRPC 请求总数",
+	}, []string{"method", "code"})
+
+	// GRPCRequestDuration gRPC 请求耗时，流式调用是整个流的持续时间
+	GRPCRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "gRPC 请求耗时（秒）",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "code"})
+
+	// GRPCRequestsInFlight 正在处理的 gRPC 请求数
+	GRPCRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_in_flight",
+		Help:      "正在处理的 gRPC 请求数",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		// Go 运行时（goroutine 数、GC、内存）和进程（CPU、打开的文件数）指标
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestsTotal,
+		RequestDuration,
+		RequestsInFlight,
+		GRPCRequestsTotal,
+		GRPCRequestDuration,
+		GRPCRequestsInFlight,
+	)
+}
+
+// MustRegister 注册其它模块的指标，名字冲突时 panic，应该在 init 或启动阶段调用
+func MustRegister(cs ...prometheus.Collector) {
+	registry.MustRegister(cs...)
+}
+
+// Handler /metrics 接口
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
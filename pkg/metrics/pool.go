@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"go_web_scaffolding/dao/kafka"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/pkg/breaker"
+	"go_web_scaffolding/pkg/eventbus"
+	"go_web_scaffolding/pkg/httpclient"
+	"go_web_scaffolding/pkg/jobs"
+	"go_web_scaffolding/pkg/mailer"
+	"go_web_scaffolding/pkg/push"
+	"go_web_scaffolding/pkg/sms"
+	"go_web_scaffolding/pkg/webhook"
+	"go_web_scaffolding/pkg/workerpool"
+	"go_web_scaffolding/pkg/ws"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 连接池指标，后台定时采样写入 gauge
+// 等待次数和等待时长持续上涨、in_use 贴着 max_open 时，说明连接池快被打满了，要在请求超时之前报警
+// 等待次数这类累计值在 profile 切换（换了新连接池）后会从 0 开始，所以用 gauge 而不是 counter
+
+var (
+	mysqlConns = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "mysql_pool",
+		Name:      "connections",
+		Help:      "MySQL 连接数，state: max_open/open/in_use/idle",
+	}, []string{"state"})
+	mysqlWaitCount = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "mysql_pool",
+		Name:      "wait_count",
+		Help:      "等待空闲连接的累计次数",
+	})
+	mysqlWaitSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "mysql_pool",
+		Name:      "wait_duration_seconds",
+		Help:      "等待空闲连接的累计时长（秒）",
+	})
+	mysqlClosed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "mysql_pool",
+		Name:      "closed",
+		Help:      "连接池主动关闭的累计连接数，reason: max_idle/max_idle_time/max_lifetime",
+	}, []string{"reason"})
+
+	// go-redis v6 的连接池没有等待时长，用获取连接超时的次数代替
+	redisConns = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "redis_pool",
+		Name:      "connections",
+		Help:      "Redis 连接数，state: total/idle/stale",
+	}, []string{"state"})
+	redisGets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "redis_pool",
+		Name:      "gets",
+		Help:      "从连接池获取连接的累计次数，result: hit（有空闲连接）/miss（新建连接）/timeout（等待超时）",
+	}, []string{"result"})
+)
+
+var (
+	samplerOnce sync.Once
+	stopCh      = make(chan struct{})
+	samplerWg   sync.WaitGroup
+)
+
+func init() {
+	registry.MustRegister(mysqlConns, mysqlWaitCount, mysqlWaitSeconds, mysqlClosed, redisConns, redisGets)
+	// SQL 耗时和错误数，在驱动包装层记录
+	registry.MustRegister(mysql.Collectors()...)
+	registry.MustRegister(kafka.Collectors()...)
+	registry.MustRegister(breaker.Collectors()...)
+	registry.MustRegister(httpclient.Collectors()...)
+	registry.MustRegister(ws.Collectors()...)
+	registry.MustRegister(jobs.Collectors()...)
+	registry.MustRegister(mailer.Collectors()...)
+	registry.MustRegister(sms.Collectors()...)
+	registry.MustRegister(push.Collectors()...)
+	registry.MustRegister(workerpool.Collectors()...)
+	registry.MustRegister(eventbus.Collectors()...)
+	registry.MustRegister(webhook.Collectors()...)
+}
+
+// StartPoolSampler 启动连接池采样，interval 不大于 0 时按 15 秒
+func StartPoolSampler(interval time.Duration) {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	samplerOnce.Do(func() {
+		samplePools()
+		samplerWg.Add(1)
+		go func() {
+			defer samplerWg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stopCh:
+					return
+				case <-ticker.C:
+					samplePools()
+				}
+			}
+		}()
+	})
+}
+
+// StopPoolSampler 停止采样，在关闭 MySQL、Redis 之前调用
+func StopPoolSampler() {
+	select {
+	case <-stopCh:
+	default:
+		close(stopCh)
+	}
+	samplerWg.Wait()
+}
+
+func samplePools() {
+	s := mysql.Stats()
+	mysqlConns.WithLabelValues("max_open").Set(float64(s.MaxOpenConnections))
+	mysqlConns.WithLabelValues("open").Set(float64(s.OpenConnections))
+	mysqlConns.WithLabelValues("in_use").Set(float64(s.InUse))
+	mysqlConns.WithLabelValues("idle").Set(float64(s.Idle))
+	mysqlWaitCount.Set(float64(s.WaitCount))
+	mysqlWaitSeconds.Set(s.WaitDuration.Seconds())
+	mysqlClosed.WithLabelValues("max_idle").Set(float64(s.MaxIdleClosed))
+	mysqlClosed.WithLabelValues("max_idle_time").Set(float64(s.MaxIdleTimeClosed))
+	mysqlClosed.WithLabelValues("max_lifetime").Set(float64(s.MaxLifetimeClosed))
+
+	r := redis.PoolStats()
+	redisConns.WithLabelValues("total").Set(float64(r.TotalConns))
+	redisConns.WithLabelValues("idle").Set(float64(r.IdleConns))
+	redisConns.WithLabelValues("stale").Set(float64(r.StaleConns))
+	redisGets.WithLabelValues("hit").Set(float64(r.Hits))
+	redisGets.WithLabelValues("miss").Set(float64(r.Misses))
+	redisGets.WithLabelValues("timeout").Set(float64(r.Timeouts))
+}
@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/migrations"
+	"io"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+)
+
+// 基于 goose 的数据库迁移，脚本在 migrations 目录，执行记录保存在 goose_db_version 表
+// 多个实例同时启动时不要都开 auto_migrate，生产环境在发布流程里单独执行 `app migrate up`
+
+// Status 一个迁移脚本的状态
+type Status struct {
+	Version   int64  `json:"version"`
+	File      string `json:"file"`
+	Applied   bool   `json:"applied"`
+	AppliedAt string `json:"applied_at,omitempty"`
+}
+
+func newProvider() (*goose.Provider, error) {
+	db := mysql.DB()
+	if db == nil {
+		return nil, errors.New("migrate: mysql is not initialized")
+	}
+	dialect := goose.DialectMySQL
+	switch mysql.Driver() {
+	case mysql.DriverPostgres:
+		dialect = goose.DialectPostgres
+	case mysql.DriverSQLite:
+		dialect = goose.DialectSQLite3
+	}
+	return goose.NewProvider(dialect, db.DB, migrations.For(mysql.Driver()))
+}
+
+// Up 执行全部未执行的迁移
+func Up(ctx context.Context) error {
+	p, err := newProvider()
+	if err != nil {
+		return err
+	}
+	results, err := p.Up(ctx)
+	for _, r := range results {
+		logResult(r)
+	}
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		zap.L().Info("migrate: database is up to date")
+	}
+	return nil
+}
+
+// Down 回滚最近的一个迁移
+func Down(ctx context.Context) error {
+	p, err := newProvider()
+	if err != nil {
+		return err
+	}
+	r, err := p.Down(ctx)
+	if r != nil {
+		logResult(r)
+	}
+	return err
+}
+
+// List 全部迁移脚本及其执行状态
+func List(ctx context.Context) ([]Status, error) {
+	p, err := newProvider()
+	if err != nil {
+		return nil, err
+	}
+	list, err := p.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]Status, 0, len(list))
+	for _, s := range list {
+		st := Status{
+			Version: s.Source.Version,
+			File:    s.Source.Path,
+			Applied: s.State == goose.StateApplied,
+		}
+		if st.Applied {
+			st.AppliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		status = append(status, st)
+	}
+	return status, nil
+}
+
+// PrintStatus 以表格形式输出迁移状态，给命令行使用
+func PrintStatus(ctx context.Context, w io.Writer) error {
+	list, err := List(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%-8s %-20s %s\n", "VERSION", "APPLIED AT", "FILE")
+	for _, s := range list {
+		at := "pending"
+		if s.Applied {
+			at = s.AppliedAt
+		}
+		fmt.Fprintf(w, "%-8d %-20s %s\n", s.Version, at, s.File)
+	}
+	return nil
+}
+
+func logResult(r *goose.MigrationResult) {
+	fields := []zap.Field{
+		zap.String("file", r.Source.Path),
+		zap.String("direction", strings.ToLower(r.Direction)),
+		zap.Duration("duration", r.Duration),
+	}
+	if r.Error != nil {
+		zap.L().Error("migrate failed", append(fields, zap.Error(r.Error))...)
+		return
+	}
+	zap.L().Info("migrate applied", fields...)
+}
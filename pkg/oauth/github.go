@@ -0,0 +1,72 @@
+package oauth
+
+import (
+	"context"
+	"go_web_scaffolding/settings"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+type gitHub struct {
+	conf *oauth2.Config
+}
+
+func newGitHub(cfg *settings.OAuthProviderConfig) Provider {
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"read:user", "user:email"}
+	}
+	return &gitHub{conf: &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       scopes,
+		Endpoint:     endpoints.GitHub,
+	}}
+}
+
+func (g *gitHub) AuthCodeURL(state string) string {
+	return g.conf.AuthCodeURL(state)
+}
+
+func (g *gitHub) Exchange(ctx context.Context, code string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+	tok, err := g.conf.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	client := g.conf.Client(ctx, tok)
+
+	var u struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err = getJSON(ctx, client, "https://api.github.com/user", &u); err != nil {
+		return nil, err
+	}
+	id := &Identity{
+		Provider: "github",
+		OpenID:   strconv.FormatInt(u.ID, 10),
+		Name:     u.Login,
+		Avatar:   u.AvatarURL,
+	}
+
+	// /user 里的 email 是用户公开展示的邮箱，不一定验证过，这里取主邮箱
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err = getJSON(ctx, client, "https://api.github.com/user/emails", &emails); err == nil {
+		for _, e := range emails {
+			if e.Primary {
+				id.Email, id.EmailVerified = e.Email, e.Verified
+				break
+			}
+		}
+	}
+	return id, nil
+}
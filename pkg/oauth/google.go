@@ -0,0 +1,57 @@
+package oauth
+
+import (
+	"context"
+	"go_web_scaffolding/settings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+type google struct {
+	conf *oauth2.Config
+}
+
+func newGoogle(cfg *settings.OAuthProviderConfig) Provider {
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &google{conf: &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       scopes,
+		Endpoint:     endpoints.Google,
+	}}
+}
+
+func (g *google) AuthCodeURL(state string) string {
+	return g.conf.AuthCodeURL(state)
+}
+
+func (g *google) Exchange(ctx context.Context, code string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+	tok, err := g.conf.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	var u struct {
+		Sub           string `json:"sub"`
+		Name          string `json:"name"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Picture       string `json:"picture"`
+	}
+	if err = getJSON(ctx, g.conf.Client(ctx, tok), "https://openidconnect.googleapis.com/v1/userinfo", &u); err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Provider:      "google",
+		OpenID:        u.Sub,
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		Avatar:        u.Picture,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/pkg/httpclient"
+	"go_web_scaffolding/settings"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ErrProviderNotFound 没有配置该第三方登录
+var ErrProviderNotFound = errors.New("oauth: provider not found")
+
+// Identity 第三方平台返回的用户身份
+type Identity struct {
+	Provider string
+	// OpenID 用户在该平台的唯一标识，微信优先使用 unionid
+	OpenID string
+	Name   string
+	Email  string
+	// EmailVerified 平台确认过邮箱归属，只有这种邮箱才会写到新建的用户上
+	EmailVerified bool
+	Avatar        string
+}
+
+// Provider 第三方登录平台
+type Provider interface {
+	// AuthCodeURL 跳转到平台授权页的地址
+	AuthCodeURL(state string) string
+	// Exchange 用回调拿到的 code 换取用户身份
+	Exchange(ctx context.Context, code string) (*Identity, error)
+}
+
+// newProviders 支持的平台，配置里的 key 必须是这里的名字之一
+var newProviders = map[string]func(cfg *settings.OAuthProviderConfig) Provider{
+	"github": newGitHub,
+	"google": newGoogle,
+	"wechat": newWeChat,
+}
+
+var (
+	providers = make(map[string]Provider)
+	stateTTL  = 10 * time.Minute
+	// httpClient 调用平台接口使用的客户端，超时、重试和熔断见 http_client 配置
+	httpClient = httpclient.Client()
+)
+
+// Init 按配置创建第三方登录平台，没有配置的平台不可用
+func Init(cfg *settings.OAuthConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	if cfg.StateTTL > 0 {
+		stateTTL = time.Duration(cfg.StateTTL) * time.Second
+	}
+	for name, pc := range cfg.Providers {
+		newFn, ok := newProviders[name]
+		if !ok {
+			return fmt.Errorf("oauth: unknown provider %q", name)
+		}
+		if pc == nil || pc.ClientID == "" {
+			continue
+		}
+		providers[name] = newFn(pc)
+	}
+	return
+}
+
+// StateTTL 跳转授权页到回调之间允许的最长时间
+func StateTTL() time.Duration {
+	return stateTTL
+}
+
+// Get 按名字取第三方登录平台
+func Get(name string) (Provider, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+	return p, nil
+}
+
+// Names 已配置的第三方登录平台
+func Names() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getJSON 请求平台接口并把 JSON 响应解析到 v
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth: GET %s status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,90 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/settings"
+	"net/url"
+)
+
+// weChat 微信开放平台网站应用扫码登录，接口不是标准 OAuth2，单独实现
+type weChat struct {
+	cfg *settings.OAuthProviderConfig
+}
+
+func newWeChat(cfg *settings.OAuthProviderConfig) Provider {
+	return &weChat{cfg: cfg}
+}
+
+func (w *weChat) AuthCodeURL(state string) string {
+	q := url.Values{}
+	q.Set("appid", w.cfg.ClientID)
+	q.Set("redirect_uri", w.cfg.RedirectURL)
+	q.Set("response_type", "code")
+	q.Set("scope", "snsapi_login")
+	q.Set("state", state)
+	return "https://open.weixin.qq.com/connect/qrconnect?" + q.Encode() + "#wechat_redirect"
+}
+
+// weChatError 微信接口出错时 HTTP 状态码也是 200，需要看 errcode
+type weChatError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (e weChatError) err() error {
+	if e.ErrCode == 0 {
+		return nil
+	}
+	return fmt.Errorf("oauth: wechat errcode %d: %s", e.ErrCode, e.ErrMsg)
+}
+
+func (w *weChat) Exchange(ctx context.Context, code string) (*Identity, error) {
+	q := url.Values{}
+	q.Set("appid", w.cfg.ClientID)
+	q.Set("secret", w.cfg.ClientSecret)
+	q.Set("code", code)
+	q.Set("grant_type", "authorization_code")
+	var tok struct {
+		weChatError
+		AccessToken string `json:"access_token"`
+		OpenID      string `json:"openid"`
+		UnionID     string `json:"unionid"`
+	}
+	if err := getJSON(ctx, httpClient, "https://api.weixin.qq.com/sns/oauth2/access_token?"+q.Encode(), &tok); err != nil {
+		return nil, err
+	}
+	if err := tok.err(); err != nil {
+		return nil, err
+	}
+
+	q = url.Values{}
+	q.Set("access_token", tok.AccessToken)
+	q.Set("openid", tok.OpenID)
+	var u struct {
+		weChatError
+		Nickname   string `json:"nickname"`
+		HeadImgURL string `json:"headimgurl"`
+		UnionID    string `json:"unionid"`
+	}
+	if err := getJSON(ctx, httpClient, "https://api.weixin.qq.com/sns/userinfo?"+q.Encode(), &u); err != nil {
+		return nil, err
+	}
+	if err := u.err(); err != nil {
+		return nil, err
+	}
+
+	// 同一个开放平台下的多个应用 unionid 相同，openid 不同，优先用 unionid
+	openID := tok.OpenID
+	if u.UnionID != "" {
+		openID = u.UnionID
+	} else if tok.UnionID != "" {
+		openID = tok.UnionID
+	}
+	return &Identity{
+		Provider: "wechat",
+		OpenID:   openID,
+		Name:     u.Nickname,
+		Avatar:   u.HeadImgURL,
+	}, nil
+}
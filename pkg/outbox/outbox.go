@@ -0,0 +1,282 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/retry"
+	"go_web_scaffolding/pkg/stream"
+	"go_web_scaffolding/settings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 事务发件箱：业务数据提交后再发消息，进程恰好在两步之间挂掉消息就丢了；
+// 先把消息和业务数据写进同一个事务，提交成功消息就一定在表里，再由后台 relay 投递到消息总线
+//
+//	err = mysql.WithTx(ctx, func(ctx context.Context) error {
+//		if err := mysql.InsertUser(ctx, u); err != nil {
+//			return err
+//		}
+//		return outbox.Add(ctx, "user:registered", u)
+//	})
+//
+// relay 先在短事务里租下一批消息（推迟它们的重试时间），在事务外逐条发布，再标记结果；
+// 多个实例同时跑 relay 时用 SKIP LOCKED 分摊（MySQL 需要 8.0 及以上），租期内不会被别的实例取到
+// 投递是"至少一次"：发布成功后、标记已投递之前进程挂掉，租期过后会再发一遍，
+// 消费者需要按业务 ID 去重（或者保证处理幂等）
+// 默认投递到 redis stream（topic 即 stream 名），可以用 SetPublisher 换成其它消息队列
+
+// ErrNoTx Add 必须在事务里调用，否则和业务数据不是原子的，就失去了发件箱的意义
+var ErrNoTx = errors.New("outbox: Add must be called inside mysql.WithTx")
+
+// Publisher 把一条消息发布到消息总线，返回错误时按退避时间重试
+type Publisher func(ctx context.Context, msg *models.OutboxMessage) error
+
+const (
+	publishTimeout = 5 * time.Second
+	cleanInterval  = time.Hour
+	cleanBatch     = 1000
+	maxErrLen      = 512
+)
+
+var (
+	enable      bool
+	interval    = time.Second
+	batch       = 100
+	maxAttempts = 10
+	backoff     = time.Second
+	maxBackoff  = 10 * time.Minute
+	retention   = 7 * 24 * time.Hour
+
+	publisher Publisher = publishStream
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	published, failed, dead atomic.Int64
+)
+
+func init() {
+	dashboard.Register("outbox", func(ctx context.Context) interface{} {
+		status := map[string]interface{}{
+			"enable":    enable,
+			"published": published.Load(),
+			"failed":    failed.Load(),
+			"dead":      dead.Load(),
+		}
+		if !enable {
+			return status
+		}
+		if counts, err := mysql.CountOutbox(ctx); err == nil {
+			status["pending_rows"] = counts[models.OutboxPending]
+			status["dead_rows"] = counts[models.OutboxDead]
+		}
+		return status
+	})
+}
+
+func Init(cfg *settings.OutboxConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	enable = cfg.Enable
+	if cfg.Interval > 0 {
+		interval = time.Duration(cfg.Interval) * time.Millisecond
+	}
+	if cfg.Batch > 0 {
+		batch = cfg.Batch
+	}
+	if cfg.MaxAttempts > 0 {
+		maxAttempts = cfg.MaxAttempts
+	}
+	if cfg.Backoff > 0 {
+		backoff = time.Duration(cfg.Backoff) * time.Second
+	}
+	if cfg.Retention > 0 {
+		retention = time.Duration(cfg.Retention) * time.Hour
+	}
+	return
+}
+
+// SetPublisher 替换默认的 redis stream 投递，需要在 Start 之前调用
+func SetPublisher(p Publisher) {
+	publisher = p
+}
+
+// publishStream 默认投递方式：写入名为 topic 的 redis stream
+func publishStream(ctx context.Context, msg *models.OutboxMessage) error {
+	_, err := stream.Add(ctx, msg.Topic, msg.Payload)
+	return err
+}
+
+// Add 在 ctx 的事务里写入一条待投递消息，payload 为 string/[]byte 时原样保存，其它类型序列化为 JSON
+func Add(ctx context.Context, topic string, payload interface{}) error {
+	if _, ok := mysql.TxFromContext(ctx); !ok {
+		return ErrNoTx
+	}
+	var data string
+	switch v := payload.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	return mysql.InsertOutbox(ctx, &models.OutboxMessage{
+		Topic:     topic,
+		Payload:   data,
+		RequestID: ctxutil.RequestID(ctx),
+	})
+}
+
+// Start 启动 relay 协程，没有开启时什么都不做
+func Start() {
+	if !enable {
+		return
+	}
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		relay(ctx)
+	}()
+}
+
+// Stop 停止 relay 并等待当前批次投递完，没投递的消息留在表里，下次启动继续
+func Stop() {
+	if cancel == nil {
+		return
+	}
+	cancel()
+	wg.Wait()
+}
+
+func relay(ctx context.Context) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastClean := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// 一次取满 batch 说明还有积压，继续取不等下一个 tick
+		for ctx.Err() == nil {
+			n, err := dispatch(ctx)
+			if err != nil {
+				zap.L().Error("outbox relay failed", zap.Error(err))
+				break
+			}
+			if n < batch {
+				break
+			}
+		}
+		if time.Since(lastClean) >= cleanInterval {
+			lastClean = time.Now()
+			clean(ctx)
+		}
+	}
+}
+
+// dispatch 租下一批消息，逐条发布后更新状态
+// 租期覆盖整批发布的最长时间，租期内没有结果的（进程挂了）由其它实例重新投递
+func dispatch(ctx context.Context) (n int, err error) {
+	msgs, err := mysql.LeaseOutbox(ctx, batch, time.Duration(batch)*publishTimeout+time.Minute)
+	if err != nil || len(msgs) == 0 {
+		return len(msgs), err
+	}
+	sent := make([]int64, 0, len(msgs))
+	for _, msg := range msgs {
+		// 关机时剩下的不发了，租期过后再投递，不算失败次数
+		if ctx.Err() != nil {
+			break
+		}
+		if err := publish(ctx, msg); err != nil {
+			if err := markFailed(ctx, msg, err); err != nil {
+				return len(msgs), err
+			}
+			continue
+		}
+		sent = append(sent, msg.ID)
+	}
+	// 已经发出去的要标记上，不跟着关机取消
+	if err = mysql.MarkOutboxSent(context.WithoutCancel(ctx), sent); err != nil {
+		// 已经发布了但没标记上，租期过后会重发一次
+		return len(msgs), err
+	}
+	published.Add(int64(len(sent)))
+	return len(msgs), nil
+}
+
+func publish(ctx context.Context, msg *models.OutboxMessage) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return publisher(ctx, msg)
+}
+
+// markFailed 按尝试次数指数退避，次数用完后标记为死信
+func markFailed(ctx context.Context, msg *models.OutboxMessage, cause error) error {
+	attempts := msg.Attempts + 1
+	status := models.OutboxPending
+	delay := retry.Policy{Initial: backoff, Max: maxBackoff, Jitter: 0.2}.Backoff(attempts)
+	log := zap.L().With(
+		zap.Int64("id", msg.ID),
+		zap.String("topic", msg.Topic),
+		zap.String("request_id", msg.RequestID),
+		zap.Int("attempts", attempts),
+		zap.Error(cause))
+	if attempts >= maxAttempts {
+		status = models.OutboxDead
+		log.Error("outbox message failed, giving up")
+	} else {
+		log.Warn("outbox publish failed, retrying", zap.Duration("backoff", delay))
+	}
+	errMsg := cause.Error()
+	if len(errMsg) > maxErrLen {
+		errMsg = errMsg[:maxErrLen]
+	}
+	if err := mysql.MarkOutboxFailed(ctx, msg.ID, status, errMsg, time.Now().Add(delay)); err != nil {
+		return err
+	}
+	failed.Add(1)
+	if status == models.OutboxDead {
+		dead.Add(1)
+	}
+	return nil
+}
+
+// clean 删除超过保留时间的已投递消息，分批删除直到删完
+func clean(ctx context.Context) {
+	before := time.Now().Add(-retention)
+	for ctx.Err() == nil {
+		n, err := mysql.DeleteSentOutbox(ctx, before, cleanBatch)
+		if err != nil {
+			zap.L().Error("clean outbox failed", zap.Error(err))
+			return
+		}
+		if n < cleanBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"go_web_scaffolding/settings"
+	"strconv"
+)
+
+// 列表接口的分页有两种模式：
+//
+//	页码模式：?page=2&size=20，返回 total，适合后台表格这种需要跳页的场景
+//	游标模式：?mode=cursor&size=20 取第一页，之后带上返回的 ?cursor=xxx，按主键做 keyset 查询，
+//	不返回 total，适合无限滚动和深翻页
+//
+// 请求参数结构体里嵌入 Params，用 controller.BindAndValidate 绑定即可：
+//
+//	type ParamListAPIKeys struct {
+//		pagination.Params
+//	}
+
+var ErrInvalidCursor = errors.New("pagination: invalid cursor")
+
+var (
+	defaultSize = 10
+	maxSize     = 100
+	// maxPage 页码模式允许的最大页数，OFFSET 太大时 MySQL 要扫描并丢弃大量的行
+	maxPage = 1000
+)
+
+func Init(cfg *settings.PaginationConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	if cfg.DefaultSize > 0 {
+		defaultSize = cfg.DefaultSize
+	}
+	if cfg.MaxSize > 0 {
+		maxSize = cfg.MaxSize
+	}
+	if cfg.MaxPage > 0 {
+		maxPage = cfg.MaxPage
+	}
+	return
+}
+
+// Params 分页参数，mode=cursor 或者带了 cursor 就是游标模式，page 被忽略
+type Params struct {
+	Mode   string `form:"mode" json:"mode" binding:"omitempty,oneof=page cursor"`
+	Page   int    `form:"page" json:"page" binding:"omitempty,min=1"`
+	Size   int    `form:"size" json:"size" binding:"omitempty,min=1"`
+	Cursor string `form:"cursor" json:"cursor"`
+}
+
+// IsCursor 是否为游标模式
+func (p Params) IsCursor() bool {
+	return p.Mode == "cursor" || p.Cursor != ""
+}
+
+// Limit 每页条数，没传用默认值，超过上限按上限算
+func (p Params) Limit() int {
+	switch {
+	case p.Size <= 0:
+		return defaultSize
+	case p.Size > maxSize:
+		return maxSize
+	}
+	return p.Size
+}
+
+// CurrentPage 页码，从 1 开始，超过上限按上限算
+func (p Params) CurrentPage() int {
+	if p.Page < 1 {
+		return 1
+	}
+	if p.Page > maxPage {
+		return maxPage
+	}
+	return p.Page
+}
+
+// Offset 页码模式下跳过的行数
+func (p Params) Offset() int {
+	return (p.CurrentPage() - 1) * p.Limit()
+}
+
+// LimitOffset 页码模式的 SQL 片段，拼在 ORDER BY 之后
+func (p Params) LimitOffset() (clause string, args []interface{}) {
+	return " LIMIT ? OFFSET ?", []interface{}{p.Limit(), p.Offset()}
+}
+
+// Keyset 游标模式 WHERE 里的条件，第一页（没有游标）时为空；desc 为 true 时按 column 倒序翻页
+//
+//	cond, args, err := p.Keyset("id", true)
+//	if cond != "" {
+//		sqlStr += " where " + cond
+//	}
+//	tail, tailArgs := p.KeysetLimit()
+//	sqlStr += " order by id desc" + tail
+func (p Params) Keyset(column string, desc bool) (cond string, args []interface{}, err error) {
+	if p.Cursor == "" {
+		return
+	}
+	last, err := DecodeCursor(p.Cursor)
+	if err != nil {
+		return
+	}
+	op := " > ?"
+	if desc {
+		op = " < ?"
+	}
+	return "`" + column + "`" + op, []interface{}{last}, nil
+}
+
+// KeysetLimit 游标模式的 LIMIT，多查一条用来判断还有没有下一页，配合 CursorResult 使用
+func (p Params) KeysetLimit() (clause string, args []interface{}) {
+	return " LIMIT ?", []interface{}{p.Limit() + 1}
+}
+
+// EncodeCursor 把上一页最后一条的主键编码成游标，客户端只需要原样带回来
+func EncodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// DecodeCursor 解析游标
+func DecodeCursor(cursor string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
@@ -0,0 +1,48 @@
+package pagination
+
+// Meta 返回给客户端的分页信息
+// 页码模式返回 page 和 total；游标模式返回 next_cursor，has_more 为 false 时说明已经是最后一页
+type Meta struct {
+	Page       int    `json:"page,omitempty"`
+	Size       int    `json:"size"`
+	Total      *int64 `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// Result 统一的列表响应
+type Result[T any] struct {
+	List       []T  `json:"list"`
+	Pagination Meta `json:"pagination"`
+}
+
+// OffsetResult 页码模式的结果，total 是满足条件的总数
+func OffsetResult[T any](list []T, total int64, p Params) *Result[T] {
+	if list == nil {
+		list = []T{}
+	}
+	return &Result[T]{
+		List: list,
+		Pagination: Meta{
+			Page:    p.CurrentPage(),
+			Size:    p.Limit(),
+			Total:   &total,
+			HasMore: int64(p.Offset()+len(list)) < total,
+		},
+	}
+}
+
+// CursorResult 游标模式的结果，list 是按 Keyset 多查了一条的结果，cursorOf 取一条记录的主键
+func CursorResult[T any](list []T, p Params, cursorOf func(T) int64) *Result[T] {
+	size := p.Limit()
+	meta := Meta{Size: size}
+	if len(list) > size {
+		list = list[:size]
+		meta.HasMore = true
+		meta.NextCursor = EncodeCursor(cursorOf(list[size-1]))
+	}
+	if list == nil {
+		list = []T{}
+	}
+	return &Result[T]{List: list, Pagination: meta}
+}
@@ -0,0 +1,177 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/settings"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgoBcrypt   = "bcrypt"
+	AlgoArgon2id = "argon2id"
+)
+
+// ErrMismatch 密码不正确
+var ErrMismatch = errors.New("password: mismatch")
+
+// ErrUnknownHash 无法识别的哈希格式
+var ErrUnknownHash = errors.New("password: unknown hash format")
+
+// argon2Params argon2id 参数，编码在哈希串里，调整配置后旧密码仍然可以校验
+type argon2Params struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+var (
+	algorithm  = AlgoBcrypt
+	bcryptCost = bcrypt.DefaultCost
+	argon2Cfg  = argon2Params{Time: 1, Memory: 64 * 1024, Threads: 4, KeyLen: 32, SaltLen: 16}
+
+	resetURL string
+	resetTTL = 30 * time.Minute
+)
+
+func Init(cfg *settings.PasswordConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	switch cfg.Algorithm {
+	case "":
+	case AlgoBcrypt, AlgoArgon2id:
+		algorithm = cfg.Algorithm
+	default:
+		return fmt.Errorf("password: unknown algorithm %q", cfg.Algorithm)
+	}
+	if cfg.BcryptCost > 0 {
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("password: bcrypt cost %d out of range", cfg.BcryptCost)
+		}
+		bcryptCost = cfg.BcryptCost
+	}
+	if cfg.Argon2Time > 0 {
+		argon2Cfg.Time = cfg.Argon2Time
+	}
+	if cfg.Argon2Memory > 0 {
+		argon2Cfg.Memory = cfg.Argon2Memory
+	}
+	if cfg.Argon2Threads > 0 {
+		argon2Cfg.Threads = cfg.Argon2Threads
+	}
+	if cfg.ResetTTL > 0 {
+		resetTTL = time.Duration(cfg.ResetTTL) * time.Second
+	}
+	resetURL = cfg.ResetURL
+	initPolicy(cfg)
+	return
+}
+
+// ResetTTL 重置密码链接的有效期
+func ResetTTL() time.Duration {
+	return resetTTL
+}
+
+// ResetURL 重置密码页面地址
+func ResetURL() string {
+	return resetURL
+}
+
+// Hash 用当前配置的算法计算密码哈希，结果自带算法和参数，可以直接存库
+func Hash(plain string) (string, error) {
+	if algorithm == AlgoArgon2id {
+		return hashArgon2(plain, argon2Cfg)
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(plain), bcryptCost)
+	return string(b), err
+}
+
+// Verify 校验密码，不正确时返回 ErrMismatch，比较过程是常量时间的
+// 根据哈希串的格式自动识别算法，切换算法后旧用户的密码仍然可以登录
+func Verify(plain, hashed string) error {
+	switch {
+	case strings.HasPrefix(hashed, "$argon2id$"):
+		p, salt, key, err := decodeArgon2(hashed)
+		if err != nil {
+			return err
+		}
+		other := argon2.IDKey([]byte(plain), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	case strings.HasPrefix(hashed, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	default:
+		return ErrUnknownHash
+	}
+}
+
+// NeedsRehash 哈希的算法或参数和当前配置不一致，登录成功后应该用明文重新计算并保存
+func NeedsRehash(hashed string) bool {
+	if strings.HasPrefix(hashed, "$argon2id$") {
+		if algorithm != AlgoArgon2id {
+			return true
+		}
+		p, _, _, err := decodeArgon2(hashed)
+		return err != nil || p.Time != argon2Cfg.Time || p.Memory != argon2Cfg.Memory || p.Threads != argon2Cfg.Threads
+	}
+	if algorithm != AlgoBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hashed))
+	return err != nil || cost != bcryptCost
+}
+
+// hashArgon2 编码格式与参考实现一致：$argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+func hashArgon2(plain string, p argon2Params) (string, error) {
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(plain), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2(hashed string) (p argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("password: unsupported argon2 version %d", version)
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	p.KeyLen = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,103 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"go_web_scaffolding/settings"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword 密码不满足强度要求，用 errors.As 取出 *PolicyError 拿到具体原因
+var ErrWeakPassword = errors.New("password: too weak")
+
+// PolicyError 不满足的全部规则
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password: " + strings.Join(e.Violations, "; ")
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// policy 密码强度要求，注册和修改密码时校验
+var policy = struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// NotContainUsername 密码中不能包含用户名
+	NotContainUsername bool
+}{
+	MinLength:          8,
+	MaxLength:          64,
+	RequireLower:       true,
+	RequireDigit:       true,
+	NotContainUsername: true,
+}
+
+func initPolicy(cfg *settings.PasswordConfig) {
+	if cfg.MinLength > 0 {
+		policy.MinLength = cfg.MinLength
+	}
+	if cfg.MaxLength > 0 {
+		policy.MaxLength = cfg.MaxLength
+	}
+	policy.RequireUpper = cfg.RequireUpper
+	policy.RequireLower = cfg.RequireLower
+	policy.RequireDigit = cfg.RequireDigit
+	policy.RequireSymbol = cfg.RequireSymbol
+	policy.NotContainUsername = cfg.NotContainUsername
+}
+
+// Validate 按配置的强度要求校验密码，不满足时返回 *PolicyError
+func Validate(plain, username string) error {
+	var v []string
+	n := utf8.RuneCountInString(plain)
+	if n < policy.MinLength {
+		v = append(v, fmt.Sprintf("at least %d characters", policy.MinLength))
+	}
+	// bcrypt 只取前 72 字节，太长的密码没有意义还会被用来做 DoS
+	if policy.MaxLength > 0 && n > policy.MaxLength {
+		v = append(v, fmt.Sprintf("at most %d characters", policy.MaxLength))
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range plain {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	if policy.RequireUpper && !upper {
+		v = append(v, "an uppercase letter")
+	}
+	if policy.RequireLower && !lower {
+		v = append(v, "a lowercase letter")
+	}
+	if policy.RequireDigit && !digit {
+		v = append(v, "a digit")
+	}
+	if policy.RequireSymbol && !symbol {
+		v = append(v, "a symbol")
+	}
+	if policy.NotContainUsername && username != "" && strings.Contains(strings.ToLower(plain), strings.ToLower(username)) {
+		v = append(v, "must not contain the username")
+	}
+	if len(v) > 0 {
+		return &PolicyError{Violations: v}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/pkg/dashboard"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// Message 收到的广播消息
+type Message struct {
+	Channel string
+	Payload string
+}
+
+// Bind 把 JSON 格式的 Payload 反序列化到 v
+func (m *Message) Bind(v interface{}) error {
+	return json.Unmarshal([]byte(m.Payload), v)
+}
+
+// Handler 消息处理函数，同一个订阅者里消息按顺序串行处理，耗时操作请自行异步
+type Handler func(ctx context.Context, msg *Message)
+
+const (
+	// receiveTimeout 每次等待消息的超时时间，超时后检查是否需要退出
+	receiveTimeout = time.Second
+	// initialBackoff、maxBackoff 连接出错后重连的首次和最大等待时间
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+)
+
+var (
+	mu       sync.RWMutex
+	handlers = make(map[string][]Handler)
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	running             atomic.Bool
+	received, reconnect atomic.Int64
+)
+
+func init() {
+	dashboard.Register("pubsub", func(ctx context.Context) interface{} {
+		mu.RLock()
+		channels := make([]string, 0, len(handlers))
+		for ch := range handlers {
+			channels = append(channels, ch)
+		}
+		mu.RUnlock()
+		return map[string]interface{}{
+			"running":    running.Load(),
+			"channels":   channels,
+			"received":   received.Load(),
+			"reconnects": reconnect.Load(),
+		}
+	})
+}
+
+// Handle 注册 channel 的处理函数，需要在 Start 之前调用
+func Handle(channel string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[channel] = append(handlers[channel], h)
+}
+
+// Publish 向 channel 广播消息，所有实例（包括自己）的订阅者都会收到
+// payload 为 string/[]byte 时原样发送，其它类型序列化为 JSON
+func Publish(ctx context.Context, channel string, payload interface{}) error {
+	var data interface{}
+	switch v := payload.(type) {
+	case string, []byte:
+		data = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = b
+	}
+	return redis.Ctx(ctx).Publish(channel, data).Err()
+}
+
+// Start 启动订阅协程，订阅所有已注册的 channel
+func Start() {
+	mu.RLock()
+	channels := make([]string, 0, len(handlers))
+	for ch := range handlers {
+		channels = append(channels, ch)
+	}
+	mu.RUnlock()
+	if len(channels) == 0 {
+		return
+	}
+
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		run(ctx, channels)
+	}()
+}
+
+// Stop 停止订阅并等待正在处理的消息处理完，和 http server 一起优雅关闭
+func Stop() {
+	if cancel == nil {
+		return
+	}
+	cancel()
+	wg.Wait()
+}
+
+// run 订阅主循环
+// 连接出错时关闭旧的订阅，指数退避后从 redis.Client() 重新订阅，
+// 这样 redis 重启或 profile 切换到新实例后都能自动恢复；重新订阅成功后退避时间从头算
+func run(ctx context.Context, channels []string) {
+	running.Store(true)
+	defer running.Store(false)
+	backoff := initialBackoff
+	for ctx.Err() == nil {
+		ps := redis.Client().Subscribe(channels...)
+		err := receive(ctx, ps, func() { backoff = initialBackoff })
+		_ = ps.Close()
+		if err == nil {
+			return
+		}
+		reconnect.Add(1)
+		zap.L().Error("pubsub receive failed, resubscribing",
+			zap.Strings("channels", channels),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// receive 持续接收消息直到 ctx 取消（返回 nil）或连接出错，订阅成功后调用一次 subscribed
+func receive(ctx context.Context, ps *goredis.PubSub, subscribed func()) error {
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		msg, err := ps.ReceiveTimeout(receiveTimeout)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		if subscribed != nil {
+			subscribed()
+			subscribed = nil
+		}
+		switch m := msg.(type) {
+		case *goredis.Message:
+			received.Add(1)
+			dispatch(ctx, &Message{Channel: m.Channel, Payload: m.Payload})
+		case *goredis.Subscription:
+			zap.L().Debug("pubsub subscription", zap.String("kind", m.Kind), zap.String("channel", m.Channel))
+		}
+	}
+}
+
+func dispatch(ctx context.Context, msg *Message) {
+	mu.RLock()
+	hs := handlers[msg.Channel]
+	mu.RUnlock()
+	for _, h := range hs {
+		func() {
+			// 单个处理函数 panic 不能把订阅协程带崩
+			defer func() {
+				if err := recover(); err != nil {
+					zap.L().Error("pubsub handler panic",
+						zap.String("channel", msg.Channel),
+						zap.Any("error", err))
+				}
+			}()
+			h(ctx, msg)
+		}()
+	}
+}
@@ -0,0 +1,128 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"go_web_scaffolding/pkg/httpclient"
+	"go_web_scaffolding/pkg/retry"
+	"go_web_scaffolding/settings"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenTTL APNs 的 provider token 一小时内有效，太频繁地换新 token 会被限流（TooManyProviderTokenUpdates）
+const tokenTTL = 50 * time.Minute
+
+// apns 用 .p8 密钥签的 provider token 调 APNs 的 HTTP/2 接口
+type apns struct {
+	host  string
+	topic string
+	keyID string
+	team  string
+	key   *ecdsa.PrivateKey
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func newAPNs(cfg *settings.APNsConfig) (*apns, error) {
+	pem, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("apns: read key: %w", err)
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("apns: parse key: %w", err)
+	}
+	host := "https://api.push.apple.com"
+	if cfg.Sandbox {
+		host = "https://api.sandbox.push.apple.com"
+	}
+	return &apns{host: host, topic: cfg.Topic, keyID: cfg.KeyID, team: cfg.TeamID, key: key}, nil
+}
+
+// providerToken 缓存的 provider token，快过期或者被 APNs 拒绝之后重新签
+func (a *apns) providerToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Since(a.issuedAt) < tokenTTL {
+		return a.token, nil
+	}
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"iss": a.team, "iat": now.Unix()})
+	t.Header["kid"] = a.keyID
+	s, err := t.SignedString(a.key)
+	if err != nil {
+		return "", err
+	}
+	a.token, a.issuedAt = s, now
+	return s, nil
+}
+
+func (a *apns) resetToken() {
+	a.mu.Lock()
+	a.token = ""
+	a.mu.Unlock()
+}
+
+func (a *apns) Send(ctx context.Context, token string, n *Notification) error {
+	aps := map[string]interface{}{"alert": map[string]string{"title": n.Title, "body": n.Body}}
+	if n.Sound != "" {
+		aps["sound"] = n.Sound
+	}
+	if n.Badge != nil {
+		aps["badge"] = *n.Badge
+	}
+	// 自定义数据和 aps 平级
+	payload := map[string]interface{}{"aps": aps}
+	for k, v := range n.Data {
+		payload[k] = v
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	jwtToken, err := a.providerToken()
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.host+"/3/device/"+token, bytes.NewReader(b))
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	req.Header.Set("Authorization", "bearer "+jwtToken)
+	req.Header.Set("apns-topic", a.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	resp, err := httpclient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var res struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&res)
+	err = fmt.Errorf("apns: status %d: %s", resp.StatusCode, res.Reason)
+	switch {
+	case resp.StatusCode == http.StatusGone, res.Reason == "BadDeviceToken", res.Reason == "Unregistered", res.Reason == "DeviceTokenNotForTopic":
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	case res.Reason == "ExpiredProviderToken":
+		a.resetToken()
+		return err
+	case retry.RetryableStatus(resp.StatusCode):
+		return err
+	}
+	return retry.Permanent(err)
+}
@@ -0,0 +1,111 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/pkg/httpclient"
+	"go_web_scaffolding/pkg/retry"
+	"go_web_scaffolding/settings"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+// fcm 用服务账号调 FCM 的 HTTP v1 接口，access token 由 oauth2 缓存，过期前自动换新
+type fcm struct {
+	url    string
+	client *http.Client
+}
+
+func newFCM(cfg *settings.FCMConfig) (*fcm, error) {
+	b, err := os.ReadFile(cfg.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("fcm: read credentials: %w", err)
+	}
+	// Firebase 控制台下载的服务账号 JSON
+	var sa struct {
+		ProjectID    string `json:"project_id"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+		TokenURI     string `json:"token_uri"`
+	}
+	if err = json.Unmarshal(b, &sa); err != nil {
+		return nil, fmt.Errorf("fcm: parse credentials: %w", err)
+	}
+	if sa.ProjectID == "" || sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, errors.New("fcm: credentials missing project_id, client_email or private_key")
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	conf := &jwt.Config{
+		Email:        sa.ClientEmail,
+		PrivateKey:   []byte(sa.PrivateKey),
+		PrivateKeyID: sa.PrivateKeyID,
+		Scopes:       []string{"https://www.googleapis.com/auth/firebase.messaging"},
+		TokenURL:     sa.TokenURI,
+	}
+	// 换 access token 也走 httpclient，超时和指标一致
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpclient.Client())
+	return &fcm{
+		url:    "https://fcm.googleapis.com/v1/projects/" + sa.ProjectID + "/messages:send",
+		client: oauth2.NewClient(ctx, conf.TokenSource(ctx)),
+	}, nil
+}
+
+func (f *fcm) Send(ctx context.Context, token string, n *Notification) error {
+	msg := map[string]interface{}{
+		"token":        token,
+		"notification": map[string]string{"title": n.Title, "body": n.Body},
+		"android":      map[string]interface{}{"priority": "high"},
+	}
+	if len(n.Data) > 0 {
+		msg["data"] = n.Data
+	}
+	b, err := json.Marshal(map[string]interface{}{"message": msg})
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(b))
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var res struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&res)
+	err = fmt.Errorf("fcm: status %d: %s %s", resp.StatusCode, res.Error.Status, res.Error.Message)
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	for _, d := range res.Error.Details {
+		if d.ErrorCode == "UNREGISTERED" {
+			return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+		}
+	}
+	if retry.RetryableStatus(resp.StatusCode) {
+		return err
+	}
+	return retry.Permanent(err)
+}
@@ -0,0 +1,15 @@
+package push
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var sent = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "app",
+	Subsystem: "push",
+	Name:      "sent_total",
+	Help:      "推送的设备数，result: success/invalid_token/failure/retry/dropped",
+}, []string{"platform", "result"})
+
+// Collectors 推送指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{sent}
+}
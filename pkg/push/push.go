@@ -0,0 +1,235 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/jobs"
+	"go_web_scaffolding/pkg/retry"
+	"go_web_scaffolding/pkg/workerpool"
+	"go_web_scaffolding/settings"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+)
+
+// 移动推送：业务按用户推送，不用关心用户有几台设备、是什么平台
+//
+//	err := push.ToUsers(ctx, []int64{uid}, &push.Notification{Title: "新消息", Body: "...", Data: map[string]string{"type": "chat"}})
+//
+// 查出用户的设备后按 batch 分成多个后台任务（jobs），任务里在 workerpool 的 "push" 池并发发送
+// 限流、服务端错误这类临时失败的设备按退避重新入队，最多尝试 max_attempts 次；
+// APNs/FCM 返回 token 失效（应用卸载、token 过期）时直接删除设备，下次不再推送
+// 没有开启任务队列时在 workerpool 里发送，临时失败不再重试；放弃推送的设备计入 result="dropped" 并记录日志
+
+// TypePush 推送一批设备的后台任务
+const TypePush = "push:send"
+
+// ErrInvalidToken token 已失效，设备要删掉
+var ErrInvalidToken = errors.New("push: invalid device token")
+
+// Notification 一条推送
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// Badge iOS 的角标数，nil 表示不修改
+	Badge *int   `json:"badge,omitempty"`
+	Sound string `json:"sound,omitempty"`
+	// Data 自定义数据，客户端按 type 之类的字段决定点开后跳到哪里
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// sender 一个平台的推送服务
+// 返回 ErrInvalidToken 时删除设备，retry.Permanent 包装的错误不重试，其它错误按退避重试
+type sender interface {
+	Send(ctx context.Context, token string, n *Notification) error
+}
+
+// target 一台设备
+type target struct {
+	Platform string `json:"platform"`
+	Token    string `json:"token"`
+}
+
+// batch 一个后台任务推送的设备
+type batch struct {
+	Targets      []target      `json:"targets"`
+	Notification *Notification `json:"notification"`
+	// Attempt 已经尝试过的次数
+	Attempt int `json:"attempt"`
+}
+
+var (
+	enable       bool
+	batchSize    = 500
+	maxAttempts  = 5
+	batchTimeout = 10 * time.Minute
+	backoff      = retry.Policy{Initial: 30 * time.Second, Max: 30 * time.Minute, Jitter: 0.2}
+
+	senders = make(map[string]sender)
+)
+
+func init() {
+	jobs.Register(TypePush, handle)
+}
+
+// Init 按配置创建 APNs、FCM 客户端，没有开启时 ToUsers 只打印日志
+func Init(cfg *settings.PushConfig) (err error) {
+	if cfg == nil || !cfg.Enable {
+		return
+	}
+	if cfg.Batch > 0 {
+		batchSize = cfg.Batch
+	}
+	if cfg.MaxAttempts > 0 {
+		maxAttempts = cfg.MaxAttempts
+	}
+	if cfg.BatchTimeout > 0 {
+		batchTimeout = time.Duration(cfg.BatchTimeout) * time.Second
+	}
+	if c := cfg.APNs; c != nil && c.KeyFile != "" {
+		if senders[models.PlatformIOS], err = newAPNs(c); err != nil {
+			return
+		}
+	}
+	if c := cfg.FCM; c != nil && c.CredentialsFile != "" {
+		if senders[models.PlatformAndroid], err = newFCM(c); err != nil {
+			return
+		}
+	}
+	enable = true
+	return
+}
+
+// ToUsers 推送给用户的所有设备，放进后台任务就返回
+func ToUsers(ctx context.Context, userIDs []int64, n *Notification) error {
+	if !enable {
+		logger.Ctx(ctx).Info("push not sent, disabled", zap.Int64s("user_ids", userIDs), zap.String("title", n.Title))
+		return nil
+	}
+	devices, err := mysql.ListPushDevicesByUsers(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	targets := make([]target, 0, len(devices))
+	for _, d := range devices {
+		// 没有配置的平台不推送
+		if senders[d.Platform] == nil {
+			sent.WithLabelValues(d.Platform, "dropped").Inc()
+			continue
+		}
+		targets = append(targets, target{Platform: d.Platform, Token: d.Token})
+	}
+	if dropped := len(devices) - len(targets); dropped > 0 {
+		logger.Module(ctx, "push").Debug("platform not configured, devices skipped", zap.Int("dropped", dropped))
+	}
+	for chunk := range slices.Chunk(targets, batchSize) {
+		if err = dispatch(ctx, &batch{Targets: chunk, Notification: n}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch 一批设备交给后台任务；重试由这里按失败的设备重新入队，不用 asynq 的重试，避免重复推送已经成功的设备
+// 任务的超时按一批算（batch_timeout），asynq 默认的 30 分钟和 workerpool 默认的 30 秒都不合适
+func dispatch(ctx context.Context, b *batch, opts ...asynq.Option) error {
+	if jobs.Enabled() {
+		_, err := jobs.Enqueue(ctx, TypePush, b, append([]asynq.Option{asynq.MaxRetry(0), asynq.Timeout(batchTimeout)}, opts...)...)
+		return err
+	}
+	// 发送的请求在 "push" 池里，这一批要等它们，放在另一个池子里，避免占着 push 池的位置等自己
+	return workerpool.Get("push_batch").Submit(ctx, func(ctx context.Context) error {
+		if failed := deliver(ctx, b); len(failed) > 0 {
+			// 没有任务队列就没有重试
+			drop(ctx, failed, "no job queue to retry")
+		}
+		return nil
+	})
+}
+
+// drop 放弃推送这些设备，计数并记录日志
+func drop(ctx context.Context, targets []target, reason string) {
+	for _, t := range targets {
+		sent.WithLabelValues(t.Platform, "dropped").Inc()
+	}
+	logger.Module(ctx, "push").Warn("push targets dropped", zap.Int("count", len(targets)), zap.String("reason", reason))
+}
+
+func handle(ctx context.Context, t *jobs.Task) error {
+	var b batch
+	if err := t.Bind(&b); err != nil {
+		return retry.Permanent(err)
+	}
+	failed := deliver(ctx, &b)
+	if len(failed) == 0 {
+		return nil
+	}
+	b.Attempt++
+	log := logger.Module(ctx, "push").With(zap.Int("failed", len(failed)), zap.Int("attempt", b.Attempt))
+	if b.Attempt >= maxAttempts {
+		log.Error("push failed, giving up")
+		drop(ctx, failed, "max attempts reached")
+		return nil
+	}
+	delay := backoff.Backoff(b.Attempt)
+	log.Warn("push failed, retrying", zap.Duration("backoff", delay))
+	return dispatch(ctx, &batch{Targets: failed, Notification: b.Notification, Attempt: b.Attempt}, asynq.ProcessIn(delay))
+}
+
+// deliver 并发推送一批设备，删除失效的 token，返回临时失败、需要重试的设备
+func deliver(ctx context.Context, b *batch) (failed []target) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		invalid []string
+	)
+	for _, t := range b.Targets {
+		s := senders[t.Platform]
+		if s == nil {
+			// 入队之后改了配置，去掉了这个平台
+			drop(ctx, []target{t}, "platform not configured")
+			continue
+		}
+		wg.Add(1)
+		err := workerpool.Get("push").Submit(ctx, func(ctx context.Context) error {
+			defer wg.Done()
+			err := s.Send(ctx, t.Token, b.Notification)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				sent.WithLabelValues(t.Platform, "success").Inc()
+			case errors.Is(err, ErrInvalidToken):
+				sent.WithLabelValues(t.Platform, "invalid_token").Inc()
+				invalid = append(invalid, t.Token)
+			case retry.IsPermanent(err):
+				sent.WithLabelValues(t.Platform, "failure").Inc()
+				logger.Module(ctx, "push").Error("push failed", zap.String("platform", t.Platform), zap.Error(err))
+			default:
+				sent.WithLabelValues(t.Platform, "retry").Inc()
+				failed = append(failed, t)
+			}
+			return nil
+		})
+		if err != nil {
+			// 关机了，留给下一次重试
+			wg.Done()
+			mu.Lock()
+			failed = append(failed, t)
+			mu.Unlock()
+		}
+	}
+	wg.Wait()
+	if len(invalid) > 0 {
+		if err := mysql.DeletePushTokens(ctx, invalid); err != nil {
+			logger.Module(ctx, "push").Error("delete invalid push tokens failed", zap.Error(err))
+		}
+	}
+	return
+}
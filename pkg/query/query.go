@@ -0,0 +1,212 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// 把列表接口的 query 参数转成 SQL 片段：
+//
+//	?sort=-create_time,name       =>  ORDER BY `create_time` DESC, `name` ASC
+//	?status=active                =>  `status` = ?
+//	?create_time[gte]=2024-01-01  =>  `create_time` >= ?
+//	?name[like]=abc               =>  `name` LIKE ?（参数为 %abc%）
+//	?id[in]=1,2,3                 =>  `id` IN (?, ?, ?)
+//
+// 用 squirrel 构造查询时，Apply 把条件和排序加到构造器上；手写 SQL 时用 Where/OrderBy 拼接
+// 列名只能来自 Spec 白名单，值一律走占位符，所以客户端传什么都拼不出额外的 SQL
+// 不在白名单里的参数（page、size、lang 等）直接忽略
+
+var (
+	ErrInvalidSort   = errors.New("query: invalid sort field")
+	ErrInvalidFilter = errors.New("query: invalid filter")
+)
+
+// Op 筛选操作符
+type Op string
+
+const (
+	Eq   Op = "eq"
+	Ne   Op = "ne"
+	Gt   Op = "gt"
+	Gte  Op = "gte"
+	Lt   Op = "lt"
+	Lte  Op = "lte"
+	Like Op = "like"
+	In   Op = "in"
+)
+
+var opSQL = map[Op]string{
+	Eq:   "=",
+	Ne:   "<>",
+	Gt:   ">",
+	Gte:  ">=",
+	Lt:   "<",
+	Lte:  "<=",
+	Like: "LIKE",
+	In:   "IN",
+}
+
+// maxInValues in 查询最多允许的值个数
+const maxInValues = 100
+
+// Field 一个允许筛选的字段，Ops 为空时只允许等值查询
+type Field struct {
+	Column string
+	Ops    []Op
+}
+
+func (f Field) allow(op Op) bool {
+	if len(f.Ops) == 0 {
+		return op == Eq
+	}
+	for _, o := range f.Ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Spec 列表接口允许的排序和筛选字段，key 是参数名，value 是实际的列名
+type Spec struct {
+	Sorts   map[string]string
+	Filters map[string]Field
+	// DefaultSort 没有传 sort 时使用，格式和 sort 参数一样，如 "-id"
+	DefaultSort string
+}
+
+// Query 解析后的条件和排序
+type Query struct {
+	conds  []string
+	args   []interface{}
+	orders []string
+}
+
+// Parse 按 spec 解析 query 参数
+func Parse(values url.Values, spec *Spec) (q *Query, err error) {
+	q = new(Query)
+
+	s := values.Get("sort")
+	if s == "" {
+		s = spec.DefaultSort
+	}
+	if s != "" {
+		for _, item := range strings.Split(s, ",") {
+			item = strings.TrimSpace(item)
+			dir := "ASC"
+			if strings.HasPrefix(item, "-") {
+				item, dir = item[1:], "DESC"
+			}
+			column, ok := spec.Sorts[item]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidSort, item)
+			}
+			q.orders = append(q.orders, "`"+column+"` "+dir)
+		}
+	}
+
+	// 参数名排序，保证同样的请求生成同样的 SQL
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name, op := key, Eq
+		if i := strings.IndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+			name, op = key[:i], Op(key[i+1:len(key)-1])
+		}
+		field, ok := spec.Filters[name]
+		if !ok {
+			continue
+		}
+		if _, known := opSQL[op]; !known || !field.allow(op) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFilter, key)
+		}
+		if err = q.filter(field.Column, op, values.Get(key)); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, key)
+		}
+	}
+	return q, nil
+}
+
+func (q *Query) filter(column string, op Op, value string) error {
+	col := "`" + column + "`"
+	switch op {
+	case In:
+		vals := strings.Split(value, ",")
+		if value == "" || len(vals) > maxInValues {
+			return ErrInvalidFilter
+		}
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(vals)), ", ")
+		q.conds = append(q.conds, col+" IN ("+marks+")")
+		for _, v := range vals {
+			q.args = append(q.args, strings.TrimSpace(v))
+		}
+	case Like:
+		q.And(col+" LIKE ?", "%"+escapeLike(value)+"%")
+	default:
+		q.And(col+" "+opSQL[op]+" ?", value)
+	}
+	return nil
+}
+
+// And 追加一个条件，比如游标分页的 keyset 条件或者按当前用户过滤
+func (q *Query) And(cond string, args ...interface{}) {
+	if cond == "" {
+		return
+	}
+	q.conds = append(q.conds, cond)
+	q.args = append(q.args, args...)
+}
+
+// Where WHERE 子句和参数，没有条件时返回空字符串
+func (q *Query) Where() (clause string, args []interface{}) {
+	if len(q.conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(q.conds, " AND "), q.args
+}
+
+// OrderBy ORDER BY 子句，fallback 是没有排序字段时使用的排序，如 "`id` DESC"
+// 自定义排序后面也会追加 fallback，保证排序字段有重复值时分页结果稳定
+func (q *Query) OrderBy(fallback string) string {
+	orders := q.Orders(fallback)
+	if len(orders) == 0 {
+		return ""
+	}
+	return " ORDER BY " + strings.Join(orders, ", ")
+}
+
+// Filter 全部条件组成的 squirrel 条件，没有条件时返回 nil（Where(nil) 不会加任何条件）
+func (q *Query) Filter() sq.Sqlizer {
+	if len(q.conds) == 0 {
+		return nil
+	}
+	return sq.Expr(strings.Join(q.conds, " AND "), q.args...)
+}
+
+// Orders 排序字段列表，用法同 OrderBy
+func (q *Query) Orders(fallback string) []string {
+	orders := q.orders
+	if fallback != "" {
+		orders = append(orders[:len(orders):len(orders)], fallback)
+	}
+	return orders
+}
+
+// Apply 把条件和排序加到 squirrel 的 select 构造器上
+func (q *Query) Apply(b sq.SelectBuilder, fallback string) sq.SelectBuilder {
+	return b.Where(q.Filter()).OrderBy(q.Orders(fallback)...)
+}
+
+// escapeLike 转义 LIKE 的通配符，用户输入的 % 和 _ 按普通字符匹配
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,88 @@
+package rbac
+
+import (
+	"context"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/models"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+)
+
+// dbTimeout 适配器每次读写 MySQL 的超时时间，casbin 的接口不带 ctx
+const dbTimeout = 5 * time.Second
+
+// adapter 把策略存在 MySQL 的 casbin_rule 表里，实现 persist.Adapter
+type adapter struct{}
+
+var _ persist.Adapter = adapter{}
+
+func newRule(ptype string, values []string) *models.CasbinRule {
+	r := &models.CasbinRule{PType: ptype}
+	fields := []*string{&r.V0, &r.V1, &r.V2, &r.V3, &r.V4, &r.V5}
+	for i, v := range values {
+		if i >= len(fields) {
+			break
+		}
+		*fields[i] = v
+	}
+	return r
+}
+
+// LoadPolicy 从数据库加载全部策略到 model
+func (adapter) LoadPolicy(m model.Model) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	rules, err := mysql.ListCasbinRules(ctx)
+	if err != nil {
+		return err
+	}
+	for _, r := range rules {
+		line := append([]string{r.PType}, r.Values()...)
+		// 去掉末尾的空字段，否则字段数和 model 定义对不上
+		for len(line) > 1 && line[len(line)-1] == "" {
+			line = line[:len(line)-1]
+		}
+		if err = persist.LoadPolicyArray(line, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SavePolicy 用 model 里的策略整体覆盖数据库
+func (adapter) SavePolicy(m model.Model) error {
+	var rules []*models.CasbinRule
+	for _, sec := range []string{"p", "g"} {
+		for ptype, ast := range m[sec] {
+			for _, values := range ast.Policy {
+				rules = append(rules, newRule(ptype, values))
+			}
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	return mysql.ReplaceCasbinRules(ctx, rules)
+}
+
+// AddPolicy 自动保存：新增一条策略
+func (adapter) AddPolicy(sec, ptype string, rule []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	return mysql.InsertCasbinRule(ctx, newRule(ptype, rule))
+}
+
+// RemovePolicy 自动保存：删除一条策略
+func (adapter) RemovePolicy(sec, ptype string, rule []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	return mysql.DeleteCasbinRule(ctx, newRule(ptype, rule))
+}
+
+// RemoveFilteredPolicy 自动保存：按字段过滤删除策略
+func (adapter) RemoveFilteredPolicy(sec, ptype string, fieldIndex int, fieldValues ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	return mysql.DeleteCasbinRulesFiltered(ctx, ptype, fieldIndex, fieldValues...)
+}
@@ -0,0 +1,168 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/pubsub"
+	"go_web_scaffolding/settings"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"go.uber.org/zap"
+)
+
+// ErrDisabled 没有开启 RBAC 时调用管理函数返回它
+var ErrDisabled = errors.New("rbac: disabled")
+
+// reloadChannel 策略变更后广播，其它实例收到后从数据库重新加载
+const reloadChannel = "rbac:reload"
+
+// defaultModel 内置的 RBAC 模型
+// sub 为用户 ID 或角色名，obj 为请求路径（支持 /api/v1/users/:id 和 /api/v1/* 这样的写法），act 为 HTTP 方法，* 表示任意方法
+const defaultModel = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && keyMatch2(r.obj, p.obj) && (r.act == p.act || p.act == "*")
+`
+
+var enforcer *casbin.SyncedEnforcer
+
+func init() {
+	dashboard.Register("rbac", func(ctx context.Context) interface{} {
+		if enforcer == nil {
+			return map[string]interface{}{"enabled": false}
+		}
+		policies, _ := enforcer.GetPolicy()
+		groupings, _ := enforcer.GetGroupingPolicy()
+		return map[string]interface{}{
+			"enabled":   true,
+			"policies":  len(policies),
+			"groupings": len(groupings),
+		}
+	})
+}
+
+// Init 创建 enforcer 并从数据库加载策略，需要在 mysql.Init 之后、pubsub.Start 之前调用
+// 未开启时不做任何事，Authorize 中间件直接放行
+func Init(cfg *settings.RBACConfig) (err error) {
+	if cfg == nil || !cfg.Enable {
+		return
+	}
+	var m model.Model
+	if cfg.Model != "" {
+		m, err = model.NewModelFromFile(cfg.Model)
+	} else {
+		m, err = model.NewModelFromString(defaultModel)
+	}
+	if err != nil {
+		return
+	}
+	e, err := casbin.NewSyncedEnforcer(m, adapter{})
+	if err != nil {
+		return
+	}
+	enforcer = e
+	// 任意实例修改了策略，所有实例都重新加载一次
+	pubsub.Handle(reloadChannel, func(ctx context.Context, msg *pubsub.Message) {
+		if err := enforcer.LoadPolicy(); err != nil {
+			zap.L().Error("rbac reload policy failed", zap.Error(err))
+		}
+	})
+	return
+}
+
+// Enabled 是否开启了 RBAC
+func Enabled() bool {
+	return enforcer != nil
+}
+
+// Enforce 判断 sub 是否可以对 obj 执行 act
+func Enforce(sub, obj, act string) (bool, error) {
+	if enforcer == nil {
+		return true, nil
+	}
+	return enforcer.Enforce(sub, obj, act)
+}
+
+// Policies 全部权限策略和角色继承关系
+func Policies() (policies, groupings [][]string, err error) {
+	if enforcer == nil {
+		return nil, nil, ErrDisabled
+	}
+	if policies, err = enforcer.GetPolicy(); err != nil {
+		return
+	}
+	groupings, err = enforcer.GetGroupingPolicy()
+	return
+}
+
+// AddPolicy 新增一条权限策略，已存在时 added 为 false
+func AddPolicy(ctx context.Context, sub, obj, act string) (added bool, err error) {
+	if enforcer == nil {
+		return false, ErrDisabled
+	}
+	if added, err = enforcer.AddPolicy(sub, obj, act); err == nil && added {
+		notify(ctx)
+	}
+	return
+}
+
+// RemovePolicy 删除一条权限策略，不存在时 removed 为 false
+func RemovePolicy(ctx context.Context, sub, obj, act string) (removed bool, err error) {
+	if enforcer == nil {
+		return false, ErrDisabled
+	}
+	if removed, err = enforcer.RemovePolicy(sub, obj, act); err == nil && removed {
+		notify(ctx)
+	}
+	return
+}
+
+// RolesForUser 用户直接拥有的角色
+func RolesForUser(user string) ([]string, error) {
+	if enforcer == nil {
+		return nil, ErrDisabled
+	}
+	return enforcer.GetRolesForUser(user)
+}
+
+// AddRoleForUser 给用户添加角色，已拥有时 added 为 false
+func AddRoleForUser(ctx context.Context, user, role string) (added bool, err error) {
+	if enforcer == nil {
+		return false, ErrDisabled
+	}
+	if added, err = enforcer.AddRoleForUser(user, role); err == nil && added {
+		notify(ctx)
+	}
+	return
+}
+
+// DeleteRoleForUser 收回用户的角色，没有该角色时 removed 为 false
+func DeleteRoleForUser(ctx context.Context, user, role string) (removed bool, err error) {
+	if enforcer == nil {
+		return false, ErrDisabled
+	}
+	if removed, err = enforcer.DeleteRoleForUser(user, role); err == nil && removed {
+		notify(ctx)
+	}
+	return
+}
+
+// notify 通知其它实例重新加载策略，失败只记日志，本实例的策略已经生效
+func notify(ctx context.Context) {
+	if err := pubsub.Publish(ctx, reloadChannel, "reload"); err != nil {
+		zap.L().Warn("rbac publish reload failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,159 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/pkg/breaker"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// 通用的重试：指数退避 + 随机抖动，等待期间 ctx 取消立即返回
+//
+//	err := retry.Do(ctx, retry.Policy{MaxAttempts: 5}, func(ctx context.Context) error {
+//		return send(ctx, msg)
+//	})
+//
+// 第 n 次重试前等待 Initial * Multiplier^(n-1)，不超过 Max，再在 [d*(1-Jitter), d] 之间随机取值，
+// 多个实例同时失败时不会在同一时刻一起重试，把刚恢复的依赖再次打垮
+//
+// 哪些错误可以重试由 Policy.Retryable 判断，没有设置时除了下面几种都重试：
+//
+//   - 用 Permanent 包装的错误：调用方明确知道重试也没用，比如参数错误、4xx
+//   - context.Canceled，以及 Do 的 ctx 已经结束
+//   - 熔断器拒绝的请求（breaker.ErrOpen）
+
+const (
+	defaultMaxAttempts = 3
+	defaultInitial     = 100 * time.Millisecond
+	defaultMax         = 10 * time.Second
+	defaultMultiplier  = 2
+)
+
+// Policy 重试策略，零值可以直接用：最多 3 次，100ms 起步每次翻倍，最长等待 10 秒
+type Policy struct {
+	// MaxAttempts 最多执行几次（包括第一次），默认 3
+	MaxAttempts int
+	// Initial 第一次重试前的等待时间，默认 100ms
+	Initial time.Duration
+	// Max 单次等待的上限，默认 10s
+	Max time.Duration
+	// Multiplier 每次等待时间的倍数，默认 2
+	Multiplier float64
+	// Jitter 随机抖动的比例，0 到 1，0 表示不抖动
+	Jitter float64
+	// Retryable 判断错误能否重试，为 nil 时使用 Temporary
+	Retryable func(err error) bool
+	// OnRetry 每次重试前调用，attempt 是刚失败的那次是第几次，一般用来记日志
+	OnRetry func(attempt int, err error, wait time.Duration)
+}
+
+// Do 按策略执行 fn，成功或者遇到不能重试的错误时返回；次数用完时返回最后一次的错误
+// Permanent 包装的错误返回时去掉包装
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	_, err := DoValue(ctx, p, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// DoValue 同 Do，fn 有返回值
+func DoValue[T any](ctx context.Context, p Policy, fn func(ctx context.Context) (T, error)) (v T, err error) {
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultMaxAttempts
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = Temporary
+	}
+	for attempt := 1; ; attempt++ {
+		v, err = fn(ctx)
+		if err == nil {
+			return
+		}
+		var pe *permanentError
+		if errors.As(err, &pe) {
+			return v, pe.err
+		}
+		if attempt >= attempts || !retryable(err) || ctx.Err() != nil {
+			return
+		}
+		wait := p.Backoff(attempt)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, wait)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Backoff 第 attempt 次失败后的等待时间，attempt 从 1 开始
+// 也可以单独使用，比如 outbox 按失败次数计算下次投递的时间
+func (p Policy) Backoff(attempt int) time.Duration {
+	initial, maxWait, mult := p.Initial, p.Max, p.Multiplier
+	if initial <= 0 {
+		initial = defaultInitial
+	}
+	if maxWait <= 0 {
+		maxWait = defaultMax
+	}
+	if mult < 1 {
+		mult = defaultMultiplier
+	}
+	d := float64(initial)
+	for i := 1; i < attempt && d < float64(maxWait); i++ {
+		d *= mult
+	}
+	d = min(d, float64(maxWait))
+	if p.Jitter > 0 {
+		d -= d * min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent 标记为不需要重试的错误，Do 遇到它直接返回 err
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent err 是否被 Permanent 包装过，给自己实现重试的地方（比如后台任务）判断要不要再试
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// Temporary 默认的判断：调用方取消、熔断器拒绝之外的错误都重试
+// 单次请求超时（比如 http.Client 的 Timeout）可以重试，Do 的 ctx 本身到期时 Do 会直接返回
+// 熔断打开说明依赖已经确认不可用，在这里等待重试只会占着 goroutine，不如直接返回
+func Temporary(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, breaker.ErrOpen)
+}
+
+// RetryableStatus HTTP 状态码是否值得重试：429 和 5xx，501 Not Implemented 重试也没用
+// 其它 4xx 是请求本身的问题，调用方应该用 Permanent 包装
+func RetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests ||
+		code >= http.StatusInternalServerError && code != http.StatusNotImplemented
+}
@@ -0,0 +1,269 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/dao/redis"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
+	"go.yaml.in/yaml/v3"
+)
+
+// 开发、测试环境的初始数据，`app seed [env]` 把 seeds/<env>/ 下的文件按文件名顺序导入：
+//
+//	*.sql   原样执行，一条语句以行尾的分号结束，需要自己保证可以重复执行（INSERT IGNORE、ON DUPLICATE KEY UPDATE）
+//	*.yaml  按下面的格式写入 MySQL 和 Redis，可以重复执行：
+//
+//	mysql:
+//	  - table: id_segment
+//	    key: [biz_tag]      # 主键或唯一索引的列，PostgreSQL、SQLite 必填
+//	    rows:
+//	      - {biz_tag: default, max_id: 0, step: 1000}
+//	redis:
+//	  - key: feature:flags
+//	    type: hash          # string、hash、set、list、zset
+//	    value: {new_ui: "1"}
+//	    ttl: 0              # 秒，0 表示不过期
+//
+// MySQL 的行用 INSERT ... ON DUPLICATE KEY UPDATE 写入，按主键或唯一索引覆盖，
+// PostgreSQL、SQLite 用 INSERT ... ON CONFLICT (key) DO UPDATE；
+// Redis 的 key 先删除再写入，执行多少次结果都一样
+// 种子数据里的密码要填哈希后的值
+
+// Dir 种子文件的根目录
+var Dir = "seeds"
+
+// identifier 表名、列名只允许字母数字下划线，直接拼进 SQL
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type fixture struct {
+	MySQL []tableRows  `yaml:"mysql"`
+	Redis []redisEntry `yaml:"redis"`
+}
+
+type tableRows struct {
+	Table string                   `yaml:"table"`
+	Key   []string                 `yaml:"key"`
+	Rows  []map[string]interface{} `yaml:"rows"`
+}
+
+type redisEntry struct {
+	Key   string    `yaml:"key"`
+	Type  string    `yaml:"type"`
+	Value yaml.Node `yaml:"value"`
+	TTL   int       `yaml:"ttl"`
+}
+
+// Run 导入 env 环境的全部种子文件
+func Run(ctx context.Context, env string) error {
+	dir := filepath.Join(Dir, env)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("seed: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		switch filepath.Ext(name) {
+		case ".sql":
+			err = loadSQL(ctx, path)
+		case ".yaml", ".yml":
+			err = loadYAML(ctx, path)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("seed %s: %w", path, err)
+		}
+		zap.L().Info("seed loaded", zap.String("file", path))
+	}
+	return nil
+}
+
+func loadSQL(ctx context.Context, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return mysql.WithTx(ctx, func(ctx context.Context) error {
+		tx, _ := mysql.TxFromContext(ctx)
+		for _, stmt := range splitSQL(string(b)) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("%w\n%s", err, stmt)
+			}
+		}
+		return nil
+	})
+}
+
+// splitSQL 按行尾的分号切分语句，跳过 -- 开头的注释行
+func splitSQL(s string) (stmts []string) {
+	var cur strings.Builder
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		cur.WriteString(line)
+		cur.WriteByte('\n')
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(cur.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return
+}
+
+func loadYAML(ctx context.Context, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f fixture
+	if err = yaml.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	if len(f.MySQL) > 0 {
+		err = mysql.WithTx(ctx, func(ctx context.Context) error {
+			for _, t := range f.MySQL {
+				if err := upsertRows(ctx, t); err != nil {
+					return fmt.Errorf("table %s: %w", t.Table, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range f.Redis {
+		if err = setRedis(ctx, e); err != nil {
+			return fmt.Errorf("redis key %s: %w", e.Key, err)
+		}
+	}
+	return nil
+}
+
+// upsertRows 每行一条 upsert 语句，行之间的列可以不一样
+func upsertRows(ctx context.Context, t tableRows) error {
+	if !identifier.MatchString(t.Table) {
+		return errors.New("invalid table name")
+	}
+	// PostgreSQL 和 SQLite 用 ON CONFLICT，需要知道冲突的唯一键
+	onConflict := mysql.Driver() != mysql.DriverMySQL
+	if onConflict && len(t.Key) == 0 {
+		return errors.New("key is required for postgres and sqlite")
+	}
+	keys := make([]string, len(t.Key))
+	for i, k := range t.Key {
+		if !identifier.MatchString(k) {
+			return fmt.Errorf("invalid key column %q", k)
+		}
+		keys[i] = "`" + k + "`"
+	}
+	tx, _ := mysql.TxFromContext(ctx)
+	for _, row := range t.Rows {
+		cols := make([]string, 0, len(row))
+		for col := range row {
+			if !identifier.MatchString(col) {
+				return fmt.Errorf("invalid column name %q", col)
+			}
+			cols = append(cols, col)
+		}
+		sort.Strings(cols)
+		args := make([]interface{}, len(cols))
+		quoted := make([]string, len(cols))
+		updates := make([]string, len(cols))
+		for i, col := range cols {
+			args[i] = row[col]
+			quoted[i] = "`" + col + "`"
+			if onConflict {
+				updates[i] = quoted[i] + " = EXCLUDED." + quoted[i]
+			} else {
+				updates[i] = quoted[i] + " = VALUES(" + quoted[i] + ")"
+			}
+		}
+		upsert := "ON DUPLICATE KEY UPDATE"
+		if onConflict {
+			upsert = "ON CONFLICT (" + strings.Join(keys, ", ") + ") DO UPDATE SET"
+		}
+		query := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s) %s %s",
+			t.Table, strings.Join(quoted, ", "),
+			strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", "),
+			upsert, strings.Join(updates, ", "))
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setRedis 删除后重新写入，整个过程在一个 MULTI 里
+func setRedis(ctx context.Context, e redisEntry) error {
+	if e.Key == "" {
+		return errors.New("empty key")
+	}
+	pipe := redis.Ctx(ctx).TxPipeline()
+	pipe.Del(e.Key)
+	switch e.Type {
+	case "", "string":
+		var v string
+		if err := e.Value.Decode(&v); err != nil {
+			return err
+		}
+		pipe.Set(e.Key, v, 0)
+	case "hash":
+		var v map[string]interface{}
+		if err := e.Value.Decode(&v); err != nil {
+			return err
+		}
+		pipe.HMSet(e.Key, v)
+	case "set":
+		var v []interface{}
+		if err := e.Value.Decode(&v); err != nil {
+			return err
+		}
+		pipe.SAdd(e.Key, v...)
+	case "list":
+		var v []interface{}
+		if err := e.Value.Decode(&v); err != nil {
+			return err
+		}
+		pipe.RPush(e.Key, v...)
+	case "zset":
+		var v map[string]float64
+		if err := e.Value.Decode(&v); err != nil {
+			return err
+		}
+		members := make([]goredis.Z, 0, len(v))
+		for m, score := range v {
+			members = append(members, goredis.Z{Score: score, Member: m})
+		}
+		pipe.ZAdd(e.Key, members...)
+	default:
+		return fmt.Errorf("unknown type %q", e.Type)
+	}
+	if e.TTL > 0 {
+		pipe.Expire(e.Key, time.Duration(e.TTL)*time.Second)
+	}
+	_, err := pipe.Exec()
+	return err
+}
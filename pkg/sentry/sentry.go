@@ -0,0 +1,124 @@
+package sentry
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/k8s"
+	"go_web_scaffolding/pkg/version"
+	"go_web_scaffolding/settings"
+	"net/http"
+	"strconv"
+	"time"
+
+	sg "github.com/getsentry/sentry-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// panic 和接口返回的 5xx 错误上报到 Sentry，事件里带上请求信息、堆栈和 request_id/trace_id/user_id，
+// 在 Sentry 上看到异常后可以用 request_id 到日志里找到这个请求的完整日志
+//
+// 没有开启或者没有配置 dsn 时所有函数都是空操作，调用方不需要判断
+
+var enabled bool
+
+func Init(cfg *settings.SentryConfig) (err error) {
+	if cfg == nil || !cfg.Enable || cfg.DSN == "" {
+		return
+	}
+	rate := cfg.SampleRate
+	if rate <= 0 {
+		rate = 1
+	}
+	err = sg.Init(sg.ClientOptions{
+		Dsn:              cfg.DSN,
+		SampleRate:       rate,
+		Environment:      settings.Conf.Mode,
+		Release:          version.Get().Version,
+		ServerName:       k8s.Pod().Name,
+		AttachStacktrace: true,
+		// 不上报 Cookie、Authorization 等敏感请求头
+		SendDefaultPII: false,
+	})
+	if err != nil {
+		return fmt.Errorf("sentry: %w", err)
+	}
+	enabled = true
+	return
+}
+
+// Enabled 是否开启了上报
+func Enabled() bool {
+	return enabled
+}
+
+// Flush 等待缓冲区里的事件发送完，程序退出前调用
+func Flush(timeout time.Duration) {
+	if enabled {
+		sg.Flush(timeout)
+	}
+}
+
+// CapturePanic 上报 recover 到的 panic，需要在 recover 所在的 defer 函数里调用，堆栈才是 panic 发生处的
+// fingerprint 和日志里的 panic 指纹一致，同一类 panic 在 Sentry 上聚合成一个 issue
+func CapturePanic(r *http.Request, err any, fingerprint string) {
+	if !enabled {
+		return
+	}
+	recoverPanic(requestHub(r), r.Context(), err, fingerprint)
+}
+
+// CapturePanicContext 同 CapturePanic，用于没有 *http.Request 的场景（gRPC、后台任务）
+func CapturePanicContext(ctx context.Context, err any, fingerprint string) {
+	if !enabled {
+		return
+	}
+	hub := sg.CurrentHub().Clone()
+	hub.ConfigureScope(func(scope *sg.Scope) {
+		setContextTags(scope, ctx)
+	})
+	recoverPanic(hub, ctx, err, fingerprint)
+}
+
+func recoverPanic(hub *sg.Hub, ctx context.Context, err any, fingerprint string) {
+	hub.ConfigureScope(func(scope *sg.Scope) {
+		scope.SetLevel(sg.LevelFatal)
+		if fingerprint != "" {
+			scope.SetFingerprint([]string{fingerprint})
+		}
+	})
+	hub.RecoverWithContext(ctx, err)
+}
+
+// CaptureError 上报接口处理过程中的错误
+func CaptureError(r *http.Request, err error) {
+	if !enabled || err == nil {
+		return
+	}
+	requestHub(r).CaptureException(err)
+}
+
+// requestHub 每个请求用独立的 hub，请求相关的 tag 不会串到别的请求的事件里
+func requestHub(r *http.Request) *sg.Hub {
+	hub := sg.CurrentHub().Clone()
+	hub.ConfigureScope(func(scope *sg.Scope) {
+		scope.SetRequest(r)
+		setContextTags(scope, r.Context())
+	})
+	return hub
+}
+
+func setContextTags(scope *sg.Scope, ctx context.Context) {
+	if id := ctxutil.RequestID(ctx); id != "" {
+		scope.SetTag("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		scope.SetTag("trace_id", sc.TraceID().String())
+	}
+	if uid := ctxutil.UserID(ctx); uid != 0 {
+		scope.SetUser(sg.User{ID: strconv.FormatInt(uid, 10)})
+	}
+	if tenant := ctxutil.Tenant(ctx); tenant != "" {
+		scope.SetTag("tenant", tenant)
+	}
+}
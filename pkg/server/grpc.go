@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// GRPCServer 把 grpc.Server 适配为 Server
+type GRPCServer struct {
+	name string
+	addr string
+	srv  *grpc.Server
+}
+
+// NewGRPC 创建 gRPC 服务，addr 形如 ":9081"
+func NewGRPC(name, addr string, srv *grpc.Server) *GRPCServer {
+	return &GRPCServer{name: name, addr: addr, srv: srv}
+}
+
+func (s *GRPCServer) Name() string {
+	return s.name
+}
+
+func (s *GRPCServer) Serve() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	zap.L().Info("server listening", zap.String("server", s.name), zap.String("addr", ln.Addr().String()))
+	// Stop/GracefulStop 之后 Serve 返回 nil
+	return s.srv.Serve(ln)
+}
+
+// Shutdown GracefulStop 会一直等到所有请求（包括流式调用）结束，ctx 超时后强制关闭剩下的连接
+func (s *GRPCServer) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.srv.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
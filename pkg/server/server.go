@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Server 由 Manager 统一启动和关闭的监听服务（公共 HTTP、内部管理、指标、gRPC 等）
+type Server interface {
+	Name() string
+	// Serve 开始监听并阻塞，被 Shutdown 正常关闭时返回 nil
+	Serve() error
+	// Shutdown 停止接收新连接并等待处理中的请求完成，最多等到 ctx 超时
+	Shutdown(ctx context.Context) error
+}
+
+// Manager 管理多个 Server 的生命周期
+// 启动时全部并发启动，任意一个异常退出都会通过 Errors 通知出来；
+// 关闭时按添加顺序依次关闭，所以要先添加对外的服务、后添加管理和指标服务，
+// 这样排空公共流量的过程中仍然可以查看指标和管理接口
+type Manager struct {
+	servers []Server
+	errs    chan error
+	wg      sync.WaitGroup
+}
+
+func NewManager() *Manager {
+	return &Manager{errs: make(chan error, 8)}
+}
+
+// Add 添加一个服务，需要在 Start 之前调用
+func (m *Manager) Add(s Server) {
+	m.servers = append(m.servers, s)
+}
+
+// Start 在后台启动所有服务
+func (m *Manager) Start() {
+	for _, s := range m.servers {
+		m.wg.Add(1)
+		go func(s Server) {
+			defer m.wg.Done()
+			zap.L().Info("server starting", zap.String("server", s.Name()))
+			if err := s.Serve(); err != nil {
+				zap.L().Error("server exited", zap.String("server", s.Name()), zap.Error(err))
+				select {
+				case m.errs <- fmt.Errorf("%s: %w", s.Name(), err):
+				default:
+				}
+			}
+		}(s)
+	}
+}
+
+// Errors 服务异常退出时（比如端口被占用）收到错误，main 据此提前退出
+func (m *Manager) Errors() <-chan error {
+	return m.errs
+}
+
+// Shutdown 按添加顺序依次优雅关闭所有服务，共享同一个超时 ctx
+func (m *Manager) Shutdown(ctx context.Context) (err error) {
+	for _, s := range m.servers {
+		zap.L().Info("server shutting down", zap.String("server", s.Name()))
+		if e := s.Shutdown(ctx); e != nil {
+			zap.L().Error("server shutdown failed", zap.String("server", s.Name()), zap.Error(e))
+			err = errors.Join(err, fmt.Errorf("%s: %w", s.Name(), e))
+		}
+	}
+	m.wg.Wait()
+	return
+}
+
+// HTTPServer 把 http.Server 适配为 Server
+type HTTPServer struct {
+	name string
+	srv  *http.Server
+}
+
+// NewHTTP 创建 HTTP 服务，addr 形如 ":8081"
+func NewHTTP(name, addr string, handler http.Handler) *HTTPServer {
+	return &HTTPServer{
+		name: name,
+		srv: &http.Server{
+			Addr:    addr,
+			Handler: handler,
+		},
+	}
+}
+
+func (s *HTTPServer) Name() string {
+	return s.name
+}
+
+func (s *HTTPServer) Serve() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	zap.L().Info("server listening", zap.String("server", s.name), zap.String("addr", ln.Addr().String()))
+	if err = s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
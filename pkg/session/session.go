@@ -0,0 +1,207 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/settings"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	goredis "github.com/go-redis/redis"
+)
+
+// 基于 redis 的服务端会话，给浏览器客户端使用，作为 JWT 之外的另一种登录态方案
+// 会话 ID 放在 HttpOnly cookie 里，会话内容保存在 redis，注销或踢下线立即生效
+//
+//	session:{id}        会话内容 JSON，TTL 为会话有效期
+//	session:user:{uid}  用户的所有会话 ID，用于"退出所有设备"
+
+var ErrNotFound = errors.New("session: not found")
+
+// Session 会话内容
+type Session struct {
+	ID        string            `json:"id"`
+	UserID    int64             `json:"user_id"`
+	Username  string            `json:"username"`
+	Values    map[string]string `json:"values,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+var (
+	cookieName = "session_id"
+	ttl        = 7 * 24 * time.Hour
+	secure     bool
+	domain     string
+)
+
+func Init(cfg *settings.SessionConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	if cfg.CookieName != "" {
+		cookieName = cfg.CookieName
+	}
+	if cfg.TTL > 0 {
+		ttl = time.Duration(cfg.TTL) * time.Second
+	}
+	secure = cfg.Secure
+	domain = cfg.Domain
+	return
+}
+
+func key(id string) string {
+	return "session:" + id
+}
+
+func userKey(userID int64) string {
+	return "session:user:" + strconv.FormatInt(userID, 10)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Create 创建会话
+func Create(ctx context.Context, userID int64, username string) (s *Session, err error) {
+	id, err := newID()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	s = &Session{
+		ID:        id,
+		UserID:    userID,
+		Username:  username,
+		Values:    map[string]string{},
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err = save(ctx, s); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func save(ctx context.Context, s *Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = redis.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Set(key(s.ID), data, time.Until(s.ExpiresAt))
+		pipe.SAdd(userKey(s.UserID), s.ID)
+		pipe.Expire(userKey(s.UserID), ttl)
+		return nil
+	})
+	return err
+}
+
+// Get 读取会话，不存在或已过期返回 ErrNotFound
+func Get(ctx context.Context, id string) (*Session, error) {
+	data, err := redis.Ctx(ctx).Get(key(id)).Bytes()
+	if err == goredis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := new(Session)
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save 保存对 Values 的修改，不改变过期时间
+func Save(ctx context.Context, s *Session) error {
+	return save(ctx, s)
+}
+
+// Refresh 滑动续期，把过期时间重置为从现在开始的一个完整有效期
+func Refresh(ctx context.Context, s *Session) error {
+	s.ExpiresAt = time.Now().Add(ttl)
+	return save(ctx, s)
+}
+
+// NeedRefresh 剩余有效期不足一半时才续期，避免每个请求都写 redis
+func NeedRefresh(s *Session) bool {
+	return time.Until(s.ExpiresAt) < ttl/2
+}
+
+// Destroy 删除会话
+func Destroy(ctx context.Context, s *Session) error {
+	_, err := redis.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Del(key(s.ID))
+		pipe.SRem(userKey(s.UserID), s.ID)
+		return nil
+	})
+	return err
+}
+
+// DestroyAll 删除用户的所有会话（退出所有设备、修改密码后使用）
+func DestroyAll(ctx context.Context, userID int64) error {
+	rdb := redis.Ctx(ctx)
+	ids, err := rdb.SMembers(userKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{userKey(userID)}
+	for _, id := range ids {
+		keys = append(keys, key(id))
+	}
+	return rdb.Del(keys...).Err()
+}
+
+type ctxKey struct{}
+
+// WithContext 把会话放进 ctx，由 session 中间件调用
+func WithContext(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, ctxKey{}, s)
+}
+
+// FromContext 取出当前请求的会话
+func FromContext(ctx context.Context) (s *Session, ok bool) {
+	s, ok = ctx.Value(ctxKey{}).(*Session)
+	return s, ok && s != nil
+}
+
+// CookieValue 从请求里读取会话 ID
+func CookieValue(c *gin.Context) string {
+	id, _ := c.Cookie(cookieName)
+	return id
+}
+
+// SetCookie 下发会话 cookie
+func SetCookie(c *gin.Context, s *Session) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cookieName, s.ID, int(time.Until(s.ExpiresAt).Seconds()), "/", domain, secure, true)
+}
+
+// Login 登录成功后调用，创建会话并下发 cookie
+func Login(c *gin.Context, userID int64, username string) (s *Session, err error) {
+	if s, err = Create(c.Request.Context(), userID, username); err != nil {
+		return
+	}
+	SetCookie(c, s)
+	return
+}
+
+// Logout 删除当前会话并清除 cookie
+func Logout(c *gin.Context) error {
+	c.SetCookie(cookieName, "", -1, "/", domain, secure, true)
+	if s, ok := FromContext(c.Request.Context()); ok {
+		return Destroy(c.Request.Context(), s)
+	}
+	return nil
+}
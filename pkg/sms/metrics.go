@@ -0,0 +1,30 @@
+package sms
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	sent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "sms",
+		Name:      "sent_total",
+		Help:      "发送的短信数，result: success/failure/rate_limited",
+	}, []string{"provider", "template", "result"})
+	duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "app",
+		Subsystem: "sms",
+		Name:      "send_duration_seconds",
+		Help:      "调用服务商接口的耗时",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"provider"})
+	reported = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "sms",
+		Name:      "reports_total",
+		Help:      "收到的送达回执数，result: success/failure",
+	}, []string{"provider", "result"})
+)
+
+// Collectors 短信指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{sent, duration, reported}
+}
@@ -0,0 +1,235 @@
+package sms
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/eventbus"
+	"go_web_scaffolding/settings"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 短信：业务按模板名发送，模板名在 sms.templates 里映射成服务商的模板 ID，换服务商只改配置
+//
+//	err := sms.Send(ctx, "+8613800000000", "verify_code", sms.P("code", code), sms.P("minutes", "10"))
+//
+// 号码用 E.164 格式（+国家码），原样交给服务商
+// 参数按顺序传：阿里云按名字填进模板，腾讯云按顺序填 {1}{2}，Twilio 按顺序填 {{1}}{{2}}
+// 同一号码 cooldown 秒内只能发一次、每天最多 max_per_day 次，超过时返回 *RateLimitError
+// 冷却在发送成功后才开始，服务商返回错误时可以马上重发
+//
+// 服务商把送达回执推到 /api/v1/sms/report/<provider>，解析后作为 Report 事件发布到 eventbus，
+// 和其它领域事件一样写入 domain_event 表，也可以配置 webhook 推给外部系统
+
+// EventReport 送达回执的事件名
+const EventReport = "sms.report"
+
+const sendTimeout = 10 * time.Second
+
+var (
+	// ErrUnknownTemplate sms.templates 里没有这个模板名
+	ErrUnknownTemplate = errors.New("sms: unknown template")
+	// ErrTooFrequent 发送太频繁，用 errors.As 取出 *RateLimitError 拿到剩余时间
+	ErrTooFrequent = errors.New("sms: sent too frequently")
+	// ErrProviderNotFound 回执的服务商和配置的不一致
+	ErrProviderNotFound = errors.New("sms: provider not found")
+	// ErrInvalidReport 回执的 token 或签名不对，或者格式解析不了
+	ErrInvalidReport = errors.New("sms: invalid report")
+)
+
+// RateLimitError 发送限额，RetryAfter 为 0 表示今天的次数用完了
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return ErrTooFrequent.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrTooFrequent
+}
+
+// Param 模板参数
+type Param struct {
+	Key   string
+	Value string
+}
+
+// P 构造模板参数
+func P(key, value string) Param {
+	return Param{Key: key, Value: value}
+}
+
+// Request 交给服务商的一条短信
+type Request struct {
+	Phone      string
+	SignName   string
+	TemplateID string
+	Params     []Param
+}
+
+// Report 送达回执
+type Report struct {
+	Provider  string `json:"provider"`
+	Phone     string `json:"phone"`
+	MessageID string `json:"message_id"` // Send 时服务商返回的 ID
+	Success   bool   `json:"success"`
+	// Code/Message 失败时服务商的错误码和说明
+	Code       string    `json:"code"`
+	Message    string    `json:"message"`
+	ReportTime time.Time `json:"report_time"`
+}
+
+func (Report) EventName() string { return EventReport }
+
+// Provider 短信服务商
+type Provider interface {
+	// Send 发送一条短信，返回服务商的消息 ID，回执里用它对应
+	Send(ctx context.Context, req *Request) (messageID string, err error)
+	// ParseReport 校验并解析回执请求，返回回执和要写回给服务商的响应体
+	ParseReport(r *http.Request) (reports []*Report, ack []byte, err error)
+}
+
+// newProviders 支持的服务商，配置里的 provider 必须是这里的名字之一
+var newProviders = map[string]func(cfg *settings.SMSConfig) Provider{
+	"aliyun":  newAliyun,
+	"tencent": newTencent,
+	"twilio":  newTwilio,
+}
+
+var (
+	cfg               = &settings.SMSConfig{}
+	name              = "log"
+	provider Provider = logProvider{}
+)
+
+// Init 按配置创建服务商，没有配置时短信只打印到日志
+func Init(c *settings.SMSConfig) (err error) {
+	if c == nil {
+		return
+	}
+	cfg = c
+	if c.Provider == "" {
+		return
+	}
+	newFn, ok := newProviders[c.Provider]
+	if !ok {
+		return fmt.Errorf("sms: unknown provider %q", c.Provider)
+	}
+	name, provider = c.Provider, newFn(c)
+	return
+}
+
+// Send 按模板名发送短信，先检查号码的发送限额
+func Send(ctx context.Context, phone, template string, params ...Param) error {
+	id, ok := cfg.Templates[template]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTemplate, template)
+	}
+	if err := allow(ctx, phone); err != nil {
+		sent.WithLabelValues(name, template, "rate_limited").Inc()
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	start := time.Now()
+	msgID, err := provider.Send(ctx, &Request{Phone: phone, SignName: cfg.SignName, TemplateID: id, Params: params})
+	duration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	log := logger.Module(ctx, "sms").With(zap.String("provider", name), zap.String("template", template))
+	if err != nil {
+		sent.WithLabelValues(name, template, "failure").Inc()
+		log.Error("send sms failed", zap.Error(err))
+		return fmt.Errorf("sms: send via %s: %w", name, err)
+	}
+	sent.WithLabelValues(name, template, "success").Inc()
+	log.Info("sms sent", zap.String("message_id", msgID))
+	if cfg.Cooldown > 0 {
+		if err = redis.SetSMSCooldown(ctx, phone, time.Duration(cfg.Cooldown)*time.Second); err != nil {
+			log.Warn("set sms cooldown failed", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// allow 同一号码的冷却和每天的次数；redis 出错时放行，不能因为限额发不出验证码
+// 冷却只在这里检查、发送成功后才设置，同一号码并发的请求可能都通过，由每天的次数兜底
+func allow(ctx context.Context, phone string) error {
+	if cfg.Cooldown > 0 {
+		ok, ttl, err := redis.SMSCooldown(ctx, phone)
+		if err != nil {
+			zap.L().Warn("sms cooldown check failed", zap.Error(err))
+		} else if !ok {
+			return &RateLimitError{RetryAfter: ttl}
+		}
+	}
+	if cfg.MaxPerDay > 0 {
+		n, err := redis.IncrSMSPhone(ctx, phone, 24*time.Hour)
+		if err != nil {
+			zap.L().Warn("sms rate limit check failed", zap.Error(err))
+		} else if n > cfg.MaxPerDay {
+			return &RateLimitError{}
+		}
+	}
+	return nil
+}
+
+// HandleReport 处理服务商推送的回执，每条回执发布一个 Report 事件，返回要写回给服务商的响应体
+func HandleReport(ctx context.Context, providerName string, r *http.Request) ([]byte, error) {
+	if providerName != name {
+		return nil, ErrProviderNotFound
+	}
+	reports, ack, err := provider.ParseReport(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, rp := range reports {
+		rp.Provider = name
+		result := "success"
+		if !rp.Success {
+			result = "failure"
+		}
+		reported.WithLabelValues(name, result).Inc()
+		eventbus.Publish(ctx, *rp)
+	}
+	return ack, nil
+}
+
+// checkToken 阿里云、腾讯云的回执不带签名，控制台也只能配置回执地址，校验回执地址上的 ?token=；没有配置 token 时拒绝所有回执
+// 访问日志会对 query 里的 token 脱敏，见 logger.MaskQuery
+func checkToken(r *http.Request) error {
+	token := r.URL.Query().Get("token")
+	if cfg.ReportToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.ReportToken)) != 1 {
+		return ErrInvalidReport
+	}
+	return nil
+}
+
+// maskedValue 和 logger 的脱敏占位符一致
+const maskedValue = "******"
+
+// logProvider 只把短信打印到日志，没有配置服务商时使用，方便本地开发
+type logProvider struct{}
+
+// Send 参数里可能有验证码，只打印参数名，值一律脱敏
+func (logProvider) Send(ctx context.Context, req *Request) (string, error) {
+	params := make([]string, 0, len(req.Params))
+	for _, p := range req.Params {
+		params = append(params, p.Key+"="+maskedValue)
+	}
+	logger.Ctx(ctx).Info("sms not sent, printed to log",
+		zap.String("phone", req.Phone),
+		zap.String("template", req.TemplateID),
+		zap.Strings("params", params))
+	return "", nil
+}
+
+func (logProvider) ParseReport(r *http.Request) ([]*Report, []byte, error) {
+	return nil, nil, ErrProviderNotFound
+}
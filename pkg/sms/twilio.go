@@ -0,0 +1,129 @@
+package sms
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go_web_scaffolding/pkg/httpclient"
+	"go_web_scaffolding/settings"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// twilio Twilio 短信，模板用 Content API 的 Content SID，参数按顺序填进 {{1}}{{2}}
+type twilio struct {
+	accountSID string
+	authToken  string
+	from       string
+	reportURL  string
+}
+
+func newTwilio(cfg *settings.SMSConfig) Provider {
+	return &twilio{accountSID: cfg.AccessKeyID, authToken: cfg.AccessKeySecret, from: cfg.From, reportURL: cfg.ReportURL}
+}
+
+func (t *twilio) Send(ctx context.Context, req *Request) (string, error) {
+	vars := make(map[string]string, len(req.Params))
+	for i, p := range req.Params {
+		vars[strconv.Itoa(i+1)] = p.Value
+	}
+	b, err := json.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"To":               {req.Phone},
+		"ContentSid":       {req.TemplateID},
+		"ContentVariables": {string(b)},
+	}
+	// MG 开头的是 Messaging Service，由它选择发送号码
+	if strings.HasPrefix(t.from, "MG") {
+		form.Set("MessagingServiceSid", t.from)
+	} else {
+		form.Set("From", t.from)
+	}
+	if t.reportURL != "" {
+		form.Set("StatusCallback", t.reportURL)
+	}
+	u := "https://api.twilio.com/2010-04-01/Accounts/" + t.accountSID + "/Messages.json"
+	hr, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	hr.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	hr.SetBasicAuth(t.accountSID, t.authToken)
+	resp, err := httpclient.Do(hr)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		SID     string `json:"sid"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", fmt.Errorf("twilio: status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("twilio: status %d: %d %s", resp.StatusCode, res.Code, res.Message)
+	}
+	return res.SID, nil
+}
+
+// ParseReport Twilio 的回执是表单，用 X-Twilio-Signature 校验：
+// HMAC-SHA1(auth token, 回执地址 + 按参数名排序拼接的 name+value)，回执地址要和发送时的 StatusCallback 完全一致
+// 只有 delivered、undelivered、failed 是最终状态，queued、sent 这些中间状态忽略
+func (t *twilio) ParseReport(r *http.Request) ([]*Report, []byte, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidReport, err)
+	}
+	if !t.verify(r.Header.Get("X-Twilio-Signature"), r.PostForm) {
+		return nil, nil, ErrInvalidReport
+	}
+	status := r.PostForm.Get("MessageStatus")
+	if status != "delivered" && status != "undelivered" && status != "failed" {
+		return nil, nil, nil
+	}
+	rp := &Report{
+		Phone:      r.PostForm.Get("To"),
+		MessageID:  r.PostForm.Get("MessageSid"),
+		Success:    status == "delivered",
+		ReportTime: time.Now(),
+	}
+	if !rp.Success {
+		rp.Code, rp.Message = r.PostForm.Get("ErrorCode"), status
+	}
+	return []*Report{rp}, nil, nil
+}
+
+func (t *twilio) verify(signature string, form url.Values) bool {
+	if t.reportURL == "" || signature == "" {
+		return false
+	}
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(t.reportURL)
+	for _, k := range keys {
+		for _, v := range form[k] {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(t.authToken))
+	mac.Write([]byte(b.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) == 1
+}
@@ -0,0 +1,50 @@
+package snowflake
+
+import (
+	"errors"
+	"go_web_scaffolding/settings"
+	"time"
+
+	sf "github.com/bwmarrin/snowflake"
+)
+
+// 雪花算法生成 64 位 ID：41 位毫秒时间戳 + 10 位机器 ID + 12 位序列号
+// 按时间递增，可以直接当主键用，分库分表、数据迁移时也不会像自增 ID 那样冲突
+// 同一时刻运行的实例 machine_id 不能相同，否则会生成重复的 ID
+//
+// ID 超过了 JavaScript 能精确表示的整数范围（2^53），返回给前端时要用字符串，json tag 加上 ",string"
+
+var (
+	node *sf.Node
+	// epoch 本包的起始时间（毫秒），只在 node 和 Time 里使用
+	epoch int64
+)
+
+func Init(cfg *settings.SnowflakeConfig) (err error) {
+	if cfg == nil {
+		return errors.New("snowflake config is required")
+	}
+	st, err := time.Parse("2006-01-02", cfg.StartTime)
+	if err != nil {
+		return
+	}
+	// 起始时间越近，能用的年限越长（41 位毫秒约 69 年）
+	epoch = st.UnixMilli()
+	// sf.Epoch 是包级变量，NewNode 时读取后保存在 node 里，创建完马上恢复，
+	// 不影响进程里其它直接使用 bwmarrin/snowflake 的代码
+	old := sf.Epoch
+	sf.Epoch = epoch
+	node, err = sf.NewNode(cfg.MachineID)
+	sf.Epoch = old
+	return
+}
+
+// GenID 生成一个新 ID
+func GenID() int64 {
+	return node.Generate().Int64()
+}
+
+// Time 从 ID 中解析出生成时间，排查问题时可以知道一条记录大概是什么时候创建的
+func Time(id int64) time.Time {
+	return time.UnixMilli(id>>(sf.NodeBits+sf.StepBits) + epoch)
+}
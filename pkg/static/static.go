@@ -0,0 +1,143 @@
+package static
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go_web_scaffolding/settings"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 前端构建产物的静态文件服务，让二进制自己带上页面，不需要另外部署 nginx
+//
+//	h := static.New(web.Dist(), cfg)
+//	r.NoRoute(gin.WrapH(h))
+//
+// 缓存策略：
+//   - html（包括 SPA 回退的 index.html）每次都要校验，发版后刷新就能拿到新页面
+//   - immutable 目录下的文件名带内容哈希，内容变了文件名就变，缓存一年
+//   - 其它文件缓存 max_age 秒
+//
+// ETag 取文件内容的 sha256，按文件名、大小、修改时间缓存；编译进二进制的文件没有修改时间，进程内只算一次
+
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// Handler 静态文件的 http.Handler
+type Handler struct {
+	fsys      fs.FS
+	prefix    string
+	spa       bool
+	maxAge    int
+	immutable []string
+
+	etags sync.Map
+}
+
+type etagKey struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// New 从 fsys 提供文件，cfg.Dir 不为空时改为读取磁盘目录
+func New(fsys fs.FS, cfg *settings.StaticConfig) *Handler {
+	if cfg.Dir != "" {
+		fsys = os.DirFS(cfg.Dir)
+	}
+	return &Handler{
+		fsys:      fsys,
+		prefix:    strings.TrimSuffix(cfg.Prefix, "/"),
+		spa:       cfg.SPA,
+		maxAge:    cfg.MaxAge,
+		immutable: cfg.Immutable,
+	}
+}
+
+// Match 路径是否在挂载路径下
+func (h *Handler) Match(p string) bool {
+	return h.prefix == "" || p == h.prefix || strings.HasPrefix(p, h.prefix+"/")
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	p := path.Clean("/" + strings.TrimPrefix(r.URL.Path, h.prefix))
+	name, info, err := h.lookup(strings.TrimPrefix(p, "/"))
+	// 前端路由的页面地址不带扩展名；带扩展名的是资源文件，找不到就是 404，不能拿 html 冒充 js
+	if errors.Is(err, fs.ErrNotExist) && h.spa && path.Ext(p) == "" {
+		name, info, err = h.lookup("")
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	content, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", h.etag(name, info, content))
+	w.Header().Set("Cache-Control", h.cacheControl(p, name))
+	// 处理 If-None-Match、Range，按扩展名设置 Content-Type
+	http.ServeContent(w, r, name, info.ModTime(), bytes.NewReader(content))
+}
+
+// lookup 找到要返回的文件，目录返回里面的 index.html
+func (h *Handler) lookup(name string) (string, fs.FileInfo, error) {
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		name = path.Join(name, "index.html")
+		if info, err = fs.Stat(h.fsys, name); err != nil {
+			return "", nil, err
+		}
+	}
+	return name, info, nil
+}
+
+func (h *Handler) etag(name string, info fs.FileInfo, content []byte) string {
+	key := etagKey{name: name, size: info.Size(), modTime: info.ModTime()}
+	if v, ok := h.etags.Load(key); ok {
+		return v.(string)
+	}
+	sum := sha256.Sum256(content)
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	h.etags.Store(key, tag)
+	return tag
+}
+
+// cacheControl p 是请求的路径，name 是实际返回的文件
+func (h *Handler) cacheControl(p, name string) string {
+	if path.Ext(name) == ".html" {
+		return "no-cache"
+	}
+	for _, dir := range h.immutable {
+		if strings.HasPrefix(p, dir) {
+			return immutableCacheControl
+		}
+	}
+	if h.maxAge > 0 {
+		return "public, max-age=" + strconv.Itoa(h.maxAge)
+	}
+	return "no-cache"
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/settings"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalPath local 存储的文件在本服务上的访问路径
+const LocalPath = "/uploads"
+
+// local 存在本地目录，先写临时文件再改名，不会读到写了一半的文件
+type local struct {
+	dir string
+}
+
+func newLocal(cfg *settings.StorageConfig) (Storage, string, error) {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = "./data/uploads"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, "", err
+	}
+	return &local{dir: dir}, LocalPath, nil
+}
+
+// path key 里的 .. 在 Join 之前按根目录清理掉，不会写到目录外面
+func (l *local) path(key string) string {
+	return filepath.Join(l.dir, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+func (l *local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (err error) {
+	p := l.path(key)
+	if err = os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if _, err = io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (l *local) Delete(ctx context.Context, key string) error {
+	err := os.Remove(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/settings"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// 文件存储：业务只关心 key（存储路径），存到哪里由 upload.storage.driver 决定
+//
+//	err := storage.Put(ctx, "2026/10/16/xxx.png", f, size, "image/png")
+//	url := storage.URL("2026/10/16/xxx.png")
+//
+// local 存在本地目录，由本服务的 /uploads 提供访问，只适合单实例或者挂了共享盘的部署
+// s3、minio 用 S3 协议（SigV4 签名），oss 用阿里云 OSS 自己的签名；都没有引入 SDK，只用到上传和删除
+// 对外的 url 由 base_url 和 key 拼成，不存进数据库，换 CDN 域名只要改配置
+
+// Storage 一种存储后端
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+}
+
+var newStorages = map[string]func(cfg *settings.StorageConfig) (Storage, string, error){
+	"local": newLocal,
+	"s3":    newS3,
+	"minio": newS3,
+	"oss":   newOSS,
+}
+
+var (
+	driver  = "local"
+	backend Storage
+	baseURL string
+)
+
+func init() {
+	dashboard.Register("storage", func(ctx context.Context) interface{} {
+		return map[string]interface{}{"driver": driver, "base_url": baseURL}
+	})
+}
+
+// Init 按配置创建存储后端，没有配置时存在本地的 ./data/uploads
+func Init(cfg *settings.StorageConfig) (err error) {
+	if cfg == nil {
+		cfg = &settings.StorageConfig{}
+	}
+	if cfg.Driver != "" {
+		driver = cfg.Driver
+	}
+	newStorage, ok := newStorages[driver]
+	if !ok {
+		return fmt.Errorf("storage: unknown driver %q", driver)
+	}
+	// 每种后端有自己默认的访问地址
+	var defaultURL string
+	if backend, defaultURL, err = newStorage(cfg); err != nil {
+		return
+	}
+	baseURL = cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultURL
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return
+}
+
+// Put 上传文件，key 已经存在时覆盖
+func Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	return backend.Put(ctx, key, r, size, contentType)
+}
+
+// Delete 删除文件，文件不存在时不返回错误
+func Delete(ctx context.Context, key string) error {
+	return backend.Delete(ctx, key)
+}
+
+// URL 文件对外访问的地址
+func URL(key string) string {
+	return baseURL + "/" + key
+}
+
+// noDirFS 不提供目录，按日期分的目录一列出来所有人上传的文件都能被遍历到
+type noDirFS struct {
+	fs http.FileSystem
+}
+
+func (n noDirFS) Open(name string) (http.File, error) {
+	f, err := n.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil || st.IsDir() {
+		_ = f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
+// Handler local 存储时访问文件的 handler，挂在 LocalPath 下；其它存储返回 nil
+func Handler() http.Handler {
+	l, ok := backend.(*local)
+	if !ok {
+		return nil
+	}
+	fs := http.StripPrefix(LocalPath, http.FileServer(noDirFS{http.Dir(l.dir)}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 用户上传的内容和接口同源，禁止浏览器猜类型、禁止执行脚本，防止传个 html 上来做 XSS
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		fs.ServeHTTP(w, r)
+	})
+}
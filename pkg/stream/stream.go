@@ -0,0 +1,332 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go_web_scaffolding/dao/redis"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/settings"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// payloadField 消息体在 stream entry 中的字段名
+const payloadField = "payload"
+
+// Message 消费到的一条消息
+type Message struct {
+	ID         string
+	Stream     string
+	Payload    string
+	Deliveries int64 // 第几次投递，首次为 1
+}
+
+// Bind 把 JSON 格式的 Payload 反序列化到 v
+func (m *Message) Bind(v interface{}) error {
+	return json.Unmarshal([]byte(m.Payload), v)
+}
+
+// Handler 返回 nil 时消息被 ACK；返回错误时消息留在 pending 列表，
+// 空闲超过 minIdle 后被重新认领再次投递，投递次数达到 maxDeliveries 后转入死信队列
+type Handler func(ctx context.Context, msg *Message) error
+
+var (
+	block         = 2 * time.Second
+	count         = int64(10)
+	minIdle       = time.Minute
+	maxDeliveries = int64(5)
+	maxLen        = int64(100000)
+	consumerName  = defaultConsumerName()
+)
+
+func Init(cfg *settings.StreamConfig) (err error) {
+	if cfg == nil {
+		return
+	}
+	if cfg.Block > 0 {
+		block = time.Duration(cfg.Block) * time.Millisecond
+	}
+	if cfg.Count > 0 {
+		count = cfg.Count
+	}
+	if cfg.MinIdle > 0 {
+		minIdle = time.Duration(cfg.MinIdle) * time.Second
+	}
+	if cfg.MaxDeliveries > 0 {
+		maxDeliveries = cfg.MaxDeliveries
+	}
+	if cfg.MaxLen > 0 {
+		maxLen = cfg.MaxLen
+	}
+	return
+}
+
+// defaultConsumerName 同一个消费组内的消费者名要唯一，k8s 里用 Pod 名，否则用 主机名-进程号
+func defaultConsumerName() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// DeadLetterStream 死信队列的 stream 名
+func DeadLetterStream(stream string) string {
+	return stream + ":dlq"
+}
+
+// Add 生产消息（XADD），payload 为 string/[]byte 时原样写入，其它类型序列化为 JSON
+// stream 长度近似裁剪到 maxLen，避免无限增长
+func Add(ctx context.Context, stream string, payload interface{}) (id string, err error) {
+	var data interface{}
+	switch v := payload.(type) {
+	case string, []byte:
+		data = v
+	default:
+		if data, err = json.Marshal(v); err != nil {
+			return
+		}
+	}
+	return redis.Ctx(ctx).XAdd(&goredis.XAddArgs{
+		Stream:       stream,
+		MaxLenApprox: maxLen,
+		Values:       map[string]interface{}{payloadField: data},
+	}).Result()
+}
+
+type consumer struct {
+	stream, group string
+	handler       Handler
+
+	processed, failed, deadLettered atomic.Int64
+}
+
+var (
+	mu        sync.Mutex
+	consumers []*consumer
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+)
+
+func init() {
+	dashboard.Register("stream", func(ctx context.Context) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		res := make([]map[string]interface{}, 0, len(consumers))
+		for _, c := range consumers {
+			res = append(res, map[string]interface{}{
+				"stream":        c.stream,
+				"group":         c.group,
+				"consumer":      consumerName,
+				"processed":     c.processed.Load(),
+				"failed":        c.failed.Load(),
+				"dead_lettered": c.deadLettered.Load(),
+			})
+		}
+		return res
+	})
+}
+
+// Handle 注册 stream 在消费组 group 下的处理函数，需要在 Start 之前调用
+func Handle(stream, group string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	consumers = append(consumers, &consumer{stream: stream, group: group, handler: h})
+}
+
+// Start 为每个注册的消费者启动一个消费协程
+func Start() {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(consumers) == 0 {
+		return
+	}
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	for _, c := range consumers {
+		wg.Add(1)
+		go func(c *consumer) {
+			defer wg.Done()
+			c.run(ctx)
+		}(c)
+	}
+}
+
+// Stop 停止消费并等待正在处理的消息处理完
+// 未 ACK 的消息留在 pending 列表，下次启动或其它实例会重新认领
+func Stop() {
+	if cancel == nil {
+		return
+	}
+	cancel()
+	wg.Wait()
+}
+
+func (c *consumer) run(ctx context.Context) {
+	log := zap.L().With(zap.String("stream", c.stream), zap.String("group", c.group))
+	for ctx.Err() == nil {
+		if err := c.ensureGroup(); err != nil {
+			log.Error("create consumer group failed", zap.Error(err))
+			sleep(ctx, time.Second)
+			continue
+		}
+		break
+	}
+
+	lastClaim := time.Time{}
+	for ctx.Err() == nil {
+		// 定期认领其它消费者（可能已经挂掉）或自己之前处理失败、长时间未 ACK 的消息
+		if time.Since(lastClaim) >= minIdle {
+			lastClaim = time.Now()
+			if err := c.claim(ctx); err != nil {
+				log.Error("claim pending messages failed", zap.Error(err))
+			}
+		}
+
+		streams, err := redis.Client().XReadGroup(&goredis.XReadGroupArgs{
+			Group:    c.group,
+			Consumer: consumerName,
+			Streams:  []string{c.stream, ">"},
+			Count:    count,
+			Block:    block,
+		}).Result()
+		if err == goredis.Nil {
+			continue
+		}
+		if err != nil {
+			// 消费组被删掉了（比如 redis 重启或切换了实例），重新创建
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				_ = c.ensureGroup()
+			}
+			log.Error("xreadgroup failed", zap.Error(err))
+			sleep(ctx, time.Second)
+			continue
+		}
+		for _, s := range streams {
+			for _, m := range s.Messages {
+				c.process(ctx, m, 1)
+			}
+		}
+	}
+}
+
+func (c *consumer) ensureGroup() error {
+	err := redis.Client().XGroupCreateMkStream(c.stream, c.group, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// claim 认领空闲超过 minIdle 的 pending 消息，投递次数超限的转入死信队列
+func (c *consumer) claim(ctx context.Context) error {
+	rdb := redis.Ctx(ctx)
+	pending, err := rdb.XPendingExt(&goredis.XPendingExtArgs{
+		Stream: c.stream,
+		Group:  c.group,
+		Start:  "-",
+		End:    "+",
+		Count:  count * 10,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, p := range pending {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if p.Idle < minIdle {
+			continue
+		}
+		msgs, err := rdb.XClaim(&goredis.XClaimArgs{
+			Stream:   c.stream,
+			Group:    c.group,
+			Consumer: consumerName,
+			MinIdle:  minIdle,
+			Messages: []string{p.Id},
+		}).Result()
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			// XCLAIM 会让投递次数 +1
+			deliveries := p.RetryCount + 1
+			if deliveries > maxDeliveries {
+				c.deadLetter(ctx, m, p.RetryCount)
+				continue
+			}
+			c.process(ctx, m, deliveries)
+		}
+	}
+	return nil
+}
+
+func (c *consumer) process(ctx context.Context, m goredis.XMessage, deliveries int64) {
+	payload, _ := m.Values[payloadField].(string)
+	msg := &Message{ID: m.ID, Stream: c.stream, Payload: payload, Deliveries: deliveries}
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return c.handler(ctx, msg)
+	}()
+	if err != nil {
+		c.failed.Add(1)
+		zap.L().Error("stream handler failed",
+			zap.String("stream", c.stream),
+			zap.String("group", c.group),
+			zap.String("id", m.ID),
+			zap.Int64("deliveries", deliveries),
+			zap.Error(err))
+		return
+	}
+	c.processed.Add(1)
+	if err := redis.Client().XAck(c.stream, c.group, m.ID).Err(); err != nil {
+		zap.L().Error("xack failed", zap.String("stream", c.stream), zap.String("id", m.ID), zap.Error(err))
+	}
+}
+
+// deadLetter 把消息写入死信队列后 ACK，死信需要人工排查后重新投递
+func (c *consumer) deadLetter(ctx context.Context, m goredis.XMessage, deliveries int64) {
+	rdb := redis.Ctx(ctx)
+	values := map[string]interface{}{
+		"origin_id":  m.ID,
+		"group":      c.group,
+		"deliveries": deliveries,
+	}
+	for k, v := range m.Values {
+		values[k] = v
+	}
+	if err := rdb.XAdd(&goredis.XAddArgs{
+		Stream:       DeadLetterStream(c.stream),
+		MaxLenApprox: maxLen,
+		Values:       values,
+	}).Err(); err != nil {
+		zap.L().Error("move to dead letter failed", zap.String("stream", c.stream), zap.String("id", m.ID), zap.Error(err))
+		return
+	}
+	c.deadLettered.Add(1)
+	_ = rdb.XAck(c.stream, c.group, m.ID).Err()
+	zap.L().Warn("message moved to dead letter",
+		zap.String("stream", c.stream),
+		zap.String("group", c.group),
+		zap.String("id", m.ID),
+		zap.Int64("deliveries", deliveries))
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,161 @@
+package tmpl
+
+import (
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gin-gonic/gin/render"
+	"go.uber.org/zap"
+)
+
+// Loader 模板加载器，实现了 gin 的 render.HTMLRender，可以直接赋值给 r.HTMLRender
+// 生产环境从 go:embed 的 fs 解析一次；dev 模式从磁盘目录读取，文件变化后自动重新解析，改模板不用重启
+// 模板名是相对模板根目录的路径，例如 "index.html"、"email/verify.html"
+type Loader struct {
+	mu      sync.RWMutex
+	tpl     *template.Template
+	fsys    fs.FS
+	watcher *fsnotify.Watcher
+}
+
+var (
+	watchingMu sync.Mutex
+	// watching dev 模式下创建的、正在监听目录的加载器，退出时由 Close 统一关闭
+	watching []*Loader
+)
+
+// New 创建模板加载器
+// dev 为 true 时忽略 embedded，从磁盘目录 dir 加载并监听；否则从 embedded 加载
+func New(embedded fs.FS, dir string, dev bool) (l *Loader, err error) {
+	l = &Loader{fsys: embedded}
+	if dev {
+		l.fsys = os.DirFS(dir)
+	}
+	if l.tpl, err = parse(l.fsys); err != nil {
+		return nil, err
+	}
+	if dev {
+		if err = l.watch(dir); err != nil {
+			return nil, err
+		}
+		watchingMu.Lock()
+		watching = append(watching, l)
+		watchingMu.Unlock()
+	}
+	return
+}
+
+// Close 关闭所有 dev 模式加载器的目录监听，程序退出前调用
+func Close() {
+	watchingMu.Lock()
+	defer watchingMu.Unlock()
+	for _, l := range watching {
+		l.Close()
+	}
+	watching = nil
+}
+
+// Template 当前生效的模板集合，给邮件等非 HTTP 场景使用
+func (l *Loader) Template() *template.Template {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.tpl
+}
+
+// Instance 实现 render.HTMLRender
+func (l *Loader) Instance(name string, data any) render.Render {
+	return render.HTML{
+		Template: l.Template(),
+		Name:     name,
+		Data:     data,
+	}
+}
+
+// Close 停止监听，生产模式下为空操作
+func (l *Loader) Close() {
+	if l.watcher != nil {
+		_ = l.watcher.Close()
+	}
+}
+
+// watch 监听模板目录（含子目录），变化后防抖重新解析
+// 解析失败时保留上一次成功的模板并记录错误，不会因为写了一半的模板把页面搞挂
+func (l *Loader) watch(dir string) (err error) {
+	if l.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return l.watcher.Add(path)
+	})
+	if err != nil {
+		_ = l.watcher.Close()
+		return
+	}
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case ev, ok := <-l.watcher.Events:
+				if !ok {
+					return
+				}
+				// 新建的子目录也要加入监听
+				if ev.Has(fsnotify.Create) {
+					if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+						_ = l.watcher.Add(ev.Name)
+					}
+				}
+				// 编辑器保存时往往连续触发多个事件，合并成一次解析
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(100*time.Millisecond, l.reload)
+			case err, ok := <-l.watcher.Errors:
+				if !ok {
+					return
+				}
+				zap.L().Warn("template watcher error", zap.Error(err))
+			}
+		}
+	}()
+	return
+}
+
+func (l *Loader) reload() {
+	tpl, err := parse(l.fsys)
+	if err != nil {
+		zap.L().Error("reload templates failed", zap.Error(err))
+		return
+	}
+	l.mu.Lock()
+	l.tpl = tpl
+	l.mu.Unlock()
+	zap.L().Debug("templates reloaded")
+}
+
+// parse 递归解析 fsys 中所有 .html 文件，模板名为相对路径
+func parse(fsys fs.FS) (*template.Template, error) {
+	root := template.New("")
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".html") {
+			return err
+		}
+		b, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		_, err = root.New(path).Parse(string(b))
+		return err
+	})
+	return root, err
+}
@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/k8s"
+	"go_web_scaffolding/pkg/version"
+	"go_web_scaffolding/settings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// 基于 OpenTelemetry 的链路追踪，span 通过 OTLP/HTTP 上报给 collector、Jaeger 或 Tempo
+// 埋点的地方：
+//
+//	HTTP 入口：routes 里的 otelgin 中间件，上游带了 traceparent 请求头时接着上游的链路
+//	MySQL：dao/mysql 用 otelsql 包装驱动，每条 SQL 一个 span
+//	Redis：dao/redis.Ctx 返回的客户端，每条命令一个 span
+//
+// 没有开启时使用 otel 默认的空实现，埋点代码的开销可以忽略
+// 需要在 MySQL、Redis 初始化之前调用
+
+var tp *sdktrace.TracerProvider
+
+func init() {
+	dashboard.Register("tracing", func(ctx context.Context) interface{} {
+		cfg := settings.Conf.TraceConfig
+		if cfg == nil || !cfg.Enable {
+			return map[string]interface{}{"enabled": false}
+		}
+		return map[string]interface{}{
+			"enabled":      true,
+			"endpoint":     cfg.Endpoint,
+			"sample_ratio": cfg.SampleRatio,
+		}
+	})
+}
+
+func Init(cfg *settings.TraceConfig) (err error) {
+	// 传播器总是设置，没开启追踪时也会把上游的 traceparent 透传给下游
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg == nil || !cfg.Enable {
+		return
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	// New 不会连接 collector，collector 不可用时只是上报失败，不影响启动
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("service.name", settings.Conf.Name),
+		attribute.String("service.version", version.Get().Version),
+		attribute.String("deployment.environment", settings.Conf.Mode),
+	}
+	for k, v := range k8s.Attributes() {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	// 上游已经决定采样的请求跟着上游走，自己发起的按比例采样
+	tp = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attrs...)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return
+}
+
+// Shutdown 把缓冲区里的 span 上报完再退出
+func Shutdown(ctx context.Context) error {
+	if tp == nil {
+		return nil
+	}
+	return tp.Shutdown(ctx)
+}
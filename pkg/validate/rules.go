@@ -0,0 +1,58 @@
+package validate
+
+import (
+	"go_web_scaffolding/pkg/password"
+	"regexp"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	// mobileRe 中国大陆手机号
+	mobileRe = regexp.MustCompile(`^1[3-9]\d{9}$`)
+	// hexRe APNs 的 device token 是十六进制字符串
+	hexRe = regexp.MustCompile(`^([0-9a-fA-F]{2})+$`)
+	// usernameRe 用户名只允许字母、数字、下划线和中划线，和第三方登录生成的用户名规则一致
+	usernameRe = regexp.MustCompile(`^[a-zA-Z0-9_\-]{3,32}$`)
+)
+
+func init() {
+	Register("mobile", func(fl validator.FieldLevel) bool {
+		return mobileRe.MatchString(fl.Field().String())
+	}, map[string]string{
+		"zh": "{0}必须是有效的手机号",
+		"en": "{0} must be a valid mobile number",
+	})
+
+	Register("username", func(fl validator.FieldLevel) bool {
+		return usernameRe.MatchString(fl.Field().String())
+	}, map[string]string{
+		"zh": "{0}只能包含字母、数字、下划线和中划线，长度3到32位",
+		"en": "{0} may only contain letters, digits, '_' and '-', 3 to 32 characters",
+	})
+
+	// 具体哪条规则不满足由业务层返回 violations，这里只给出笼统的提示
+	Register("strong_password", func(fl validator.FieldLevel) bool {
+		return password.Validate(fl.Field().String(), "") == nil
+	}, map[string]string{
+		"zh": "{0}强度不够",
+		"en": "{0} is too weak",
+	})
+
+	// 同一个结构体里 Platform 为 ios 时要求是十六进制，FCM 的 token 格式不固定，不检查
+	Register("push_token", func(fl validator.FieldLevel) bool {
+		platform := fl.Parent().FieldByName("Platform")
+		if !platform.IsValid() || platform.String() != "ios" {
+			return true
+		}
+		return hexRe.MatchString(fl.Field().String())
+	}, map[string]string{
+		"zh": "{0}不是有效的 iOS 设备 token",
+		"en": "{0} is not a valid iOS device token",
+	})
+
+	RegisterAlias("code6", "len=6,numeric", map[string]string{
+		"zh": "{0}必须是6位数字",
+		"en": "{0} must be 6 digits",
+	})
+}
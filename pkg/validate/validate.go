@@ -0,0 +1,86 @@
+package validate
+
+import (
+	"fmt"
+	"sync"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// 项目自定义的校验规则统一在这里登记，启动时由 controller.InitTrans 一次性注册到 gin 的校验器上
+// 业务包在 init() 里调用 Register / RegisterAlias，之后任何请求结构体都可以直接写 binding:"mobile"
+
+// rule 一条自定义校验，messages 是各语言的错误提示，{0} 会被替换成字段名
+type rule struct {
+	tag      string
+	fn       validator.Func
+	alias    string
+	messages map[string]string
+}
+
+var (
+	mu    sync.Mutex
+	rules []rule
+	// applied Apply 之后再登记的规则不会生效，直接 panic 提醒调用方
+	applied bool
+)
+
+// Register 登记一个自定义校验函数
+func Register(tag string, fn validator.Func, messages map[string]string) {
+	add(rule{tag: tag, fn: fn, messages: messages})
+}
+
+// RegisterAlias 登记一个标签别名，比如 RegisterAlias("code6", "len=6,numeric", ...)
+// 校验失败时报错的 tag 是别名本身，所以提示也按别名登记
+func RegisterAlias(alias, tags string, messages map[string]string) {
+	add(rule{tag: alias, alias: tags, messages: messages})
+}
+
+func add(r rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	if applied {
+		panic(fmt.Sprintf("validate: %q registered after Apply", r.tag))
+	}
+	rules = append(rules, r)
+}
+
+// Apply 把登记的规则和提示注册到校验器，uni 里没有对应语言的提示会被跳过
+func Apply(v *validator.Validate, uni *ut.UniversalTranslator) (err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	applied = true
+
+	for _, r := range rules {
+		if r.alias != "" {
+			v.RegisterAlias(r.tag, r.alias)
+		} else if err = v.RegisterValidation(r.tag, r.fn); err != nil {
+			return fmt.Errorf("register validation %q: %w", r.tag, err)
+		}
+		for lang, msg := range r.messages {
+			trans, found := uni.GetTranslator(lang)
+			if !found {
+				continue
+			}
+			if err = v.RegisterTranslation(r.tag, trans, registerFunc(r.tag, msg), translateFunc); err != nil {
+				return fmt.Errorf("register translation %q(%s): %w", r.tag, lang, err)
+			}
+		}
+	}
+	return
+}
+
+func registerFunc(tag, msg string) validator.RegisterTranslationsFunc {
+	return func(trans ut.Translator) error {
+		return trans.Add(tag, msg, true)
+	}
+}
+
+func translateFunc(trans ut.Translator, fe validator.FieldError) string {
+	msg, err := trans.T(fe.Tag(), fe.Field())
+	if err != nil {
+		return fe.Error()
+	}
+	return msg
+}
@@ -0,0 +1,71 @@
+package version
+
+import (
+	"go_web_scaffolding/settings"
+	"runtime"
+	"runtime/debug"
+)
+
+// 版本信息在构建时通过 ldflags 写入：
+//
+//	go build -ldflags "-X go_web_scaffolding/pkg/version.Version=v1.2.3 \
+//	  -X go_web_scaffolding/pkg/version.GitCommit=$(git rev-parse --short HEAD) \
+//	  -X go_web_scaffolding/pkg/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// 没有传 ldflags 时（比如 go run），Version 用配置里的 version，
+// GitCommit 从 go build 自动记录的 vcs 信息里取，取不到就是空的
+
+var (
+	Version   string
+	GitCommit string
+	BuildTime string
+)
+
+// Info 构建信息
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get 当前运行的构建信息
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	if info.Version == "" {
+		info.Version = settings.Conf.Version
+	}
+	if info.GitCommit == "" {
+		info.GitCommit = vcsRevision()
+	}
+	return info
+}
+
+// vcsRevision go build 记录的 commit，工作区有未提交的修改时后面加上 -dirty
+func vcsRevision() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision, modified string
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if len(revision) > 7 {
+		revision = revision[:7]
+	}
+	if revision != "" && modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// webhook 的 URL 是管理员填的，投递时服务端按它发请求，不加限制的话可以用来探测内网、
+// 读云厂商的元数据接口（169.254.169.254、100.100.100.200）拿到临时凭证。
+// 这里不用 httpclient 的公共客户端，单独一个：
+//
+//   - 在建立连接时检查解析出来的 IP，而不是只检查 URL，防止域名解析到内网地址（DNS rebinding）
+//   - 不跟随重定向，3xx 当作失败，防止对方用 302 跳到内网地址
+//   - 不走环境变量里的代理，否则检查的是代理的地址
+
+// errBlockedAddress 目标是内网、回环或者元数据地址
+var errBlockedAddress = errors.New("webhook: destination address is not allowed")
+
+// blockedPrefixes net.IP 的 IsPrivate、IsLoopback 等没有覆盖到的网段
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT，阿里云的元数据地址也在这里
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// allowPrivate 为 true 时不检查目标地址，本地开发调试用
+var allowPrivate bool
+
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   checkDial,
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: t,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkDial 在 connect 之前调用，address 是已经解析好的 ip:port
+func checkDial(network, address string, _ syscall.RawConn) error {
+	if allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if blocked(addr.Unmap()) {
+		return fmt.Errorf("%w: %s", errBlockedAddress, host)
+	}
+	return nil
+}
+
+func blocked(addr netip.Addr) bool {
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
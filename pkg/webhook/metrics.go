@@ -0,0 +1,24 @@
+package webhook
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	deliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "webhook",
+		Name:      "deliveries_total",
+		Help:      "webhook 投递次数，result: success/retry/dead",
+	}, []string{"result"})
+	duration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "app",
+		Subsystem: "webhook",
+		Name:      "delivery_duration_seconds",
+		Help:      "webhook 请求耗时",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+// Collectors webhook 指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{deliveries, duration}
+}
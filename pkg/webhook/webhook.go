@@ -0,0 +1,405 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_web_scaffolding/dao/mysql"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/models"
+	"go_web_scaffolding/pkg/cache"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/eventbus"
+	"go_web_scaffolding/pkg/retry"
+	"go_web_scaffolding/pkg/workerpool"
+	"go_web_scaffolding/settings"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// webhook：领域事件发生后 POST 给订阅了这个事件的外部 URL
+//
+// 发布事件时按订阅关系给每个 webhook 写一条 webhook_delivery，由后台 relay 投递，请求体：
+//
+//	{"id": 123, "event": "user.signup", "created_at": "2026-01-02T15:04:05+08:00", "data": {...}}
+//
+// 请求头带上 X-Webhook-Event、X-Webhook-Delivery（投递 ID，重试时不变，对方按它去重）、
+// X-Webhook-Timestamp（unix 秒）和 X-Webhook-Signature: sha256=<hex>，签名算法见 Sign，
+// 对方校验签名并拒绝时间戳太旧的请求，防止伪造和重放
+//
+// 目标地址不能是内网、回环和云厂商的元数据地址，也不跟随重定向，见 client.go
+//
+// 对方返回 2xx 算成功，其它状态码和网络错误按指数退避重试，次数用完标记为死信，
+// 在管理接口查看投递日志并重新投递。投递是"至少一次"，对方需要能处理重复的请求
+
+const (
+	cleanInterval = time.Hour
+	cleanBatch    = 1000
+	maxErrLen     = 512
+	maxBackoff    = time.Hour
+)
+
+// Header 投递请求头
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	enable      bool
+	interval    = time.Second
+	batch       = 50
+	timeout     = 10 * time.Second
+	maxAttempts = 8
+	backoff     = 10 * time.Second
+	retention   = 7 * 24 * time.Hour
+
+	client = newClient()
+
+	// wake 有新的投递时唤醒 relay，不用等下一个 tick
+	wake   = make(chan struct{}, 1)
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+)
+
+func init() {
+	dashboard.Register("webhook", func(ctx context.Context) interface{} {
+		status := map[string]interface{}{"enable": enable}
+		if !enable {
+			return status
+		}
+		if counts, err := mysql.CountWebhookDeliveries(ctx); err == nil {
+			status["pending_rows"] = counts[models.WebhookPending]
+			status["success_rows"] = counts[models.WebhookSuccess]
+			status["dead_rows"] = counts[models.WebhookDead]
+		}
+		return status
+	})
+}
+
+// Init 读取配置并订阅所有领域事件，没有开启时事件不会记录投递
+func Init(cfg *settings.WebhookConfig) (err error) {
+	if cfg == nil || !cfg.Enable {
+		return
+	}
+	enable = true
+	if cfg.Interval > 0 {
+		interval = time.Duration(cfg.Interval) * time.Millisecond
+	}
+	if cfg.Batch > 0 {
+		batch = cfg.Batch
+	}
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	if cfg.MaxAttempts > 0 {
+		maxAttempts = cfg.MaxAttempts
+	}
+	if cfg.Backoff > 0 {
+		backoff = time.Duration(cfg.Backoff) * time.Second
+	}
+	if cfg.Retention > 0 {
+		retention = time.Duration(cfg.Retention) * time.Hour
+	}
+	allowPrivate = cfg.AllowPrivate
+	// 同步执行：异步订阅者在协程池满了或者进程退出时会被丢掉，投递记录都没写就谈不上重试
+	// 订阅关系有缓存，每个订阅了该事件的 webhook 只多一条 INSERT
+	eventbus.SubscribeAll("webhook", record)
+	return
+}
+
+// Sign 计算签名：HMAC-SHA256(secret, timestamp + "." + body) 的十六进制
+// 对方用同样的算法计算后和 X-Webhook-Signature 里 sha256= 后面的部分做常量时间比较
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify 唤醒 relay 立即投递，比如管理接口重新投递之后
+func Notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// subscriptionsCacheKey 启用的 webhook 订阅关系的缓存，每个领域事件都要用，不能每次都查库
+const (
+	subscriptionsCacheKey = "webhook:subscriptions"
+	subscriptionsCacheTTL = time.Minute
+)
+
+// subscription 缓存里只放 ID 和订阅的事件，签名密钥不进 redis
+type subscription struct {
+	ID     int64  `json:"id"`
+	Events string `json:"events"`
+}
+
+func subscriptions(ctx context.Context) ([]*models.Webhook, error) {
+	list, err := cache.GetOrLoad(ctx, subscriptionsCacheKey, subscriptionsCacheTTL, func(ctx context.Context) ([]subscription, error) {
+		hooks, err := mysql.ListEnabledWebhooks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]subscription, 0, len(hooks))
+		for _, w := range hooks {
+			list = append(list, subscription{ID: w.ID, Events: w.Events})
+		}
+		return list, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	hooks := make([]*models.Webhook, 0, len(list))
+	for _, s := range list {
+		hooks = append(hooks, &models.Webhook{ID: s.ID, Events: s.Events, Enabled: true})
+	}
+	return hooks, nil
+}
+
+// Invalidate 创建、修改、删除 webhook 后调用，下一个事件重新从数据库加载订阅关系
+func Invalidate(ctx context.Context) error {
+	return cache.Delete(ctx, subscriptionsCacheKey)
+}
+
+// record 给订阅了事件的每个 webhook 写一条投递记录
+func record(ctx context.Context, e eventbus.Event) error {
+	// 业务数据已经写成功了，请求被取消也要把投递记录写完
+	ctx = context.WithoutCancel(ctx)
+	hooks, err := subscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	for _, w := range hooks {
+		if !w.Subscribed(e.EventName()) {
+			continue
+		}
+		if data == nil {
+			if data, err = json.Marshal(e); err != nil {
+				return err
+			}
+		}
+		d := &models.WebhookDelivery{
+			WebhookID: w.ID,
+			Event:     e.EventName(),
+			Payload:   string(data),
+			RequestID: ctxutil.RequestID(ctx),
+		}
+		if err = mysql.InsertWebhookDelivery(ctx, d); err != nil {
+			return err
+		}
+	}
+	if data != nil {
+		Notify()
+	}
+	return nil
+}
+
+// Start 启动 relay 协程，没有开启时什么都不做
+func Start() {
+	if !enable {
+		return
+	}
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		relay(ctx)
+	}()
+}
+
+// Stop 停止 relay 并等待当前批次投递完，没投递的记录留在表里，下次启动继续
+func Stop() {
+	if cancel == nil {
+		return
+	}
+	cancel()
+	wg.Wait()
+}
+
+func relay(ctx context.Context) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastClean := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+		// 一次取满 batch 说明还有积压，继续取不等下一个 tick
+		for ctx.Err() == nil {
+			n, err := dispatch(ctx)
+			if err != nil {
+				zap.L().Error("webhook relay failed", zap.Error(err))
+				break
+			}
+			if n < batch {
+				break
+			}
+		}
+		if time.Since(lastClean) >= cleanInterval {
+			lastClean = time.Now()
+			clean(ctx)
+		}
+	}
+}
+
+// dispatch 租下一批到期的投递，在 workerpool 里并发发送，等这一批都有结果再返回
+func dispatch(ctx context.Context) (n int, err error) {
+	// 租期覆盖请求超时加上排队的时间，租期内没有结果的（进程挂了）由其它实例重新投递
+	list, err := mysql.LeaseWebhookDeliveries(ctx, batch, 2*timeout+time.Minute)
+	if err != nil || len(list) == 0 {
+		return len(list), err
+	}
+	ids := make([]int64, 0, len(list))
+	for _, d := range list {
+		ids = append(ids, d.WebhookID)
+	}
+	hooks, err := mysql.GetWebhooksByIDs(ctx, ids)
+	if err != nil {
+		return
+	}
+	byID := make(map[int64]*models.Webhook, len(hooks))
+	for _, w := range hooks {
+		byID[w.ID] = w
+	}
+
+	var batchWg sync.WaitGroup
+	for _, d := range list {
+		w := byID[d.WebhookID]
+		batchWg.Add(1)
+		// workerpool 里任务的 ctx 不跟着 relay 的 ctx 取消，关机时把手上这批做完
+		err := workerpool.Get("webhook").Submit(ctx, func(ctx context.Context) error {
+			defer batchWg.Done()
+			if d.RequestID != "" {
+				ctx = ctxutil.WithRequestID(ctx, d.RequestID)
+			}
+			return finish(ctx, d, w)
+		})
+		if err != nil {
+			// 没提交上的等租期过了再投递
+			batchWg.Done()
+		}
+	}
+	batchWg.Wait()
+	return len(list), nil
+}
+
+// finish 投递一条并记录结果
+func finish(ctx context.Context, d *models.WebhookDelivery, w *models.Webhook) error {
+	var (
+		code  int
+		cause error
+	)
+	if w == nil || !w.Enabled {
+		// webhook 已经删除或停用，不再重试
+		cause = retry.Permanent(fmt.Errorf("webhook %d deleted or disabled", d.WebhookID))
+	} else {
+		start := time.Now()
+		code, cause = send(ctx, w, d)
+		duration.Observe(time.Since(start).Seconds())
+	}
+	if cause == nil {
+		deliveries.WithLabelValues("success").Inc()
+		return mysql.MarkWebhookDelivery(ctx, d.ID, models.WebhookSuccess, code, "", time.Now())
+	}
+
+	attempts := d.Attempts + 1
+	status := models.WebhookPending
+	delay := retry.Policy{Initial: backoff, Max: maxBackoff, Jitter: 0.2}.Backoff(attempts)
+	log := logger.Module(ctx, "webhook").With(
+		zap.Int64("id", d.ID),
+		zap.Int64("webhook_id", d.WebhookID),
+		zap.String("event", d.Event),
+		zap.Int("attempts", attempts),
+		zap.Int("code", code),
+		zap.Error(cause))
+	if attempts >= maxAttempts || retry.IsPermanent(cause) {
+		status = models.WebhookDead
+		deliveries.WithLabelValues("dead").Inc()
+		log.Error("webhook delivery failed, giving up")
+	} else {
+		deliveries.WithLabelValues("retry").Inc()
+		log.Warn("webhook delivery failed, retrying", zap.Duration("backoff", delay))
+	}
+	errMsg := cause.Error()
+	if len(errMsg) > maxErrLen {
+		errMsg = errMsg[:maxErrLen]
+	}
+	return mysql.MarkWebhookDelivery(ctx, d.ID, status, code, errMsg, time.Now().Add(delay))
+}
+
+// send 发送一次请求，返回对方的状态码，非 2xx 返回错误
+func send(ctx context.Context, w *models.Webhook, d *models.WebhookDelivery) (code int, err error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"id":         d.ID,
+		"event":      d.Event,
+		"created_at": d.CreateTime,
+		"data":       json.RawMessage(d.Payload),
+	})
+	if err != nil {
+		return 0, retry.Permanent(err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, retry.Permanent(err)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(w.Secret, ts, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, errBlockedAddress) {
+			return 0, retry.Permanent(err)
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// 响应体不记录：对方可能把内网页面、错误堆栈之类的东西返回回来，投递日志在管理后台能看到
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// clean 删除超过保留时间的已结束投递记录，分批删除直到删完
+func clean(ctx context.Context) {
+	before := time.Now().Add(-retention)
+	for ctx.Err() == nil {
+		n, err := mysql.DeleteWebhookDeliveries(ctx, before, cleanBatch)
+		if err != nil {
+			zap.L().Error("clean webhook deliveries failed", zap.Error(err))
+			return
+		}
+		if n < cleanBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package workerpool
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	running = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "app",
+		Subsystem: "worker_pool",
+		Name:      "in_flight",
+		Help:      "正在执行的任务数",
+	}, []string{"pool"})
+	tasks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "worker_pool",
+		Name:      "tasks_total",
+		Help:      "任务数，result: success/error/timeout/panic/rejected（池子满了或已关闭）",
+	}, []string{"pool", "result"})
+	duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "app",
+		Subsystem: "worker_pool",
+		Name:      "duration_seconds",
+		Help:      "任务执行耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"pool"})
+)
+
+// Collectors 协程池指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{running, tasks, duration}
+}
@@ -0,0 +1,242 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"go_web_scaffolding/logger"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/settings"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 进程内的后台任务统一交给协程池执行，不要直接 go func()：
+//
+//	err := workerpool.Get("idgen").TrySubmit(ctx, func(ctx context.Context) error {
+//		return preload(ctx)
+//	})
+//
+// 和直接开协程相比：
+//   - 并发有上限，突发流量下不会无限制地开协程把数据库连接池打满
+//   - 每个任务有超时，ctx 继承提交时 ctx 里的 request_id 等信息，但不会随请求结束而取消
+//   - panic 会被 recover 并记录日志，不会让整个进程退出
+//   - 关机时 Shutdown 等待执行中的任务完成，和 http server 共用同一个超时，超时后取消任务的 ctx
+//
+// 需要持久化、失败重试的任务用 jobs，这里的任务进程退出就没了
+
+var (
+	// ErrFull 池子满了，TrySubmit 不等待直接返回
+	ErrFull = errors.New("workerpool: pool is full")
+	// ErrClosed 已经开始关机，不再接受新任务
+	ErrClosed = errors.New("workerpool: pool is closed")
+)
+
+const (
+	defaultSize    = 16
+	defaultTimeout = 30 * time.Second
+)
+
+// Task 后台任务，返回的错误只记录日志
+type Task func(ctx context.Context) error
+
+// Pool 有并发上限的协程池
+type Pool struct {
+	name    string
+	sem     chan struct{}
+	timeout time.Duration
+
+	wg     sync.WaitGroup
+	closed atomic.Bool
+	// kill 关机等待超时后取消，执行中的任务的 ctx 跟着取消
+	kill   context.Context
+	cancel context.CancelFunc
+}
+
+var (
+	mu    sync.Mutex
+	cfg   *settings.WorkerPoolConfig
+	pools = make(map[string]*Pool)
+)
+
+func init() {
+	dashboard.Register("workerpool", func(ctx context.Context) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		res := make(map[string]interface{}, len(pools))
+		for name, p := range pools {
+			res[name] = map[string]interface{}{
+				"size":    cap(p.sem),
+				"running": len(p.sem),
+				"timeout": p.timeout.String(),
+				"closed":  p.closed.Load(),
+			}
+		}
+		return res
+	})
+}
+
+// Init 读取配置，需要在第一次 Get 之前调用
+func Init(c *settings.WorkerPoolConfig) (err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+	return
+}
+
+// Get 按名字取协程池，第一次调用时按配置创建，没有单独配置的使用默认的大小和超时
+func Get(name string) *Pool {
+	mu.Lock()
+	defer mu.Unlock()
+	if p, ok := pools[name]; ok {
+		return p
+	}
+	size, timeout := defaultSize, defaultTimeout
+	if cfg != nil {
+		size, timeout = merge(size, timeout, &cfg.PoolConfig)
+		if pc := cfg.Pools[name]; pc != nil {
+			size, timeout = merge(size, timeout, pc)
+		}
+	}
+	p := &Pool{
+		name:    name,
+		sem:     make(chan struct{}, size),
+		timeout: timeout,
+	}
+	p.kill, p.cancel = context.WithCancel(context.Background())
+	pools[name] = p
+	return p
+}
+
+func merge(size int, timeout time.Duration, pc *settings.PoolConfig) (int, time.Duration) {
+	if pc.Size > 0 {
+		size = pc.Size
+	}
+	if pc.Timeout > 0 {
+		timeout = time.Duration(pc.Timeout) * time.Second
+	}
+	return size, timeout
+}
+
+// Submit 提交任务，池子满了时等待空位，直到 ctx 结束
+func (p *Pool) Submit(ctx context.Context, task Task) error {
+	if p.closed.Load() {
+		tasks.WithLabelValues(p.name, "rejected").Inc()
+		return ErrClosed
+	}
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		tasks.WithLabelValues(p.name, "rejected").Inc()
+		return ctx.Err()
+	}
+	return p.start(ctx, task)
+}
+
+// TrySubmit 提交任务，池子满了时直接返回 ErrFull，适合请求里顺手触发、做不做都行的任务
+func (p *Pool) TrySubmit(ctx context.Context, task Task) error {
+	if p.closed.Load() {
+		tasks.WithLabelValues(p.name, "rejected").Inc()
+		return ErrClosed
+	}
+	select {
+	case p.sem <- struct{}{}:
+	default:
+		tasks.WithLabelValues(p.name, "rejected").Inc()
+		return ErrFull
+	}
+	return p.start(ctx, task)
+}
+
+// start 已经占到了位置，调用方持有 sem
+func (p *Pool) start(ctx context.Context, task Task) error {
+	// 拿到位置和 Shutdown 之间有竞争，wg.Add 之后再检查一次，保证 Shutdown 能等到这个任务
+	p.wg.Add(1)
+	if p.closed.Load() {
+		p.wg.Done()
+		<-p.sem
+		tasks.WithLabelValues(p.name, "rejected").Inc()
+		return ErrClosed
+	}
+	running.WithLabelValues(p.name).Inc()
+	go p.run(context.WithoutCancel(ctx), task)
+	return nil
+}
+
+func (p *Pool) run(ctx context.Context, task Task) {
+	start := time.Now()
+	result := "success"
+	defer func() {
+		if r := recover(); r != nil {
+			result = "panic"
+			logger.Module(ctx, "workerpool").Error("task panic",
+				zap.String("pool", p.name),
+				zap.Any("error", r),
+				zap.String("stack", string(debug.Stack())))
+		}
+		tasks.WithLabelValues(p.name, result).Inc()
+		duration.WithLabelValues(p.name).Observe(time.Since(start).Seconds())
+		running.WithLabelValues(p.name).Dec()
+		<-p.sem
+		p.wg.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	stop := context.AfterFunc(p.kill, cancel)
+	defer stop()
+
+	if err := task(ctx); err != nil {
+		result = "error"
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+			result = "timeout"
+		}
+		logger.Module(ctx, "workerpool").Warn("task failed", zap.String("pool", p.name), zap.String("result", result), zap.Error(err))
+	}
+}
+
+// shutdown 不再接受新任务并等待执行中的任务，ctx 到期后取消它们的 ctx
+func (p *Pool) shutdown(ctx context.Context) error {
+	p.closed.Store(true)
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		zap.L().Warn("workerpool shutdown timeout, tasks cancelled", zap.String("pool", p.name), zap.Int("running", len(p.sem)))
+		return ctx.Err()
+	}
+}
+
+// Shutdown 关闭所有协程池，在 http server 关闭之后调用，共用同一个超时的 ctx
+func Shutdown(ctx context.Context) (err error) {
+	mu.Lock()
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	mu.Unlock()
+	sort.Strings(names)
+
+	// 各个池子同时等待，总时间不超过 ctx
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, p *Pool) {
+			defer wg.Done()
+			errs[i] = p.shutdown(ctx)
+		}(i, Get(name))
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,190 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"go_web_scaffolding/logger"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// Conn 一个 WebSocket 连接
+// 读写各一个协程：读协程就是 Serve 所在的请求协程，处理上行消息；写协程负责推送和 ping
+type Conn struct {
+	UserID int64
+
+	ctx context.Context
+	// check 定时重新校验登录状态，返回错误时断开
+	check func(ctx context.Context) error
+
+	ws   *websocket.Conn
+	send chan []byte
+	// rooms 加入的房间，由 hub.mu 保护
+	rooms map[string]struct{}
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// CloseSessionExpired 登录状态失效时关闭连接的 close code，客户端收到后重新登录再连
+const CloseSessionExpired = 4001
+
+// Serve 把请求升级成 WebSocket 并一直处理到连接断开，调用方需要先完成登录校验
+// check 在连接存续期间定时调用，返回错误时用 4001 关闭连接，为 nil 时不检查
+// 升级失败时 gorilla 已经写好了 HTTP 错误响应，调用方不需要再写
+func Serve(w http.ResponseWriter, r *http.Request, userID int64, check func(ctx context.Context) error) error {
+	if h.isClosing() {
+		return ErrShuttingDown
+	}
+	wsConn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+	c := &Conn{
+		UserID: userID,
+		ctx:    r.Context(),
+		check:  check,
+		ws:     wsConn,
+		send:   make(chan []byte, opts.sendBuffer),
+		rooms:  make(map[string]struct{}),
+		done:   make(chan struct{}),
+	}
+	if err = h.add(c); err != nil {
+		c.goingAway()
+		c.close()
+		return nil
+	}
+	defer func() {
+		h.remove(c)
+		c.close()
+	}()
+	log := logger.Module(c.ctx, "ws")
+	log.Debug("connected")
+	go c.writeLoop()
+	c.readLoop()
+	log.Debug("disconnected")
+	return nil
+}
+
+// Send 推送给这一个连接，通常用于回复上行消息
+func (c *Conn) Send(msg *Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		logger.Module(c.ctx, "ws").Error("marshal message failed", zap.Error(err))
+		return
+	}
+	c.sendRaw(data)
+}
+
+// sendRaw 放进发送队列，不阻塞；队列满了说明客户端收得太慢，断开它，客户端重连后重新拉取数据
+func (c *Conn) sendRaw(data []byte) {
+	select {
+	case c.send <- data:
+	case <-c.done:
+	default:
+		dropped.Inc()
+		logger.Module(c.ctx, "ws").Warn("send buffer full, closing slow connection")
+		c.close()
+	}
+}
+
+func (c *Conn) readLoop() {
+	c.ws.SetReadLimit(opts.maxMessageSize)
+	_ = c.ws.SetReadDeadline(time.Now().Add(opts.pongTimeout))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(opts.pongTimeout))
+	})
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+				logger.Module(c.ctx, "ws").Debug("read failed", zap.Error(err))
+			}
+			return
+		}
+		_ = c.ws.SetReadDeadline(time.Now().Add(opts.pongTimeout))
+		received.Inc()
+
+		var msg Message
+		if err = json.Unmarshal(data, &msg); err != nil {
+			c.Send(errorMessage("", "invalid message"))
+			continue
+		}
+		fn, ok := handlers[msg.Type]
+		if !ok {
+			c.Send(errorMessage(msg.Room, "unknown message type: "+msg.Type))
+			continue
+		}
+		c.handle(fn, &msg)
+	}
+}
+
+// handle 处理函数 panic 时只记录日志，不断开连接
+func (c *Conn) handle(fn Handler, msg *Message) {
+	defer func() {
+		if p := recover(); p != nil {
+			logger.Module(c.ctx, "ws").Error("handler panic", zap.String("type", msg.Type), zap.Any("error", p), zap.Stack("stack"))
+		}
+	}()
+	fn(c.ctx, c, msg)
+}
+
+func (c *Conn) writeLoop() {
+	ticker := time.NewTicker(opts.pingInterval)
+	defer ticker.Stop()
+	var checkC <-chan time.Time
+	if c.check != nil {
+		checkTicker := time.NewTicker(opts.sessionCheck)
+		defer checkTicker.Stop()
+		checkC = checkTicker.C
+	}
+	for {
+		select {
+		case data := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(opts.writeTimeout))
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close()
+				return
+			}
+			sent.Inc()
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(opts.writeTimeout)); err != nil {
+				c.close()
+				return
+			}
+		case <-checkC:
+			if err := c.check(c.ctx); err != nil {
+				logger.Module(c.ctx, "ws").Info("session expired, closing", zap.Error(err))
+				msg := websocket.FormatCloseMessage(CloseSessionExpired, "session expired")
+				_ = c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(opts.writeTimeout))
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// goingAway 通知客户端服务端要关闭了，客户端回 close 帧后读协程退出
+func (c *Conn) goingAway() {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	_ = c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(opts.writeTimeout))
+}
+
+// close 关闭底层连接，阻塞在读上的读协程会立即返回
+func (c *Conn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		_ = c.ws.Close()
+	})
+}
+
+func errorMessage(room, text string) *Message {
+	data, _ := json.Marshal(text)
+	return &Message{Type: "error", Room: room, Data: data}
+}
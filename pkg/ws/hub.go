@@ -0,0 +1,177 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// hub 本实例上的所有连接，按用户和房间建索引
+type hub struct {
+	mu      sync.RWMutex
+	conns   map[*Conn]struct{}
+	users   map[int64]map[*Conn]struct{}
+	rooms   map[string]map[*Conn]struct{}
+	closing bool
+	// empty 关机时所有连接都断开后关闭
+	empty chan struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		conns: make(map[*Conn]struct{}),
+		users: make(map[int64]map[*Conn]struct{}),
+		rooms: make(map[string]map[*Conn]struct{}),
+	}
+}
+
+func (h *hub) add(c *Conn) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return ErrShuttingDown
+	}
+	h.conns[c] = struct{}{}
+	addTo(h.users, c.UserID, c)
+	connections.Inc()
+	return nil
+}
+
+func (h *hub) remove(c *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[c]; !ok {
+		return
+	}
+	delete(h.conns, c)
+	removeFrom(h.users, c.UserID, c)
+	for room := range c.rooms {
+		removeFrom(h.rooms, room, c)
+	}
+	connections.Dec()
+	if h.closing && len(h.conns) == 0 {
+		close(h.empty)
+	}
+}
+
+func (h *hub) isClosing() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.closing
+}
+
+func (h *hub) join(c *Conn, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[c]; !ok {
+		return
+	}
+	c.rooms[room] = struct{}{}
+	addTo(h.rooms, room, c)
+}
+
+func (h *hub) leave(c *Conn, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(c.rooms, room)
+	removeFrom(h.rooms, room, c)
+}
+
+func addTo[K comparable](m map[K]map[*Conn]struct{}, k K, c *Conn) {
+	set := m[k]
+	if set == nil {
+		set = make(map[*Conn]struct{})
+		m[k] = set
+	}
+	set[c] = struct{}{}
+}
+
+func removeFrom[K comparable](m map[K]map[*Conn]struct{}, k K, c *Conn) {
+	if set := m[k]; set != nil {
+		delete(set, c)
+		if len(set) == 0 {
+			delete(m, k)
+		}
+	}
+}
+
+// deliver 把 pubsub 收到的消息投递给本实例上的目标连接
+// 消息只序列化一次，各连接共用同一份数据
+func (h *hub) deliver(env *envelope) {
+	data, err := json.Marshal(env.Msg)
+	if err != nil {
+		zap.L().Error("ws marshal message failed", zap.Error(err))
+		return
+	}
+	h.mu.RLock()
+	var targets []*Conn
+	switch {
+	case env.UserID != 0:
+		targets = keys(h.users[env.UserID])
+	case env.Room != "":
+		targets = keys(h.rooms[env.Room])
+	default:
+		targets = keys(h.conns)
+	}
+	h.mu.RUnlock()
+	for _, c := range targets {
+		c.sendRaw(data)
+	}
+}
+
+func keys(set map[*Conn]struct{}) []*Conn {
+	list := make([]*Conn, 0, len(set))
+	for c := range set {
+		list = append(list, c)
+	}
+	return list
+}
+
+func (h *hub) shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	h.empty = make(chan struct{})
+	if len(h.conns) == 0 {
+		close(h.empty)
+	}
+	all := keys(h.conns)
+	h.mu.Unlock()
+
+	zap.L().Info("ws draining connections", zap.Int("connections", len(all)))
+	for _, c := range all {
+		c.goingAway()
+	}
+	select {
+	case <-h.empty:
+		return nil
+	case <-ctx.Done():
+		// 客户端没有回 close 帧的连接直接断开
+		h.mu.RLock()
+		left := keys(h.conns)
+		h.mu.RUnlock()
+		zap.L().Warn("ws drain timeout, closing connections", zap.Int("connections", len(left)))
+		for _, c := range left {
+			c.close()
+		}
+		// 等读协程退出把连接移除，这里不会再阻塞多久
+		select {
+		case <-h.empty:
+		case <-time.After(time.Second):
+		}
+		return ctx.Err()
+	}
+}
+
+func (h *hub) stats() interface{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return map[string]interface{}{
+		"connections": len(h.conns),
+		"users":       len(h.users),
+		"rooms":       len(h.rooms),
+		"closing":     h.closing,
+	}
+}
@@ -0,0 +1,35 @@
+package ws
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	connections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "app",
+		Subsystem: "ws",
+		Name:      "connections",
+		Help:      "本实例当前的 WebSocket 连接数",
+	})
+	sent = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "ws",
+		Name:      "messages_sent_total",
+		Help:      "推送给客户端的消息数",
+	})
+	received = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "ws",
+		Name:      "messages_received_total",
+		Help:      "客户端发来的消息数",
+	})
+	dropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "ws",
+		Name:      "slow_consumers_total",
+		Help:      "发送队列满了被断开的连接数",
+	})
+)
+
+// Collectors WebSocket 指标，由 pkg/metrics 注册
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{connections, sent, received, dropped}
+}
@@ -0,0 +1,212 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go_web_scaffolding/pkg/dashboard"
+	"go_web_scaffolding/pkg/pubsub"
+	"go_web_scaffolding/settings"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// WebSocket 推送：
+//
+//	连接  GET /api/v1/ws，登录后升级成 WebSocket，一个用户可以同时有多个连接（多个标签页、多台设备）
+//	房间  客户端发 {"type": "join", "room": "..."} / {"type": "leave", ...} 加入、退出房间，
+//	      默认不允许加入任何房间，业务上用到房间时通过 SetJoinGuard 按房间做权限检查
+//	推送  业务代码调用 SendToUser / SendToRoom / Broadcast，消息通过 redis pubsub 广播到所有实例，
+//	      每个实例只投递给连在自己身上的连接，所以不用关心用户连在哪个实例上
+//	上行  客户端发来的其它类型的消息交给 Handle 注册的处理函数
+//
+// 服务端定时发 ping，客户端（浏览器自动）回 pong，超时没收到就断开
+// 连接建立后按 session_check_interval 定时重新校验登录状态，token 过期、注销或者会话被吊销后断开（4001）
+// 关机时先停止接受新连接，再给所有连接发 1001 Going Away，客户端收到后自行重连到其它实例
+
+// Message 服务端推送和客户端上行的消息格式
+type Message struct {
+	Type string          `json:"type"`
+	Room string          `json:"room,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// NewMessage 创建消息，data 序列化为 JSON
+func NewMessage(typ string, data interface{}) (*Message, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Type: typ, Data: b}, nil
+}
+
+// Handler 处理客户端发来的一种类型的消息，同一个连接的消息按顺序串行处理
+type Handler func(ctx context.Context, c *Conn, msg *Message)
+
+// deliverChannel 投递消息的 pubsub channel
+const deliverChannel = "ws:deliver"
+
+// envelope 广播给所有实例的投递请求，UserID 和 Room 都为空时发给所有连接
+type envelope struct {
+	UserID int64    `json:"user_id,omitempty"`
+	Room   string   `json:"room,omitempty"`
+	Msg    *Message `json:"msg"`
+}
+
+var (
+	// ErrShuttingDown 正在关机，不再接受新连接
+	ErrShuttingDown = errors.New("ws: shutting down")
+
+	enabled  bool
+	opts     options
+	upgrader websocket.Upgrader
+
+	handlers = make(map[string]Handler)
+	h        = newHub()
+
+	// joinGuard 判断用户能不能加入房间，默认都不可以，房间名是客户端随便填的，
+	// 放开之后任何登录用户都能收到任意房间的消息；业务上用到房间时通过 SetJoinGuard 替换
+	joinGuard = func(ctx context.Context, userID int64, room string) bool { return false }
+)
+
+type options struct {
+	pingInterval   time.Duration
+	pongTimeout    time.Duration
+	writeTimeout   time.Duration
+	maxMessageSize int64
+	sendBuffer     int
+	sessionCheck   time.Duration
+}
+
+// Init 读取配置并订阅投递 channel，需要在 pubsub.Start 之前调用，未开启时不做任何事
+func Init(cfg *settings.WSConfig) (err error) {
+	if cfg == nil || !cfg.Enable {
+		return
+	}
+	opts = options{
+		pingInterval:   seconds(cfg.PingInterval, 25),
+		pongTimeout:    seconds(cfg.PongTimeout, 60),
+		writeTimeout:   seconds(cfg.WriteTimeout, 10),
+		maxMessageSize: int64(cfg.MaxMessageSize) << 10,
+		sendBuffer:     cfg.SendBuffer,
+		sessionCheck:   seconds(cfg.SessionCheckInterval, 60),
+	}
+	if opts.maxMessageSize <= 0 {
+		opts.maxMessageSize = 64 << 10
+	}
+	if opts.sendBuffer <= 0 {
+		opts.sendBuffer = 256
+	}
+	if opts.pongTimeout <= opts.pingInterval {
+		return errors.New("ws: pong_timeout must be greater than ping_interval")
+	}
+	upgrader = websocket.Upgrader{
+		HandshakeTimeout: 10 * time.Second,
+		// 浏览器不能自定义请求头，token 放在子协议里传过来，这里要原样回一个 bearer
+		Subprotocols: []string{"bearer"},
+		CheckOrigin:  checkOrigin(cfg.AllowedOrigins),
+	}
+
+	Handle("join", func(ctx context.Context, c *Conn, msg *Message) {
+		if msg.Room == "" || !joinGuard(ctx, c.UserID, msg.Room) {
+			c.Send(errorMessage(msg.Room, "join denied"))
+			return
+		}
+		h.join(c, msg.Room)
+	})
+	Handle("leave", func(ctx context.Context, c *Conn, msg *Message) {
+		h.leave(c, msg.Room)
+	})
+	pubsub.Handle(deliverChannel, func(ctx context.Context, msg *pubsub.Message) {
+		var env envelope
+		if err := msg.Bind(&env); err != nil || env.Msg == nil {
+			zap.L().Warn("ws invalid envelope", zap.String("payload", msg.Payload), zap.Error(err))
+			return
+		}
+		h.deliver(&env)
+	})
+	dashboard.Register("ws", func(ctx context.Context) interface{} {
+		return h.stats()
+	})
+	enabled = true
+	return
+}
+
+func seconds(n, def int) time.Duration {
+	if n <= 0 {
+		n = def
+	}
+	return time.Duration(n) * time.Second
+}
+
+// checkOrigin 没有配置时用 gorilla 默认的同源检查
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	if len(allowed) == 0 {
+		return nil
+	}
+	if slices.Contains(allowed, "*") {
+		return func(r *http.Request) bool { return true }
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		return slices.Contains(allowed, u.Scheme+"://"+u.Host)
+	}
+}
+
+// Enabled 是否开启了 WebSocket
+func Enabled() bool {
+	return enabled
+}
+
+// Handle 注册客户端上行消息的处理函数，需要在 Init 之后、开始接受连接之前调用
+func Handle(typ string, fn Handler) {
+	handlers[typ] = fn
+}
+
+// SetJoinGuard 替换加入房间的权限检查，比如只允许加入自己所在群组的房间
+func SetJoinGuard(fn func(ctx context.Context, userID int64, room string) bool) {
+	joinGuard = fn
+}
+
+// SendToUser 推送给用户的所有连接，用户不在线时消息直接丢弃
+func SendToUser(ctx context.Context, userID int64, msg *Message) error {
+	return publish(ctx, &envelope{UserID: userID, Msg: msg})
+}
+
+// SendToRoom 推送给房间里的所有连接
+func SendToRoom(ctx context.Context, room string, msg *Message) error {
+	return publish(ctx, &envelope{Room: room, Msg: msg})
+}
+
+// Broadcast 推送给所有连接
+func Broadcast(ctx context.Context, msg *Message) error {
+	return publish(ctx, &envelope{Msg: msg})
+}
+
+func publish(ctx context.Context, env *envelope) error {
+	if !enabled {
+		return nil
+	}
+	return pubsub.Publish(ctx, deliverChannel, env)
+}
+
+// Shutdown 停止接受新连接，通知所有连接关闭并等待它们断开，ctx 超时后强制关闭剩下的连接
+// http.Server.Shutdown 不管已经升级的连接，所以要在它之后单独调用
+func Shutdown(ctx context.Context) error {
+	if !enabled {
+		return nil
+	}
+	return h.shutdown(ctx)
+}
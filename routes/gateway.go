@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	userv1 "go_web_scaffolding/api/user/v1"
+	"go_web_scaffolding/controller/response"
+	"go_web_scaffolding/middlewares"
+	"go_web_scaffolding/pkg/ctxutil"
+	"go_web_scaffolding/settings"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// grpc-gateway：按 proto 里的 google.api.http 注解把 REST 请求转成对本机 gRPC 端口的调用，
+// 内部服务直接调 gRPC，浏览器和 App 继续用 JSON，两边是同一套实现、同一套拦截器（登录、日志、指标）
+//
+//	成功  直接返回 proto 消息的 JSON，字段名和 proto 一致（snake_case），零值字段也会输出
+//	失败  和其它 REST 接口一样返回 {"code": 1006, "msg": "需要登录"}，HTTP 状态码和 REST 接口的同一个业务状态码一致
+//
+// 请求 ID、客户端 IP、租户和语言通过 metadata 传给 gRPC 服务，Authorization 头由 gateway 自己转发
+// 路由上和 /api/v1 一样先过 JWTAuthMiddleware 和 Authorize，gRPC 服务的拦截器会再校验一次 token
+
+// registerGateway 把 proto 里定义的 REST 接口挂到 gin 上，gRPC 服务没有开启时不挂载
+func registerGateway(r *gin.Engine) {
+	cfg := settings.Conf.GRPCConfig
+	if cfg == nil || !cfg.Enable || !cfg.Gateway {
+		return
+	}
+	// 连接是惰性建立的，这里 gRPC 服务还没有开始监听也没关系
+	conn, err := grpc.NewClient(
+		fmt.Sprintf("127.0.0.1:%d", cfg.Port),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		zap.L().Error("grpc gateway dial failed", zap.Error(err))
+		return
+	}
+
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithMetadata(gatewayMetadata),
+		runtime.WithErrorHandler(gatewayError),
+	)
+	ctx := context.Background()
+	if err = userv1.RegisterUserServiceHandler(ctx, mux, conn); err != nil {
+		zap.L().Error("register gateway handler failed", zap.Error(err))
+		return
+	}
+	r.Any("/v1/*path", middlewares.JWTAuthMiddleware(), middlewares.Authorize(), gin.WrapH(mux))
+}
+
+// gatewayMetadata 把 HTTP 中间件已经解析好的请求信息传给 gRPC 服务，
+// 这样两边日志里的 request_id 是同一个，按 IP 的限流和登录保护拿到的也是真实客户端 IP 而不是 127.0.0.1
+func gatewayMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	rc := r.Context()
+	md := metadata.Pairs(strings.ToLower(middlewares.RequestIDHeader), ctxutil.RequestID(rc))
+	if ip := ctxutil.ClientIP(rc); ip != "" {
+		md.Set(middlewares.GatewayClientIPKey, ip)
+	}
+	if tenant := ctxutil.Tenant(rc); tenant != "" {
+		md.Set("x-tenant-id", tenant)
+	}
+	if lang := r.Header.Get("Accept-Language"); lang != "" {
+		md.Set("accept-language", lang)
+	}
+	return md
+}
+
+// gatewayError gRPC 错误转成统一的响应格式，业务状态码从错误详情里取，取不到时按 gRPC 状态码对应
+func gatewayError(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	httpStatus := runtime.HTTPStatusFromCode(st.Code())
+	code, ok := response.CodeFromGRPC(st)
+	msg := st.Message()
+	if ok {
+		httpStatus = code.HTTPStatus()
+	} else {
+		code = grpcResCodes[st.Code()]
+		if code == 0 {
+			code = response.CodeServerBusy
+		}
+		// 不是业务错误时不把底层的错误信息返回给客户端，参数解析错误除外
+		if st.Code() != codes.InvalidArgument {
+			msg = code.Localize(r.Context())
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(httpStatus)
+	_ = json.NewEncoder(w).Encode(&response.ResponseData{Code: code, Msg: msg})
+}
+
+// grpcResCodes 不带业务状态码的 gRPC 错误（gateway 自己的错误、未实现的方法等）对应的业务状态码
+var grpcResCodes = map[codes.Code]response.ResCode{
+	codes.InvalidArgument:   response.CodeInvalidParam,
+	codes.NotFound:          response.CodeNotFound,
+	codes.Unimplemented:     response.CodeNotFound,
+	codes.Unauthenticated:   response.CodeNeedLogin,
+	codes.PermissionDenied:  response.CodeForbidden,
+	codes.ResourceExhausted: response.CodeTooManyRequests,
+	codes.Unavailable:       response.CodeServiceUnavailable,
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"go_web_scaffolding/graph"
+	"go_web_scaffolding/middlewares"
+	"go_web_scaffolding/settings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// registerGraphQL 挂载 POST /graphql，没有开启时不挂载
+// 和 REST 接口一样先过 JWT 再过 casbin，开启 RBAC 时需要给角色加 POST /graphql 的策略
+func registerGraphQL(r *gin.Engine) {
+	cfg := settings.Conf.GraphQLConfig
+	if cfg == nil || !cfg.Enable {
+		return
+	}
+	h, err := graph.Handler(cfg)
+	if err != nil {
+		zap.L().Error("init graphql failed", zap.Error(err))
+		return
+	}
+	r.POST("/graphql", middlewares.JWTAuthMiddleware(), middlewares.Authorize(), middlewares.Locale(), h)
+}
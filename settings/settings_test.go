@@ -0,0 +1,46 @@
+package settings
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	in := map[string]interface{}{
+		"name": "web_app",
+		"mysql": map[string]interface{}{
+			"user":     "root",
+			"password": "root1234",
+			"databases": []interface{}{
+				map[string]interface{}{"name": "order", "host": "10.0.0.2", "password": "order1234"},
+			},
+		},
+		"log": map[string]interface{}{
+			"sinks": []interface{}{
+				map[string]interface{}{"type": "loki", "url": "http://loki:3100", "basic_auth": map[string]interface{}{"password": "loki1234"}},
+			},
+		},
+		"admin": map[string]interface{}{"token": "", "tokens": []interface{}{"t1", "t2"}},
+		"ports": []interface{}{8081, 8082},
+	}
+	want := map[string]interface{}{
+		"name": "web_app",
+		"mysql": map[string]interface{}{
+			"user":     "root",
+			"password": "******",
+			"databases": []interface{}{
+				map[string]interface{}{"name": "order", "host": "10.0.0.2", "password": "******"},
+			},
+		},
+		"log": map[string]interface{}{
+			"sinks": []interface{}{
+				map[string]interface{}{"type": "loki", "url": "http://loki:3100", "basic_auth": map[string]interface{}{"password": "******"}},
+			},
+		},
+		"admin": map[string]interface{}{"token": "", "tokens": []interface{}{"******", "******"}},
+		"ports": []interface{}{8081, 8082},
+	}
+	if got := redact(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("redact() = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// TemplateDir 模板在源码中的目录（相对项目根目录），dev 模式下直接从这里读取并监听变化
+const TemplateDir = "web/templates"
+
+//go:embed templates
+var templateFS embed.FS
+
+//go:embed admin/index.html
+var adminUI []byte
+
+// DistDir 前端构建产物的目录，构建时把 dist 拷贝到这里再编译
+const DistDir = "web/dist"
+
+//go:embed all:dist
+var distFS embed.FS
+
+// Templates 编译进二进制的模板，生产环境使用，部署时不需要额外拷贝模板文件
+func Templates() fs.FS {
+	sub, _ := fs.Sub(templateFS, "templates")
+	return sub
+}
+
+// AdminUI 管理后台的单页面，数据通过 /admin/dashboard 接口获取
+func AdminUI() []byte {
+	return adminUI
+}
+
+// Dist 编译进二进制的前端页面，由 static 配置决定是否提供访问
+func Dist() fs.FS {
+	sub, _ := fs.Sub(distFS, "dist")
+	return sub
+}